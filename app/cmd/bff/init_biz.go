@@ -23,8 +23,11 @@ func initBiz(ac component.ApplicationContext, r *gin.Engine) {
 	// リポジトリの作成
 	userRepository := repository.NewUserRepositoryForRestAPI(ac.GetHTTPClient(), ac.GetLogger(), ac.GetConfig())
 	todoRepository := repository.NewTodoRepositoryForRestAPI(ac.GetHTTPClient(), ac.GetLogger(), ac.GetConfig())
-	tempRepository := repository.NewTempRepository(ac.GetDynamoDBTemplate(), ac.GetDynamoDBAccessor(),
+	tempRepository, err := repository.NewTempRepository(ac.GetDynamoDBTemplate(), ac.GetDynamoDBAccessor(),
 		ac.GetLogger(), ac.GetConfig(), ac.GetIDGenerator())
+	if err != nil {
+		panic(err)
+	}
 	bookRepository := repository.NewBookRepositoryForRestAPI(ac.GetHTTPClient(), ac.GetLogger(), ac.GetConfig())
 	// Configからキュー名を取得する
 	sampleQueueName := ac.GetConfig().Get("SampleQueueName", "SampleQueue")
@@ -63,10 +63,14 @@ func (r *commonErrorResponse) BusinessErrorResponse(businessErrors *errors.Busin
 
 // WarnErrorResponse implements api.ErrorResponse.
 func (r *commonErrorResponse) WarnErrorResponse(err error) (int, any) {
+	var optimisticLockError errors.OptimisticLockError
 	if errors.Is(err, api.NoRouteError) {
 		return http.StatusNotFound, r.errorResponseBody(err.Error(), "")
 	} else if errors.Is(err, api.NoMethodError) {
 		return http.StatusMethodNotAllowed, r.errorResponseBody(err.Error(), "")
+	} else if errors.As(err, &optimisticLockError) {
+		return http.StatusConflict, r.errorResponseBody(mymessage.W_EX_8010,
+			r.messageSource.GetMessage(mymessage.W_EX_8010))
 	} else {
 		return http.StatusBadRequest, r.errorResponseBody(err.Error(), "")
 	}
@@ -18,10 +18,16 @@ func initBiz(ac component.ApplicationContext) handler.AsyncControllerFunc {
 		panic(err)
 	}
 	// リポジトリの作成
-	tempRepository := repository.NewTempRepository(ac.GetDynamoDBTemplate(), ac.GetDynamoDBAccessor(),
+	tempRepository, err := repository.NewTempRepository(ac.GetDynamoDBTemplate(), ac.GetDynamoDBAccessor(),
 		ac.GetLogger(), ac.GetConfig(), ac.GetIDGenerator())
-	todoRepository := repository.NewTodoRepositoryForDynamoDB(ac.GetDynamoDBTemplate(), ac.GetDynamoDBAccessor(),
+	if err != nil {
+		panic(err)
+	}
+	todoRepository, err := repository.NewTodoRepositoryForDynamoDB(ac.GetDynamoDBTemplate(), ac.GetDynamoDBAccessor(),
 		ac.GetLogger(), ac.GetConfig(), ac.GetIDGenerator())
+	if err != nil {
+		panic(err)
+	}
 	// サービスの作成
 	todoAsyncService := service.New(ac.GetLogger(), ac.GetConfig(), ac.GetObjectStorageAccessor(), tempRepository, todoRepository)
 	// コントローラの作成
@@ -32,7 +32,10 @@ func TestRegisterAllAsync(t *testing.T) {
 		// TODO: テーブル作成
 		// TODO: tempテーブルのテストデータ登録（仮置きのコード）
 		value := "todoFiles/cd0cab72-4788-11f1-861b-62c1af981055.json"
-		tempRepository := repository.NewTempRepository(ac.GetDynamoDBTemplate(), ac.GetDynamoDBAccessor(), ac.GetLogger(), ac.GetConfig(), ac.GetIDGenerator())
+		tempRepository, err := repository.NewTempRepository(ac.GetDynamoDBTemplate(), ac.GetDynamoDBAccessor(), ac.GetLogger(), ac.GetConfig(), ac.GetIDGenerator())
+		if err != nil {
+			t.Fatalf("Repository作成エラー: %v", err)
+		}
 		testData, err := tempRepository.CreateOne(&model.Temp{Value: value})
 		tempId := testData.ID
 		if err != nil {
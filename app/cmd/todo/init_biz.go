@@ -19,8 +19,11 @@ func initBiz(ac component.ApplicationContext, r *gin.Engine) {
 		panic(err)
 	}
 	// リポジトリの作成
-	todoRepository := repository.NewTodoRepositoryForDynamoDB(ac.GetDynamoDBTemplate(),
+	todoRepository, err := repository.NewTodoRepositoryForDynamoDB(ac.GetDynamoDBTemplate(),
 		ac.GetDynamoDBAccessor(), ac.GetLogger(), ac.GetConfig(), ac.GetIDGenerator())
+	if err != nil {
+		panic(err)
+	}
 	// サービスの作成
 	todoService := service.New(ac.GetLogger(), ac.GetConfig(), todoRepository)
 	// コントローラの作成
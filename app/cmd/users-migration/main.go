@@ -0,0 +1,60 @@
+package main
+
+import (
+	"app/internal/pkg/schema"
+	"context"
+	"flag"
+	"os"
+	"testing"
+
+	"example.com/appbase/pkg/apcontext"
+	"example.com/appbase/pkg/component"
+	"example.com/appbase/pkg/handler"
+	"example.com/appbase/pkg/otel"
+	"example.com/appbase/pkg/rdb/migration"
+)
+
+var (
+	ac            component.ApplicationContext
+	migrator      migration.Migrator
+	lambdaHandler handler.SimpleLambdaHandlerFunc
+)
+
+// コードルドスタート時の初期化処理
+func init() {
+	// 処理テストコード実行に、init関数が動作してしまうのを回避
+	if testing.Testing() {
+		return
+	}
+	// ApplicationContextの作成
+	ac = component.NewApplicationContext()
+	// 埋め込んだマイグレーションファイルを適用するMigratorの作成
+	migrator = migration.NewMigrator(ac.GetLogger(), ac.GetConfig(), ac.GetRDBConnectionPool(), schema.MigrationFS())
+	// ハンドラ関数の作成（デプロイ時に1回だけ実行するLambda）
+	lambdaHandler = ac.GetSimpleLambdaHandler().Handle(func(ctx context.Context, event any) (any, error) {
+		applied, err := migrator.MigrateWithContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]int{"applied": applied}, nil
+	})
+}
+
+// Main関数
+func main() {
+	cli := flag.Bool("cli", false, "Lambdaとして起動せず、マイグレーションを実行して終了する")
+	flag.Parse()
+	if *cli {
+		// CLIコマンドとして実行
+		apcontext.Context = context.Background()
+		_, err := migrator.Migrate()
+		ac.GetRDBConnectionPool().Close()
+		if err != nil {
+			ac.GetLogger().ErrorWithUnexpectedError(err)
+			os.Exit(1)
+		}
+		return
+	}
+	// ADOTに対応したLambdaの起動
+	otel.StartLambda(lambdaHandler)
+}
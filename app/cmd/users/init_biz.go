@@ -18,9 +18,9 @@ func initBiz(ac component.ApplicationContext, r *gin.Engine) {
 		panic(err)
 	}
 	// リポジトリの作成（DynamoDBの場合）
-	//userRepository := repository.NewUserRepositoryForDynamoDB(ac.GetDynamoDBAccessor(), ac.GetLogger(), ac.GetConfig())
+	//userRepository, err := repository.NewUserRepositoryForDynamoDB(ac.GetDynamoDBTemplate(), ac.GetLogger(), ac.GetConfig(), ac.GetIDGenerator())
 	// リポジトリの作成（RDBの場合）
-	userRepository := repository.NewUserRepositoryForRDB(ac.GetRDBAccessor(), ac.GetLogger(), ac.GetIDGenerator())
+	userRepository := repository.NewUserRepositoryForRDB(ac.GetRDBTemplate(), ac.GetLogger(), ac.GetIDGenerator())
 	// サービスの作成
 	userService := service.New(ac.GetLogger(), ac.GetConfig(), userRepository)
 	// コントローラの作成
@@ -5,6 +5,7 @@ import (
 	"app/internal/pkg/message"
 	"app/internal/pkg/model"
 	"app/internal/pkg/repository"
+	"context"
 	"encoding/json"
 	"fmt"
 
@@ -12,6 +13,7 @@ import (
 	"example.com/appbase/pkg/errors"
 	"example.com/appbase/pkg/logging"
 	"example.com/appbase/pkg/objectstorage"
+	"example.com/appbase/pkg/transaction"
 )
 
 const (
@@ -87,6 +89,13 @@ func (ts *todoAsyncServiceImpl) RegisterTodosAsync(asyncMesssage model.AsyncMess
 		}
 		ts.logger.Info(message.I_EX_0003, newTodo.ID)
 	}
+	// 登録のコミット後に、S3上の一時ファイルを削除する（ロールバック時は、再実行のため残しておく）
+	err = transaction.OnCommit(func(ctx context.Context) error {
+		return ts.objectstorageAccessor.DeleteWithContext(ctx, bucketName, filePath)
+	})
+	if err != nil {
+		return errors.NewSystemError(err, message.E_EX_9001)
+	}
 	// TODO: tempテーブルのアイテムの削除
 	return nil
 }
@@ -21,6 +21,7 @@ const (
 	W_EX_8007 = "w.ex.8007"
 	W_EX_8008 = "w.ex.8008"
 	W_EX_8009 = "w.ex.8009"
+	W_EX_8010 = "w.ex.8010"
 
 	// システムエラーメッセージID（エラーコード）
 	E_EX_9001 = "e.ex.9001"
@@ -4,7 +4,7 @@ package model
 // Temp は、一時テーブルの構造体です。
 type Temp struct {
 	// ID は、ダミーテーブルのIDです。
-	ID string `json:"id" dynamodbav:"id"`
+	ID string `json:"id" dynamodbav:"id" dynamodb:"pk"`
 	// Value は、ダミーテーブルの値です。
 	Value string `json:"value" dynamodbav:"value"`
 }
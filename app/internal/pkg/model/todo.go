@@ -4,7 +4,7 @@ package model
 // Todo はやることリスト（Todo）のEntityです。
 type Todo struct {
 	// ID は、TodoのIDです。
-	ID string `json:"todo_id" dynamodbav:"todo_id"`
+	ID string `json:"todo_id" dynamodbav:"todo_id" dynamodb:"pk"`
 	// Title は、Todoのタイトルです。
 	Title string `json:"todo_title" dynamodbav:"todo_title"`
 }
@@ -4,11 +4,10 @@ package repository
 import (
 	"app/internal/pkg/message"
 	"app/internal/pkg/model"
-	mytables "app/internal/pkg/repository/tables"
 
 	"example.com/appbase/pkg/config"
 	mydynamodb "example.com/appbase/pkg/dynamodb"
-	"example.com/appbase/pkg/dynamodb/input"
+	dynamodbrepository "example.com/appbase/pkg/dynamodb/repository"
 	"example.com/appbase/pkg/dynamodb/tables"
 	"example.com/appbase/pkg/errors"
 	"example.com/appbase/pkg/id"
@@ -34,47 +33,40 @@ type TempRepository interface {
 func NewTempRepository(dynamoDBTempalte transaction.TransactionalDynamoDBTemplate,
 	accessor transaction.TransactionalDynamoDBAccessor,
 	logger logging.Logger, config config.Config,
-	id id.IDGenerator) TempRepository {
+	id id.IDGenerator) (TempRepository, error) {
 	// テーブル名の取得
 	tableName := tables.DynamoDBTableName(config.Get(TEMP_TABLE_NAME, "temp"))
-	// テーブル定義の設定
-	mytables.Temp{}.InitPK(tableName)
+	// エンティティの構造体タグから、テーブル定義を設定したRepositoryの作成
+	tempRepository, err := dynamodbrepository.NewRepository[model.Temp](dynamoDBTempalte, tableName)
+	if err != nil {
+		return nil, err
+	}
 
 	return &tempRepositoryImpl{
-		dynamodbTemplate: dynamoDBTempalte,
-		accessor:         accessor,
-		logger:           logger,
-		config:           config,
-		tableName:        tableName,
-		id:               id,
-	}
+		repository: tempRepository,
+		accessor:   accessor,
+		logger:     logger,
+		config:     config,
+		tableName:  tableName,
+		id:         id,
+	}, nil
 }
 
 // tempRepositoryImpl は、TempRepositoryを実装する構造体です。
 type tempRepositoryImpl struct {
-	dynamodbTemplate transaction.TransactionalDynamoDBTemplate
-	accessor         transaction.TransactionalDynamoDBAccessor
-	logger           logging.Logger
-	config           config.Config
-	tableName        tables.DynamoDBTableName
-	id               id.IDGenerator
+	repository dynamodbrepository.Repository[model.Temp]
+	accessor   transaction.TransactionalDynamoDBAccessor
+	logger     logging.Logger
+	config     config.Config
+	tableName  tables.DynamoDBTableName
+	id         id.IDGenerator
 }
 
 // FindOne implements TempRepository.
 func (r *tempRepositoryImpl) FindOne(id string) (*model.Temp, error) {
-	// DynamoDBTemplateを使ったコード
-	input := input.PKOnlyQueryInput{
-		PrimaryKey: input.PrimaryKey{
-			PartitionKey: input.Attribute{
-				Name:  tables.GetPrimaryKey(r.tableName).PartitionKey,
-				Value: id,
-			},
-		},
-		//ConsitentRead: true,
-	}
-	var temp model.Temp
+	// Repositoryを使ったコード
 	// Itemの取得
-	err := r.dynamodbTemplate.FindOneByTableKey(r.tableName, input, &temp)
+	temp, err := r.repository.Get(dynamodbrepository.Key{PartitionKey: id} /*, dynamodbrepository.WithConsistentRead()*/)
 	if err != nil {
 		if errors.Is(err, mydynamodb.ErrRecordNotFound) {
 			// レコード未取得の場合
@@ -101,8 +93,10 @@ func (r *tempRepositoryImpl) FindOne(id string) (*model.Temp, error) {
 		err = attributevalue.UnmarshalMap(result.Item, &temp)
 		if err != nil {
 			return nil, errors.NewSystemError(err, message.E_EX_9001)
-		}*/
-	return &temp, nil
+		}
+		return &temp, nil
+	*/
+	return temp, nil
 }
 
 // CreateOneTx implements TempRepository.
@@ -116,9 +110,14 @@ func (r *tempRepositoryImpl) CreateOneTx(temp *model.Temp) (*model.Temp, error)
 	r.logger.Debug("CreateOneTx Table name: %s", r.tableName)
 	r.logger.Debug("CreateOneTx Temp id: %s", id)
 
-	// DynamoDBTemplateを使ったコード
-	err = r.dynamodbTemplate.CreateOneWithTransaction(r.tableName, temp)
+	// Repositoryを使ったコード
+	err = r.repository.PutWithTransaction(temp)
 	if err != nil {
+		var sysErr *errors.SystemError
+		if errors.As(err, &sysErr) {
+			// トランザクションの上限超過等、AP基盤でSystemErrorとなっている場合はそのまま返却
+			return nil, err
+		}
 		return nil, errors.NewSystemError(err, message.E_EX_9001)
 	}
 
@@ -153,8 +152,8 @@ func (r *tempRepositoryImpl) CreateOne(temp *model.Temp) (*model.Temp, error) {
 	r.logger.Debug("CreateOne Table name: %s", r.tableName)
 	r.logger.Debug("CreateOne Temp id: %s", id)
 
-	// DynamoDBTemplateを使ったコード
-	err = r.dynamodbTemplate.CreateOne(r.tableName, temp)
+	// Repositoryを使ったコード
+	err = r.repository.Put(temp)
 	if err != nil {
 		if errors.Is(err, mydynamodb.ErrKeyDuplicaiton) {
 			return nil, errors.NewBusinessError(message.W_EX_8007, id)
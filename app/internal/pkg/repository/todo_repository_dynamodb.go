@@ -4,11 +4,10 @@ package repository
 import (
 	"app/internal/pkg/message"
 	"app/internal/pkg/model"
-	mytables "app/internal/pkg/repository/tables"
 
 	"example.com/appbase/pkg/config"
 	mydynamodb "example.com/appbase/pkg/dynamodb"
-	"example.com/appbase/pkg/dynamodb/input"
+	dynamodbrepository "example.com/appbase/pkg/dynamodb/repository"
 	"example.com/appbase/pkg/dynamodb/tables"
 	"example.com/appbase/pkg/errors"
 	"example.com/appbase/pkg/id"
@@ -25,45 +24,39 @@ const (
 func NewTodoRepositoryForDynamoDB(dynamoDBTempalte transaction.TransactionalDynamoDBTemplate,
 	accessor transaction.TransactionalDynamoDBAccessor,
 	logger logging.Logger, config config.Config,
-	id id.IDGenerator) TodoRepository {
+	id id.IDGenerator) (TodoRepository, error) {
 	// テーブル名の取得
 	tableName := tables.DynamoDBTableName(config.Get(TODO_TABLE_NAME, "todo"))
-	// テーブル定義の設定
-	mytables.Todo{}.InitPK(tableName)
+	// エンティティの構造体タグから、テーブル定義を設定したRepositoryの作成
+	todoRepository, err := dynamodbrepository.NewRepository[model.Todo](dynamoDBTempalte, tableName)
+	if err != nil {
+		return nil, err
+	}
 
 	return &todoRepositoryImplByDynamoDB{
-		dynamodbTemplate: dynamoDBTempalte,
-		accessor:         accessor,
-		logger:           logger,
-		config:           config,
-		tableName:        tableName,
-		id:               id,
-	}
+		repository: todoRepository,
+		accessor:   accessor,
+		logger:     logger,
+		config:     config,
+		tableName:  tableName,
+		id:         id,
+	}, nil
 }
 
 // todoRepositoryImplByDynamoDB は、TodoRepositoryを実装する構造体です。
 type todoRepositoryImplByDynamoDB struct {
-	dynamodbTemplate transaction.TransactionalDynamoDBTemplate
-	accessor         transaction.TransactionalDynamoDBAccessor
-	logger           logging.Logger
-	config           config.Config
-	tableName        tables.DynamoDBTableName
-	id               id.IDGenerator
+	repository dynamodbrepository.Repository[model.Todo]
+	accessor   transaction.TransactionalDynamoDBAccessor
+	logger     logging.Logger
+	config     config.Config
+	tableName  tables.DynamoDBTableName
+	id         id.IDGenerator
 }
 
 func (tr *todoRepositoryImplByDynamoDB) FindOne(todoId string) (*model.Todo, error) {
-	// DynamoDBTemplateを使ったコード
-	input := input.PKOnlyQueryInput{
-		PrimaryKey: input.PrimaryKey{
-			PartitionKey: input.Attribute{
-				Name:  tables.GetPrimaryKey(tr.tableName).PartitionKey,
-				Value: todoId,
-			},
-		},
-	}
-	var todo model.Todo
+	// Repositoryを使ったコード
 	// Itemの取得
-	err := tr.dynamodbTemplate.FindOneByTableKey(tr.tableName, input, &todo)
+	todo, err := tr.repository.Get(dynamodbrepository.Key{PartitionKey: todoId})
 	if err != nil {
 		if errors.Is(err, mydynamodb.ErrRecordNotFound) {
 			// レコード未取得の場合
@@ -98,8 +91,9 @@ func (tr *todoRepositoryImplByDynamoDB) FindOne(todoId string) (*model.Todo, err
 		if err != nil {
 			return nil, errors.NewSystemError(err, message.E_EX_9001)
 		}
+		return &todo, nil
 	*/
-	return &todo, nil
+	return todo, nil
 }
 
 func (tr *todoRepositoryImplByDynamoDB) CreateOne(todo *model.Todo) (*model.Todo, error) {
@@ -110,8 +104,8 @@ func (tr *todoRepositoryImplByDynamoDB) CreateOne(todo *model.Todo) (*model.Todo
 	}
 	//todoId := "dummy"
 	todo.ID = todoId
-	// DynamoDBTemplateを使ったコード
-	err = tr.dynamodbTemplate.CreateOne(tr.tableName, todo)
+	// Repositoryを使ったコード
+	err = tr.repository.Put(todo)
 	if err != nil {
 		if errors.Is(err, mydynamodb.ErrKeyDuplicaiton) {
 			// キーの重複の場合
@@ -157,9 +151,14 @@ func (tr *todoRepositoryImplByDynamoDB) CreateOneTx(todo *model.Todo) (*model.To
 	}
 	//todoId := "dummy"
 	todo.ID = todoId
-	// DynamoDBTemplateを使ったコード
-	err = tr.dynamodbTemplate.CreateOneWithTransaction(tr.tableName, todo)
+	// Repositoryを使ったコード
+	err = tr.repository.PutWithTransaction(todo)
 	if err != nil {
+		var sysErr *errors.SystemError
+		if errors.As(err, &sysErr) {
+			// トランザクションの上限超過等、AP基盤でSystemErrorとなっている場合はそのまま返却
+			return nil, err
+		}
 		return nil, errors.NewSystemError(err, message.E_EX_9001)
 	}
 
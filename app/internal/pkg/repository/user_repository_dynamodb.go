@@ -7,12 +7,12 @@ import (
 
 	"example.com/appbase/pkg/config"
 	mydynamodb "example.com/appbase/pkg/dynamodb"
+	dynamodbrepository "example.com/appbase/pkg/dynamodb/repository"
+	"example.com/appbase/pkg/dynamodb/tables"
 	"example.com/appbase/pkg/errors"
 	"example.com/appbase/pkg/id"
 	"example.com/appbase/pkg/logging"
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"example.com/appbase/pkg/transaction"
 )
 
 const (
@@ -20,56 +20,42 @@ const (
 )
 
 // NewUserRepositoryForDynamoDB は、DynamoDB保存のためのUserRepository実装を作成します。
-func NewUserRepositoryForDynamoDB(accessor mydynamodb.DynamoDBAccessor,
+func NewUserRepositoryForDynamoDB(dynamoDBTempalte transaction.TransactionalDynamoDBTemplate,
 	logger logging.Logger, config config.Config,
-	id id.IDGenerator) UserRepository {
-	return &UserRepositoryImplByDynamoDB{
-		accessor: accessor,
-		logger:   logger,
-		config:   config,
-		id:       id,
+	id id.IDGenerator) (UserRepository, error) {
+	// テーブル名の取得
+	tableName := tables.DynamoDBTableName(config.Get(USERS_TABLE_NAME, "users"))
+	// エンティティの構造体タグから、テーブル定義を設定したRepositoryの作成
+	userRepository, err := dynamodbrepository.NewRepository[model.User](dynamoDBTempalte, tableName)
+	if err != nil {
+		return nil, err
 	}
+	return &UserRepositoryImplByDynamoDB{
+		repository: userRepository,
+		logger:     logger,
+		config:     config,
+		id:         id,
+	}, nil
 }
 
 // UserRepositoryImplByDynamoDB は、DynamoDB保存のためのUserRepository実装です。
 type UserRepositoryImplByDynamoDB struct {
-	accessor mydynamodb.DynamoDBAccessor
-	logger   logging.Logger
-	config   config.Config
-	id       id.IDGenerator
+	repository dynamodbrepository.Repository[model.User]
+	logger     logging.Logger
+	config     config.Config
+	id         id.IDGenerator
 }
 
 func (ur *UserRepositoryImplByDynamoDB) FindOne(userId string) (*model.User, error) {
-	// AWS SDK for Go v2 Migration
-	// https://docs.aws.amazon.com/ja_jp/code-library/latest/ug/go_2_dynamodb_code_examples.html
-	// https://github.com/awsdocs/aws-doc-sdk-examples/tree/main/gov2/dynamodb
-	//Itemの取得（X-Rayトレース）
-	user := model.User{ID: userId}
-	key, err := user.GetKey()
-
-	userTable := ur.config.Get(USERS_TABLE_NAME, "users")
+	// Itemの取得
+	user, err := ur.repository.Get(dynamodbrepository.Key{PartitionKey: userId})
 	if err != nil {
-		// return nil, errors.Wrapf(err, "failed to get key")
+		if errors.Is(err, mydynamodb.ErrRecordNotFound) {
+			return nil, nil
+		}
 		return nil, errors.NewSystemError(err, message.E_EX_9001)
 	}
-	result, err := ur.accessor.GetItemSdk(&dynamodb.GetItemInput{
-		TableName: aws.String(userTable),
-		Key:       key,
-	})
-	if err != nil {
-		// return nil, errors.Wrapf(err, "failed to get item")
-		return nil, errors.NewSystemError(err, message.E_EX_9001)
-	}
-	if result.Item == nil {
-		return nil, nil
-	}
-
-	err = attributevalue.UnmarshalMap(result.Item, &user)
-	if err != nil {
-		// return nil, errors.Wrapf(err, "failed to marshal item")
-		return nil, errors.NewSystemError(err, message.E_EX_9001)
-	}
-	return &user, nil
+	return user, nil
 }
 
 func (ur *UserRepositoryImplByDynamoDB) CreateOne(user *model.User) (*model.User, error) {
@@ -79,20 +65,9 @@ func (ur *UserRepositoryImplByDynamoDB) CreateOne(user *model.User) (*model.User
 		return nil, errors.NewSystemError(err, message.E_EX_9001)
 	}
 	user.ID = userId
-	userTable := ur.config.Get(USERS_TABLE_NAME, "users")
-	av, err := attributevalue.MarshalMap(user)
-	if err != nil {
-		// return nil, errors.Wrapf(err, "failed to marshal item")
-		return nil, errors.NewSystemError(err, message.E_EX_9001)
-	}
-	input := &dynamodb.PutItemInput{
-		Item:      av,
-		TableName: aws.String(userTable),
-	}
 	// Itemの登録
-	_, err = ur.accessor.PutItemSdk(input)
+	err = ur.repository.Put(user)
 	if err != nil {
-		// return nil, errors.Wrapf(err, "failed to put item")
 		return nil, errors.NewSystemError(err, message.E_EX_9001)
 	}
 	return user, nil
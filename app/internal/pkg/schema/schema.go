@@ -0,0 +1,24 @@
+/*
+schema パッケージは、RDBのスキーマのマイグレーションファイルを埋め込むパッケージです。
+*/
+package schema
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// マイグレーションファイル（V<バージョン>__<説明>.sql）
+//
+//go:embed sql/*.sql
+var migrationFiles embed.FS
+
+// MigrationFS は、マイグレーションファイルを格納したファイルシステムを返却します。
+func MigrationFS() fs.FS {
+	fsys, err := fs.Sub(migrationFiles, "sql")
+	if err != nil {
+		// 埋め込み時のディレクトリ名のため発生しない
+		panic(err)
+	}
+	return fsys
+}
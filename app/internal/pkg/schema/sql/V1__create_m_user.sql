@@ -0,0 +1,2 @@
+-- ユーザテーブル
+CREATE TABLE IF NOT EXISTS m_user (user_id VARCHAR(50) PRIMARY KEY, user_name VARCHAR(50));
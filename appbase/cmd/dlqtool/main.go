@@ -0,0 +1,188 @@
+/*
+dlqtool は、SQSのデッドレターキュー（DLQ）のメッセージの確認、再送（Redrive）、エクスポート、削除を行うコマンドです。
+
+使い方:
+
+	dlqtool list    -dlq DLQ名 [-source 送信元キュー名] [-min-age 期間] [-max-age 期間] [-attr キー=値]... [-id メッセージID]...
+	dlqtool export  -dlq DLQ名 [-source 送信元キュー名] [-out 出力ファイル] [絞り込み条件]
+	dlqtool redrive -dlq DLQ名 -source 送信元キュー名 [絞り込み条件]
+	dlqtool purge   -dlq DLQ名 -yes
+
+設定は、AP本体と同様に環境変数ENVに対応する設定ファイル（configs/config-{ENV}.yaml）、または環境変数から取得します。
+ローカル環境（ElasticMQ）に対して実行する場合は、SQS_LOCAL_ENDPOINT、DYNAMODB_LOCAL_ENDPOINTを指定します。
+*/
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"example.com/appbase/pkg/apcontext"
+	"example.com/appbase/pkg/config"
+	"example.com/appbase/pkg/dlq"
+	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/message"
+	"example.com/appbase/pkg/transaction"
+)
+
+// stringsFlag は、複数回指定可能なコマンドライン引数を表す型です。
+type stringsFlag []string
+
+// String implements flag.Value.
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+// Set implements flag.Value.
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// Main関数
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	if err := run(os.Args[1], os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "エラー: %+v\n", err)
+		os.Exit(1)
+	}
+}
+
+// usage は、コマンドの使い方を出力します。
+func usage() {
+	fmt.Fprintln(os.Stderr, "使い方: dlqtool <list|export|redrive|purge> -dlq DLQ名 [オプション]")
+}
+
+// run は、サブコマンドを実行します。
+func run(command string, args []string) error {
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	dlqName := fs.String("dlq", "", "DLQ名")
+	sourceQueueName := fs.String("source", "", "送信元（再送先）のキュー名")
+	minAge := fs.Duration("min-age", 0, "送信からの経過時間がこの値以上のメッセージを対象とする（例：1h）")
+	maxAge := fs.Duration("max-age", 0, "送信からの経過時間がこの値以下のメッセージを対象とする（例：24h）")
+	out := fs.String("out", "", "exportの出力ファイル（未指定の場合は標準出力）")
+	yes := fs.Bool("yes", false, "purgeの実行を確認済とする")
+	var attrs, ids stringsFlag
+	fs.Var(&attrs, "attr", "メッセージ属性の条件（キー=値）。複数指定可")
+	fs.Var(&ids, "id", "メッセージIDの条件。複数指定可")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dlqName == "" {
+		fs.Usage()
+		return fmt.Errorf("-dlqを指定してください")
+	}
+	filter := &dlq.Filter{
+		MinAge:     *minAge,
+		MaxAge:     *maxAge,
+		Attributes: make(map[string]string),
+		MessageIds: ids,
+	}
+	for _, v := range attrs {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok {
+			return fmt.Errorf("-attrは「キー=値」の形式で指定してください: %s", v)
+		}
+		filter.Attributes[key] = value
+	}
+
+	apcontext.Context = context.Background()
+	manager, logger, err := newDeadLetterQueueManager()
+	if err != nil {
+		return err
+	}
+	defer logger.Sync()
+
+	switch command {
+	case "list":
+		msgs, err := manager.List(*dlqName, *sourceQueueName, filter)
+		if err != nil {
+			return err
+		}
+		printMessages(os.Stdout, msgs)
+	case "export":
+		var w io.Writer = os.Stdout
+		if *out != "" {
+			f, err := os.Create(*out)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		count, err := manager.Export(*dlqName, *sourceQueueName, filter, w)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%d件のメッセージをエクスポートしました。\n", count)
+	case "redrive":
+		count, err := manager.Redrive(*dlqName, *sourceQueueName, filter)
+		fmt.Fprintf(os.Stderr, "%d件のメッセージを再送しました。\n", count)
+		if err != nil {
+			return err
+		}
+	case "purge":
+		if !*yes {
+			return fmt.Errorf("DLQ[%s]の全メッセージを削除します。実行する場合は-yesを指定してください", *dlqName)
+		}
+		if err := manager.Purge(*dlqName); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "DLQ[%s]のメッセージを削除しました。\n", *dlqName)
+	default:
+		usage()
+		return fmt.Errorf("サブコマンドが不正です: %s", command)
+	}
+	return nil
+}
+
+// printMessages は、メッセージの一覧を表形式で出力します。
+func printMessages(w io.Writer, msgs []*dlq.DeadLetterMessage) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MESSAGE_ID\tMESSAGE_GROUP_ID\tSENT_TIME\tRECEIVE_COUNT\tSTATUS\tBODY")
+	for _, v := range msgs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%v\n", v.MessageId, v.MessageGroupId,
+			v.SentTime.Format(time.RFC3339), v.ReceiveCount, v.QueueMessageStatus, v.Body)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "%d件\n", len(msgs))
+}
+
+// newDeadLetterQueueManager は、DeadLetterQueueManagerの作成に必要なAP基盤のコンポーネントを作成します。
+// DLQの操作に不要なRDB、DocumentDB等への接続を行わないよう、ApplicationContextは利用しません。
+func newDeadLetterQueueManager() (dlq.DeadLetterQueueManager, logging.Logger, error) {
+	messageSource, err := message.NewMessageSource()
+	if err != nil {
+		return nil, nil, fmt.Errorf("メッセージの読み込みに失敗しました: %w", err)
+	}
+	logger, err := logging.NewLogger(messageSource)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ロガーの作成に失敗しました: %w", err)
+	}
+	cfg, err := config.NewConfig(logger)
+	if err != nil {
+		return nil, nil, fmt.Errorf("設定の読み込みに失敗しました: %w", err)
+	}
+	dynamodbAccessor, err := transaction.NewTransactionalDynamoDBAccessor(logger, cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("DynamoDBAccessorの作成に失敗しました: %w", err)
+	}
+	dynamodbTemplate := transaction.NewTransactionalDynamoDBTemplate(logger, dynamodbAccessor)
+	queueMessageItemRepository := transaction.NewQueueMessageItemRepository(cfg, logger, dynamodbTemplate)
+	messageRegisterer := transaction.NewMessageRegisterer(queueMessageItemRepository)
+	sqsAccessor, err := transaction.NewTransactionalSQSAccessor(logger, cfg, messageRegisterer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("SQSAccessorの作成に失敗しました: %w", err)
+	}
+	transactionManager := transaction.NewTransactionManager(logger, dynamodbAccessor, sqsAccessor, messageRegisterer)
+	return dlq.NewDeadLetterQueueManager(logger, cfg, sqsAccessor, transactionManager, queueMessageItemRepository), logger, nil
+}
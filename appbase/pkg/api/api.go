@@ -38,9 +38,10 @@ type defaultApiResponseFormatter struct {
 // ReturnResponseBody implements ApiResponseFormatter.
 func (f *defaultApiResponseFormatter) ReturnResponseBody(ctx *gin.Context, errorResponse ErrorResponse) {
 	var (
-		validationError *myerrors.ValidationError
-		businessErrors  *myerrors.BusinessErrors
-		systemError     *myerrors.SystemError
+		validationError     *myerrors.ValidationError
+		businessErrors      *myerrors.BusinessErrors
+		systemError         *myerrors.SystemError
+		optimisticLockError myerrors.OptimisticLockError
 	)
 	errs := ctx.Errors
 
@@ -55,6 +56,8 @@ func (f *defaultApiResponseFormatter) ReturnResponseBody(ctx *gin.Context, error
 			ctx.JSON(errorResponse.BusinessErrorResponse(businessErrors))
 		} else if errors.As(err, &systemError) {
 			ctx.JSON(errorResponse.SystemErrorResponse(systemError))
+		} else if errors.As(err, &optimisticLockError) {
+			ctx.JSON(errorResponse.WarnErrorResponse(optimisticLockError))
 		} else if errors.Is(err, NoRouteError) {
 			ctx.JSON(errorResponse.WarnErrorResponse(NoRouteError))
 		} else if errors.Is(err, NoMethodError) {
@@ -11,7 +11,7 @@ type ErrorResponse interface {
 	ValidationErrorResponse(validationError *errors.ValidationError) (int, any)
 	// BusinessErrorResponse は、業務エラーに対応するレスポンスを返却します。
 	BusinessErrorResponse(businessErrors *errors.BusinessErrors) (int, any)
-	// WarnErrorResponse は、警告エラーに対応するレスポンスを返却します。
+	// WarnErrorResponse は、警告エラー（NoRouteError、NoMethodError、楽観ロックエラー）に対応するレスポンスを返却します。
 	WarnErrorResponse(err error) (int, any)
 	// SystemErrorResponse は、システムエラーに対応するレスポンスを返却します。
 	SystemErrorResponse(systemError *errors.SystemError) (int, any)
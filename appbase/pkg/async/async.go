@@ -5,15 +5,18 @@ package async
 
 import (
 	"context"
+	"sync"
 
 	"example.com/appbase/pkg/apcontext"
 	"example.com/appbase/pkg/awssdk"
 	myConfig "example.com/appbase/pkg/config"
 	"example.com/appbase/pkg/logging"
 	"example.com/appbase/pkg/message"
+	"example.com/appbase/pkg/otel"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/cockroachdb/errors"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
 )
@@ -49,6 +52,26 @@ type SQSAccessor interface {
 	SendMessageSdk(queueName string, input *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
 	// SendMessageSdkWithContext は、AWS SDKによるSendMessageをラップします。goroutine向けに、渡されたContextを利用して実行します。
 	SendMessageSdkWithContext(ctx context.Context, queueName string, input *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+	// ChangeMessageVisibilitySdk は、AWS SDKによるChangeMessageVisibilityをラップします。
+	ChangeMessageVisibilitySdk(queueName string, input *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
+	// ChangeMessageVisibilitySdkWithContext は、AWS SDKによるChangeMessageVisibilityをラップします。goroutine向けに、渡されたContextを利用して実行します。
+	ChangeMessageVisibilitySdkWithContext(ctx context.Context, queueName string, input *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
+	// ChangeMessageVisibilityBatchSdk は、AWS SDKによるChangeMessageVisibilityBatchをラップします。
+	ChangeMessageVisibilityBatchSdk(queueName string, input *sqs.ChangeMessageVisibilityBatchInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityBatchOutput, error)
+	// ChangeMessageVisibilityBatchSdkWithContext は、AWS SDKによるChangeMessageVisibilityBatchをラップします。goroutine向けに、渡されたContextを利用して実行します。
+	ChangeMessageVisibilityBatchSdkWithContext(ctx context.Context, queueName string, input *sqs.ChangeMessageVisibilityBatchInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityBatchOutput, error)
+	// ReceiveMessageSdk は、AWS SDKによるReceiveMessageをラップします。
+	ReceiveMessageSdk(queueName string, input *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	// ReceiveMessageSdkWithContext は、AWS SDKによるReceiveMessageをラップします。goroutine向けに、渡されたContextを利用して実行します。
+	ReceiveMessageSdkWithContext(ctx context.Context, queueName string, input *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	// DeleteMessageSdk は、AWS SDKによるDeleteMessageをラップします。
+	DeleteMessageSdk(queueName string, input *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+	// DeleteMessageSdkWithContext は、AWS SDKによるDeleteMessageをラップします。goroutine向けに、渡されたContextを利用して実行します。
+	DeleteMessageSdkWithContext(ctx context.Context, queueName string, input *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+	// PurgeQueueSdk は、AWS SDKによるPurgeQueueをラップします。
+	PurgeQueueSdk(queueName string, optFns ...func(*sqs.Options)) (*sqs.PurgeQueueOutput, error)
+	// PurgeQueueSdkWithContext は、AWS SDKによるPurgeQueueをラップします。goroutine向けに、渡されたContextを利用して実行します。
+	PurgeQueueSdkWithContext(ctx context.Context, queueName string, optFns ...func(*sqs.Options)) (*sqs.PurgeQueueOutput, error)
 }
 
 // NewSQSAccessor は、SQSAccessorを作成します。
@@ -93,6 +116,8 @@ type defaultSQSAccessor struct {
 	config    myConfig.Config
 	logger    logging.Logger
 	sqsClient *sqs.Client
+	// ハートビート用のgoroutineからも参照されるため、キャッシュへのアクセスは排他制御する
+	mu        sync.RWMutex
 	queueUrls map[string]string
 }
 
@@ -107,25 +132,11 @@ func (sa *defaultSQSAccessor) SendMessageSdkWithContext(ctx context.Context, que
 		ctx = apcontext.Context
 	}
 	// QueueのURLの取得・設定
-	queueUrl, ok := sa.queueUrls[queueName]
-	if ok {
-		// キャッシュがある場合は、キャッシュから取得
-		sa.logger.Debug("QueueURLキャッシュ:%s", queueUrl)
-		input.QueueUrl = &queueUrl
-	} else {
-		// キャッシュがない場合は、APIで取得
-		queueUrlOutput, err := sa.sqsClient.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{
-			QueueName: aws.String(queueName),
-		}, optFns...)
-		if err != nil {
-			return nil, errors.WithStack(err)
-		}
-		sa.logger.Debug("GetQueueURL:%s", *queueUrlOutput.QueueUrl)
-		// 送信先の設定
-		input.QueueUrl = queueUrlOutput.QueueUrl
-		// キャッシュへ格納
-		sa.queueUrls[queueName] = *queueUrlOutput.QueueUrl
+	queueUrl, err := sa.getQueueUrl(ctx, queueName, optFns...)
+	if err != nil {
+		return nil, err
 	}
+	input.QueueUrl = aws.String(queueUrl)
 	// ログ出力
 	if input.MessageGroupId != nil {
 		if input.MessageDeduplicationId != nil {
@@ -140,6 +151,12 @@ func (sa *defaultSQSAccessor) SendMessageSdkWithContext(ctx context.Context, que
 		sa.logger.Debug("Message=%s", *input.MessageBody)
 	}
 
+	// トレースコンテキストをメッセージ属性に格納し、非同期処理側へ伝搬する
+	if input.MessageAttributes == nil {
+		input.MessageAttributes = make(map[string]types.MessageAttributeValue)
+	}
+	otel.InjectSQSMessageAttributes(ctx, input.MessageAttributes)
+
 	//　SQSへメッセージ送信する
 	output, err := sa.sqsClient.SendMessage(ctx, input, optFns...)
 	if err != nil {
@@ -158,3 +175,149 @@ func (sa *defaultSQSAccessor) SendMessageSdkWithContext(ctx context.Context, que
 	}
 	return output, nil
 }
+
+// ChangeMessageVisibilitySdk implements SQSAccessor.
+func (sa *defaultSQSAccessor) ChangeMessageVisibilitySdk(queueName string, input *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	return sa.ChangeMessageVisibilitySdkWithContext(apcontext.Context, queueName, input, optFns...)
+}
+
+// ChangeMessageVisibilitySdkWithContext implements SQSAccessor.
+func (sa *defaultSQSAccessor) ChangeMessageVisibilitySdkWithContext(ctx context.Context, queueName string, input *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	// QueueのURLの取得・設定
+	queueUrl, err := sa.getQueueUrl(ctx, queueName, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	input.QueueUrl = aws.String(queueUrl)
+	output, err := sa.sqsClient.ChangeMessageVisibility(ctx, input, optFns...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sa.logger.Debug("ChangeMessageVisibility: キュー名[%s], VisibilityTimeout[%d]", queueName, input.VisibilityTimeout)
+	return output, nil
+}
+
+// ChangeMessageVisibilityBatchSdk implements SQSAccessor.
+func (sa *defaultSQSAccessor) ChangeMessageVisibilityBatchSdk(queueName string, input *sqs.ChangeMessageVisibilityBatchInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+	return sa.ChangeMessageVisibilityBatchSdkWithContext(apcontext.Context, queueName, input, optFns...)
+}
+
+// ChangeMessageVisibilityBatchSdkWithContext implements SQSAccessor.
+func (sa *defaultSQSAccessor) ChangeMessageVisibilityBatchSdkWithContext(ctx context.Context, queueName string, input *sqs.ChangeMessageVisibilityBatchInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	// QueueのURLの取得・設定
+	queueUrl, err := sa.getQueueUrl(ctx, queueName, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	input.QueueUrl = aws.String(queueUrl)
+	output, err := sa.sqsClient.ChangeMessageVisibilityBatch(ctx, input, optFns...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sa.logger.Debug("ChangeMessageVisibilityBatch: キュー名[%s], 件数[%d]", queueName, len(input.Entries))
+	return output, nil
+}
+
+// ReceiveMessageSdk implements SQSAccessor.
+func (sa *defaultSQSAccessor) ReceiveMessageSdk(queueName string, input *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	return sa.ReceiveMessageSdkWithContext(apcontext.Context, queueName, input, optFns...)
+}
+
+// ReceiveMessageSdkWithContext implements SQSAccessor.
+func (sa *defaultSQSAccessor) ReceiveMessageSdkWithContext(ctx context.Context, queueName string, input *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	// QueueのURLの取得・設定
+	queueUrl, err := sa.getQueueUrl(ctx, queueName, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	input.QueueUrl = aws.String(queueUrl)
+	output, err := sa.sqsClient.ReceiveMessage(ctx, input, optFns...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sa.logger.Debug("ReceiveMessage: キュー名[%s], 件数[%d]", queueName, len(output.Messages))
+	return output, nil
+}
+
+// DeleteMessageSdk implements SQSAccessor.
+func (sa *defaultSQSAccessor) DeleteMessageSdk(queueName string, input *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	return sa.DeleteMessageSdkWithContext(apcontext.Context, queueName, input, optFns...)
+}
+
+// DeleteMessageSdkWithContext implements SQSAccessor.
+func (sa *defaultSQSAccessor) DeleteMessageSdkWithContext(ctx context.Context, queueName string, input *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	// QueueのURLの取得・設定
+	queueUrl, err := sa.getQueueUrl(ctx, queueName, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	input.QueueUrl = aws.String(queueUrl)
+	output, err := sa.sqsClient.DeleteMessage(ctx, input, optFns...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sa.logger.Debug("DeleteMessage: キュー名[%s]", queueName)
+	return output, nil
+}
+
+// PurgeQueueSdk implements SQSAccessor.
+func (sa *defaultSQSAccessor) PurgeQueueSdk(queueName string, optFns ...func(*sqs.Options)) (*sqs.PurgeQueueOutput, error) {
+	return sa.PurgeQueueSdkWithContext(apcontext.Context, queueName, optFns...)
+}
+
+// PurgeQueueSdkWithContext implements SQSAccessor.
+func (sa *defaultSQSAccessor) PurgeQueueSdkWithContext(ctx context.Context, queueName string, optFns ...func(*sqs.Options)) (*sqs.PurgeQueueOutput, error) {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	// QueueのURLの取得
+	queueUrl, err := sa.getQueueUrl(ctx, queueName, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	output, err := sa.sqsClient.PurgeQueue(ctx, &sqs.PurgeQueueInput{
+		QueueUrl: aws.String(queueUrl),
+	}, optFns...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sa.logger.Debug("PurgeQueue: キュー名[%s]", queueName)
+	return output, nil
+}
+
+// getQueueUrl は、キュー名からQueueのURLを取得します。
+func (sa *defaultSQSAccessor) getQueueUrl(ctx context.Context, queueName string, optFns ...func(*sqs.Options)) (string, error) {
+	sa.mu.RLock()
+	queueUrl, ok := sa.queueUrls[queueName]
+	sa.mu.RUnlock()
+	if ok {
+		// キャッシュがある場合は、キャッシュから取得
+		sa.logger.Debug("QueueURLキャッシュ:%s", queueUrl)
+		return queueUrl, nil
+	}
+	// キャッシュがない場合は、APIで取得
+	queueUrlOutput, err := sa.sqsClient.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{
+		QueueName: aws.String(queueName),
+	}, optFns...)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	sa.logger.Debug("GetQueueURL:%s", *queueUrlOutput.QueueUrl)
+	// キャッシュへ格納
+	sa.mu.Lock()
+	sa.queueUrls[queueName] = *queueUrlOutput.QueueUrl
+	sa.mu.Unlock()
+	return *queueUrlOutput.QueueUrl, nil
+}
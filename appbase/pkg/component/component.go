@@ -17,7 +17,10 @@ import (
 	"example.com/appbase/pkg/logging"
 	"example.com/appbase/pkg/message"
 	"example.com/appbase/pkg/objectstorage"
+	"example.com/appbase/pkg/outbox"
 	"example.com/appbase/pkg/rdb"
+	"example.com/appbase/pkg/saga"
+	"example.com/appbase/pkg/stepfunctions"
 	"example.com/appbase/pkg/transaction"
 	"example.com/appbase/pkg/validator"
 )
@@ -39,22 +42,33 @@ type ApplicationContext interface {
 	// GetDynamoDBTransactionManagerForDBOnly は、DynamoDBトランザクション管理機能のインタフェースTransactionManagerを取得します。
 	// DynamoDBのみのトランザクションのみを行う場合に利用します。
 	GetDynamoDBTransactionManagerForDBOnly() transaction.TransactionManager
+	// GetDynamoDBTransactionManagerWithOutbox は、DynamoDBトランザクション管理機能のインタフェースTransactionManagerを取得します。
+	// SQSのメッセージを直接送信せず、トランザクショナルアウトボックスに登録する場合に利用します。
+	GetDynamoDBTransactionManagerWithOutbox() transaction.TransactionManager
 	// GetDynamoDBTemplate は、トランザクション対応のDynamoDBアクセス機能の汎用インタフェースTransactionalDynamoDBTemplateを取得します。
 	GetDynamoDBTemplate() transaction.TransactionalDynamoDBTemplate
 	// GetSQSAccessor は、トランザクション対応の非同期実行依頼機能のインタフェースTransactionalSQSAccessorを取得します。
 	GetSQSAccessor() transaction.TransactionalSQSAccessor
 	// GetSQSTemplate は、非同期実行依頼機能の汎用インタフェースSQSTemplateを取得します。
 	GetSQSTemplate() async.SQSTemplate
+	// GetOutboxTemplate は、SNS、EventBridgeへのメッセージをトランザクショナルアウトボックスに登録する機能のインタフェースOutboxTemplateを取得します。
+	GetOutboxTemplate() transaction.OutboxTemplate
 	// GetObjectStorageAccessor は、オブジェクトストレージアクセス機能のインタフェースObjectStorageAccessorを取得します。
 	GetObjectStorageAccessor() objectstorage.ObjectStorageAccessor
 	// GetRDBAccessor は、RDBアクセス機能のインタフェースRDBAccessorを取得します。
 	GetRDBAccessor() rdb.RDBAccessor
+	// GetRDBConnectionPool は、RDBのコネクションプールを管理するインタフェースRDBConnectionPoolを取得します。
+	GetRDBConnectionPool() rdb.RDBConnectionPool
+	// GetRDBTemplate は、名前付きパラメータのSQLを実行するインタフェースRDBTemplateを取得します。
+	GetRDBTemplate() rdb.RDBTemplate
 	// GetRDBTransactionManager は、RDBトランザクション管理機能のインタフェースTransactionManagerを取得します。
 	GetRDBTransactionManager() rdb.TransactionManager
 	// GetDocumentDBAccessor は、DocumentDBアクセス機能のインタフェースDocumentDBAccessorを取得します。
 	GetDocumentDBAccessor() documentdb.DocumentDBAccessor
 	// GetHTTPClient は、HTTPクライアント機能のインタフェースHTTPClientを取得します。
 	GetHTTPClient() httpclient.HTTPClient
+	// GetStepFunctionsAccessor は、StepFunctionsのタスク連携機能のインタフェースStepFunctionsAccessorを取得します。
+	GetStepFunctionsAccessor() stepfunctions.StepFunctionsAccessor
 	// GetInterceptor は、集約例外ハンドリング機能のインターセプタのインタフェースHandlerInterceptorを取得します。
 	GetInterceptor() handler.HandlerInterceptor
 	// GetAPILambdaHandler は、APIトリガのオンラインAP実行制御機能のインタフェースAPILambdaHandlerを取得します。
@@ -63,6 +77,14 @@ type ApplicationContext interface {
 	GetAsyncLambdaHandler() *handler.AsyncLambdaHandler
 	// GetSimpleLambdaHandler は、その他トリガのAP実行制御機能のインタフェースSimpleLambdaHandlerを取得します。
 	GetSimpleLambdaHandler() *handler.SimpleLambdaHandler
+	// GetStepFunctionsTaskLambdaHandler は、StepFunctionsのタスクトリガのAP実行制御機能のインタフェースStepFunctionsTaskLambdaHandlerを取得します。
+	GetStepFunctionsTaskLambdaHandler() *handler.StepFunctionsTaskLambdaHandler
+	// GetOutboxRelayLambdaHandler は、DynamoDB Streamsトリガのアウトボックスのリレー実行制御機能のインタフェースOutboxRelayLambdaHandlerを取得します。
+	GetOutboxRelayLambdaHandler() *handler.OutboxRelayLambdaHandler
+	// GetSagaOrchestrator は、補償処理付きのステップの連なり（サーガ）を実行する機能のインタフェースSagaOrchestratorを取得します。
+	GetSagaOrchestrator() saga.SagaOrchestrator
+	// GetSagaRecoveryLambdaHandler は、定期実行トリガの中断したサーガの再開実行制御機能のインタフェースSagaRecoveryLambdaHandlerを取得します。
+	GetSagaRecoveryLambdaHandler() *handler.SagaRecoveryLambdaHandler
 	// GetValidationManager は、入力チェック機能のインタフェースValidationManagerを取得します。
 	GetValidationManager() validator.ValidationManager
 	// GetDateManager は、日付管理機能のインタフェースDateManagerを取得します。
@@ -89,67 +111,98 @@ func NewApplicationContext() ApplicationContext {
 	objectStorageAccessor := createObjectStorageAccessor(config, logger)
 	dynamoDBTransactionManager := createDynamoDBTransactionManager(logger, dynamodbAccessor, sqsAccessor, messageRegisterer)
 	dynamoDBTransactionManagerForDBOnly := createDynamoDBTransactionManagerForDBOnly(logger, dynamodbAccessor, messageRegisterer)
+	outboxItemRepository := createOutboxItemRepository(config, logger, dynamoDBTempalte)
+	outboxRegisterer := createOutboxRegisterer(config, idGenerator, outboxItemRepository)
+	dynamoDBTransactionManagerWithOutbox := createDynamoDBTransactionManagerWithOutbox(logger, dynamodbAccessor, messageRegisterer, outboxRegisterer)
+	outboxTemplate := createOutboxTemplate(logger, outboxRegisterer)
+	outboxRelay := createOutboxRelay(logger, config, dynamoDBTransactionManager, sqsAccessor, outboxRegisterer, outboxItemRepository)
 	rdbAccessor := createRDBAccessor()
-	rdbTransactionManager := rdb.NewTransactionManager(logger, config, rdbAccessor)
+	rdbConnectionPool := createRDBConnectionPool(logger, config)
+	rdbTransactionManager := rdb.NewTransactionManager(logger, rdbAccessor, rdbConnectionPool)
+	rdbTemplate := createRDBTemplate(logger, config, rdbAccessor, rdbConnectionPool)
 	documetDBAccessor := createDocumentDBAccessor(config, logger)
 	httpclient := createHTTPClient(config, logger)
+	stepFunctionsAccessor := createStepFunctionsAccessor(config, logger, messageSource)
 	interceptor := createHanderInterceptor(config, logger)
 	apiLambdaHandler := createAPILambdaHandler(config, logger, messageSource, apiResponseFormatter)
-	asyncLambdaHandler := createAsyncLambdaHandler(config, logger, queueMessageItemRepository)
+	asyncLambdaHandler := createAsyncLambdaHandler(config, logger, queueMessageItemRepository, sqsAccessor)
 	simpleLambdaHandler := createSimpleLambdaHandler(config, logger)
+	stepFunctionsTaskLambdaHandler := createStepFunctionsTaskLambdaHandler(config, logger, messageSource, stepFunctionsAccessor)
+	outboxRelayLambdaHandler := createOutboxRelayLambdaHandler(config, logger, outboxRelay)
+	sagaRepository := createSagaRepository(config, logger, dynamoDBTempalte)
+	sagaOrchestrator := createSagaOrchestrator(logger, config, idGenerator, dateManager, dynamoDBTransactionManager, sqsTemplate, sagaRepository)
+	sagaRecoveryLambdaHandler := createSagaRecoveryLambdaHandler(config, logger, sagaOrchestrator)
 	validationManager := createValidationManager(logger)
 	idempotencyRepository := createIdempotencyRepository(logger, dynamodbAccessor, dynamoDBTempalte, dateManager, config)
 	idempotencyManager := createIdempotencyManager(logger, dateManager, config, idempotencyRepository)
 
 	return &defaultApplicationContext{
-		id:                                  idGenerator,
-		config:                              config,
-		messageSource:                       messageSource,
-		logger:                              logger,
-		dateManager:                         dateManager,
-		dynamoDBAccessor:                    dynamodbAccessor,
-		dynamoDBTransactionManager:          dynamoDBTransactionManager,
-		dynamoDBTransactionManagerForDBOnly: dynamoDBTransactionManagerForDBOnly,
-		dynamodbTempalte:                    dynamoDBTempalte,
-		sqsAccessor:                         sqsAccessor,
-		sqsTemplate:                         sqsTemplate,
-		objectStorageAccessor:               objectStorageAccessor,
-		rdbAccessor:                         rdbAccessor,
-		rdbTransactionManager:               rdbTransactionManager,
-		documetDBAccessor:                   documetDBAccessor,
-		httpClient:                          httpclient,
-		interceptor:                         interceptor,
-		apiLambdaHandler:                    apiLambdaHandler,
-		asyncLambdaHandler:                  asyncLambdaHandler,
-		simpleLambdaHandler:                 simpleLambdaHandler,
-		validationManager:                   validationManager,
-		idempotencyManager:                  idempotencyManager,
+		id:                                   idGenerator,
+		config:                               config,
+		messageSource:                        messageSource,
+		logger:                               logger,
+		dateManager:                          dateManager,
+		dynamoDBAccessor:                     dynamodbAccessor,
+		dynamoDBTransactionManager:           dynamoDBTransactionManager,
+		dynamoDBTransactionManagerForDBOnly:  dynamoDBTransactionManagerForDBOnly,
+		dynamoDBTransactionManagerWithOutbox: dynamoDBTransactionManagerWithOutbox,
+		dynamodbTempalte:                     dynamoDBTempalte,
+		sqsAccessor:                          sqsAccessor,
+		sqsTemplate:                          sqsTemplate,
+		outboxTemplate:                       outboxTemplate,
+		objectStorageAccessor:                objectStorageAccessor,
+		rdbAccessor:                          rdbAccessor,
+		rdbConnectionPool:                    rdbConnectionPool,
+		rdbTemplate:                          rdbTemplate,
+		rdbTransactionManager:                rdbTransactionManager,
+		documetDBAccessor:                    documetDBAccessor,
+		httpClient:                           httpclient,
+		stepFunctionsAccessor:                stepFunctionsAccessor,
+		interceptor:                          interceptor,
+		apiLambdaHandler:                     apiLambdaHandler,
+		asyncLambdaHandler:                   asyncLambdaHandler,
+		simpleLambdaHandler:                  simpleLambdaHandler,
+		stepFunctionsTaskLambdaHandler:       stepFunctionsTaskLambdaHandler,
+		outboxRelayLambdaHandler:             outboxRelayLambdaHandler,
+		sagaOrchestrator:                     sagaOrchestrator,
+		sagaRecoveryLambdaHandler:            sagaRecoveryLambdaHandler,
+		validationManager:                    validationManager,
+		idempotencyManager:                   idempotencyManager,
 	}
 }
 
 type defaultApplicationContext struct {
-	id                                  id.IDGenerator
-	config                              config.Config
-	messageSource                       message.MessageSource
-	logger                              logging.Logger
-	dateManager                         date.DateManager
-	dynamoDBAccessor                    transaction.TransactionalDynamoDBAccessor
-	dynamoDBTransactionManager          transaction.TransactionManager
-	dynamoDBTransactionManagerForDBOnly transaction.TransactionManager
-	dynamodbTempalte                    transaction.TransactionalDynamoDBTemplate
-	sqsAccessor                         transaction.TransactionalSQSAccessor
-	sqsTemplate                         async.SQSTemplate
-	objectStorageAccessor               objectstorage.ObjectStorageAccessor
-	rdbAccessor                         rdb.RDBAccessor
-	rdbTransactionManager               rdb.TransactionManager
-	documetDBAccessor                   documentdb.DocumentDBAccessor
-	httpClient                          httpclient.HTTPClient
-	interceptor                         handler.HandlerInterceptor
-	apiLambdaHandler                    *handler.APILambdaHandler
-	asyncLambdaHandler                  *handler.AsyncLambdaHandler
-	simpleLambdaHandler                 *handler.SimpleLambdaHandler
-	validationManager                   validator.ValidationManager
-	idempotencyManager                  idempotency.IdempotencyManager
+	id                                   id.IDGenerator
+	config                               config.Config
+	messageSource                        message.MessageSource
+	logger                               logging.Logger
+	dateManager                          date.DateManager
+	dynamoDBAccessor                     transaction.TransactionalDynamoDBAccessor
+	dynamoDBTransactionManager           transaction.TransactionManager
+	dynamoDBTransactionManagerForDBOnly  transaction.TransactionManager
+	dynamoDBTransactionManagerWithOutbox transaction.TransactionManager
+	dynamodbTempalte                     transaction.TransactionalDynamoDBTemplate
+	sqsAccessor                          transaction.TransactionalSQSAccessor
+	sqsTemplate                          async.SQSTemplate
+	outboxTemplate                       transaction.OutboxTemplate
+	objectStorageAccessor                objectstorage.ObjectStorageAccessor
+	rdbAccessor                          rdb.RDBAccessor
+	rdbConnectionPool                    rdb.RDBConnectionPool
+	rdbTemplate                          rdb.RDBTemplate
+	rdbTransactionManager                rdb.TransactionManager
+	documetDBAccessor                    documentdb.DocumentDBAccessor
+	httpClient                           httpclient.HTTPClient
+	stepFunctionsAccessor                stepfunctions.StepFunctionsAccessor
+	interceptor                          handler.HandlerInterceptor
+	apiLambdaHandler                     *handler.APILambdaHandler
+	asyncLambdaHandler                   *handler.AsyncLambdaHandler
+	simpleLambdaHandler                  *handler.SimpleLambdaHandler
+	stepFunctionsTaskLambdaHandler       *handler.StepFunctionsTaskLambdaHandler
+	outboxRelayLambdaHandler             *handler.OutboxRelayLambdaHandler
+	sagaOrchestrator                     saga.SagaOrchestrator
+	sagaRecoveryLambdaHandler            *handler.SagaRecoveryLambdaHandler
+	validationManager                    validator.ValidationManager
+	idempotencyManager                   idempotency.IdempotencyManager
 }
 
 // GetIDGenerator implements ApplicationContext.
@@ -192,6 +245,11 @@ func (ac *defaultApplicationContext) GetDynamoDBTransactionManagerForDBOnly() tr
 	return ac.dynamoDBTransactionManagerForDBOnly
 }
 
+// GetDynamoDBTransactionManagerWithOutbox implements ApplicationContext.
+func (ac *defaultApplicationContext) GetDynamoDBTransactionManagerWithOutbox() transaction.TransactionManager {
+	return ac.dynamoDBTransactionManagerWithOutbox
+}
+
 // GetDynamoDBTemplate implements ApplicationContext.
 func (ac *defaultApplicationContext) GetDynamoDBTemplate() transaction.TransactionalDynamoDBTemplate {
 	return ac.dynamodbTempalte
@@ -202,6 +260,16 @@ func (ac *defaultApplicationContext) GetRDBAccessor() rdb.RDBAccessor {
 	return ac.rdbAccessor
 }
 
+// GetRDBConnectionPool implements ApplicationContext.
+func (ac *defaultApplicationContext) GetRDBConnectionPool() rdb.RDBConnectionPool {
+	return ac.rdbConnectionPool
+}
+
+// GetRDBTemplate implements ApplicationContext.
+func (ac *defaultApplicationContext) GetRDBTemplate() rdb.RDBTemplate {
+	return ac.rdbTemplate
+}
+
 // GetRDBTransactionManager implements ApplicationContext.
 func (ac *defaultApplicationContext) GetRDBTransactionManager() rdb.TransactionManager {
 	return ac.rdbTransactionManager
@@ -217,6 +285,11 @@ func (ac *defaultApplicationContext) GetSQSTemplate() async.SQSTemplate {
 	return ac.sqsTemplate
 }
 
+// GetOutboxTemplate implements ApplicationContext.
+func (ac *defaultApplicationContext) GetOutboxTemplate() transaction.OutboxTemplate {
+	return ac.outboxTemplate
+}
+
 // GetObjectStorageAccessor implements ApplicationContext.
 func (ac *defaultApplicationContext) GetObjectStorageAccessor() objectstorage.ObjectStorageAccessor {
 	return ac.objectStorageAccessor
@@ -232,6 +305,11 @@ func (ac *defaultApplicationContext) GetHTTPClient() httpclient.HTTPClient {
 	return ac.httpClient
 }
 
+// GetStepFunctionsAccessor implements ApplicationContext.
+func (ac *defaultApplicationContext) GetStepFunctionsAccessor() stepfunctions.StepFunctionsAccessor {
+	return ac.stepFunctionsAccessor
+}
+
 // GetInterceptor implements ApplicationContext.
 func (ac *defaultApplicationContext) GetInterceptor() handler.HandlerInterceptor {
 	return ac.interceptor
@@ -252,6 +330,26 @@ func (ac *defaultApplicationContext) GetSimpleLambdaHandler() *handler.SimpleLam
 	return ac.simpleLambdaHandler
 }
 
+// GetStepFunctionsTaskLambdaHandler implements ApplicationContext.
+func (ac *defaultApplicationContext) GetStepFunctionsTaskLambdaHandler() *handler.StepFunctionsTaskLambdaHandler {
+	return ac.stepFunctionsTaskLambdaHandler
+}
+
+// GetOutboxRelayLambdaHandler implements ApplicationContext.
+func (ac *defaultApplicationContext) GetOutboxRelayLambdaHandler() *handler.OutboxRelayLambdaHandler {
+	return ac.outboxRelayLambdaHandler
+}
+
+// GetSagaOrchestrator implements ApplicationContext.
+func (ac *defaultApplicationContext) GetSagaOrchestrator() saga.SagaOrchestrator {
+	return ac.sagaOrchestrator
+}
+
+// GetSagaRecoveryLambdaHandler implements ApplicationContext.
+func (ac *defaultApplicationContext) GetSagaRecoveryLambdaHandler() *handler.SagaRecoveryLambdaHandler {
+	return ac.sagaRecoveryLambdaHandler
+}
+
 // GetValidationManager implements ApplicationContext.
 func (ac *defaultApplicationContext) GetValidationManager() validator.ValidationManager {
 	return ac.validationManager
@@ -332,6 +430,30 @@ func createDynamoDBTransactionManagerForDBOnly(logger logging.Logger,
 	return transaction.NewTransactionManagerForDBOnly(logger, dynamodbAccessor, messageRegigsterer)
 }
 
+func createDynamoDBTransactionManagerWithOutbox(logger logging.Logger,
+	dynamodbAccessor transaction.TransactionalDynamoDBAccessor,
+	messageRegigsterer transaction.MessageRegisterer,
+	outboxRegisterer transaction.OutboxRegisterer) transaction.TransactionManager {
+	return transaction.NewTransactionManagerWithOutbox(logger, dynamodbAccessor, messageRegigsterer, outboxRegisterer)
+}
+
+func createOutboxTemplate(logger logging.Logger, outboxRegisterer transaction.OutboxRegisterer) transaction.OutboxTemplate {
+	return transaction.NewOutboxTemplate(logger, outboxRegisterer)
+}
+
+func createOutboxRelay(logger logging.Logger, config config.Config,
+	transactionManager transaction.TransactionManager,
+	sqsAccessor transaction.TransactionalSQSAccessor,
+	outboxRegisterer transaction.OutboxRegisterer,
+	outboxItemRepository transaction.OutboxItemRepository) outbox.OutboxRelay {
+	relay, err := outbox.NewOutboxRelay(logger, config, transactionManager, sqsAccessor, outboxRegisterer, outboxItemRepository)
+	if err != nil {
+		// 異常終了
+		panic(err)
+	}
+	return relay
+}
+
 func createDynamoDBTemplate(logger logging.Logger, dynamodbAccessor transaction.TransactionalDynamoDBAccessor) transaction.TransactionalDynamoDBTemplate {
 	return transaction.NewTransactionalDynamoDBTemplate(logger, dynamodbAccessor)
 }
@@ -349,6 +471,20 @@ func createRDBAccessor() rdb.RDBAccessor {
 	return rdb.NewRDBAccessor()
 }
 
+func createRDBConnectionPool(logger logging.Logger, config config.Config) rdb.RDBConnectionPool {
+	// RDB_DRIVERの設定に応じたRDBの種類
+	dialect, err := rdb.NewDialect(config.Get(rdb.RDB_DRIVER_NAME, rdb.RDB_DRIVER_POSTGRES))
+	if err != nil {
+		// 異常終了
+		panic(err)
+	}
+	return rdb.NewRDBConnectionPool(logger, config, dialect)
+}
+
+func createRDBTemplate(logger logging.Logger, config config.Config, rdbAccessor rdb.RDBAccessor, connectionPool rdb.RDBConnectionPool) rdb.RDBTemplate {
+	return rdb.NewRDBTemplate(logger, config, rdbAccessor, connectionPool)
+}
+
 func createDocumentDBAccessor(config config.Config, logger logging.Logger) documentdb.DocumentDBAccessor {
 	accessor, err := documentdb.NewDocumentDBAccessor(config, logger)
 	if err != nil {
@@ -362,6 +498,15 @@ func createHTTPClient(config config.Config, logger logging.Logger) httpclient.HT
 	return httpclient.NewHTTPClient(config, logger)
 }
 
+func createStepFunctionsAccessor(config config.Config, logger logging.Logger, messageSource message.MessageSource) stepfunctions.StepFunctionsAccessor {
+	accessor, err := stepfunctions.NewStepFunctionsAccessor(logger, config, messageSource)
+	if err != nil {
+		// 異常終了
+		panic(err)
+	}
+	return accessor
+}
+
 func createHanderInterceptor(config config.Config, logger logging.Logger) handler.HandlerInterceptor {
 	return handler.NewHandlerInterceptor(config, logger)
 }
@@ -374,14 +519,18 @@ func createAPILambdaHandler(config config.Config, logger logging.Logger, message
 	return handler.NewAPILambdaHandler(config, logger, messageSource, apiResponseFormatter)
 }
 
-func createAsyncLambdaHandler(config config.Config, logger logging.Logger, queueMessageItemRepository transaction.QueueMessageItemRepository) *handler.AsyncLambdaHandler {
-	return handler.NewAsyncLambdaHandler(config, logger, queueMessageItemRepository)
+func createAsyncLambdaHandler(config config.Config, logger logging.Logger, queueMessageItemRepository transaction.QueueMessageItemRepository, sqsAccessor async.SQSAccessor) *handler.AsyncLambdaHandler {
+	return handler.NewAsyncLambdaHandler(config, logger, queueMessageItemRepository, sqsAccessor)
 }
 
 func createSimpleLambdaHandler(config config.Config, logger logging.Logger) *handler.SimpleLambdaHandler {
 	return handler.NewSimpleLambdaHandler(config, logger)
 }
 
+func createStepFunctionsTaskLambdaHandler(config config.Config, logger logging.Logger, messageSource message.MessageSource, stepFunctionsAccessor stepfunctions.StepFunctionsAccessor) *handler.StepFunctionsTaskLambdaHandler {
+	return handler.NewStepFunctionsTaskLambdaHandler(config, logger, messageSource, stepFunctionsAccessor)
+}
+
 func createQueueMessageItemRepository(config config.Config, logger logging.Logger, dynamodbTemplate transaction.TransactionalDynamoDBTemplate) transaction.QueueMessageItemRepository {
 	return transaction.NewQueueMessageItemRepository(config, logger, dynamodbTemplate)
 }
@@ -390,6 +539,31 @@ func createMessageRegisterer(queueMessageItemRepository transaction.QueueMessage
 	return transaction.NewMessageRegisterer(queueMessageItemRepository)
 }
 
+func createOutboxRelayLambdaHandler(config config.Config, logger logging.Logger, outboxRelay outbox.OutboxRelay) *handler.OutboxRelayLambdaHandler {
+	return handler.NewOutboxRelayLambdaHandler(config, logger, outboxRelay)
+}
+
+func createOutboxItemRepository(config config.Config, logger logging.Logger, dynamodbTemplate transaction.TransactionalDynamoDBTemplate) transaction.OutboxItemRepository {
+	return transaction.NewOutboxItemRepository(config, logger, dynamodbTemplate)
+}
+
+func createOutboxRegisterer(config config.Config, id id.IDGenerator, outboxItemRepository transaction.OutboxItemRepository) transaction.OutboxRegisterer {
+	return transaction.NewOutboxRegisterer(config, id, outboxItemRepository)
+}
+
+func createSagaRepository(config config.Config, logger logging.Logger, dynamodbTemplate transaction.TransactionalDynamoDBTemplate) saga.SagaRepository {
+	return saga.NewSagaRepository(config, logger, dynamodbTemplate)
+}
+
+func createSagaOrchestrator(logger logging.Logger, config config.Config, id id.IDGenerator, dateManager date.DateManager,
+	transactionManager transaction.TransactionManager, sqsTemplate async.SQSTemplate, sagaRepository saga.SagaRepository) saga.SagaOrchestrator {
+	return saga.NewSagaOrchestrator(logger, config, id, dateManager, transactionManager, sqsTemplate, sagaRepository)
+}
+
+func createSagaRecoveryLambdaHandler(config config.Config, logger logging.Logger, sagaOrchestrator saga.SagaOrchestrator) *handler.SagaRecoveryLambdaHandler {
+	return handler.NewSagaRecoveryLambdaHandler(config, logger, sagaOrchestrator)
+}
+
 func createValidationManager(logger logging.Logger) validator.ValidationManager {
 	return validator.NewValidationManager(logger.Debug, logger.Warn)
 }
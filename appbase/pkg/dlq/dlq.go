@@ -0,0 +1,416 @@
+/*
+dlq パッケージは、SQSのデッドレターキュー（DLQ）のメッセージの確認、再送（Redrive）を行う機能を提供するパッケージです。
+*/
+package dlq
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+
+	"example.com/appbase/pkg/apcontext"
+	myConfig "example.com/appbase/pkg/config"
+	"example.com/appbase/pkg/constant"
+	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/message"
+	"example.com/appbase/pkg/transaction"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// DLQからメッセージを受信する際の可視性タイムアウト（秒）のプロパティ名
+	// 一覧取得では全メッセージの受信から出力まで、再送では受信したバッチ（最大10件）の再送の完了まで、
+	// 他の受信者から見えないよう十分な時間を設定すること
+	DLQ_VISIBILITY_TIMEOUT_SECONDS_NAME = "DLQ_VISIBILITY_TIMEOUT_SECONDS"
+	// DLQから1回の操作で受信するメッセージの最大件数のプロパティ名
+	DLQ_MAX_MESSAGES_NAME = "DLQ_MAX_MESSAGES"
+	// DLQ_RECEIVE_BATCH_MAX_COUNT は、ReceiveMessageで1回に受信できるメッセージの最大件数です。
+	DLQ_RECEIVE_BATCH_MAX_COUNT = 10
+)
+
+// キューメッセージ管理テーブルのステータスの表示用の値
+const (
+	// QUEUE_MESSAGE_STATUS_INCOMPLETE は、キューメッセージ管理テーブルにアイテムが存在し、未完了であることを表します。
+	QUEUE_MESSAGE_STATUS_INCOMPLETE = "incomplete"
+	// QUEUE_MESSAGE_STATUS_NOT_FOUND は、キューメッセージ管理テーブルにアイテムが存在しないことを表します。
+	QUEUE_MESSAGE_STATUS_NOT_FOUND = "not_found"
+	// QUEUE_MESSAGE_STATUS_UNKNOWN は、送信元のキュー名が指定されていない等で、ステータスを確認できないことを表します。
+	QUEUE_MESSAGE_STATUS_UNKNOWN = "unknown"
+)
+
+// Filter は、DLQのメッセージを絞り込む条件を表す構造体です。未指定の項目は条件としません。
+type Filter struct {
+	// MinAge は、送信からの経過時間がこの値以上のメッセージを対象とします。
+	MinAge time.Duration
+	// MaxAge は、送信からの経過時間がこの値以下のメッセージを対象とします。
+	MaxAge time.Duration
+	// Attributes は、メッセージ属性の値がすべて一致するメッセージを対象とします。
+	Attributes map[string]string
+	// MessageIds は、メッセージIDがいずれかに一致するメッセージを対象とします。
+	MessageIds []string
+}
+
+// Match は、DLQのメッセージが条件に一致するかを判定します。
+func (f *Filter) Match(msg *DeadLetterMessage, now time.Time) bool {
+	if f == nil {
+		return true
+	}
+	age := now.Sub(msg.SentTime)
+	if f.MinAge > 0 && age < f.MinAge {
+		return false
+	}
+	if f.MaxAge > 0 && age > f.MaxAge {
+		return false
+	}
+	for k, v := range f.Attributes {
+		if actual, ok := msg.Attributes[k]; !ok || actual != v {
+			return false
+		}
+	}
+	if len(f.MessageIds) > 0 && !slices.Contains(f.MessageIds, msg.MessageId) {
+		return false
+	}
+	return true
+}
+
+// DeadLetterMessage は、DLQのメッセージを表す構造体です。
+type DeadLetterMessage struct {
+	// MessageId は、メッセージIDです。
+	MessageId string `json:"messageId"`
+	// MessageGroupId は、FIFOキューの場合のメッセージグループIDです。
+	MessageGroupId string `json:"messageGroupId,omitempty"`
+	// SentTime は、メッセージの送信日時です。
+	SentTime time.Time `json:"sentTime"`
+	// ReceiveCount は、メッセージのおおよその受信回数です。
+	ReceiveCount int `json:"receiveCount"`
+	// Attributes は、文字列型のメッセージ属性です。
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Body は、メッセージ本文です。json形式の場合はデコードした値となります。
+	Body any `json:"body"`
+	// QueueMessageStatus は、送信元のキューのメッセージに対応するキューメッセージ管理テーブルのステータスです。
+	QueueMessageStatus string `json:"queueMessageStatus"`
+	// 受信したメッセージ
+	message types.Message
+}
+
+// DeadLetterQueueManager は、DLQのメッセージの確認、再送を行うインタフェースです。
+// メッセージの一覧取得のため、DLQの全メッセージをいったん受信するので、
+// 当該機能の利用中は、他の受信者がDLQのメッセージを受信できないことに注意してください。
+type DeadLetterQueueManager interface {
+	// List は、DLQのメッセージのうちfilterに一致するメッセージの一覧を取得します。
+	// sourceQueueNameを指定すると、送信元のキューのキューメッセージ管理テーブルのステータスも取得します。
+	List(dlqName string, sourceQueueName string, filter *Filter) ([]*DeadLetterMessage, error)
+	// ListWithContext は、goroutine向けに渡されたContextを利用して、DLQのメッセージのうちfilterに一致するメッセージの一覧を取得します。
+	ListWithContext(ctx context.Context, dlqName string, sourceQueueName string, filter *Filter) ([]*DeadLetterMessage, error)
+	// Export は、DLQのメッセージのうちfilterに一致するメッセージを、1行1メッセージのjson形式でwに出力します。出力件数を返却します。
+	Export(dlqName string, sourceQueueName string, filter *Filter, w io.Writer) (int, error)
+	// ExportWithContext は、goroutine向けに渡されたContextを利用して、
+	// DLQのメッセージのうちfilterに一致するメッセージを、1行1メッセージのjson形式でwに出力します。出力件数を返却します。
+	ExportWithContext(ctx context.Context, dlqName string, sourceQueueName string, filter *Filter, w io.Writer) (int, error)
+	// Redrive は、DLQのメッセージのうちfilterに一致するメッセージを送信元のキューに再送し、DLQから削除します。再送件数を返却します。
+	// 受信から再送までに可視性タイムアウトが経過しないよう、受信したバッチ（最大10件）ごとに再送します。
+	// 再送したメッセージは、キューメッセージ管理テーブルに再登録するため、AsyncLambdaHandlerで処理できます。
+	Redrive(dlqName string, sourceQueueName string, filter *Filter) (int, error)
+	// RedriveWithContext は、goroutine向けに渡されたContextを利用して、
+	// DLQのメッセージのうちfilterに一致するメッセージを送信元のキューに再送し、DLQから削除します。再送件数を返却します。
+	RedriveWithContext(ctx context.Context, dlqName string, sourceQueueName string, filter *Filter) (int, error)
+	// Purge は、DLQのメッセージをすべて削除します。
+	Purge(dlqName string) error
+	// PurgeWithContext は、goroutine向けに渡されたContextを利用して、DLQのメッセージをすべて削除します。
+	PurgeWithContext(ctx context.Context, dlqName string) error
+}
+
+// NewDeadLetterQueueManager は、DeadLetterQueueManagerを作成します。
+func NewDeadLetterQueueManager(logger logging.Logger,
+	config myConfig.Config,
+	sqsAccessor transaction.TransactionalSQSAccessor,
+	transactionManager transaction.TransactionManager,
+	queueMessageItemRepository transaction.QueueMessageItemRepository) DeadLetterQueueManager {
+	return &defaultDeadLetterQueueManager{
+		logger:                     logger,
+		sqsAccessor:                sqsAccessor,
+		transactionManager:         transactionManager,
+		queueMessageItemRepository: queueMessageItemRepository,
+		visibilityTimeout:          config.GetInt(DLQ_VISIBILITY_TIMEOUT_SECONDS_NAME, 300),
+		maxMessages:                config.GetInt(DLQ_MAX_MESSAGES_NAME, 1000),
+	}
+}
+
+// defaultDeadLetterQueueManager は、DeadLetterQueueManagerを実装する構造体です。
+type defaultDeadLetterQueueManager struct {
+	logger                     logging.Logger
+	sqsAccessor                transaction.TransactionalSQSAccessor
+	transactionManager         transaction.TransactionManager
+	queueMessageItemRepository transaction.QueueMessageItemRepository
+	visibilityTimeout          int
+	maxMessages                int
+}
+
+// List implements DeadLetterQueueManager.
+func (m *defaultDeadLetterQueueManager) List(dlqName string, sourceQueueName string, filter *Filter) ([]*DeadLetterMessage, error) {
+	return m.ListWithContext(apcontext.Context, dlqName, sourceQueueName, filter)
+}
+
+// ListWithContext implements DeadLetterQueueManager.
+func (m *defaultDeadLetterQueueManager) ListWithContext(ctx context.Context, dlqName string, sourceQueueName string, filter *Filter) ([]*DeadLetterMessage, error) {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	msgs, err := m.receiveAll(ctx, dlqName, sourceQueueName)
+	// 一覧取得では、メッセージを削除しないため、受信したメッセージはすぐに再度可視にする
+	defer m.release(ctx, dlqName, msgs)
+	if err != nil {
+		return nil, err
+	}
+	return m.filter(msgs, filter), nil
+}
+
+// Export implements DeadLetterQueueManager.
+func (m *defaultDeadLetterQueueManager) Export(dlqName string, sourceQueueName string, filter *Filter, w io.Writer) (int, error) {
+	return m.ExportWithContext(apcontext.Context, dlqName, sourceQueueName, filter, w)
+}
+
+// ExportWithContext implements DeadLetterQueueManager.
+func (m *defaultDeadLetterQueueManager) ExportWithContext(ctx context.Context, dlqName string, sourceQueueName string, filter *Filter, w io.Writer) (int, error) {
+	msgs, err := m.ListWithContext(ctx, dlqName, sourceQueueName, filter)
+	if err != nil {
+		return 0, err
+	}
+	encoder := json.NewEncoder(w)
+	for i, v := range msgs {
+		if err := encoder.Encode(v); err != nil {
+			return i, errors.WithStack(err)
+		}
+	}
+	return len(msgs), nil
+}
+
+// Redrive implements DeadLetterQueueManager.
+func (m *defaultDeadLetterQueueManager) Redrive(dlqName string, sourceQueueName string, filter *Filter) (int, error) {
+	return m.RedriveWithContext(apcontext.Context, dlqName, sourceQueueName, filter)
+}
+
+// RedriveWithContext implements DeadLetterQueueManager.
+func (m *defaultDeadLetterQueueManager) RedriveWithContext(ctx context.Context, dlqName string, sourceQueueName string, filter *Filter) (int, error) {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	if sourceQueueName == "" {
+		return 0, errors.New("再送先のキュー名が指定されていません")
+	}
+	// 再送対象外のメッセージは、再度受信しないよう、終了時にまとめて再度可視にする
+	// 可視性タイムアウトの経過後に再度受信した場合は、最新の受信ハンドルで可視にする
+	skipped := make(map[string]*DeadLetterMessage)
+	defer func() {
+		m.release(ctx, dlqName, slices.Collect(maps.Values(skipped)))
+	}()
+	var count, received int
+	for received < m.maxMessages {
+		msgs, err := m.receive(ctx, dlqName, sourceQueueName, min(DLQ_RECEIVE_BATCH_MAX_COUNT, m.maxMessages-received))
+		if err != nil {
+			m.release(ctx, dlqName, msgs)
+			return count, err
+		}
+		if len(msgs) == 0 {
+			break
+		}
+		received += len(msgs)
+		now := time.Now()
+		for i, v := range msgs {
+			if !filter.Match(v, now) {
+				skipped[v.MessageId] = v
+				continue
+			}
+			if err := m.redrive(ctx, dlqName, sourceQueueName, v); err != nil {
+				// 未処理のメッセージは、再度可視にする
+				m.release(ctx, dlqName, msgs[i:])
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Purge implements DeadLetterQueueManager.
+func (m *defaultDeadLetterQueueManager) Purge(dlqName string) error {
+	return m.PurgeWithContext(apcontext.Context, dlqName)
+}
+
+// PurgeWithContext implements DeadLetterQueueManager.
+func (m *defaultDeadLetterQueueManager) PurgeWithContext(ctx context.Context, dlqName string) error {
+	_, err := m.sqsAccessor.PurgeQueueSdkWithContext(ctx, dlqName)
+	return err
+}
+
+// receiveAll は、DLQのメッセージを最大件数まで受信します。
+func (m *defaultDeadLetterQueueManager) receiveAll(ctx context.Context, dlqName string, sourceQueueName string) ([]*DeadLetterMessage, error) {
+	var msgs []*DeadLetterMessage
+	for len(msgs) < m.maxMessages {
+		received, err := m.receive(ctx, dlqName, sourceQueueName, min(DLQ_RECEIVE_BATCH_MAX_COUNT, m.maxMessages-len(msgs)))
+		msgs = append(msgs, received...)
+		if err != nil {
+			return msgs, err
+		}
+		if len(received) == 0 {
+			break
+		}
+	}
+	return msgs, nil
+}
+
+// receive は、DLQのメッセージを1回のReceiveMessageで最大maxNumberOfMessages件受信します。
+// エラーの場合も、それまでに作成したメッセージを返却するため、呼び出し元で再度可視にしてください。
+func (m *defaultDeadLetterQueueManager) receive(ctx context.Context, dlqName string, sourceQueueName string, maxNumberOfMessages int) ([]*DeadLetterMessage, error) {
+	output, err := m.sqsAccessor.ReceiveMessageSdkWithContext(ctx, dlqName, &sqs.ReceiveMessageInput{
+		MaxNumberOfMessages:         int32(maxNumberOfMessages),
+		VisibilityTimeout:           int32(m.visibilityTimeout),
+		WaitTimeSeconds:             1,
+		MessageAttributeNames:       []string{"All"},
+		MessageSystemAttributeNames: []types.MessageSystemAttributeName{types.MessageSystemAttributeNameAll},
+	})
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]*DeadLetterMessage, 0, len(output.Messages))
+	for i, v := range output.Messages {
+		msg, err := m.newDeadLetterMessage(sourceQueueName, v)
+		if err != nil {
+			// 受信済で未処理のメッセージが可視性タイムアウトまで見えなくならないよう、再度可視にする
+			unprocessed := make([]*DeadLetterMessage, 0, len(output.Messages)-i)
+			for _, u := range output.Messages[i:] {
+				unprocessed = append(unprocessed, &DeadLetterMessage{MessageId: aws.ToString(u.MessageId), message: u})
+			}
+			m.release(ctx, dlqName, unprocessed)
+			return msgs, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// newDeadLetterMessage は、受信したメッセージからDeadLetterMessageを作成します。
+func (m *defaultDeadLetterQueueManager) newDeadLetterMessage(sourceQueueName string, v types.Message) (*DeadLetterMessage, error) {
+	msg := &DeadLetterMessage{
+		MessageId:          aws.ToString(v.MessageId),
+		MessageGroupId:     v.Attributes[string(types.MessageSystemAttributeNameMessageGroupId)],
+		Attributes:         make(map[string]string),
+		QueueMessageStatus: QUEUE_MESSAGE_STATUS_UNKNOWN,
+		message:            v,
+	}
+	if sentTimestamp, err := strconv.ParseInt(v.Attributes[string(types.MessageSystemAttributeNameSentTimestamp)], 10, 64); err == nil {
+		msg.SentTime = time.UnixMilli(sentTimestamp)
+	}
+	msg.ReceiveCount, _ = strconv.Atoi(v.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)])
+	for k, attr := range v.MessageAttributes {
+		if attr.StringValue != nil {
+			msg.Attributes[k] = *attr.StringValue
+		}
+	}
+	// メッセージ本文がjson形式の場合はデコードする
+	body := aws.ToString(v.Body)
+	var decoded any
+	if err := json.Unmarshal([]byte(body), &decoded); err == nil {
+		msg.Body = decoded
+	} else {
+		msg.Body = body
+	}
+
+	if sourceQueueName == "" {
+		return msg, nil
+	}
+	deleteTimeStr, ok := msg.Attributes[constant.QUEUE_MESSAGE_DELETE_TIME_NAME]
+	if !ok {
+		msg.QueueMessageStatus = QUEUE_MESSAGE_STATUS_NOT_FOUND
+		return msg, nil
+	}
+	deleteTime, err := strconv.Atoi(deleteTimeStr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// DLQへ移動したメッセージも、メッセージIDは変わらないため、送信元のキュー名とメッセージIDで検索する
+	queueMessageItem, err := m.queueMessageItemRepository.FindOne(sourceQueueName+"_"+msg.MessageId, deleteTime)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case queueMessageItem.MessageId == "":
+		msg.QueueMessageStatus = QUEUE_MESSAGE_STATUS_NOT_FOUND
+	case queueMessageItem.Status == "":
+		msg.QueueMessageStatus = QUEUE_MESSAGE_STATUS_INCOMPLETE
+	default:
+		msg.QueueMessageStatus = queueMessageItem.Status
+	}
+	return msg, nil
+}
+
+// filter は、条件に一致するメッセージを抽出します。
+func (m *defaultDeadLetterQueueManager) filter(msgs []*DeadLetterMessage, filter *Filter) []*DeadLetterMessage {
+	now := time.Now()
+	var result []*DeadLetterMessage
+	for _, v := range msgs {
+		if filter.Match(v, now) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// redrive は、メッセージを送信元のキューに再送し、DLQから削除します。
+func (m *defaultDeadLetterQueueManager) redrive(ctx context.Context, dlqName string, sourceQueueName string, msg *DeadLetterMessage) error {
+	// 削除時間は再送時に改めて設定されるため除外し、その他のメッセージ属性を引き継ぐ
+	attributes := maps.Clone(msg.message.MessageAttributes)
+	delete(attributes, constant.QUEUE_MESSAGE_DELETE_TIME_NAME)
+	input := &sqs.SendMessageInput{
+		MessageBody:       msg.message.Body,
+		MessageAttributes: attributes,
+	}
+	if msg.MessageGroupId != "" {
+		input.MessageGroupId = aws.String(msg.MessageGroupId)
+		// 再送の二重実行でメッセージが重複しないよう、もとのメッセージIDを重複排除IDとする
+		input.MessageDeduplicationId = aws.String(msg.MessageId)
+	}
+	// 終了時に、コンテキスト領域を呼び出し元のContextに戻す
+	outerCtx := apcontext.Context
+	defer func() {
+		apcontext.Context = outerCtx
+	}()
+	// TransactionalSQSAccessorを利用して送信し、キューメッセージ管理テーブルへ再登録する
+	_, err := m.transactionManager.ExecuteTransactionWithContext(ctx, func(ctxWithTx context.Context) (any, error) {
+		// キューメッセージ管理テーブルへの登録は、コンテキスト領域のトランザクションを利用するため格納しておく
+		apcontext.Context = ctxWithTx
+		return nil, m.sqsAccessor.AppendTransactMessageWithContext(ctxWithTx, sourceQueueName, input)
+	})
+	if err != nil {
+		return err
+	}
+	_, err = m.sqsAccessor.DeleteMessageSdkWithContext(ctx, dlqName, &sqs.DeleteMessageInput{
+		ReceiptHandle: msg.message.ReceiptHandle,
+	})
+	if err != nil {
+		return err
+	}
+	m.logger.Info(message.I_FW_0011, dlqName, msg.MessageId, sourceQueueName)
+	return nil
+}
+
+// release は、受信したメッセージの可視性タイムアウトを0にし、再度可視にします。
+func (m *defaultDeadLetterQueueManager) release(ctx context.Context, dlqName string, msgs []*DeadLetterMessage) {
+	for _, v := range msgs {
+		_, err := m.sqsAccessor.ChangeMessageVisibilitySdkWithContext(ctx, dlqName, &sqs.ChangeMessageVisibilityInput{
+			ReceiptHandle:     v.message.ReceiptHandle,
+			VisibilityTimeout: 0,
+		})
+		if err != nil {
+			// 可視性タイムアウト経過後には可視になるため、警告ログ出力のみとする
+			m.logger.WarnWithError(err, message.W_FW_8017, dlqName, v.MessageId)
+		}
+	}
+}
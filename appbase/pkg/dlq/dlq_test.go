@@ -0,0 +1,174 @@
+package dlq
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"example.com/appbase/pkg/config"
+	"example.com/appbase/pkg/constant"
+	"example.com/appbase/pkg/domain"
+	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/message"
+	"example.com/appbase/pkg/transaction"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// sqsStub は、受信、再送、削除、可視性タイムアウトの変更を記録するTransactionalSQSAccessorです。
+// 再度可視にしたメッセージは、同じ実行の中では再度受信しないものとします。
+type sqsStub struct {
+	transaction.TransactionalSQSAccessor
+	visible []types.Message
+	// 操作の記録（receive、send:メッセージID）
+	events   []string
+	deleted  []string
+	released []string
+	// 再送に失敗するメッセージ本文
+	failBody string
+}
+
+func (s *sqsStub) ReceiveMessageSdkWithContext(ctx context.Context, queueName string, input *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	n := min(len(s.visible), int(input.MaxNumberOfMessages))
+	received := s.visible[:n]
+	s.visible = s.visible[n:]
+	s.events = append(s.events, "receive")
+	return &sqs.ReceiveMessageOutput{Messages: received}, nil
+}
+
+func (s *sqsStub) AppendTransactMessageWithContext(ctx context.Context, queueName string, input *sqs.SendMessageInput) error {
+	if aws.ToString(input.MessageBody) == s.failBody {
+		return errors.New("send error")
+	}
+	s.events = append(s.events, "send:"+aws.ToString(input.MessageBody))
+	return nil
+}
+
+func (s *sqsStub) DeleteMessageSdkWithContext(ctx context.Context, queueName string, input *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	s.deleted = append(s.deleted, aws.ToString(input.ReceiptHandle))
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (s *sqsStub) ChangeMessageVisibilitySdkWithContext(ctx context.Context, queueName string, input *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	s.released = append(s.released, aws.ToString(input.ReceiptHandle))
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+// transactionManagerStub は、業務処理をそのまま実行するTransactionManagerです。
+type transactionManagerStub struct {
+	transaction.TransactionManager
+}
+
+func (tm *transactionManagerStub) ExecuteTransactionWithContext(ctx context.Context, serviceFunc domain.ServiceFuncWithContext, opts ...transaction.Option) (any, error) {
+	return serviceFunc(ctx)
+}
+
+// newMessage は、メッセージIDを本文とし、受信ハンドルを「rh-メッセージID」とするメッセージを作成します。
+func newMessage(id string, attrs map[string]string) types.Message {
+	msg := types.Message{
+		MessageId:     aws.String(id),
+		ReceiptHandle: aws.String("rh-" + id),
+		Body:          aws.String(id),
+		Attributes: map[string]string{
+			string(types.MessageSystemAttributeNameSentTimestamp): strconv.FormatInt(time.Now().UnixMilli(), 10),
+		},
+		MessageAttributes: make(map[string]types.MessageAttributeValue),
+	}
+	for k, v := range attrs {
+		msg.MessageAttributes[k] = types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(v)}
+	}
+	return msg
+}
+
+func newTestManager(t *testing.T, stub *sqsStub) DeadLetterQueueManager {
+	msg, err := message.NewMessageSource()
+	assert.NoError(t, err)
+	logger, err := logging.NewLogger(msg)
+	assert.NoError(t, err)
+	return NewDeadLetterQueueManager(logger, config.NewTestConfig(map[string]string{}), stub, &transactionManagerStub{}, nil)
+}
+
+func TestFilter_Match(t *testing.T) {
+	now := time.Now()
+	msg := &DeadLetterMessage{
+		MessageId:  "m1",
+		SentTime:   now.Add(-2 * time.Hour),
+		Attributes: map[string]string{"type": "order"},
+	}
+	tests := []struct {
+		name     string
+		filter   *Filter
+		expected bool
+	}{
+		{name: "条件なし", filter: nil, expected: true},
+		{name: "経過時間が下限以上", filter: &Filter{MinAge: time.Hour}, expected: true},
+		{name: "経過時間が下限未満", filter: &Filter{MinAge: 3 * time.Hour}, expected: false},
+		{name: "経過時間が上限超過", filter: &Filter{MaxAge: time.Hour}, expected: false},
+		{name: "属性が一致", filter: &Filter{Attributes: map[string]string{"type": "order"}}, expected: true},
+		{name: "属性が不一致", filter: &Filter{Attributes: map[string]string{"type": "user"}}, expected: false},
+		{name: "属性がない", filter: &Filter{Attributes: map[string]string{"tenant": "a"}}, expected: false},
+		{name: "メッセージIDが一致", filter: &Filter{MessageIds: []string{"m0", "m1"}}, expected: true},
+		{name: "メッセージIDが不一致", filter: &Filter{MessageIds: []string{"m0"}}, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.filter.Match(msg, now))
+		})
+	}
+}
+
+func TestRedriveWithContext(t *testing.T) {
+	stub := &sqsStub{}
+	for i := range 12 {
+		attrs := map[string]string{"type": "order"}
+		if i == 2 {
+			attrs["type"] = "user"
+		}
+		stub.visible = append(stub.visible, newMessage("m"+strconv.Itoa(i), attrs))
+	}
+	manager := newTestManager(t, stub)
+
+	count, err := manager.RedriveWithContext(context.Background(), "test-dlq", "test-queue", &Filter{Attributes: map[string]string{"type": "order"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 11, count)
+	assert.Len(t, stub.deleted, 11)
+	// 受信したバッチ（10件）を再送してから、次のバッチを受信する
+	assert.Equal(t, "receive", stub.events[0])
+	assert.Equal(t, "receive", stub.events[10])
+	assert.Equal(t, []string{"send:m10", "send:m11", "receive"}, stub.events[11:])
+	// 再送対象外のメッセージは、終了時に再度可視にする
+	assert.Equal(t, []string{"rh-m2"}, stub.released)
+}
+
+func TestRedriveWithContext_Error(t *testing.T) {
+	stub := &sqsStub{failBody: "m1"}
+	stub.visible = []types.Message{newMessage("m0", nil), newMessage("m1", nil), newMessage("m2", nil)}
+	manager := newTestManager(t, stub)
+
+	count, err := manager.RedriveWithContext(context.Background(), "test-dlq", "test-queue", nil)
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []string{"rh-m0"}, stub.deleted)
+	// 再送に失敗したメッセージ以降の未処理のメッセージは、再度可視にする
+	assert.Equal(t, []string{"rh-m1", "rh-m2"}, stub.released)
+}
+
+func TestListWithContext_DecodeError(t *testing.T) {
+	invalid := newMessage("m1", map[string]string{constant.QUEUE_MESSAGE_DELETE_TIME_NAME: "invalid"})
+	stub := &sqsStub{visible: []types.Message{newMessage("m0", nil), invalid, newMessage("m2", nil)}}
+	manager := newTestManager(t, stub)
+
+	msgs, err := manager.ListWithContext(context.Background(), "test-dlq", "test-queue", nil)
+
+	assert.Error(t, err)
+	assert.Nil(t, msgs)
+	// 作成に失敗したメッセージ以降と、作成済のメッセージの全てを再度可視にする
+	assert.ElementsMatch(t, []string{"rh-m0", "rh-m1", "rh-m2"}, stub.released)
+	assert.Empty(t, stub.deleted)
+}
@@ -71,6 +71,14 @@ type DynamoDBAccessor interface {
 	QueryPagesSdk(input *dynamodb.QueryInput, fn func(*dynamodb.QueryOutput) bool, optFns ...func(*dynamodb.Options)) error
 	// QueryPagesSdkWithContext は、AWS SDKによるQueryのページング処理をラップします。goroutine向けに、渡されたContextを利用して実行します。
 	QueryPagesSdkWithContext(ctx context.Context, input *dynamodb.QueryInput, fn func(*dynamodb.QueryOutput) bool, optFns ...func(*dynamodb.Options)) error
+	// ScanSdk は、AWS SDKによるScanをラップします。
+	ScanSdk(input *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	// ScanSdkWithContext は、AWS SDKによるScanをラップします。goroutine向けに、渡されたContextを利用して実行します。
+	ScanSdkWithContext(ctx context.Context, input *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	// ScanPagesSdk は、AWS SDKによるScanのページング処理をラップします。
+	ScanPagesSdk(input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput) bool, optFns ...func(*dynamodb.Options)) error
+	// ScanPagesSdkWithContext は、AWS SDKによるScanのページング処理をラップします。goroutine向けに、渡されたContextを利用して実行します。
+	ScanPagesSdkWithContext(ctx context.Context, input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput) bool, optFns ...func(*dynamodb.Options)) error
 	// PutItemSdk は、AWS SDKによるPutItemをラップします。
 	PutItemSdk(input *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
 	// PutItemSdkWithContext は、AWS SDKによるPutItemをラップします。goroutine向けに、渡されたContextを利用して実行します。
@@ -187,6 +195,59 @@ func (da *defaultDynamoDBAccessor) QueryPagesSdkWithContext(ctx context.Context,
 	return nil
 }
 
+// ScanSdk implements DynamoDBAccessor.
+func (da *defaultDynamoDBAccessor) ScanSdk(input *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	return da.ScanSdkWithContext(apcontext.Context, input, optFns...)
+}
+
+// ScanSdkWithContext implements DynamoDBAccessor.
+func (da *defaultDynamoDBAccessor) ScanSdkWithContext(ctx context.Context, input *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	if ReturnConsumedCapacity(da.config) {
+		input.ReturnConsumedCapacity = types.ReturnConsumedCapacityTotal
+	}
+	output, err := da.dynamodbClient.Scan(ctx, input, optFns...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if output.ConsumedCapacity != nil {
+		da.logger.Debug("Scan[%s]消費キャパシティユニット:%f", *output.ConsumedCapacity.TableName, *output.ConsumedCapacity.CapacityUnits)
+	}
+	return output, nil
+}
+
+// ScanPagesSdk implements DynamoDBAccessor.
+func (da *defaultDynamoDBAccessor) ScanPagesSdk(input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput) bool,
+	optFns ...func(*dynamodb.Options)) error {
+	return da.ScanPagesSdkWithContext(apcontext.Context, input, fn, optFns...)
+}
+
+// ScanPagesSdkWithContext implements DynamoDBAccessor.
+func (da *defaultDynamoDBAccessor) ScanPagesSdkWithContext(ctx context.Context, input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput) bool, optFns ...func(*dynamodb.Options)) error {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	if ReturnConsumedCapacity(da.config) {
+		input.ReturnConsumedCapacity = types.ReturnConsumedCapacityTotal
+	}
+	paginator := dynamodb.NewScanPaginator(da.dynamodbClient, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx, optFns...)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if page.ConsumedCapacity != nil {
+			da.logger.Debug("Scan[%s]消費キャパシティユニット:%f", *page.ConsumedCapacity.TableName, *page.ConsumedCapacity.CapacityUnits)
+		}
+		if fn(page) {
+			break
+		}
+	}
+	return nil
+}
+
 // PutItemSdk implements DynamoDBAccessor.
 func (da *defaultDynamoDBAccessor) PutItemSdk(input *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
 	return da.PutItemSdkWithContext(apcontext.Context, input, optFns...)
@@ -0,0 +1,193 @@
+/*
+dynamodb パッケージは、DynamoDBアクセスに関する機能を提供するパッケージです。
+*/
+package dynamodb
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+
+	"example.com/appbase/pkg/dynamodb/gsi"
+	"example.com/appbase/pkg/dynamodb/input"
+	"example.com/appbase/pkg/dynamodb/tables"
+	"github.com/cockroachdb/errors"
+)
+
+// エンティティのキー定義
+
+const (
+	// DYNAMODB_TAG_PARTITION_KEY は、テーブルのパーティションキーを表す構造体タグの値です。
+	// 例）ID string `dynamodbav:"todo_id" dynamodb:"pk"`
+	DYNAMODB_TAG_PARTITION_KEY = "pk"
+	// DYNAMODB_TAG_SORT_KEY は、テーブルのソートキーを表す構造体タグの値です。
+	DYNAMODB_TAG_SORT_KEY = "sk"
+	// DYNAMODB_TAG_GSI_PARTITION_KEY は、GSIのパーティションキーを表す構造体タグの値です。「gsi_pk=GSI名」の形式で指定します。
+	// 例）Status string `dynamodbav:"status" dynamodb:"gsi_pk=status-index"`
+	DYNAMODB_TAG_GSI_PARTITION_KEY = "gsi_pk"
+	// DYNAMODB_TAG_GSI_SORT_KEY は、GSIのソートキーを表す構造体タグの値です。「gsi_sk=GSI名」の形式で指定します。
+	DYNAMODB_TAG_GSI_SORT_KEY = "gsi_sk"
+)
+
+// EntityField は、エンティティの構造体のフィールドと、DynamoDBの属性の対応です。
+type EntityField struct {
+	// 属性名（dynamodbavタグの名前、省略時はフィールド名）
+	AttributeName string
+	// 構造体タグ（dynamodb）で指定された役割（pk、sk、version、gsi_pk=GSI名等）
+	Roles []string
+	// フィールドのインデックス（埋め込み構造体の場合は複数）
+	index []int
+}
+
+// Value は、エンティティ（構造体またはそのポインタ）のフィールドの値を返却します。
+func (f *EntityField) Value(entity any) any {
+	v := reflect.Indirect(reflect.ValueOf(entity))
+	return v.FieldByIndex(f.index).Interface()
+}
+
+// hasRole は、フィールドが指定した役割を持つかを判定します。
+func (f *EntityField) hasRole(role string) bool {
+	return slices.Contains(f.Roles, role)
+}
+
+// EntityMetadata は、エンティティの構造体タグから取得した、テーブルのキー等の定義です。
+type EntityMetadata struct {
+	// パーティションキー
+	PartitionKey *EntityField
+	// ソートキー（ない場合はnil）
+	SortKey *EntityField
+	// 楽観ロックのバージョン属性（ない場合はnil）
+	Version *EntityField
+	// GSIのキー
+	GSIKeys map[gsi.DynamoDBGSIName]*gsi.GSIKeyPair
+	// 全てのフィールド
+	Fields []*EntityField
+}
+
+// GetEntityMetadata は、エンティティの型の構造体タグ（dynamodb）から、テーブルのキー等の定義を取得します。
+// パーティションキー（dynamodb:"pk"）の指定は必須です。
+func GetEntityMetadata(entityType reflect.Type) (*EntityMetadata, error) {
+	for entityType.Kind() == reflect.Pointer {
+		entityType = entityType.Elem()
+	}
+	if entityType.Kind() != reflect.Struct {
+		return nil, errors.Errorf("エンティティが構造体ではありません: %s", entityType)
+	}
+	metadata := &EntityMetadata{
+		GSIKeys: make(map[gsi.DynamoDBGSIName]*gsi.GSIKeyPair),
+		Fields:  collectEntityFields(entityType, nil),
+	}
+	for _, f := range metadata.Fields {
+		for _, role := range f.Roles {
+			name, indexName, _ := strings.Cut(role, "=")
+			switch name {
+			case DYNAMODB_TAG_PARTITION_KEY:
+				metadata.PartitionKey = f
+			case DYNAMODB_TAG_SORT_KEY:
+				metadata.SortKey = f
+			case DYNAMODB_TAG_VERSION:
+				metadata.Version = f
+			case DYNAMODB_TAG_GSI_PARTITION_KEY, DYNAMODB_TAG_GSI_SORT_KEY:
+				if indexName == "" {
+					return nil, errors.Errorf("GSI名が指定されていません: %s.%s", entityType, f.AttributeName)
+				}
+				keyPair, ok := metadata.GSIKeys[gsi.DynamoDBGSIName(indexName)]
+				if !ok {
+					keyPair = &gsi.GSIKeyPair{}
+					metadata.GSIKeys[gsi.DynamoDBGSIName(indexName)] = keyPair
+				}
+				if name == DYNAMODB_TAG_GSI_PARTITION_KEY {
+					keyPair.PartitionKey = f.AttributeName
+				} else {
+					keyPair.SortKey = f.AttributeName
+				}
+			default:
+				return nil, errors.Errorf("構造体タグ（%s）の値が不正です: %s.%s", DYNAMODB_TAG_KEY, entityType, role)
+			}
+		}
+	}
+	if metadata.PartitionKey == nil {
+		return nil, errors.Errorf("パーティションキー（%s:\"%s\"）が指定されていません: %s", DYNAMODB_TAG_KEY, DYNAMODB_TAG_PARTITION_KEY, entityType)
+	}
+	for indexName, keyPair := range metadata.GSIKeys {
+		if keyPair.PartitionKey == "" {
+			return nil, errors.Errorf("GSI[%s]のパーティションキーが指定されていません: %s", indexName, entityType)
+		}
+	}
+	return metadata, nil
+}
+
+// collectEntityFields は、構造体のフィールド（埋め込み構造体を含む）を、attributevalueと同様の規則で属性と対応付けます。
+func collectEntityFields(t reflect.Type, parent []int) []*EntityField {
+	var fields []*EntityField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		index := append(append([]int{}, parent...), i)
+		name, _, _ := strings.Cut(sf.Tag.Get("dynamodbav"), ",")
+		if name == "-" {
+			continue
+		}
+		// 属性名の指定のない埋め込み構造体は、フィールドを展開する
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			fields = append(fields, collectEntityFields(sf.Type, index)...)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		var roles []string
+		if tag := sf.Tag.Get(DYNAMODB_TAG_KEY); tag != "" {
+			roles = strings.Split(tag, ",")
+		}
+		fields = append(fields, &EntityField{AttributeName: name, Roles: roles, index: index})
+	}
+	return fields
+}
+
+// PKKeyPair は、テーブルのプライマリキーの定義を返却します。
+func (m *EntityMetadata) PKKeyPair() *tables.PKKeyPair {
+	keyPair := &tables.PKKeyPair{PartitionKey: m.PartitionKey.AttributeName}
+	if m.SortKey != nil {
+		keyPair.SortKey = &m.SortKey.AttributeName
+	}
+	return keyPair
+}
+
+// Register は、テーブルのプライマリキー、GSIの定義を登録します（tables.SetPrimaryKey、gsi.AddGSIKeyPair）。
+func (m *EntityMetadata) Register(tableName tables.DynamoDBTableName) {
+	tables.SetPrimaryKey(tableName, m.PKKeyPair())
+	for indexName, keyPair := range m.GSIKeys {
+		gsi.AddGSIKeyPair(tableName, indexName, keyPair)
+	}
+}
+
+// PrimaryKey は、エンティティのキーの値から、プライマリキーの完全一致の条件を作成します。
+func (m *EntityMetadata) PrimaryKey(entity any) input.PrimaryKey {
+	primaryKey := input.PrimaryKey{
+		PartitionKey: input.Attribute{Name: m.PartitionKey.AttributeName, Value: m.PartitionKey.Value(entity)},
+	}
+	if m.SortKey != nil {
+		primaryKey.SortKey = &input.Attribute{Name: m.SortKey.AttributeName, Value: m.SortKey.Value(entity)}
+	}
+	return primaryKey
+}
+
+// IsUpdatable は、属性が、更新可能な属性（プライマリキー、楽観ロックのバージョン属性以外）かどうかを判定します。
+func (m *EntityMetadata) IsUpdatable(attributeName string) bool {
+	return attributeName != m.PartitionKey.AttributeName &&
+		(m.SortKey == nil || attributeName != m.SortKey.AttributeName) &&
+		(m.Version == nil || attributeName != m.Version.AttributeName)
+}
+
+// Field は、属性名に対応するフィールドを返却します。ない場合はnilを返却します。
+func (m *EntityMetadata) Field(attributeName string) *EntityField {
+	for _, f := range m.Fields {
+		if f.AttributeName == attributeName {
+			return f
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package dynamodb
+
+import (
+	"reflect"
+	"testing"
+
+	"example.com/appbase/pkg/dynamodb/gsi"
+	"github.com/stretchr/testify/assert"
+)
+
+type entityBase struct {
+	CreatedAt string `dynamodbav:"created_at"`
+	Version   int64  `dynamodbav:"version" dynamodb:"version"`
+}
+
+type taggedEntity struct {
+	entityBase
+	UserID   string `dynamodbav:"user_id" dynamodb:"pk"`
+	TodoID   string `dynamodbav:"todo_id" dynamodb:"sk,gsi_sk=status-index"`
+	Status   string `dynamodbav:"status,omitempty" dynamodb:"gsi_pk=status-index"`
+	Title    string
+	Ignored  string `dynamodbav:"-" dynamodb:"pk"`
+	internal string
+}
+
+func TestGetEntityMetadata(t *testing.T) {
+	metadata, err := GetEntityMetadata(reflect.TypeFor[*taggedEntity]())
+	assert.NoError(t, err)
+
+	assert.Equal(t, "user_id", metadata.PartitionKey.AttributeName)
+	assert.Equal(t, "todo_id", metadata.SortKey.AttributeName)
+	assert.Equal(t, "version", metadata.Version.AttributeName)
+	assert.Equal(t, map[gsi.DynamoDBGSIName]*gsi.GSIKeyPair{
+		"status-index": {PartitionKey: "status", SortKey: "todo_id"},
+	}, metadata.GSIKeys)
+
+	names := make([]string, 0, len(metadata.Fields))
+	for _, f := range metadata.Fields {
+		names = append(names, f.AttributeName)
+	}
+	// 埋め込み構造体のフィールドは展開し、「-」と非公開のフィールドは除く
+	assert.Equal(t, []string{"created_at", "version", "user_id", "todo_id", "status", "Title"}, names)
+
+	entity := &taggedEntity{UserID: "u1", TodoID: "t1", entityBase: entityBase{Version: 3}}
+	assert.Equal(t, "u1", metadata.PartitionKey.Value(entity))
+	assert.Equal(t, int64(3), metadata.Version.Value(*entity))
+	assert.False(t, metadata.IsUpdatable("user_id"))
+	assert.False(t, metadata.IsUpdatable("version"))
+	assert.True(t, metadata.IsUpdatable("status"))
+}
+
+func TestGetEntityMetadata_Error(t *testing.T) {
+	tests := []struct {
+		name       string
+		entityType reflect.Type
+	}{
+		{
+			name:       "構造体以外",
+			entityType: reflect.TypeFor[string](),
+		},
+		{
+			name: "パーティションキーなし",
+			entityType: reflect.TypeFor[struct {
+				ID string `dynamodbav:"id" dynamodb:"sk"`
+			}](),
+		},
+		{
+			name: "GSI名なし",
+			entityType: reflect.TypeFor[struct {
+				ID     string `dynamodbav:"id" dynamodb:"pk"`
+				Status string `dynamodbav:"status" dynamodb:"gsi_pk"`
+			}](),
+		},
+		{
+			name: "GSIのパーティションキーなし",
+			entityType: reflect.TypeFor[struct {
+				ID     string `dynamodbav:"id" dynamodb:"pk"`
+				Status string `dynamodbav:"status" dynamodb:"gsi_sk=status-index"`
+			}](),
+		},
+		{
+			name: "不正な値",
+			entityType: reflect.TypeFor[struct {
+				ID string `dynamodbav:"id" dynamodb:"pk,unknown"`
+			}](),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata, err := GetEntityMetadata(tt.entityType)
+			assert.Error(t, err)
+			assert.Nil(t, metadata)
+		})
+	}
+}
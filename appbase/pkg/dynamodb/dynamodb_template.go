@@ -5,29 +5,49 @@ package dynamodb
 
 import (
 	"context"
+	"reflect"
 	"strings"
 
 	"example.com/appbase/pkg/apcontext"
 	"example.com/appbase/pkg/dynamodb/input"
 	"example.com/appbase/pkg/dynamodb/tables"
 	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/retry"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/cockroachdb/errors"
 )
 
+const (
+	// BatchGetItemで1回に取得可能な項目数の上限
+	// https://docs.aws.amazon.com/ja_jp/amazondynamodb/latest/developerguide/ServiceQuotas.html#limits-api
+	BATCH_GET_ITEM_MAX_COUNT = 100
+	// BatchWriteItemで1回に登録、削除可能な項目数の上限
+	BATCH_WRITE_ITEM_MAX_COUNT = 25
+	// 一括処理で、分割したリクエストを並列実行する同時実行数の上限
+	BATCH_MAX_CONCURRENCY = 5
+	// 一括処理で、未処理の項目を再実行する最大のリトライ回数
+	BATCH_MAX_RETRY_TIMES = 5
+)
+
 var (
 	ErrRecordNotFound     = errors.New("対象レコードなし")
 	ErrKeyDuplicaiton     = errors.New("プライマリキー重複エラー")
 	ErrUpdateWithCondtion = errors.New("条件付き更新エラー")
 	ErrDeleteWithCondtion = errors.New("条件付き削除エラー")
+	ErrUnprocessedItems   = errors.New("一括処理の未処理項目残存エラー")
+
+	// スキャンのfnによる終了指示で、他のセグメントのスキャンを中断するためのエラー
+	errScanStopped = errors.New("スキャン終了")
 )
 
 // DynamoDBTemplate は、DynamoDBアクセスを定型化した高次のインタフェースです。
 type DynamoDBTemplate interface {
 	// CreateOne は、DynamoDBに項目を1件登録します。
+	// エンティティに楽観ロックのバージョン属性（dynamodb:"version"タグ）がある場合は、バージョンを1加算して登録します。
 	CreateOne(tableName tables.DynamoDBTableName, inputEntity any, optFns ...func(*dynamodb.Options)) error
 	// CreateOneWithContext は、goroutine向けに渡されたContextを利用して、DynamoDBに項目を1件登録します。
 	CreateOneWithContext(ctx context.Context, tableName tables.DynamoDBTableName, inputEntity any, optFns ...func(*dynamodb.Options)) error
@@ -43,16 +63,57 @@ type DynamoDBTemplate interface {
 	FindSomeByGSIKey(tableName tables.DynamoDBTableName, input input.GsiQueryInput, outEntities any, optFns ...func(*dynamodb.Options)) error
 	// FindSomeByGSIKeyWithContext は、goroutine向けに渡されたContextを利用して、GSIのプライマリキーによる条件でDynamoDBから項目を複数件取得します。
 	FindSomeByGSIKeyWithContext(ctx context.Context, tableName tables.DynamoDBTableName, input input.GsiQueryInput, outEntities any, optFns ...func(*dynamodb.Options)) error
+	// FindAllByScan は、テーブル（またはGSI）全体のスキャンにより、フィルタ条件に一致する全ての項目を取得します。
+	// input.TotalSegmentsを指定した場合は、セグメントごとに並列でスキャンします。
+	FindAllByScan(tableName tables.DynamoDBTableName, input input.ScanInput, outEntities any, optFns ...func(*dynamodb.Options)) error
+	// FindAllByScanWithContext は、goroutine向けに渡されたContextを利用して、テーブル（またはGSI）全体のスキャンにより、フィルタ条件に一致する全ての項目を取得します。
+	FindAllByScanWithContext(ctx context.Context, tableName tables.DynamoDBTableName, input input.ScanInput, outEntities any, optFns ...func(*dynamodb.Options)) error
+	// ScanPages は、テーブル（またはGSI）全体をスキャンし、取得したページごとにfnを呼び出します。
+	// 全件をメモリに保持せずに処理する、エクスポート等のバッチ処理で利用します。
+	ScanPages(tableName tables.DynamoDBTableName, input input.ScanInput, fn ScanPageFunc, optFns ...func(*dynamodb.Options)) error
+	// ScanPagesWithContext は、goroutine向けに渡されたContextを利用して、テーブル（またはGSI）全体をスキャンし、取得したページごとにfnを呼び出します。
+	ScanPagesWithContext(ctx context.Context, tableName tables.DynamoDBTableName, input input.ScanInput, fn ScanPageFunc, optFns ...func(*dynamodb.Options)) error
+	// FindManyByKeys は、プライマリキーの完全一致でDynamoDBから複数件の項目を一括取得します（BatchGetItem）。
+	// 100件ごとに分割して並列で取得し、未処理のキーはエクスポネンシャルバックオフでリトライします。
+	// 取得結果の順序は、プライマリキーの指定順と一致しません。重複したプライマリキーは1件として取得します。
+	// 1件も取得できない場合は、ErrRecordNotFoundを返却します。
+	FindManyByKeys(tableName tables.DynamoDBTableName, input input.BatchGetInput, outEntities any, optFns ...func(*dynamodb.Options)) error
+	// FindManyByKeysWithContext は、goroutine向けに渡されたContextを利用して、プライマリキーの完全一致でDynamoDBから複数件の項目を一括取得します。
+	FindManyByKeysWithContext(ctx context.Context, tableName tables.DynamoDBTableName, input input.BatchGetInput, outEntities any, optFns ...func(*dynamodb.Options)) error
+	// CreateMany は、DynamoDBに複数件の項目（エンティティのスライス）を一括登録します（BatchWriteItem）。
+	// 25件ごとに分割して並列で登録し、未処理の項目はエクスポネンシャルバックオフでリトライします。
+	// BatchWriteItemは条件を指定できないため、CreateOneと異なり、既存の項目がある場合は置き換え、楽観ロックも行いません。
+	// ただし、楽観ロックのバージョン属性がある場合は、CreateOneと同様にバージョンを1加算して登録し、登録後にエンティティのバージョンを更新します。
+	// エンティティのバージョンを更新するため、構造体のスライスか、構造体のポインタのスライスを指定してください。
+	// 同じプライマリキーの項目が複数ある場合は、最後の項目を登録します（テーブルのプライマリキーの定義が必要です）。
+	// リトライしても未処理の項目が残る場合は、ErrUnprocessedItemsを返却します。
+	CreateMany(tableName tables.DynamoDBTableName, inputEntities any, optFns ...func(*dynamodb.Options)) error
+	// CreateManyWithContext は、goroutine向けに渡されたContextを利用して、DynamoDBに複数件の項目を一括登録します。
+	CreateManyWithContext(ctx context.Context, tableName tables.DynamoDBTableName, inputEntities any, optFns ...func(*dynamodb.Options)) error
+	// DeleteMany は、プライマリキーの完全一致でDynamoDBの複数件の項目を一括削除します（BatchWriteItem）。
+	// 25件ごとに分割して並列で削除し、未処理の項目はエクスポネンシャルバックオフでリトライします。
+	// 重複したプライマリキーは1件として削除します。
+	// リトライしても未処理の項目が残る場合は、ErrUnprocessedItemsを返却します。
+	DeleteMany(tableName tables.DynamoDBTableName, primaryKeys []input.PrimaryKey, optFns ...func(*dynamodb.Options)) error
+	// DeleteManyWithContext は、goroutine向けに渡されたContextを利用して、DynamoDBの複数件の項目を一括削除します。
+	DeleteManyWithContext(ctx context.Context, tableName tables.DynamoDBTableName, primaryKeys []input.PrimaryKey, optFns ...func(*dynamodb.Options)) error
 	// UpdateOne は、DynamoDBの項目を更新します。
+	// input.VersionedEntityを指定した場合は楽観ロックを行い、バージョンが一致しない場合はOptimisticLockErrorを返却します。
 	UpdateOne(tableName tables.DynamoDBTableName, input input.UpdateInput, optFns ...func(*dynamodb.Options)) error
 	// UpdateOneWithContext は、goroutine向けに渡されたContextを利用して、DynamoDBの項目を更新します。
 	UpdateOneWithContext(ctx context.Context, tableName tables.DynamoDBTableName, input input.UpdateInput, optFns ...func(*dynamodb.Options)) error
 	// DeleteOne は、DynamoDBの項目を削除します。
+	// input.VersionedEntityを指定した場合は楽観ロックを行い、バージョンが一致しない場合はOptimisticLockErrorを返却します。
 	DeleteOne(tableName tables.DynamoDBTableName, input input.DeleteInput, optFns ...func(*dynamodb.Options)) error
 	// DeleteOneWithContext は、goroutine向けに渡されたContextを利用して、DynamoDBの項目を削除します。
 	DeleteOneWithContext(ctx context.Context, tableName tables.DynamoDBTableName, input input.DeleteInput, optFns ...func(*dynamodb.Options)) error
 }
 
+// ScanPageFunc は、スキャンで取得したページごとに呼び出される関数です。
+// segmentは並列スキャンのセグメント番号（並列スキャンしない場合は0）で、並列スキャンの場合は複数のgoroutineから同時に呼び出されます。
+// trueを返却すると全セグメントのスキャンを終了し、エラーを返却するとスキャンを中断してそのエラーを返却します。
+type ScanPageFunc func(segment int32, items []map[string]types.AttributeValue) (bool, error)
+
 // NewDynamoDBTemplate は、DynamoDBTemplateのインスタンスを生成します。
 func NewDynamoDBTemplate(logger logging.Logger, dynamodbAccessor DynamoDBAccessor) DynamoDBTemplate {
 	return &defaultDynamoDBTemplate{
@@ -78,6 +139,12 @@ func (t *defaultDynamoDBTemplate) CreateOneWithContext(ctx context.Context, tabl
 	if err != nil {
 		return errors.Wrap(err, "CreateOneで構造体をAttributeValueのMap変換時にエラー")
 	}
+	// 楽観ロックのバージョン属性の初期値
+	ver, err := GetVersionAttribute(inputEntity)
+	if err != nil {
+		return errors.Wrap(err, "CreateOneでバージョン属性の取得時エラー")
+	}
+	ver.SetNextVersion(attributes)
 	// パーティションキーの重複判定条件
 	partitonkeyName := tables.GetPrimaryKey(tableName).PartitionKey
 	conditionExpression := aws.String("attribute_not_exists(#partition_key)")
@@ -98,6 +165,7 @@ func (t *defaultDynamoDBTemplate) CreateOneWithContext(ctx context.Context, tabl
 		}
 		return errors.Wrap(err, "CreateOneで登録実行時エラー")
 	}
+	ver.Increment()
 	return nil
 }
 
@@ -258,6 +326,316 @@ func (t *defaultDynamoDBTemplate) FindSomeByGSIKeyWithContext(ctx context.Contex
 	return nil
 }
 
+// FindAllByScan implements DynamoDBTemplate.
+func (t *defaultDynamoDBTemplate) FindAllByScan(tableName tables.DynamoDBTableName, input input.ScanInput, outEntities any, optFns ...func(*dynamodb.Options)) error {
+	return t.FindAllByScanWithContext(apcontext.Context, tableName, input, outEntities, optFns...)
+}
+
+// FindAllByScanWithContext implements DynamoDBTemplate.
+func (t *defaultDynamoDBTemplate) FindAllByScanWithContext(ctx context.Context, tableName tables.DynamoDBTableName, input input.ScanInput, outEntities any, optFns ...func(*dynamodb.Options)) error {
+	// セグメントごとの検索結果（セグメントの順に結合するため、セグメントごとに保持する）
+	segmentItems := make([][]map[string]types.AttributeValue, max(input.TotalSegments, 1))
+	err := t.ScanPagesWithContext(ctx, tableName, input, func(segment int32, items []map[string]types.AttributeValue) (bool, error) {
+		segmentItems[segment] = append(segmentItems[segment], items...)
+		return false, nil
+	}, optFns...)
+	if err != nil {
+		return errors.Wrap(err, "FindAllByScanで検索時エラー")
+	}
+	// 最終的な検索結果
+	var resultItems []map[string]types.AttributeValue
+	for _, items := range segmentItems {
+		resultItems = append(resultItems, items...)
+	}
+	if len(resultItems) == 0 {
+		return ErrRecordNotFound
+	}
+	if err := attributevalue.UnmarshalListOfMaps(resultItems, &outEntities); err != nil {
+		return errors.Wrap(err, "FindAllByScanで検索結果を構造体にアンマーシャル時エラー")
+	}
+	return nil
+}
+
+// ScanPages implements DynamoDBTemplate.
+func (t *defaultDynamoDBTemplate) ScanPages(tableName tables.DynamoDBTableName, input input.ScanInput, fn ScanPageFunc, optFns ...func(*dynamodb.Options)) error {
+	return t.ScanPagesWithContext(apcontext.Context, tableName, input, fn, optFns...)
+}
+
+// ScanPagesWithContext implements DynamoDBTemplate.
+func (t *defaultDynamoDBTemplate) ScanPagesWithContext(ctx context.Context, tableName tables.DynamoDBTableName, input input.ScanInput, fn ScanPageFunc, optFns ...func(*dynamodb.Options)) error {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	// スキャン表現の作成
+	expr, err := CreateScanExpression(input)
+	if err != nil {
+		return errors.Wrap(err, "ScanPagesで検索条件生成時エラー")
+	}
+	// 消費RCUの上限による流量制御（全セグメントで共有）
+	limiter := newCapacityLimiter(input.RCULimitPerSecond)
+	// セグメントごとに並列でスキャン
+	totalSegments := max(input.TotalSegments, 1)
+	err = runConcurrently(ctx, int(totalSegments), input.MaxConcurrency, func(ctx context.Context, i int) error {
+		return t.scanSegment(ctx, tableName, input, expr, int32(i), totalSegments, limiter, fn, optFns...)
+	})
+	if errors.Is(err, errScanStopped) {
+		// fnによる終了指示の場合は、エラーとしない
+		return nil
+	}
+	return err
+}
+
+// scanSegment は、1つのセグメント（並列スキャンしない場合はテーブル全体）をスキャンします。
+// fnにより終了が指示された場合は、errScanStoppedを返却します。
+func (t *defaultDynamoDBTemplate) scanSegment(ctx context.Context, tableName tables.DynamoDBTableName, input input.ScanInput, expr *expression.Expression,
+	segment int32, totalSegments int32, limiter *capacityLimiter, fn ScanPageFunc, optFns ...func(*dynamodb.Options)) error {
+	scanInput := &dynamodb.ScanInput{
+		TableName:      aws.String(string(tableName)),
+		ConsistentRead: aws.Bool(input.ConsitentRead),
+		Limit:          input.LimitPerScan,
+	}
+	if expr != nil {
+		scanInput.ProjectionExpression = expr.Projection()
+		scanInput.ExpressionAttributeNames = expr.Names()
+		scanInput.ExpressionAttributeValues = expr.Values()
+		scanInput.FilterExpression = expr.Filter()
+	}
+	if input.GSIName != "" {
+		scanInput.IndexName = aws.String(string(input.GSIName))
+	}
+	if totalSegments > 1 {
+		scanInput.Segment = aws.Int32(segment)
+		scanInput.TotalSegments = aws.Int32(totalSegments)
+	}
+	if limiter != nil {
+		// 流量制御のため、消費キャパシティユニットを返却させる
+		scanInput.ReturnConsumedCapacity = types.ReturnConsumedCapacityTotal
+	}
+	var (
+		stop      bool
+		handleErr error
+		pagingCnt int
+	)
+	// ページングの処理
+	handleFn := func(result *dynamodb.ScanOutput) bool {
+		pagingCnt += 1
+		t.logger.Debug("セグメント: %d, ページング回数: %d, 今回取得件数: %d, 今回評価件数: %d", segment, pagingCnt, result.Count, result.ScannedCount)
+		stop, handleErr = fn(segment, result.Items)
+		if stop || handleErr != nil {
+			return true
+		}
+		// 消費RCUの上限を超えないよう待機
+		if result.ConsumedCapacity != nil && result.ConsumedCapacity.CapacityUnits != nil {
+			handleErr = limiter.Wait(ctx, *result.ConsumedCapacity.CapacityUnits)
+		}
+		return handleErr != nil
+	}
+	if err := t.dynamodbAccessor.ScanPagesSdkWithContext(ctx, scanInput, handleFn, optFns...); err != nil {
+		return errors.Wrap(err, "ScanPagesでスキャン実行時エラー")
+	}
+	if handleErr != nil {
+		return handleErr
+	}
+	if stop {
+		return errScanStopped
+	}
+	return nil
+}
+
+// FindManyByKeys implements DynamoDBTemplate.
+func (t *defaultDynamoDBTemplate) FindManyByKeys(tableName tables.DynamoDBTableName, input input.BatchGetInput, outEntities any, optFns ...func(*dynamodb.Options)) error {
+	return t.FindManyByKeysWithContext(apcontext.Context, tableName, input, outEntities, optFns...)
+}
+
+// FindManyByKeysWithContext implements DynamoDBTemplate.
+func (t *defaultDynamoDBTemplate) FindManyByKeysWithContext(ctx context.Context, tableName tables.DynamoDBTableName, input input.BatchGetInput, outEntities any, optFns ...func(*dynamodb.Options)) error {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	// プライマリキーの条件
+	keys := make([]map[string]types.AttributeValue, 0, len(input.PrimaryKeys))
+	for _, primaryKey := range input.PrimaryKeys {
+		keyMap, err := CreatePkAttributeValue(primaryKey)
+		if err != nil {
+			return errors.Wrap(err, "FindManyByKeysで検索条件生成時エラー")
+		}
+		keys = append(keys, keyMap)
+	}
+	// 取得項目
+	var (
+		projection *string
+		names      map[string]string
+	)
+	if proj := CreateProjection(input.SelectAttributes); proj != nil {
+		expr, err := expression.NewBuilder().WithProjection(*proj).Build()
+		if err != nil {
+			return errors.Wrap(err, "FindManyByKeysで取得項目生成時エラー")
+		}
+		projection, names = expr.Projection(), expr.Names()
+	}
+	// 重複したキーを除き、上限件数ごとに分割して並列で取得
+	chunks := chunkBy(dedupeKeys(keys), BATCH_GET_ITEM_MAX_COUNT)
+	chunkItems := make([][]map[string]types.AttributeValue, len(chunks))
+	err := runConcurrently(ctx, len(chunks), BATCH_MAX_CONCURRENCY, func(ctx context.Context, i int) error {
+		requestItems := map[string]types.KeysAndAttributes{
+			string(tableName): {
+				Keys:                     chunks[i],
+				ProjectionExpression:     projection,
+				ExpressionAttributeNames: names,
+				ConsistentRead:           aws.Bool(input.ConsitentRead),
+			},
+		}
+		items, err := t.batchGetItems(ctx, tableName, requestItems, optFns...)
+		chunkItems[i] = items
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "FindManyByKeysで検索実行時エラー")
+	}
+	// 最終的な検索結果
+	var resultItems []map[string]types.AttributeValue
+	for _, items := range chunkItems {
+		resultItems = append(resultItems, items...)
+	}
+	if len(resultItems) == 0 {
+		return ErrRecordNotFound
+	}
+	if err := attributevalue.UnmarshalListOfMaps(resultItems, &outEntities); err != nil {
+		return errors.Wrap(err, "FindManyByKeysで検索結果を構造体にアンマーシャル時エラー")
+	}
+	return nil
+}
+
+// batchGetItems は、BatchGetItemを実行し、未処理のキーがある場合は、未処理のキーのみをエクスポネンシャルバックオフでリトライします。
+func (t *defaultDynamoDBTemplate) batchGetItems(ctx context.Context, tableName tables.DynamoDBTableName, requestItems map[string]types.KeysAndAttributes, optFns ...func(*dynamodb.Options)) ([]map[string]types.AttributeValue, error) {
+	var resultItems []map[string]types.AttributeValue
+	retryer := retry.NewRetryer[map[string]types.KeysAndAttributes](t.logger)
+	unprocessed, err := retryer.DoWithContext(ctx,
+		func() (map[string]types.KeysAndAttributes, error) {
+			output, err := t.dynamodbAccessor.BatchGetItemSdkWithContext(ctx, &dynamodb.BatchGetItemInput{RequestItems: requestItems}, optFns...)
+			if err != nil {
+				return nil, err
+			}
+			resultItems = append(resultItems, output.Responses[string(tableName)]...)
+			// リトライ時は、未処理のキーのみを取得する
+			requestItems = output.UnprocessedKeys
+			return output.UnprocessedKeys, nil
+		},
+		func(unprocessed map[string]types.KeysAndAttributes, err error) bool {
+			return err == nil && len(unprocessed) > 0
+		},
+		retry.MaxRetryTimes(BATCH_MAX_RETRY_TIMES),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(unprocessed) > 0 {
+		return nil, errors.Wrapf(ErrUnprocessedItems, "未処理のキー: %d件", len(unprocessed[string(tableName)].Keys))
+	}
+	return resultItems, nil
+}
+
+// CreateMany implements DynamoDBTemplate.
+func (t *defaultDynamoDBTemplate) CreateMany(tableName tables.DynamoDBTableName, inputEntities any, optFns ...func(*dynamodb.Options)) error {
+	return t.CreateManyWithContext(apcontext.Context, tableName, inputEntities, optFns...)
+}
+
+// CreateManyWithContext implements DynamoDBTemplate.
+func (t *defaultDynamoDBTemplate) CreateManyWithContext(ctx context.Context, tableName tables.DynamoDBTableName, inputEntities any, optFns ...func(*dynamodb.Options)) error {
+	v := reflect.Indirect(reflect.ValueOf(inputEntities))
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return errors.Errorf("CreateManyで登録対象がスライスではありません: %T", inputEntities)
+	}
+	requests := make([]types.WriteRequest, 0, v.Len())
+	vers := make([]*VersionAttribute, 0, v.Len())
+	for i := range v.Len() {
+		entity := v.Index(i)
+		if entity.Kind() == reflect.Struct && entity.CanAddr() {
+			// 登録後にエンティティのバージョンを更新できるよう、ポインタとして扱う
+			entity = entity.Addr()
+		}
+		attributes, err := attributevalue.MarshalMap(entity.Interface())
+		if err != nil {
+			return errors.Wrap(err, "CreateManyで構造体をAttributeValueのMap変換時にエラー")
+		}
+		// 楽観ロックのバージョン属性の初期値
+		ver, err := GetVersionAttribute(entity.Interface())
+		if err != nil {
+			return errors.Wrap(err, "CreateManyでバージョン属性の取得時エラー")
+		}
+		ver.SetNextVersion(attributes)
+		vers = append(vers, ver)
+		requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: attributes}})
+	}
+	if err := t.batchWriteItemsConcurrently(ctx, tableName, requests, optFns...); err != nil {
+		return errors.Wrap(err, "CreateManyで登録実行時エラー")
+	}
+	for _, ver := range vers {
+		ver.Increment()
+	}
+	return nil
+}
+
+// DeleteMany implements DynamoDBTemplate.
+func (t *defaultDynamoDBTemplate) DeleteMany(tableName tables.DynamoDBTableName, primaryKeys []input.PrimaryKey, optFns ...func(*dynamodb.Options)) error {
+	return t.DeleteManyWithContext(apcontext.Context, tableName, primaryKeys, optFns...)
+}
+
+// DeleteManyWithContext implements DynamoDBTemplate.
+func (t *defaultDynamoDBTemplate) DeleteManyWithContext(ctx context.Context, tableName tables.DynamoDBTableName, primaryKeys []input.PrimaryKey, optFns ...func(*dynamodb.Options)) error {
+	requests := make([]types.WriteRequest, 0, len(primaryKeys))
+	for _, primaryKey := range primaryKeys {
+		keyMap, err := CreatePkAttributeValue(primaryKey)
+		if err != nil {
+			return errors.Wrap(err, "DeleteManyで削除対象条件の生成時エラー")
+		}
+		requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: keyMap}})
+	}
+	if err := t.batchWriteItemsConcurrently(ctx, tableName, requests, optFns...); err != nil {
+		return errors.Wrap(err, "DeleteManyで削除実行時エラー")
+	}
+	return nil
+}
+
+// batchWriteItemsConcurrently は、書き込み要求を上限件数ごとに分割し、並列でBatchWriteItemを実行します。
+func (t *defaultDynamoDBTemplate) batchWriteItemsConcurrently(ctx context.Context, tableName tables.DynamoDBTableName, requests []types.WriteRequest, optFns ...func(*dynamodb.Options)) error {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	// 同じプライマリキーへの重複した要求を除いてから分割する
+	chunks := chunkBy(dedupeWriteRequests(tableName, requests), BATCH_WRITE_ITEM_MAX_COUNT)
+	return runConcurrently(ctx, len(chunks), BATCH_MAX_CONCURRENCY, func(ctx context.Context, i int) error {
+		return t.batchWriteItems(ctx, tableName, map[string][]types.WriteRequest{string(tableName): chunks[i]}, optFns...)
+	})
+}
+
+// batchWriteItems は、BatchWriteItemを実行し、未処理の項目がある場合は、未処理の項目のみをエクスポネンシャルバックオフでリトライします。
+func (t *defaultDynamoDBTemplate) batchWriteItems(ctx context.Context, tableName tables.DynamoDBTableName, requestItems map[string][]types.WriteRequest, optFns ...func(*dynamodb.Options)) error {
+	retryer := retry.NewRetryer[map[string][]types.WriteRequest](t.logger)
+	unprocessed, err := retryer.DoWithContext(ctx,
+		func() (map[string][]types.WriteRequest, error) {
+			output, err := t.dynamodbAccessor.BatchWriteItemSdkWithContext(ctx, &dynamodb.BatchWriteItemInput{RequestItems: requestItems}, optFns...)
+			if err != nil {
+				return nil, err
+			}
+			// リトライ時は、未処理の項目のみを書き込む
+			requestItems = output.UnprocessedItems
+			return output.UnprocessedItems, nil
+		},
+		func(unprocessed map[string][]types.WriteRequest, err error) bool {
+			return err == nil && len(unprocessed) > 0
+		},
+		retry.MaxRetryTimes(BATCH_MAX_RETRY_TIMES),
+	)
+	if err != nil {
+		return err
+	}
+	if len(unprocessed) > 0 {
+		return errors.Wrapf(ErrUnprocessedItems, "未処理の項目: %d件", len(unprocessed[string(tableName)]))
+	}
+	return nil
+}
+
 // UpdateOne implements DynamoDBTemplate.
 func (t *defaultDynamoDBTemplate) UpdateOne(tableName tables.DynamoDBTableName, input input.UpdateInput, optFns ...func(*dynamodb.Options)) error {
 	return t.UpdateOneWithContext(apcontext.Context, tableName, input, optFns...)
@@ -275,15 +653,22 @@ func (t *defaultDynamoDBTemplate) UpdateOneWithContext(ctx context.Context, tabl
 	if err != nil {
 		return errors.Wrap(err, "UpdateOneで更新条件の生成時エラー")
 	}
+	// 楽観ロックのバージョンの条件
+	ver, err := GetVersionAttribute(input.VersionedEntity)
+	if err != nil {
+		return errors.Wrap(err, "UpdateOneでバージョン属性の取得時エラー")
+	}
+	condition, names, values := ver.AppendCondition(expr.Condition(), expr.Names(), expr.Values())
 	// UpdateItemInput
 	updateItemInput := &dynamodb.UpdateItemInput{
-		TableName:                 aws.String(string(tableName)),
-		Key:                       keyMap,
-		ExpressionAttributeNames:  expr.Names(),
-		ExpressionAttributeValues: expr.Values(),
-		UpdateExpression:          expr.Update(),
-		ConditionExpression:       expr.Condition(),
-		ReturnValues:              types.ReturnValueAllNew,
+		TableName:                           aws.String(string(tableName)),
+		Key:                                 keyMap,
+		ExpressionAttributeNames:            names,
+		ExpressionAttributeValues:           values,
+		UpdateExpression:                    expr.Update(),
+		ConditionExpression:                 condition,
+		ReturnValues:                        types.ReturnValueAllNew,
+		ReturnValuesOnConditionCheckFailure: ver.ReturnValuesOnConditionCheckFailure(),
 	}
 	// UpdateItemの実行
 	_, err = t.dynamodbAccessor.UpdateItemSdkWithContext(ctx, updateItemInput, optFns...)
@@ -291,10 +676,14 @@ func (t *defaultDynamoDBTemplate) UpdateOneWithContext(ctx context.Context, tabl
 		// 更新条件エラー
 		var condErr *types.ConditionalCheckFailedException
 		if errors.As(err, &condErr) {
+			if ver.IsConflict(condErr.Item) {
+				return errors.WithStack(NewOptimisticLockError(err, tableName, keyMap, ver.Expected))
+			}
 			return ErrUpdateWithCondtion
 		}
 		return errors.Wrap(err, "UpdateOneで更新実行時エラー")
 	}
+	ver.Increment()
 	return nil
 }
 
@@ -315,23 +704,29 @@ func (t *defaultDynamoDBTemplate) DeleteOneWithContext(ctx context.Context, tabl
 	if err != nil {
 		return errors.Wrap(err, "DelteOneで削除条件の生成時エラー")
 	}
+	var (
+		condition *string
+		names     map[string]string
+		values    map[string]types.AttributeValue
+	)
+	if expr != nil {
+		condition, names, values = expr.Condition(), expr.Names(), expr.Values()
+	}
+	// 楽観ロックのバージョンの条件
+	ver, err := GetVersionAttribute(input.VersionedEntity)
+	if err != nil {
+		return errors.Wrap(err, "DeleteOneでバージョン属性の取得時エラー")
+	}
+	condition, names, values = ver.AppendCondition(condition, names, values)
 	// DeleteItemInput
-	var deleteItemInput *dynamodb.DeleteItemInput
-	if expr == nil {
-		deleteItemInput = &dynamodb.DeleteItemInput{
-			TableName:    aws.String(string(tableName)),
-			Key:          keyMap,
-			ReturnValues: types.ReturnValueNone,
-		}
-	} else {
-		deleteItemInput = &dynamodb.DeleteItemInput{
-			TableName:                 aws.String(string(tableName)),
-			Key:                       keyMap,
-			ExpressionAttributeNames:  expr.Names(),
-			ExpressionAttributeValues: expr.Values(),
-			ConditionExpression:       expr.Condition(),
-			ReturnValues:              types.ReturnValueNone,
-		}
+	deleteItemInput := &dynamodb.DeleteItemInput{
+		TableName:                           aws.String(string(tableName)),
+		Key:                                 keyMap,
+		ExpressionAttributeNames:            names,
+		ExpressionAttributeValues:           values,
+		ConditionExpression:                 condition,
+		ReturnValues:                        types.ReturnValueNone,
+		ReturnValuesOnConditionCheckFailure: ver.ReturnValuesOnConditionCheckFailure(),
 	}
 
 	// DeleteItemの実行
@@ -340,6 +735,9 @@ func (t *defaultDynamoDBTemplate) DeleteOneWithContext(ctx context.Context, tabl
 		// 削除条件エラー
 		var condErr *types.ConditionalCheckFailedException
 		if errors.As(err, &condErr) {
+			if ver.IsConflict(condErr.Item) {
+				return errors.WithStack(NewOptimisticLockError(err, tableName, keyMap, ver.Expected))
+			}
 			return ErrDeleteWithCondtion
 		}
 		return errors.Wrap(err, "DeleteOneで削除実行時エラー")
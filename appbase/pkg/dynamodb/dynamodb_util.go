@@ -4,10 +4,20 @@ dynamodb パッケージは、DynamoDBアクセスに関する機能を提供す
 package dynamodb
 
 import (
+	"context"
+	"fmt"
+	"maps"
+	"math/big"
+	"reflect"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"example.com/appbase/pkg/config"
 	"example.com/appbase/pkg/dynamodb/input"
+	"example.com/appbase/pkg/dynamodb/tables"
 	"example.com/appbase/pkg/env"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
@@ -66,6 +76,8 @@ func CreateQueryExpressionForGSI(input input.GsiQueryInput) (*expression.Express
 	return createQueryExpression(primaryKey, input.SelectAttributes, input.WhereClauses)
 }
 
+// createQueryExpression は、キー条件、取得項目、フィルタ条件から、クエリのExpressionを作成します。
+// WhereClausesを指定した場合は、フィルタ条件（FilterExpression）としてクエリに反映します。
 func createQueryExpression(primaryKey *input.PrimaryKey, attributes []string, whereCauses []*input.WhereClause) (*expression.Expression, error) {
 	keyCond, err := CreateKeyCondition(primaryKey)
 	if err != nil {
@@ -84,7 +96,7 @@ func createQueryExpression(primaryKey *input.PrimaryKey, attributes []string, wh
 		return nil, err
 	}
 	if filterCond != nil {
-		eb.WithFilter(*filterCond)
+		eb = eb.WithFilter(*filterCond)
 	}
 	// クエリ表現の作成
 	expr, err := eb.Build()
@@ -94,6 +106,33 @@ func createQueryExpression(primaryKey *input.PrimaryKey, attributes []string, wh
 	return &expr, nil
 }
 
+// CreateScanExpression は、スキャンの取得項目、フィルタ条件のExpressionを作成します。
+// 取得項目、フィルタ条件のいずれの指定もない場合は、nilを返却します。
+func CreateScanExpression(input input.ScanInput) (*expression.Expression, error) {
+	proj := CreateProjection(input.SelectAttributes)
+	filterCond, err := CreateWhereCondition(input.WhereClauses)
+	if err != nil {
+		return nil, err
+	}
+	if proj == nil && filterCond == nil {
+		return nil, nil
+	}
+	eb := expression.NewBuilder()
+	// 取得項目の設定
+	if proj != nil {
+		eb = eb.WithProjection(*proj)
+	}
+	// フィルタ条件の設定
+	if filterCond != nil {
+		eb = eb.WithFilter(*filterCond)
+	}
+	expr, err := eb.Build()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &expr, nil
+}
+
 // CreateUpdateExpression は、更新条件のExpressionを作成します。
 func CreateUpdateExpression(input input.UpdateInput) (*expression.Expression, error) {
 	// 更新項目の設定
@@ -107,6 +146,14 @@ func CreateUpdateExpression(input input.UpdateInput) (*expression.Expression, er
 	for _, name := range input.RemoveAttributeNames {
 		upd = upd.Remove(expression.Name(name))
 	}
+	// 楽観ロックのバージョン属性のインクリメント（バージョンの一致の条件は、VersionAttribute.AppendConditionで付加する）
+	ver, err := GetVersionAttribute(input.VersionedEntity)
+	if err != nil {
+		return nil, err
+	}
+	if ver != nil {
+		upd = upd.Set(expression.Name(ver.Name), expression.Value(ver.Next()))
+	}
 	// Update表現の作成
 	eb := expression.NewBuilder().WithUpdate(upd)
 	// 更新条件の作成
@@ -143,6 +190,24 @@ func CreateDeleteExpression(input input.DeleteInput) (*expression.Expression, er
 	return &expr, nil
 }
 
+// CreateConditionCheckExpression は、トランザクションでの条件チェックのExpressionを作成します。
+func CreateConditionCheckExpression(input input.ConditionCheckInput) (*expression.Expression, error) {
+	// チェック条件の作成
+	checkCond, err := CreateWhereCondition(input.WhereClauses)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if checkCond == nil {
+		return nil, errors.New("条件チェックの条件が指定されていません")
+	}
+	eb := expression.NewBuilder().WithCondition(*checkCond)
+	expr, err := eb.Build()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &expr, nil
+}
+
 // CreateKeyCondition は、キー条件を作成します。
 func CreateKeyCondition(primaryKeyCond *input.PrimaryKey) (*expression.KeyConditionBuilder, error) {
 	// パーティションキーの条件
@@ -194,50 +259,130 @@ func CreateProjection(attributeNames []string) *expression.ProjectionBuilder {
 
 // CreateWhereCondition は、Where句をもとにした条件を作成します。
 func CreateWhereCondition(whereClauses []*input.WhereClause) (*expression.ConditionBuilder, error) {
-	fn := func(where input.WhereClause, cond *expression.ConditionBuilder) (*expression.ConditionBuilder, error) {
-		var tmp expression.ConditionBuilder
-		switch where.WhereOp {
-		case input.WHERE_EQUAL:
-			tmp = expression.Name(where.Attribute.Name).Equal(expression.Value(where.Attribute.Value))
-		case input.WHERE_NOT_EQUAL:
-			tmp = expression.Name(where.Attribute.Name).NotEqual(expression.Value(where.Attribute.Value))
-		case input.WHERE_BEGINS_WITH:
-			if v, ok := where.Attribute.Value.(string); ok {
-				tmp = expression.Name(where.Attribute.Name).BeginsWith(v)
-			} else {
-				return nil, errors.New("type not supported")
-			}
-		case input.WHERE_GREATER_THAN:
-			tmp = expression.Name(where.Attribute.Name).GreaterThan(expression.Value(where.Attribute.Value))
-		case input.WHERE_GREATER_THAN_EQ:
-			tmp = expression.Name(where.Attribute.Name).GreaterThanEqual(expression.Value(where.Attribute.Value))
-		case input.WHERE_LESS_THAN:
-			tmp = expression.Name(where.Attribute.Name).LessThan(expression.Value(where.Attribute.Value))
-		case input.WHERE_LESS_THAN_EQ:
-			tmp = expression.Name(where.Attribute.Name).LessThanEqual(expression.Value(where.Attribute.Value))
-		default:
-			return nil, errors.New("operator not supported")
+	var filterCond *expression.ConditionBuilder
+	for _, where := range whereClauses {
+		if where == nil {
+			continue
 		}
-		if cond != nil {
+		cond, err := createCondition(where)
+		if err != nil {
+			return nil, err
+		}
+		if cond == nil {
+			// 空のグループ
+			continue
+		}
+		if filterCond != nil {
 			if where.AppendOp == input.APPEND_OR {
-				tmp = cond.Or(*cond, tmp)
+				*cond = filterCond.Or(*cond)
 			} else {
-				tmp = cond.And(*cond, tmp)
+				*cond = filterCond.And(*cond)
 			}
 		}
-		return &tmp, nil
+		filterCond = cond
 	}
-	var filterCond *expression.ConditionBuilder
-	var conderr error
-	for _, where := range whereClauses {
-		if where != nil {
-			filterCond, conderr = fn(*where, filterCond)
-			if conderr != nil {
-				return nil, conderr
-			}
+	return filterCond, nil
+}
+
+// sizeOperators は、サイズの比較の演算子と、対応する比較の演算子です。
+var sizeOperators = map[input.WhereOperator]input.WhereOperator{
+	input.WHERE_SIZE_EQUAL:           input.WHERE_EQUAL,
+	input.WHERE_SIZE_NOT_EQUAL:       input.WHERE_NOT_EQUAL,
+	input.WHERE_SIZE_GREATER_THAN:    input.WHERE_GREATER_THAN,
+	input.WHERE_SIZE_GREATER_THAN_EQ: input.WHERE_GREATER_THAN_EQ,
+	input.WHERE_SIZE_LESS_THAN:       input.WHERE_LESS_THAN,
+	input.WHERE_SIZE_LESS_THAN_EQ:    input.WHERE_LESS_THAN_EQ,
+}
+
+// createCondition は、1つのWhere句（またはグループ）の条件を作成します。
+func createCondition(where *input.WhereClause) (*expression.ConditionBuilder, error) {
+	// 括弧で囲むグループの条件
+	if len(where.Group) > 0 {
+		return CreateWhereCondition(where.Group)
+	}
+	name := expression.Name(where.Attribute.Name)
+	value := where.Attribute.Value
+	// サイズの比較の場合は、属性のサイズを比較する
+	op := where.WhereOp
+	var left expression.OperandBuilder = name
+	if compareOp, ok := sizeOperators[op]; ok {
+		left = name.Size()
+		op = compareOp
+	}
+	var cond expression.ConditionBuilder
+	switch op {
+	case input.WHERE_EQUAL:
+		cond = expression.Equal(left, expression.Value(value))
+	case input.WHERE_NOT_EQUAL:
+		cond = expression.NotEqual(left, expression.Value(value))
+	case input.WHERE_GREATER_THAN:
+		cond = expression.GreaterThan(left, expression.Value(value))
+	case input.WHERE_GREATER_THAN_EQ:
+		cond = expression.GreaterThanEqual(left, expression.Value(value))
+	case input.WHERE_LESS_THAN:
+		cond = expression.LessThan(left, expression.Value(value))
+	case input.WHERE_LESS_THAN_EQ:
+		cond = expression.LessThanEqual(left, expression.Value(value))
+	case input.WHERE_BEGINS_WITH:
+		v, ok := value.(string)
+		if !ok {
+			return nil, errors.Errorf("type not supported: %s, %T", op, value)
+		}
+		cond = expression.BeginsWith(name, v)
+	case input.WHERE_CONTAINS:
+		// 文字列以外の値も、数値セット、リストの要素として判定できるよう、そのまま属性値とする
+		if value == nil {
+			return nil, errors.Errorf("type not supported: %s, %T", op, value)
+		}
+		cond = expression.Contains(name, value)
+	case input.WHERE_IN:
+		operands, err := inOperands(value)
+		if err != nil {
+			return nil, err
+		}
+		cond = expression.In(name, operands[0], operands[1:]...)
+	case input.WHERE_BETWEEN:
+		// Attribute.Value[0] <= 属性 <= Attribute.Value[1]
+		v, ok := value.([2]any)
+		if !ok {
+			return nil, errors.Errorf("type not supported: %s, %T", op, value)
+		}
+		cond = expression.Between(name, expression.Value(v[0]), expression.Value(v[1]))
+	case input.WHERE_ATTRIBUTE_EXISTS:
+		cond = expression.AttributeExists(name)
+	case input.WHERE_ATTRIBUTE_NOT_EXISTS:
+		cond = expression.AttributeNotExists(name)
+	case input.WHERE_ATTRIBUTE_TYPE:
+		var attributeType input.AttributeType
+		switch v := value.(type) {
+		case input.AttributeType:
+			attributeType = v
+		case string:
+			attributeType = input.AttributeType(v)
+		default:
+			return nil, errors.Errorf("type not supported: %s, %T", op, value)
 		}
+		cond = expression.AttributeType(name, expression.DynamoDBAttributeType(attributeType))
+	default:
+		return nil, errors.Errorf("operator not supported: %s", where.WhereOp)
 	}
-	return filterCond, nil
+	return &cond, nil
+}
+
+// inOperands は、WHERE_INの値（スライス）から、比較する値のリストを作成します。
+func inOperands(value any) ([]expression.OperandBuilder, error) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, errors.Errorf("type not supported: %s, %T", input.WHERE_IN, value)
+	}
+	if v.Len() == 0 {
+		return nil, errors.Errorf("%sの値が指定されていません", input.WHERE_IN)
+	}
+	operands := make([]expression.OperandBuilder, 0, v.Len())
+	for i := range v.Len() {
+		operands = append(operands, expression.Value(v.Index(i).Interface()))
+	}
+	return operands, nil
 }
 
 // StandardIndexForward は、インデックスの検索順序を返します。
@@ -254,8 +399,12 @@ func typeSwitch(attribute input.Attribute) (types.AttributeValue, error) {
 	switch attribute.Value.(type) {
 	case nil:
 		return nil, errors.Errorf("cannot switch type because attribuite is nil")
-	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
-		return &types.AttributeValueMemberN{Value: strconv.Itoa(attribute.Value.(int))}, nil
+	case int, int8, int16, int32, int64:
+		return &types.AttributeValueMemberN{Value: strconv.FormatInt(reflect.ValueOf(attribute.Value).Int(), 10)}, nil
+	case uint, uint8, uint16, uint32, uint64:
+		return &types.AttributeValueMemberN{Value: strconv.FormatUint(reflect.ValueOf(attribute.Value).Uint(), 10)}, nil
+	case float32, float64:
+		return &types.AttributeValueMemberN{Value: strconv.FormatFloat(reflect.ValueOf(attribute.Value).Float(), 'f', -1, 64)}, nil
 	case string:
 		return &types.AttributeValueMemberS{Value: attribute.Value.(string)}, nil
 	case bool:
@@ -266,3 +415,189 @@ func typeSwitch(attribute input.Attribute) (types.AttributeValue, error) {
 		return nil, errors.Errorf("type not supported: %T", attribute.Value)
 	}
 }
+
+// capacityLimiter は、1秒あたりの消費キャパシティユニットの上限値による流量制御を行う構造体です。
+// 並列スキャンの各セグメントのgoroutineから共有して利用します。
+type capacityLimiter struct {
+	mu sync.Mutex
+	// 1秒あたりの消費キャパシティユニットの上限値
+	unitsPerSecond float64
+	// 次の実行が可能になる時刻
+	next time.Time
+}
+
+// newCapacityLimiter は、capacityLimiterを作成します。上限値が0以下の場合は、nil（制限しない）を返却します。
+func newCapacityLimiter(unitsPerSecond float64) *capacityLimiter {
+	if unitsPerSecond <= 0 {
+		return nil
+	}
+	return &capacityLimiter{unitsPerSecond: unitsPerSecond}
+}
+
+// Wait は、消費したキャパシティユニットが上限値を超えないよう、次の実行が可能になる時刻まで待機します。
+func (l *capacityLimiter) Wait(ctx context.Context, consumedUnits float64) error {
+	if l == nil || consumedUnits <= 0 {
+		return nil
+	}
+	wait := l.reserve(time.Now(), consumedUnits)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserve は、消費したキャパシティユニット分、次の実行が可能になる時刻を進め、待機時間を返却します。
+func (l *capacityLimiter) reserve(now time.Time, consumedUnits float64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(consumedUnits / l.unitsPerSecond * float64(time.Second)))
+	return l.next.Sub(now)
+}
+
+// runConcurrently は、0からn-1のインデックスごとにfnを、同時実行数の上限（0以下の場合はn）までgoroutineで並列実行します。
+// いずれかのfnがエラーとなった場合は、fnに渡したContextをキャンセルして他の実行を中断し、最初のエラーを返却します。
+func runConcurrently(ctx context.Context, n int, concurrency int, fn func(ctx context.Context, i int) error) error {
+	if concurrency <= 0 || concurrency > n {
+		concurrency = n
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	setErr := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		// 最初のエラーによる中断で、他の実行で発生したエラーは無視する
+		if firstErr == nil {
+			firstErr = err
+		}
+		cancel()
+	}
+	// 同時実行数の制御用のセマフォ
+	sem := make(chan struct{}, concurrency)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-runCtx.Done():
+				setErr(errors.WithStack(runCtx.Err()))
+				return
+			}
+			if err := fn(runCtx, i); err != nil {
+				setErr(err)
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// dedupeKeys は、プライマリキーの重複を除きます（最初に指定したキーを残します）。
+// BatchGetItemは、1回の要求に同じキーが含まれるとValidationExceptionとなるため、分割前に重複を除きます。
+func dedupeKeys(keys []map[string]types.AttributeValue) []map[string]types.AttributeValue {
+	seen := make(map[string]struct{}, len(keys))
+	result := make([]map[string]types.AttributeValue, 0, len(keys))
+	for _, key := range keys {
+		k := keyString(key)
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		result = append(result, key)
+	}
+	return result
+}
+
+// dedupeWriteRequests は、同じプライマリキーへの書き込み要求の重複を除きます（最後に指定した要求を残します）。
+// BatchWriteItemは、1回の要求に同じキーが含まれるとValidationExceptionとなり、
+// 分割した要求の間では並列実行のため書き込み順序が不定となるため、分割前に重複を除きます。
+// 登録要求は、テーブルのプライマリキーの定義（tables.SetPrimaryKey）がない場合、キーを判定できないため重複を除きません。
+func dedupeWriteRequests(tableName tables.DynamoDBTableName, requests []types.WriteRequest) []types.WriteRequest {
+	var keyNames []string
+	if pkKeyPair := tables.GetPrimaryKey(tableName); pkKeyPair != nil {
+		keyNames = append(keyNames, pkKeyPair.PartitionKey)
+		if pkKeyPair.SortKey != nil {
+			keyNames = append(keyNames, *pkKeyPair.SortKey)
+		}
+	}
+	seen := make(map[string]struct{}, len(requests))
+	result := make([]types.WriteRequest, 0, len(requests))
+	// 最後の要求を残すため、末尾から判定する
+	for _, request := range slices.Backward(requests) {
+		var key map[string]types.AttributeValue
+		switch {
+		case request.DeleteRequest != nil:
+			key = request.DeleteRequest.Key
+		case request.PutRequest != nil && len(keyNames) > 0:
+			key = make(map[string]types.AttributeValue, len(keyNames))
+			for _, name := range keyNames {
+				key[name] = request.PutRequest.Item[name]
+			}
+		}
+		if key != nil {
+			k := keyString(key)
+			if _, ok := seen[k]; ok {
+				continue
+			}
+			seen[k] = struct{}{}
+		}
+		result = append(result, request)
+	}
+	slices.Reverse(result)
+	return result
+}
+
+// keyString は、プライマリキーの重複判定のため、キーの属性を一意な文字列に変換します。
+// 数値型は、DynamoDBと同様に表記の違い（1と1.0等）を同じ値として扱います。
+func keyString(key map[string]types.AttributeValue) string {
+	var b strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(key)) {
+		var value string
+		switch v := key[name].(type) {
+		case *types.AttributeValueMemberS:
+			value = "S" + v.Value
+		case *types.AttributeValueMemberN:
+			if r, ok := new(big.Rat).SetString(v.Value); ok {
+				value = "N" + r.RatString()
+			} else {
+				value = "N" + v.Value
+			}
+		case *types.AttributeValueMemberB:
+			value = "B" + string(v.Value)
+		default:
+			value = fmt.Sprintf("%T%v", v, v)
+		}
+		fmt.Fprintf(&b, "%q=%q;", name, value)
+	}
+	return b.String()
+}
+
+// chunkBy は、指定のサイズでスライスを分割します。
+func chunkBy[T any](items []T, chunkSize int) [][]T {
+	if len(items) == 0 || chunkSize <= 0 {
+		return [][]T{}
+	}
+
+	// https://go.dev/wiki/SliceTricks#batching-with-minimal-allocation
+	chunks := make([][]T, 0, (len(items)+chunkSize-1)/chunkSize)
+	for chunkSize < len(items) {
+		items, chunks = items[chunkSize:], append(chunks, items[0:chunkSize:chunkSize])
+	}
+	return append(chunks, items)
+}
@@ -0,0 +1,298 @@
+package dynamodb
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/appbase/pkg/dynamodb/input"
+	"example.com/appbase/pkg/dynamodb/tables"
+	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/message"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateWhereCondition(t *testing.T) {
+	// status IN ("A", "B") AND (size(tags) > 2 OR attribute_not_exists(address.city))
+	cond, err := CreateWhereCondition([]*input.WhereClause{
+		{Attribute: input.Attribute{Name: "status", Value: []string{"A", "B"}}, WhereOp: input.WHERE_IN},
+		{AppendOp: input.APPEND_AND, Group: []*input.WhereClause{
+			{Attribute: input.Attribute{Name: "tags", Value: 2}, WhereOp: input.WHERE_SIZE_GREATER_THAN},
+			{Attribute: input.Attribute{Name: "address.city"}, WhereOp: input.WHERE_ATTRIBUTE_NOT_EXISTS, AppendOp: input.APPEND_OR},
+		}},
+	})
+	assert.NoError(t, err)
+	expr, err := expression.NewBuilder().WithCondition(*cond).Build()
+	assert.NoError(t, err)
+
+	condition := *expr.Condition()
+	assert.Contains(t, condition, " IN ")
+	assert.Contains(t, condition, "size")
+	assert.Contains(t, condition, "attribute_not_exists")
+	assert.Contains(t, condition, ") AND (")
+	assert.Contains(t, condition, ") OR (")
+	// ネストした属性はドキュメントパスとして、属性名ごとにプレースホルダに置き換えられる
+	assert.ElementsMatch(t, []string{"status", "tags", "address", "city"}, slices.Collect(maps.Values(expr.Names())))
+	assert.Len(t, expr.Values(), 3)
+}
+
+func TestCreateWhereCondition_ContainsNumber(t *testing.T) {
+	// 文字列以外の値も、数値セット、リストの要素として判定できる
+	cond, err := CreateWhereCondition([]*input.WhereClause{
+		{Attribute: input.Attribute{Name: "location_ids", Value: 2}, WhereOp: input.WHERE_CONTAINS},
+	})
+	assert.NoError(t, err)
+	expr, err := expression.NewBuilder().WithCondition(*cond).Build()
+	assert.NoError(t, err)
+
+	assert.Equal(t, "contains (#0, :0)", *expr.Condition())
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "2"}, expr.Values()[":0"])
+}
+
+func TestCreateWhereCondition_Error(t *testing.T) {
+	tests := []struct {
+		name  string
+		where *input.WhereClause
+	}{
+		{"INの値がスライスでない", &input.WhereClause{Attribute: input.Attribute{Name: "a", Value: "A"}, WhereOp: input.WHERE_IN}},
+		{"INの値が空", &input.WhereClause{Attribute: input.Attribute{Name: "a", Value: []string{}}, WhereOp: input.WHERE_IN}},
+		{"BETWEENの値が[2]anyでない", &input.WhereClause{Attribute: input.Attribute{Name: "a", Value: []int{1, 2}}, WhereOp: input.WHERE_BETWEEN}},
+		{"CONTAINSの値がない", &input.WhereClause{Attribute: input.Attribute{Name: "a"}, WhereOp: input.WHERE_CONTAINS}},
+		{"未対応の演算子", &input.WhereClause{Attribute: input.Attribute{Name: "a", Value: 1}, WhereOp: "Unknown"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CreateWhereCondition([]*input.WhereClause{tt.where})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCapacityLimiter_Reserve(t *testing.T) {
+	limiter := newCapacityLimiter(100)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// 50RCU消費 → 0.5秒待機
+	assert.Equal(t, 500*time.Millisecond, limiter.reserve(now, 50))
+	// 他のセグメントの消費分も累積される
+	assert.Equal(t, 1500*time.Millisecond, limiter.reserve(now, 100))
+	// 次の実行可能時刻を過ぎている場合は、現在時刻から計算する
+	assert.Equal(t, 250*time.Millisecond, limiter.reserve(now.Add(10*time.Second), 25))
+}
+
+func TestCapacityLimiter_Unlimited(t *testing.T) {
+	limiter := newCapacityLimiter(0)
+
+	assert.Nil(t, limiter)
+	assert.NoError(t, limiter.Wait(t.Context(), 1000))
+}
+
+func TestRunConcurrently(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		running int
+		peak    int
+		done    []int
+	)
+	err := runConcurrently(t.Context(), 10, 3, func(ctx context.Context, i int) error {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		done = append(done, i)
+		mu.Unlock()
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, done, 10)
+	assert.LessOrEqual(t, peak, 3)
+}
+
+func TestRunConcurrently_Error(t *testing.T) {
+	cause := errors.New("chunk error")
+	err := runConcurrently(t.Context(), 5, 5, func(ctx context.Context, i int) error {
+		if i == 0 {
+			return cause
+		}
+		// 最初のエラーにより中断され、以降はキャンセルされたContextで実行される
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	assert.ErrorIs(t, err, cause)
+}
+
+func TestChunkBy(t *testing.T) {
+	chunks := chunkBy([]int{1, 2, 3, 4, 5}, 2)
+
+	assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5}}, chunks)
+	assert.Empty(t, chunkBy([]int{}, 2))
+}
+
+func TestDedupeKeys(t *testing.T) {
+	newKey := func(id string, seq string) map[string]types.AttributeValue {
+		return map[string]types.AttributeValue{
+			"id":  &types.AttributeValueMemberS{Value: id},
+			"seq": &types.AttributeValueMemberN{Value: seq},
+		}
+	}
+	keys := []map[string]types.AttributeValue{newKey("a", "1"), newKey("b", "1"), newKey("a", "1.0"), newKey("a", "2")}
+
+	// 数値の表記の違いは同じキーとして扱い、最初に指定したキーを残す
+	assert.Equal(t, []map[string]types.AttributeValue{keys[0], keys[1], keys[3]}, dedupeKeys(keys))
+}
+
+func TestDedupeWriteRequests(t *testing.T) {
+	tableName := tables.DynamoDBTableName("dedupe-test")
+	tables.SetPrimaryKey(tableName, &tables.PKKeyPair{PartitionKey: "id", SortKey: aws.String("seq")})
+	newPut := func(id string, name string) types.WriteRequest {
+		return types.WriteRequest{PutRequest: &types.PutRequest{Item: map[string]types.AttributeValue{
+			"id":   &types.AttributeValueMemberS{Value: id},
+			"seq":  &types.AttributeValueMemberN{Value: "1"},
+			"name": &types.AttributeValueMemberS{Value: name},
+		}}}
+	}
+	newDelete := func(id string) types.WriteRequest {
+		return types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: map[string]types.AttributeValue{
+			"id":  &types.AttributeValueMemberS{Value: id},
+			"seq": &types.AttributeValueMemberN{Value: "1"},
+		}}}
+	}
+
+	t.Run("登録は最後に指定した項目を残す", func(t *testing.T) {
+		requests := []types.WriteRequest{newPut("a", "first"), newPut("b", "b"), newPut("a", "last")}
+		assert.Equal(t, []types.WriteRequest{requests[1], requests[2]}, dedupeWriteRequests(tableName, requests))
+	})
+
+	t.Run("削除は重複したキーを1件にする", func(t *testing.T) {
+		requests := []types.WriteRequest{newDelete("a"), newDelete("a"), newDelete("b")}
+		assert.Equal(t, []types.WriteRequest{requests[1], requests[2]}, dedupeWriteRequests(tableName, requests))
+	})
+
+	t.Run("プライマリキーの定義がない場合、登録は重複を除かない", func(t *testing.T) {
+		requests := []types.WriteRequest{newPut("a", "first"), newPut("a", "last")}
+		assert.Equal(t, requests, dedupeWriteRequests("dedupe-test-undefined", requests))
+	})
+}
+
+func TestCreateQueryExpression_WithFilter(t *testing.T) {
+	whereClauses := []*input.WhereClause{
+		{Attribute: input.Attribute{Name: "status", Value: "done"}, WhereOp: input.WHERE_EQUAL},
+	}
+	// フィルタ条件がクエリに反映されること
+	expr, err := CreateQueryExpressionForTable(input.PKQueryInput{
+		PrimaryKey:   input.PrimaryKey{PartitionKey: input.Attribute{Name: "user_id", Value: "u1"}},
+		WhereClauses: whereClauses,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, expr.KeyCondition())
+	assert.NotNil(t, expr.Filter())
+	assert.Contains(t, slices.Collect(maps.Values(expr.Names())), "status")
+
+	expr, err = CreateQueryExpressionForGSI(input.GsiQueryInput{
+		IndexKey:     input.PrimaryKey{PartitionKey: input.Attribute{Name: "user_id", Value: "u1"}},
+		WhereClauses: whereClauses,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, expr.Filter())
+
+	// フィルタ条件がない場合
+	expr, err = CreateQueryExpressionForTable(input.PKQueryInput{
+		PrimaryKey: input.PrimaryKey{PartitionKey: input.Attribute{Name: "user_id", Value: "u1"}},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, expr.Filter())
+}
+
+// queryStub は、Queryの入力を記録し、ページごとの検索結果を返却するDynamoDBAccessorです。
+// ページング処理も、ページごとにQueryを実行したものとして記録します。
+type queryStub struct {
+	DynamoDBAccessor
+	pages  [][]map[string]types.AttributeValue
+	inputs []*dynamodb.QueryInput
+}
+
+func (s *queryStub) QuerySDKWithContext(ctx context.Context, input *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	s.inputs = append(s.inputs, input)
+	output := &dynamodb.QueryOutput{Items: s.pages[len(s.inputs)-1]}
+	if len(s.inputs) < len(s.pages) {
+		output.LastEvaluatedKey = map[string]types.AttributeValue{"user_id": &types.AttributeValueMemberS{Value: "u1"}}
+	}
+	return output, nil
+}
+
+func (s *queryStub) QueryPagesSdkWithContext(ctx context.Context, input *dynamodb.QueryInput, fn func(*dynamodb.QueryOutput) bool, optFns ...func(*dynamodb.Options)) error {
+	for {
+		output, err := s.QuerySDKWithContext(ctx, input, optFns...)
+		if err != nil {
+			return err
+		}
+		if fn(output) || len(output.LastEvaluatedKey) == 0 {
+			return nil
+		}
+	}
+}
+
+func TestFindSomeByKeyWithContext_Filter(t *testing.T) {
+	msg, err := message.NewMessageSource()
+	assert.NoError(t, err)
+	logger, err := logging.NewLogger(msg)
+	assert.NoError(t, err)
+	whereClauses := []*input.WhereClause{
+		{Attribute: input.Attribute{Name: "status", Value: "done"}, WhereOp: input.WHERE_EQUAL},
+	}
+	item := map[string]types.AttributeValue{
+		"user_id": &types.AttributeValueMemberS{Value: "u1"},
+		"status":  &types.AttributeValueMemberS{Value: "done"},
+	}
+	type entity struct {
+		UserID string `dynamodbav:"user_id"`
+		Status string `dynamodbav:"status"`
+	}
+
+	t.Run("テーブルのキー", func(t *testing.T) {
+		stub := &queryStub{pages: [][]map[string]types.AttributeValue{{item}, {item}}}
+		var entities []entity
+		err := NewDynamoDBTemplate(logger, stub).FindSomeByTableKeyWithContext(context.Background(), "test", input.PKQueryInput{
+			PrimaryKey:   input.PrimaryKey{PartitionKey: input.Attribute{Name: "user_id", Value: "u1"}},
+			WhereClauses: whereClauses,
+		}, &entities)
+		assert.NoError(t, err)
+		assert.Len(t, entities, 2)
+		// 全てのページのクエリに、フィルタ条件を指定する
+		if assert.Len(t, stub.inputs, 2) {
+			for _, v := range stub.inputs {
+				assert.NotNil(t, v.KeyConditionExpression)
+				assert.NotNil(t, v.FilterExpression)
+				assert.Contains(t, v.ExpressionAttributeValues, ":1")
+			}
+		}
+	})
+
+	t.Run("GSIのキー", func(t *testing.T) {
+		stub := &queryStub{pages: [][]map[string]types.AttributeValue{{item}}}
+		var entities []entity
+		err := NewDynamoDBTemplate(logger, stub).FindSomeByGSIKeyWithContext(context.Background(), "test", input.GsiQueryInput{
+			GSIName:      "status-index",
+			IndexKey:     input.PrimaryKey{PartitionKey: input.Attribute{Name: "user_id", Value: "u1"}},
+			WhereClauses: whereClauses,
+		}, &entities)
+		assert.NoError(t, err)
+		if assert.Len(t, stub.inputs, 1) {
+			assert.Equal(t, "status-index", aws.ToString(stub.inputs[0].IndexName))
+			assert.NotNil(t, stub.inputs[0].FilterExpression)
+		}
+	})
+}
@@ -0,0 +1,220 @@
+/*
+dynamodb パッケージは、DynamoDBアクセスに関する機能を提供するパッケージです。
+*/
+package dynamodb
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"strconv"
+
+	"example.com/appbase/pkg/dynamodb/tables"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cockroachdb/errors"
+)
+
+// 楽観ロック
+
+const (
+	// DYNAMODB_TAG_KEY は、エンティティの属性の役割を指定する構造体タグのキーです。
+	DYNAMODB_TAG_KEY = "dynamodb"
+	// DYNAMODB_TAG_VERSION は、楽観ロックのバージョン属性を表す構造体タグの値です。
+	// 例）Version int `dynamodbav:"version" dynamodb:"version"`
+	DYNAMODB_TAG_VERSION = "version"
+
+	// バージョン属性の条件の式で利用するプレースホルダ
+	// トランザクションのキャンセル時に、楽観ロックの条件を含む操作かを判定するためにも利用する
+	versionNamePlaceholder  = "#optlock_version"
+	versionValuePlaceholder = ":optlock_expected_version"
+)
+
+// OptimisticLockError は、楽観ロックのバージョン属性の不一致（他の処理による更新、削除）により、
+// 登録、更新、削除に失敗した場合のエラーです。errors.Asで取得できます。
+// DynamoDBに依存しない判定では、errors.OptimisticLockErrorインタフェースで取得してください。
+type OptimisticLockError struct {
+	cause error
+	// テーブル名
+	TableName tables.DynamoDBTableName
+	// プライマリキー
+	Key map[string]types.AttributeValue
+	// 更新時に期待したバージョン
+	ExpectedVersion int64
+}
+
+// NewOptimisticLockError は、OptimisticLockErrorを作成します。
+func NewOptimisticLockError(cause error, tableName tables.DynamoDBTableName, key map[string]types.AttributeValue, expectedVersion int64) *OptimisticLockError {
+	return &OptimisticLockError{cause: cause, TableName: tableName, Key: key, ExpectedVersion: expectedVersion}
+}
+
+// Error implements error.
+func (e *OptimisticLockError) Error() string {
+	return fmt.Sprintf("楽観ロックエラー: テーブル[%s], 期待したバージョン[%d]", e.TableName, e.ExpectedVersion)
+}
+
+// Unwrap は、元のエラーを返却します。
+func (e *OptimisticLockError) Unwrap() error {
+	return e.cause
+}
+
+// LockTarget implements errors.OptimisticLockError.
+func (e *OptimisticLockError) LockTarget() string {
+	return string(e.TableName)
+}
+
+// ExpectedLockVersion implements errors.OptimisticLockError.
+func (e *OptimisticLockError) ExpectedLockVersion() int64 {
+	return e.ExpectedVersion
+}
+
+// VersionAttribute は、エンティティの楽観ロックのバージョン属性です。
+// メソッドは、nilの場合（バージョン属性がない場合）、何もしません。
+type VersionAttribute struct {
+	// 属性名
+	Name string
+	// 更新時に期待する（エンティティの現在の）バージョン
+	Expected int64
+	// エンティティのフィールド（ポインタでない場合は設定不可）
+	field reflect.Value
+}
+
+// GetVersionAttribute は、エンティティの構造体タグ（dynamodb:"version"）から、楽観ロックのバージョン属性を取得します。
+// 属性名は、dynamodbavタグの名前（省略時はフィールド名）です。バージョン属性がない場合は、nilを返却します。
+func GetVersionAttribute(entity any) (*VersionAttribute, error) {
+	if entity == nil {
+		return nil, nil
+	}
+	v := reflect.ValueOf(entity)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, nil
+	}
+	for _, f := range collectEntityFields(v.Type(), nil) {
+		if !f.hasRole(DYNAMODB_TAG_VERSION) {
+			continue
+		}
+		field := v.FieldByIndex(f.index)
+		var expected int64
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			expected = field.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			expected = int64(field.Uint())
+		default:
+			return nil, errors.Errorf("バージョン属性[%s]の型が整数ではありません: %s", f.AttributeName, field.Type())
+		}
+		return &VersionAttribute{Name: f.AttributeName, Expected: expected, field: field}, nil
+	}
+	return nil, nil
+}
+
+// VersionAttributeFromExpression は、操作の式の属性名、属性値から、楽観ロックのバージョン属性を取得します。
+// 楽観ロックの条件を含まない場合は、nilを返却します。
+func VersionAttributeFromExpression(names map[string]string, values map[string]types.AttributeValue) *VersionAttribute {
+	name, ok := names[versionNamePlaceholder]
+	if !ok {
+		return nil
+	}
+	expected, _ := versionValue(values[versionValuePlaceholder])
+	return &VersionAttribute{Name: name, Expected: expected}
+}
+
+// Next は、登録、更新後のバージョンを返却します。
+func (v *VersionAttribute) Next() int64 {
+	return v.Expected + 1
+}
+
+// NextValue は、登録、更新後のバージョンのAttributeValueを返却します。
+func (v *VersionAttribute) NextValue() types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(v.Next(), 10)}
+}
+
+// SetNextVersion は、項目のバージョン属性に、登録、更新後のバージョンを設定します。
+func (v *VersionAttribute) SetNextVersion(item map[string]types.AttributeValue) {
+	if v == nil {
+		return
+	}
+	item[v.Name] = v.NextValue()
+}
+
+// AppendCondition は、条件の式に、バージョン属性が期待するバージョンと一致する条件をANDで付加します。
+// 期待するバージョンが0の場合は、バージョン属性が存在しない（未登録の）条件とします。
+func (v *VersionAttribute) AppendCondition(condition *string, names map[string]string, values map[string]types.AttributeValue) (*string, map[string]string, map[string]types.AttributeValue) {
+	if v == nil {
+		return condition, names, values
+	}
+	names = maps.Clone(names)
+	if names == nil {
+		names = make(map[string]string)
+	}
+	names[versionNamePlaceholder] = v.Name
+	var versionCond string
+	if v.Expected == 0 {
+		versionCond = "attribute_not_exists(" + versionNamePlaceholder + ")"
+	} else {
+		values = maps.Clone(values)
+		if values == nil {
+			values = make(map[string]types.AttributeValue)
+		}
+		values[versionValuePlaceholder] = &types.AttributeValueMemberN{Value: strconv.FormatInt(v.Expected, 10)}
+		versionCond = versionNamePlaceholder + " = " + versionValuePlaceholder
+	}
+	if condition != nil && *condition != "" {
+		versionCond = "(" + *condition + ") AND (" + versionCond + ")"
+	}
+	return &versionCond, names, values
+}
+
+// ReturnValuesOnConditionCheckFailure は、条件チェックの失敗時に、バージョンの不一致を判定するため、
+// 既存の項目を返却する指定を返却します。
+func (v *VersionAttribute) ReturnValuesOnConditionCheckFailure() types.ReturnValuesOnConditionCheckFailure {
+	if v == nil {
+		return ""
+	}
+	return types.ReturnValuesOnConditionCheckFailureAllOld
+}
+
+// IsConflict は、条件チェックの失敗時に返却された既存の項目から、バージョンの不一致かどうかを判定します。
+// バージョンが一致する場合は、バージョン以外の条件による失敗です。
+func (v *VersionAttribute) IsConflict(oldItem map[string]types.AttributeValue) bool {
+	if v == nil {
+		return false
+	}
+	actual, exists := versionValue(oldItem[v.Name])
+	if v.Expected == 0 {
+		return exists
+	}
+	return !exists || actual != v.Expected
+}
+
+// Increment は、エンティティのバージョン属性に、登録、更新後のバージョンを設定します。
+// エンティティがポインタでない場合は、設定しません。
+func (v *VersionAttribute) Increment() {
+	if v == nil || !v.field.IsValid() || !v.field.CanSet() {
+		return
+	}
+	switch v.field.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.field.SetUint(uint64(v.Next()))
+	default:
+		v.field.SetInt(v.Next())
+	}
+}
+
+// versionValue は、バージョン属性のAttributeValueから、バージョンを取得します。
+func versionValue(av types.AttributeValue) (int64, bool) {
+	n, ok := av.(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(n.Value, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
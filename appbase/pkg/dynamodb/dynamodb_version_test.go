@@ -0,0 +1,131 @@
+package dynamodb
+
+import (
+	"context"
+	"testing"
+
+	"example.com/appbase/pkg/dynamodb/input"
+	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/message"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type versionedEntity struct {
+	ID      string `dynamodbav:"id"`
+	Version int64  `dynamodbav:"version" dynamodb:"version"`
+}
+
+// updateItemStub は、UpdateItemの入力を記録し、指定したエラーを返却するDynamoDBAccessorです。
+type updateItemStub struct {
+	DynamoDBAccessor
+	err   error
+	input *dynamodb.UpdateItemInput
+}
+
+func (s *updateItemStub) UpdateItemSdkWithContext(ctx context.Context, input *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	s.input = input
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func TestVersionAttribute_AppendCondition(t *testing.T) {
+	tests := []struct {
+		name          string
+		expected      int64
+		condition     *string
+		wantCondition string
+		wantValue     bool
+	}{
+		{name: "未登録", expected: 0, wantCondition: "attribute_not_exists(#optlock_version)"},
+		{name: "登録済", expected: 2, wantCondition: "#optlock_version = :optlock_expected_version", wantValue: true},
+		{name: "他の条件とAND", expected: 2, condition: aws.String("#s = :s"),
+			wantCondition: "(#s = :s) AND (#optlock_version = :optlock_expected_version)", wantValue: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ver, err := GetVersionAttribute(&versionedEntity{ID: "1", Version: tt.expected})
+			assert.NoError(t, err)
+			condition, names, values := ver.AppendCondition(tt.condition, nil, nil)
+			assert.Equal(t, tt.wantCondition, *condition)
+			assert.Equal(t, "version", names["#optlock_version"])
+			if tt.wantValue {
+				assert.Equal(t, &types.AttributeValueMemberN{Value: "2"}, values[":optlock_expected_version"])
+			} else {
+				assert.NotContains(t, values, ":optlock_expected_version")
+			}
+		})
+	}
+}
+
+func TestVersionAttribute_IsConflict(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected int64
+		oldItem  map[string]types.AttributeValue
+		want     bool
+	}{
+		{name: "未登録を期待して既に登録済", expected: 0, oldItem: map[string]types.AttributeValue{"version": &types.AttributeValueMemberN{Value: "1"}}, want: true},
+		{name: "未登録を期待して項目なし", expected: 0, oldItem: nil, want: false},
+		{name: "バージョンが一致（他の条件による失敗）", expected: 2, oldItem: map[string]types.AttributeValue{"version": &types.AttributeValueMemberN{Value: "2"}}, want: false},
+		{name: "他の処理で更新済", expected: 2, oldItem: map[string]types.AttributeValue{"version": &types.AttributeValueMemberN{Value: "3"}}, want: true},
+		{name: "他の処理で削除済", expected: 2, oldItem: nil, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ver := &VersionAttribute{Name: "version", Expected: tt.expected}
+			assert.Equal(t, tt.want, ver.IsConflict(tt.oldItem))
+		})
+	}
+}
+
+func TestUpdateOneWithVersion(t *testing.T) {
+	msg, err := message.NewMessageSource()
+	assert.NoError(t, err)
+	logger, err := logging.NewLogger(msg)
+	assert.NoError(t, err)
+	newInput := func(entity *versionedEntity) input.UpdateInput {
+		return input.UpdateInput{
+			PrimaryKey:       input.PrimaryKey{PartitionKey: input.Attribute{Name: "id", Value: entity.ID}},
+			UpdateAttributes: []*input.Attribute{{Name: "name", Value: "taro"}},
+			VersionedEntity:  entity,
+		}
+	}
+
+	t.Run("成功した場合のみバージョンを加算", func(t *testing.T) {
+		stub := &updateItemStub{}
+		entity := &versionedEntity{ID: "1", Version: 2}
+		err := NewDynamoDBTemplate(logger, stub).UpdateOneWithContext(context.Background(), "test", newInput(entity))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), entity.Version)
+		// 競合の判定のため、条件チェックの失敗時は既存の項目を返却させる
+		assert.Equal(t, types.ReturnValuesOnConditionCheckFailureAllOld, stub.input.ReturnValuesOnConditionCheckFailure)
+	})
+
+	t.Run("バージョンの不一致は楽観ロックエラーでバージョンは変えない", func(t *testing.T) {
+		stub := &updateItemStub{err: &types.ConditionalCheckFailedException{
+			Item: map[string]types.AttributeValue{"version": &types.AttributeValueMemberN{Value: "3"}},
+		}}
+		entity := &versionedEntity{ID: "1", Version: 2}
+		err := NewDynamoDBTemplate(logger, stub).UpdateOneWithContext(context.Background(), "test", newInput(entity))
+		var optimisticLockError *OptimisticLockError
+		assert.True(t, errors.As(err, &optimisticLockError))
+		assert.Equal(t, int64(2), optimisticLockError.ExpectedVersion)
+		assert.Equal(t, int64(2), entity.Version)
+	})
+
+	t.Run("バージョン以外の条件による失敗", func(t *testing.T) {
+		stub := &updateItemStub{err: &types.ConditionalCheckFailedException{
+			Item: map[string]types.AttributeValue{"version": &types.AttributeValueMemberN{Value: "2"}},
+		}}
+		entity := &versionedEntity{ID: "1", Version: 2}
+		err := NewDynamoDBTemplate(logger, stub).UpdateOneWithContext(context.Background(), "test", newInput(entity))
+		assert.ErrorIs(t, err, ErrUpdateWithCondtion)
+		assert.Equal(t, int64(2), entity.Version)
+	})
+}
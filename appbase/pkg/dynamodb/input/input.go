@@ -3,7 +3,10 @@ input パッケージは、検索条件の入力データを扱うパッケー
 */
 package input
 
-import "example.com/appbase/pkg/dynamodb/gsi"
+import (
+	"example.com/appbase/pkg/dynamodb/gsi"
+	"example.com/appbase/pkg/dynamodb/tables"
+)
 
 // PKOnlyQueryInput は、プライマリキーの完全一致の条件指定による検索時のインプット構造体
 type PKOnlyQueryInput struct {
@@ -43,6 +46,51 @@ type GsiQueryInput struct {
 	LimitPerQuery *int32
 }
 
+// ScanInput は、テーブル（またはGSI）全体のスキャンによる複数検索用のインプット構造体
+type ScanInput struct {
+	// GSI名（GSIをスキャンする場合のみ指定）
+	GSIName gsi.DynamoDBGSIName
+	// 取得項目
+	SelectAttributes []string
+	// フィルタ条件
+	WhereClauses []*WhereClause
+	// 強い整合性読み込みの使用有無（GSIのスキャンでは指定不可）
+	ConsitentRead bool
+	// 1回のスキャンで評価する件数の上限値
+	LimitPerScan *int32
+	// 並列スキャンのセグメント数（1以下の場合は並列スキャンしない）
+	TotalSegments int32
+	// 並列スキャンの同時実行数の上限値（0以下の場合はセグメント数）
+	MaxConcurrency int
+	// 1秒あたりの消費読み込みキャパシティユニット（RCU）の上限値（0以下の場合は制限しない）
+	// 本番のトラフィックに影響を与えないよう、バッチ処理等でのスキャンの流量を制御する場合に指定します。
+	RCULimitPerSecond float64
+}
+
+// BatchGetInput は、プライマリキーの完全一致による一括検索時のインプット構造体
+type BatchGetInput struct {
+	// プライマリキーのリスト
+	PrimaryKeys []PrimaryKey
+	// 取得項目
+	SelectAttributes []string
+	// 強い整合性読み込みの使用有無
+	ConsitentRead bool
+}
+
+// TransactGetInput は、トランザクションによる複数テーブルからの一括取得時の、1件分のインプット構造体
+type TransactGetInput struct {
+	// テーブル名
+	TableName tables.DynamoDBTableName
+	// プライマリキー
+	PrimaryKey PrimaryKey
+	// 取得項目
+	SelectAttributes []string
+	// 取得結果を格納する構造体のポインタ
+	OutEntity any
+	// 取得結果の有無（取得後に設定されます）
+	Found bool
+}
+
 // UpdateInput は、更新時のインプット構造体
 type UpdateInput struct {
 	// プライマリキーの条件
@@ -53,6 +101,9 @@ type UpdateInput struct {
 	UpdateAttributes []*Attribute
 	// 削除項目名（更新時に、属性の値事態を削除したい）
 	RemoveAttributeNames []string
+	// 楽観ロックを行う場合の、バージョン属性（dynamodb:"version"タグ）を持つエンティティのポインタ
+	// 指定した場合、バージョンの一致の条件とバージョンのインクリメントを付加し、更新後にエンティティのバージョンを更新します。
+	VersionedEntity any
 }
 
 // DeleteInput は、削除時のインプット構造体
@@ -61,6 +112,17 @@ type DeleteInput struct {
 	PrimaryKey PrimaryKey
 	// 条件付き削除の条件
 	WhereClauses []*WhereClause
+	// 楽観ロックを行う場合の、バージョン属性（dynamodb:"version"タグ）を持つエンティティ
+	// 指定した場合、バージョンの一致の条件を付加します。
+	VersionedEntity any
+}
+
+// ConditionCheckInput は、トランザクションでの条件チェック時のインプット構造体
+type ConditionCheckInput struct {
+	// プライマリキーの条件
+	PrimaryKey PrimaryKey
+	// チェックする条件
+	WhereClauses []*WhereClause
 }
 
 // Attribute は、属性の名称と値のペア構造体です。
@@ -103,6 +165,18 @@ const (
 )
 
 // WhereClause は、検索時のフィルタ条件または、更新・登録時の条件を表します。
+//
+// 属性名には、ドキュメントパスでマップ、リストの要素を指定できます（例："address.city"、"items[0].name"）。
+// Groupを指定すると、Group内の条件を括弧で囲んだ1つの条件として、AppendOpで連結します（Attribute、WhereOpは無視されます）。
+// 例）status = "A" AND (priority > 3 OR attribute_not_exists(due_date))
+//
+//	[]*WhereClause{
+//		{Attribute: Attribute{Name: "status", Value: "A"}, WhereOp: WHERE_EQUAL},
+//		{AppendOp: APPEND_AND, Group: []*WhereClause{
+//			{Attribute: Attribute{Name: "priority", Value: 3}, WhereOp: WHERE_GREATER_THAN},
+//			{Attribute: Attribute{Name: "due_date"}, WhereOp: WHERE_ATTRIBUTE_NOT_EXISTS, AppendOp: APPEND_OR},
+//		}},
+//	}
 type WhereClause struct {
 	// Where句で指定する属性
 	Attribute Attribute
@@ -110,6 +184,8 @@ type WhereClause struct {
 	WhereOp WhereOperator
 	// Where句を連結する演算子
 	AppendOp AppendOperator
+	// 括弧で囲むWhere句のグループ
+	Group []*WhereClause
 }
 
 // WhereOperator は、フィルタの条件指定する際の演算子です。
@@ -123,6 +199,25 @@ const (
 	WHERE_GREATER_THAN_EQ = WhereOperator("GreaterThanEqual")
 	WHERE_LESS_THAN       = WhereOperator("LessThan")
 	WHERE_LESS_THAN_EQ    = WhereOperator("LessThanEqual")
+	// 値のいずれかに一致（Attribute.Valueには、値のスライスを指定）
+	WHERE_IN = WhereOperator("In")
+	// Attribute.Value[0] <= 属性 <= Attribute.Value[1]（Attribute.Valueには、[2]anyを指定）
+	WHERE_BETWEEN = WhereOperator("Between")
+	// 文字列の部分一致、セットの要素、リストの要素を含む（Attribute.Valueには、文字列、または数値セット等の要素の値を指定）
+	WHERE_CONTAINS = WhereOperator("Contains")
+	// 属性が存在する（Attribute.Valueは不要）
+	WHERE_ATTRIBUTE_EXISTS = WhereOperator("AttributeExists")
+	// 属性が存在しない（Attribute.Valueは不要）
+	WHERE_ATTRIBUTE_NOT_EXISTS = WhereOperator("AttributeNotExists")
+	// 属性のデータ型が一致（Attribute.Valueには、AttributeTypeを指定）
+	WHERE_ATTRIBUTE_TYPE = WhereOperator("AttributeType")
+	// 属性のサイズ（文字列の長さ、バイナリのバイト数、セット、リスト、マップの要素数）の比較
+	WHERE_SIZE_EQUAL           = WhereOperator("SizeEqual")
+	WHERE_SIZE_NOT_EQUAL       = WhereOperator("SizeNotEqual")
+	WHERE_SIZE_GREATER_THAN    = WhereOperator("SizeGreaterThan")
+	WHERE_SIZE_GREATER_THAN_EQ = WhereOperator("SizeGreaterThanEqual")
+	WHERE_SIZE_LESS_THAN       = WhereOperator("SizeLessThan")
+	WHERE_SIZE_LESS_THAN_EQ    = WhereOperator("SizeLessThanEqual")
 )
 
 // AppendOperator は、フィルタの条件を連結する際の演算子です。
@@ -132,3 +227,19 @@ const (
 	APPEND_AND = AppendOperator("And")
 	APPEND_OR  = AppendOperator("Or")
 )
+
+// AttributeType は、WHERE_ATTRIBUTE_TYPEで指定する属性のデータ型です。
+type AttributeType string
+
+const (
+	ATTRIBUTE_TYPE_STRING     = AttributeType("S")
+	ATTRIBUTE_TYPE_STRING_SET = AttributeType("SS")
+	ATTRIBUTE_TYPE_NUMBER     = AttributeType("N")
+	ATTRIBUTE_TYPE_NUMBER_SET = AttributeType("NS")
+	ATTRIBUTE_TYPE_BINARY     = AttributeType("B")
+	ATTRIBUTE_TYPE_BINARY_SET = AttributeType("BS")
+	ATTRIBUTE_TYPE_BOOLEAN    = AttributeType("BOOL")
+	ATTRIBUTE_TYPE_NULL       = AttributeType("NULL")
+	ATTRIBUTE_TYPE_LIST       = AttributeType("L")
+	ATTRIBUTE_TYPE_MAP        = AttributeType("M")
+)
@@ -0,0 +1,487 @@
+/*
+repository パッケージは、DynamoDBのエンティティの型付きのRepositoryを提供するパッケージです。
+*/
+package repository
+
+import (
+	"context"
+	"reflect"
+
+	"example.com/appbase/pkg/apcontext"
+	mydynamodb "example.com/appbase/pkg/dynamodb"
+	"example.com/appbase/pkg/dynamodb/gsi"
+	"example.com/appbase/pkg/dynamodb/input"
+	"example.com/appbase/pkg/dynamodb/tables"
+	"example.com/appbase/pkg/transaction"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/cockroachdb/errors"
+)
+
+// Repository は、エンティティTの型付きのDynamoDBアクセスを提供するインタフェースです。
+// テーブルのプライマリキー、GSIのキーは、エンティティの構造体タグ（dynamodb:"pk"、"sk"、"gsi_pk=GSI名"、"gsi_sk=GSI名"）で定義します。
+// 楽観ロックのバージョン属性（dynamodb:"version"）がある場合は、登録、更新、削除時に楽観ロックを行います。
+type Repository[T any] interface {
+	// TableName は、テーブル名を返却します。
+	TableName() tables.DynamoDBTableName
+	// Get は、プライマリキーの完全一致で項目を1件取得します。項目がない場合は、ErrRecordNotFoundを返却します。
+	Get(key Key, opts ...QueryOption) (*T, error)
+	// GetWithContext は、goroutine向けに渡されたContextを利用して、プライマリキーの完全一致で項目を1件取得します。
+	GetWithContext(ctx context.Context, key Key, opts ...QueryOption) (*T, error)
+	// Query は、パーティションキーの完全一致とオプションの条件で、ベーステーブルから項目を取得します。
+	// 項目がない場合は、エラーとせずに空のスライスを返却します。
+	Query(partitionKey any, opts ...QueryOption) ([]T, error)
+	// QueryWithContext は、goroutine向けに渡されたContextを利用して、ベーステーブルから項目を取得します。
+	QueryWithContext(ctx context.Context, partitionKey any, opts ...QueryOption) ([]T, error)
+	// QueryByGSI は、GSIのパーティションキーの完全一致とオプションの条件で、項目を取得します。
+	// 項目がない場合は、エラーとせずに空のスライスを返却します。
+	QueryByGSI(indexName gsi.DynamoDBGSIName, partitionKey any, opts ...QueryOption) ([]T, error)
+	// QueryByGSIWithContext は、goroutine向けに渡されたContextを利用して、GSIで項目を取得します。
+	QueryByGSIWithContext(ctx context.Context, indexName gsi.DynamoDBGSIName, partitionKey any, opts ...QueryOption) ([]T, error)
+	// GetMany は、プライマリキーの完全一致で項目を一括取得します。取得結果の順序は、プライマリキーの指定順と一致しません。
+	// 項目がない場合は、エラーとせずに空のスライスを返却します。
+	GetMany(keys []Key, opts ...QueryOption) ([]T, error)
+	// GetManyWithContext は、goroutine向けに渡されたContextを利用して、プライマリキーの完全一致で項目を一括取得します。
+	GetManyWithContext(ctx context.Context, keys []Key, opts ...QueryOption) ([]T, error)
+	// Put は、項目を登録します。同じプライマリキーの項目がある場合は、ErrKeyDuplicaitonを返却します。
+	Put(entity *T) error
+	// PutWithContext は、goroutine向けに渡されたContextを利用して、項目を登録します。
+	PutWithContext(ctx context.Context, entity *T) error
+	// PutMany は、項目を一括登録します。Putと異なり、同じプライマリキーの項目がある場合は置き換えます。
+	PutMany(entities []T) error
+	// PutManyWithContext は、goroutine向けに渡されたContextを利用して、項目を一括登録します。
+	PutManyWithContext(ctx context.Context, entities []T) error
+	// Update は、エンティティのプライマリキーの項目の、指定した属性（省略時はプライマリキー、バージョン属性以外の全ての属性）を更新します。
+	// 値が空で登録されない属性（omitempty）を指定した場合は、属性を削除します。
+	Update(entity *T, attributeNames ...string) error
+	// UpdateWithContext は、goroutine向けに渡されたContextを利用して、項目を更新します。
+	UpdateWithContext(ctx context.Context, entity *T, attributeNames ...string) error
+	// Delete は、エンティティのプライマリキーの項目を削除します。
+	Delete(entity *T) error
+	// DeleteWithContext は、goroutine向けに渡されたContextを利用して、項目を削除します。
+	DeleteWithContext(ctx context.Context, entity *T) error
+	// DeleteMany は、エンティティのプライマリキーの項目を一括削除します。楽観ロックは行いません。
+	DeleteMany(entities []T) error
+	// DeleteManyWithContext は、goroutine向けに渡されたContextを利用して、項目を一括削除します。
+	DeleteManyWithContext(ctx context.Context, entities []T) error
+	// PutWithTransaction は、トランザクションで項目を登録します。
+	PutWithTransaction(entity *T) error
+	// PutWithTransactionInContext は、goroutine向けに渡されたContextを利用して、トランザクションで項目を登録します。
+	PutWithTransactionInContext(ctx context.Context, entity *T) error
+	// UpdateWithTransaction は、トランザクションで項目を更新します。
+	UpdateWithTransaction(entity *T, attributeNames ...string) error
+	// UpdateWithTransactionInContext は、goroutine向けに渡されたContextを利用して、トランザクションで項目を更新します。
+	UpdateWithTransactionInContext(ctx context.Context, entity *T, attributeNames ...string) error
+	// DeleteWithTransaction は、トランザクションで項目を削除します。
+	DeleteWithTransaction(entity *T) error
+	// DeleteWithTransactionInContext は、goroutine向けに渡されたContextを利用して、トランザクションで項目を削除します。
+	DeleteWithTransactionInContext(ctx context.Context, entity *T) error
+	// GetManyWithTransaction は、読み込みトランザクション（TransactGetItems）で、プライマリキーの完全一致で最大100件の項目をアトミックに取得します。
+	// 取得結果は、プライマリキーの指定順で、項目がないプライマリキーを除いて返却します。
+	// 書き込みトランザクションと異なり、TransactionManagerのトランザクションとは関係なく、即時に実行されます。
+	GetManyWithTransaction(keys []Key, opts ...QueryOption) ([]T, error)
+	// GetManyWithTransactionInContext は、goroutine向けに渡されたContextを利用して、読み込みトランザクションで項目を一括取得します。
+	GetManyWithTransactionInContext(ctx context.Context, keys []Key, opts ...QueryOption) ([]T, error)
+}
+
+// Key は、プライマリキーの値です。
+type Key struct {
+	// パーティションキーの値
+	PartitionKey any
+	// ソートキーの値（ソートキーがないテーブルの場合は不要）
+	SortKey any
+}
+
+// QueryOption は、Repositoryの検索時のFunctional Optionパターンによるオプションの関数です。
+type QueryOption func(*QueryOptions)
+
+// QueryOptions は、Repositoryの検索時のオプションを保持します。
+type QueryOptions struct {
+	// ソートキーの条件の演算子
+	SortKeyOp input.SortKeyOperator
+	// ソートキーの条件の値（SORTKEY_BETWEENの場合は[2]any）
+	SortKeyValue any
+	// ソート順
+	OrderBy input.OrderBy
+	// フィルタ条件
+	WhereClauses []*input.WhereClause
+	// 取得項目
+	SelectAttributes []string
+	// 強い整合性読み込みの使用有無（GSIの検索では無効）
+	ConsistentRead bool
+	// 取得件数の上限値（GSIの検索のみ有効）
+	TotalLimit *int32
+}
+
+// WithSortKeyCondition は、ソートキーの条件を指定するオプションを生成します。
+func WithSortKeyCondition(op input.SortKeyOperator, value any) QueryOption {
+	return func(o *QueryOptions) {
+		o.SortKeyOp = op
+		o.SortKeyValue = value
+	}
+}
+
+// WithOrderBy は、ソートキーによるソート順を指定するオプションを生成します。
+func WithOrderBy(orderBy input.OrderBy) QueryOption {
+	return func(o *QueryOptions) {
+		o.OrderBy = orderBy
+	}
+}
+
+// WithFilter は、キー以外の属性によるフィルタ条件を指定するオプションを生成します。
+func WithFilter(whereClauses ...*input.WhereClause) QueryOption {
+	return func(o *QueryOptions) {
+		o.WhereClauses = append(o.WhereClauses, whereClauses...)
+	}
+}
+
+// WithSelectAttributes は、取得項目を指定するオプションを生成します。
+func WithSelectAttributes(attributeNames ...string) QueryOption {
+	return func(o *QueryOptions) {
+		o.SelectAttributes = append(o.SelectAttributes, attributeNames...)
+	}
+}
+
+// WithConsistentRead は、強い整合性読み込みを使用するオプションを生成します。
+func WithConsistentRead() QueryOption {
+	return func(o *QueryOptions) {
+		o.ConsistentRead = true
+	}
+}
+
+// WithTotalLimit は、GSIの検索での取得件数の上限値を指定するオプションを生成します。
+func WithTotalLimit(limit int32) QueryOption {
+	return func(o *QueryOptions) {
+		o.TotalLimit = &limit
+	}
+}
+
+// NewRepository は、エンティティTのRepositoryを作成します。
+// エンティティの構造体タグから取得したプライマリキー、GSIの定義を、テーブルの定義として登録します（tables.SetPrimaryKey、gsi.AddGSIKeyPair）。
+func NewRepository[T any](dynamodbTemplate transaction.TransactionalDynamoDBTemplate, tableName tables.DynamoDBTableName) (Repository[T], error) {
+	metadata, err := mydynamodb.GetEntityMetadata(reflect.TypeFor[T]())
+	if err != nil {
+		return nil, err
+	}
+	metadata.Register(tableName)
+	return &defaultRepository[T]{
+		dynamodbTemplate: dynamodbTemplate,
+		tableName:        tableName,
+		metadata:         metadata,
+	}, nil
+}
+
+// defaultRepository は、Repositoryを実装する構造体です。
+type defaultRepository[T any] struct {
+	dynamodbTemplate transaction.TransactionalDynamoDBTemplate
+	tableName        tables.DynamoDBTableName
+	metadata         *mydynamodb.EntityMetadata
+}
+
+// TableName implements Repository.
+func (r *defaultRepository[T]) TableName() tables.DynamoDBTableName {
+	return r.tableName
+}
+
+// Get implements Repository.
+func (r *defaultRepository[T]) Get(key Key, opts ...QueryOption) (*T, error) {
+	return r.GetWithContext(apcontext.Context, key, opts...)
+}
+
+// GetWithContext implements Repository.
+func (r *defaultRepository[T]) GetWithContext(ctx context.Context, key Key, opts ...QueryOption) (*T, error) {
+	options := newQueryOptions(opts)
+	var entity T
+	err := r.dynamodbTemplate.FindOneByTableKeyWithContext(ctx, r.tableName, input.PKOnlyQueryInput{
+		PrimaryKey:       r.newPrimaryKey(key),
+		SelectAttributes: options.SelectAttributes,
+		ConsitentRead:    options.ConsistentRead,
+	}, &entity)
+	if err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+// GetMany implements Repository.
+func (r *defaultRepository[T]) GetMany(keys []Key, opts ...QueryOption) ([]T, error) {
+	return r.GetManyWithContext(apcontext.Context, keys, opts...)
+}
+
+// GetManyWithContext implements Repository.
+func (r *defaultRepository[T]) GetManyWithContext(ctx context.Context, keys []Key, opts ...QueryOption) ([]T, error) {
+	options := newQueryOptions(opts)
+	primaryKeys := make([]input.PrimaryKey, 0, len(keys))
+	for _, key := range keys {
+		primaryKeys = append(primaryKeys, r.newPrimaryKey(key))
+	}
+	var entities []T
+	err := r.dynamodbTemplate.FindManyByKeysWithContext(ctx, r.tableName, input.BatchGetInput{
+		PrimaryKeys:      primaryKeys,
+		SelectAttributes: options.SelectAttributes,
+		ConsitentRead:    options.ConsistentRead,
+	}, &entities)
+	return toResult(entities, err)
+}
+
+// Query implements Repository.
+func (r *defaultRepository[T]) Query(partitionKey any, opts ...QueryOption) ([]T, error) {
+	return r.QueryWithContext(apcontext.Context, partitionKey, opts...)
+}
+
+// QueryWithContext implements Repository.
+func (r *defaultRepository[T]) QueryWithContext(ctx context.Context, partitionKey any, opts ...QueryOption) ([]T, error) {
+	options := newQueryOptions(opts)
+	var sortKeyName string
+	if r.metadata.SortKey != nil {
+		sortKeyName = r.metadata.SortKey.AttributeName
+	}
+	primaryKey, err := newKeyCondition(r.metadata.PartitionKey.AttributeName, sortKeyName, partitionKey, options)
+	if err != nil {
+		return nil, err
+	}
+	var entities []T
+	err = r.dynamodbTemplate.FindSomeByTableKeyWithContext(ctx, r.tableName, input.PKQueryInput{
+		PrimaryKey:       *primaryKey,
+		SelectAttributes: options.SelectAttributes,
+		WhereClauses:     options.WhereClauses,
+		ConsitentRead:    options.ConsistentRead,
+	}, &entities)
+	return toResult(entities, err)
+}
+
+// QueryByGSI implements Repository.
+func (r *defaultRepository[T]) QueryByGSI(indexName gsi.DynamoDBGSIName, partitionKey any, opts ...QueryOption) ([]T, error) {
+	return r.QueryByGSIWithContext(apcontext.Context, indexName, partitionKey, opts...)
+}
+
+// QueryByGSIWithContext implements Repository.
+func (r *defaultRepository[T]) QueryByGSIWithContext(ctx context.Context, indexName gsi.DynamoDBGSIName, partitionKey any, opts ...QueryOption) ([]T, error) {
+	keyPair, ok := r.metadata.GSIKeys[indexName]
+	if !ok {
+		return nil, errors.Errorf("GSI[%s]がエンティティに定義されていません", indexName)
+	}
+	options := newQueryOptions(opts)
+	indexKey, err := newKeyCondition(keyPair.PartitionKey, keyPair.SortKey, partitionKey, options)
+	if err != nil {
+		return nil, err
+	}
+	var entities []T
+	err = r.dynamodbTemplate.FindSomeByGSIKeyWithContext(ctx, r.tableName, input.GsiQueryInput{
+		GSIName:          indexName,
+		IndexKey:         *indexKey,
+		SelectAttributes: options.SelectAttributes,
+		WhereClauses:     options.WhereClauses,
+		TotalLimit:       options.TotalLimit,
+	}, &entities)
+	return toResult(entities, err)
+}
+
+// Put implements Repository.
+func (r *defaultRepository[T]) Put(entity *T) error {
+	return r.PutWithContext(apcontext.Context, entity)
+}
+
+// PutWithContext implements Repository.
+func (r *defaultRepository[T]) PutWithContext(ctx context.Context, entity *T) error {
+	return r.dynamodbTemplate.CreateOneWithContext(ctx, r.tableName, entity)
+}
+
+// PutMany implements Repository.
+func (r *defaultRepository[T]) PutMany(entities []T) error {
+	return r.PutManyWithContext(apcontext.Context, entities)
+}
+
+// PutManyWithContext implements Repository.
+func (r *defaultRepository[T]) PutManyWithContext(ctx context.Context, entities []T) error {
+	return r.dynamodbTemplate.CreateManyWithContext(ctx, r.tableName, entities)
+}
+
+// Update implements Repository.
+func (r *defaultRepository[T]) Update(entity *T, attributeNames ...string) error {
+	return r.UpdateWithContext(apcontext.Context, entity, attributeNames...)
+}
+
+// UpdateWithContext implements Repository.
+func (r *defaultRepository[T]) UpdateWithContext(ctx context.Context, entity *T, attributeNames ...string) error {
+	updateInput, err := r.newUpdateInput(entity, attributeNames)
+	if err != nil {
+		return err
+	}
+	return r.dynamodbTemplate.UpdateOneWithContext(ctx, r.tableName, *updateInput)
+}
+
+// Delete implements Repository.
+func (r *defaultRepository[T]) Delete(entity *T) error {
+	return r.DeleteWithContext(apcontext.Context, entity)
+}
+
+// DeleteWithContext implements Repository.
+func (r *defaultRepository[T]) DeleteWithContext(ctx context.Context, entity *T) error {
+	return r.dynamodbTemplate.DeleteOneWithContext(ctx, r.tableName, r.newDeleteInput(entity))
+}
+
+// DeleteMany implements Repository.
+func (r *defaultRepository[T]) DeleteMany(entities []T) error {
+	return r.DeleteManyWithContext(apcontext.Context, entities)
+}
+
+// DeleteManyWithContext implements Repository.
+func (r *defaultRepository[T]) DeleteManyWithContext(ctx context.Context, entities []T) error {
+	primaryKeys := make([]input.PrimaryKey, 0, len(entities))
+	for i := range entities {
+		primaryKeys = append(primaryKeys, r.metadata.PrimaryKey(&entities[i]))
+	}
+	return r.dynamodbTemplate.DeleteManyWithContext(ctx, r.tableName, primaryKeys)
+}
+
+// PutWithTransaction implements Repository.
+func (r *defaultRepository[T]) PutWithTransaction(entity *T) error {
+	return r.PutWithTransactionInContext(apcontext.Context, entity)
+}
+
+// PutWithTransactionInContext implements Repository.
+func (r *defaultRepository[T]) PutWithTransactionInContext(ctx context.Context, entity *T) error {
+	return r.dynamodbTemplate.CreateOneWithTransactionInContext(ctx, r.tableName, entity)
+}
+
+// UpdateWithTransaction implements Repository.
+func (r *defaultRepository[T]) UpdateWithTransaction(entity *T, attributeNames ...string) error {
+	return r.UpdateWithTransactionInContext(apcontext.Context, entity, attributeNames...)
+}
+
+// UpdateWithTransactionInContext implements Repository.
+func (r *defaultRepository[T]) UpdateWithTransactionInContext(ctx context.Context, entity *T, attributeNames ...string) error {
+	updateInput, err := r.newUpdateInput(entity, attributeNames)
+	if err != nil {
+		return err
+	}
+	return r.dynamodbTemplate.UpdateOneWithTransactionInContext(ctx, r.tableName, *updateInput)
+}
+
+// DeleteWithTransaction implements Repository.
+func (r *defaultRepository[T]) DeleteWithTransaction(entity *T) error {
+	return r.DeleteWithTransactionInContext(apcontext.Context, entity)
+}
+
+// DeleteWithTransactionInContext implements Repository.
+func (r *defaultRepository[T]) DeleteWithTransactionInContext(ctx context.Context, entity *T) error {
+	return r.dynamodbTemplate.DeleteOneWithTransactionInContext(ctx, r.tableName, r.newDeleteInput(entity))
+}
+
+// GetManyWithTransaction implements Repository.
+func (r *defaultRepository[T]) GetManyWithTransaction(keys []Key, opts ...QueryOption) ([]T, error) {
+	return r.GetManyWithTransactionInContext(apcontext.Context, keys, opts...)
+}
+
+// GetManyWithTransactionInContext implements Repository.
+func (r *defaultRepository[T]) GetManyWithTransactionInContext(ctx context.Context, keys []Key, opts ...QueryOption) ([]T, error) {
+	options := newQueryOptions(opts)
+	outEntities := make([]T, len(keys))
+	inputs := make([]*input.TransactGetInput, 0, len(keys))
+	for i, key := range keys {
+		inputs = append(inputs, &input.TransactGetInput{
+			TableName:        r.tableName,
+			PrimaryKey:       r.newPrimaryKey(key),
+			SelectAttributes: options.SelectAttributes,
+			OutEntity:        &outEntities[i],
+		})
+	}
+	if err := r.dynamodbTemplate.FindManyByKeysWithTransactionInContext(ctx, inputs); err != nil {
+		return nil, err
+	}
+	entities := make([]T, 0, len(keys))
+	for i, v := range inputs {
+		if v.Found {
+			entities = append(entities, outEntities[i])
+		}
+	}
+	return entities, nil
+}
+
+// newPrimaryKey は、プライマリキーの値から、プライマリキーの完全一致の条件を作成します。
+func (r *defaultRepository[T]) newPrimaryKey(key Key) input.PrimaryKey {
+	primaryKey := input.PrimaryKey{
+		PartitionKey: input.Attribute{Name: r.metadata.PartitionKey.AttributeName, Value: key.PartitionKey},
+	}
+	if r.metadata.SortKey != nil {
+		primaryKey.SortKey = &input.Attribute{Name: r.metadata.SortKey.AttributeName, Value: key.SortKey}
+	}
+	return primaryKey
+}
+
+// newUpdateInput は、エンティティの値から、更新時のインプット構造体を作成します。
+func (r *defaultRepository[T]) newUpdateInput(entity *T, attributeNames []string) (*input.UpdateInput, error) {
+	// 登録時と同じ規則（omitempty等）で属性の有無を判定するため、一度AttributeValueに変換する
+	attributes, err := attributevalue.MarshalMap(entity)
+	if err != nil {
+		return nil, errors.Wrap(err, "Updateで構造体をAttributeValueのMap変換時にエラー")
+	}
+	if len(attributeNames) == 0 {
+		for _, f := range r.metadata.Fields {
+			if r.metadata.IsUpdatable(f.AttributeName) {
+				attributeNames = append(attributeNames, f.AttributeName)
+			}
+		}
+	}
+	updateInput := &input.UpdateInput{PrimaryKey: r.metadata.PrimaryKey(entity)}
+	for _, name := range attributeNames {
+		field := r.metadata.Field(name)
+		if field == nil || !r.metadata.IsUpdatable(name) {
+			return nil, errors.Errorf("Updateで更新できない属性が指定されています: %s", name)
+		}
+		if _, ok := attributes[name]; !ok {
+			updateInput.RemoveAttributeNames = append(updateInput.RemoveAttributeNames, name)
+			continue
+		}
+		updateInput.UpdateAttributes = append(updateInput.UpdateAttributes, &input.Attribute{Name: name, Value: field.Value(entity)})
+	}
+	if r.metadata.Version != nil {
+		updateInput.VersionedEntity = entity
+	}
+	return updateInput, nil
+}
+
+// newDeleteInput は、エンティティの値から、削除時のインプット構造体を作成します。
+func (r *defaultRepository[T]) newDeleteInput(entity *T) input.DeleteInput {
+	deleteInput := input.DeleteInput{PrimaryKey: r.metadata.PrimaryKey(entity)}
+	if r.metadata.Version != nil {
+		deleteInput.VersionedEntity = entity
+	}
+	return deleteInput
+}
+
+// newQueryOptions は、オプションの関数を適用したQueryOptionsを作成します。
+func newQueryOptions(opts []QueryOption) *QueryOptions {
+	options := &QueryOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// newKeyCondition は、パーティションキーの値とオプションのソートキーの条件から、キーの条件を作成します。
+func newKeyCondition(partitionKeyName string, sortKeyName string, partitionKey any, options *QueryOptions) (*input.PrimaryKey, error) {
+	keyCondition := &input.PrimaryKey{
+		PartitionKey:   input.Attribute{Name: partitionKeyName, Value: partitionKey},
+		SortkeyOrderBy: options.OrderBy,
+	}
+	if options.SortKeyValue != nil {
+		if sortKeyName == "" {
+			return nil, errors.New("ソートキーがないため、ソートキーの条件は指定できません")
+		}
+		keyCondition.SortKey = &input.Attribute{Name: sortKeyName, Value: options.SortKeyValue}
+		keyCondition.SortKeyOp = options.SortKeyOp
+	}
+	return keyCondition, nil
+}
+
+// toResult は、検索結果が0件の場合に、エラーとせず空のスライスに変換します。
+func toResult[T any](entities []T, err error) ([]T, error) {
+	if errors.Is(err, mydynamodb.ErrRecordNotFound) {
+		return []T{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/message"
+	"example.com/appbase/pkg/transaction"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type versionedTodo struct {
+	ID      string `dynamodbav:"id" dynamodb:"pk"`
+	Title   string `dynamodbav:"title"`
+	Version int64  `dynamodbav:"version" dynamodb:"version"`
+}
+
+// tableStub は、一括登録した項目を保持し、更新時に楽観ロックのバージョンが一致しない場合は条件チェックの失敗を返却するDynamoDBAccessorです。
+type tableStub struct {
+	transaction.TransactionalDynamoDBAccessor
+	items map[string]map[string]types.AttributeValue
+}
+
+func (s *tableStub) BatchWriteItemSdkWithContext(ctx context.Context, input *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	for _, requests := range input.RequestItems {
+		for _, r := range requests {
+			id := r.PutRequest.Item["id"].(*types.AttributeValueMemberS).Value
+			s.items[id] = r.PutRequest.Item
+		}
+	}
+	return &dynamodb.BatchWriteItemOutput{}, nil
+}
+
+func (s *tableStub) UpdateItemSdkWithContext(ctx context.Context, input *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	id := input.Key["id"].(*types.AttributeValueMemberS).Value
+	item := s.items[id]
+	// 期待するバージョンと、登録済の項目のバージョンが一致しない場合は、条件チェックの失敗とする
+	if expected, ok := input.ExpressionAttributeValues[":optlock_expected_version"]; !ok || item == nil ||
+		expected.(*types.AttributeValueMemberN).Value != item["version"].(*types.AttributeValueMemberN).Value {
+		return nil, &types.ConditionalCheckFailedException{Item: item}
+	}
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func TestPutManyAndUpdate(t *testing.T) {
+	msg, err := message.NewMessageSource()
+	assert.NoError(t, err)
+	logger, err := logging.NewLogger(msg)
+	assert.NoError(t, err)
+	stub := &tableStub{items: map[string]map[string]types.AttributeValue{}}
+	repo, err := NewRepository[versionedTodo](transaction.NewTransactionalDynamoDBTemplate(logger, stub), "todo")
+	assert.NoError(t, err)
+	todos := []versionedTodo{{ID: "1", Title: "a"}, {ID: "2", Title: "b"}}
+
+	err = repo.PutManyWithContext(context.Background(), todos)
+
+	assert.NoError(t, err)
+	// 一括登録でも、バージョンを1として登録し、エンティティのバージョンを更新する
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "1"}, stub.items["1"]["version"])
+	assert.Equal(t, int64(1), todos[0].Version)
+	assert.Equal(t, int64(1), todos[1].Version)
+
+	// 一括登録したエンティティを、そのまま楽観ロックで更新できる
+	todos[0].Title = "c"
+	err = repo.UpdateWithContext(context.Background(), &todos[0])
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), todos[0].Version)
+}
@@ -0,0 +1,14 @@
+/*
+erros パッケージは、エラー情報を扱うパッケージです。
+*/
+package errors
+
+// OptimisticLockError は、楽観ロックのバージョンの不一致（他の処理による更新、削除）を表すエラーのインタフェースです。
+// DynamoDB等のデータストアの楽観ロックのエラーが実装するため、データストアのパッケージに依存せずにerrors.Asで判定できます。
+type OptimisticLockError interface {
+	error
+	// LockTarget は、楽観ロックの対象（テーブル名等）を返却します。
+	LockTarget() string
+	// ExpectedLockVersion は、更新時に期待したバージョンを返却します。
+	ExpectedLockVersion() int64
+}
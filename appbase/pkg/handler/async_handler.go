@@ -5,22 +5,30 @@ package handler
 
 import (
 	"context"
+	"maps"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"example.com/appbase/pkg/apcontext"
+	"example.com/appbase/pkg/async"
 	"example.com/appbase/pkg/config"
 	"example.com/appbase/pkg/constant"
 	"example.com/appbase/pkg/idempotency"
 	"example.com/appbase/pkg/logging"
 	"example.com/appbase/pkg/message"
+	"example.com/appbase/pkg/otel"
 	"example.com/appbase/pkg/transaction"
 	"example.com/appbase/pkg/transaction/model"
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/cockroachdb/errors"
+	"go.opentelemetry.io/otel/codes"
 )
 
 const (
@@ -30,6 +38,14 @@ const (
 	TABLE_ACESS_RETRY_DURATION = time.Duration(500)
 	// TODO: リトライ回数の設定切り出し
 	TABLE_ACESS_RETRY_COUNT = 5
+	// メッセージの可視性タイムアウトを延長するハートビートの送信間隔（秒）のプロパティ名。0以下または未設定の場合はハートビートを送信しない
+	// キュー毎に設定する場合は、プロパティ名の末尾に"_"とキュー名を付与する（例：SQS_VISIBILITY_HEARTBEAT_INTERVAL_SECONDS_sample-queue）
+	SQS_VISIBILITY_HEARTBEAT_INTERVAL_SECONDS_NAME = "SQS_VISIBILITY_HEARTBEAT_INTERVAL_SECONDS"
+	// ハートビートで延長する可視性タイムアウト（秒）のプロパティ名。未設定の場合は送信間隔の2倍とする
+	// キュー毎に設定する場合は、プロパティ名の末尾に"_"とキュー名を付与する
+	SQS_VISIBILITY_HEARTBEAT_TIMEOUT_SECONDS_NAME = "SQS_VISIBILITY_HEARTBEAT_TIMEOUT_SECONDS"
+	// ChangeMessageVisibilityBatchで1回に指定できるメッセージの最大件数
+	SQS_CHANGE_MESSAGE_VISIBILITY_BATCH_MAX_COUNT = 10
 )
 
 var ErrMessageIdNotFound = errors.New("メッセージIDが取得できません")
@@ -42,16 +58,19 @@ type AsyncLambdaHandler struct {
 	config                     config.Config
 	logger                     logging.Logger
 	queueMessageItemRepository transaction.QueueMessageItemRepository
+	sqsAccessor                async.SQSAccessor
 }
 
 // NewAsyncLambdaHandler は、AsyncLambdaHandlerを作成します。
 func NewAsyncLambdaHandler(config config.Config,
 	logger logging.Logger,
-	queueMessageItemRepository transaction.QueueMessageItemRepository) *AsyncLambdaHandler {
+	queueMessageItemRepository transaction.QueueMessageItemRepository,
+	sqsAccessor async.SQSAccessor) *AsyncLambdaHandler {
 	return &AsyncLambdaHandler{
 		config:                     config,
 		logger:                     logger,
 		queueMessageItemRepository: queueMessageItemRepository,
+		sqsAccessor:                sqsAccessor,
 	}
 }
 
@@ -78,9 +97,16 @@ func (h *AsyncLambdaHandler) Handle(asyncControllerFunc AsyncControllerFunc) SQS
 			// FIFOの場合はメッセージをソート
 			h.sortMessages(event.Records)
 		}
+		// 処理中、処理待ちのメッセージが再度可視にならないよう、バッチ内の未処理のメッセージ全ての可視性タイムアウトを延長するハートビートを開始
+		heartbeat := h.startVisibilityHeartbeat(ctx, h.getQueueName(event.Records[0]), event.Records)
+		defer heartbeat.stop()
 		// 既にエラーになったメッセージのMessageIdを格納するための集合を初期化
 		failedMessageGroupIdSet := make(map[string]struct{})
 		for i, v := range event.Records {
+			if i > 0 {
+				// 処理結果によらず、前のメッセージは処理済のため、ハートビートの対象外とする
+				heartbeat.complete(event.Records[i-1].MessageId)
+			}
 			// ハンドラから受け取ったもとのContext（ctx）を毎回コンテキスト領域に格納しなおす
 			apcontext.Context = ctx
 
@@ -135,10 +161,21 @@ func (h *AsyncLambdaHandler) Handle(asyncControllerFunc AsyncControllerFunc) SQS
 }
 
 // doHandle は、SQSのメッセージを1件に対して、ディレード処理（ジョブ）を実行します。
-func (h *AsyncLambdaHandler) doHandle(sqsMsg events.SQSMessage, asyncControllerFunc AsyncControllerFunc) error {
+func (h *AsyncLambdaHandler) doHandle(sqsMsg events.SQSMessage, asyncControllerFunc AsyncControllerFunc) (resultErr error) {
 	queueName := h.getQueueName(sqsMsg)
 	messageId := sqsMsg.MessageId
 
+	// 送信元から伝搬されたトレースコンテキストと関連付けて、メッセージ1件の処理のSpanを開始
+	spanCtx, span := otel.StartSQSMessageSpan(apcontext.Context, queueName, sqsMsg)
+	defer func() {
+		if resultErr != nil {
+			span.RecordError(resultErr)
+			span.SetStatus(codes.Error, resultErr.Error())
+		}
+		span.End()
+	}()
+	apcontext.Context = spanCtx
+
 	h.logger.Debug("doHandle[QueueName: %s, MessageId: %s]", queueName, messageId)
 	// キューメッセージテーブルのキーを作成
 	status, err := h.checkMessageId(sqsMsg)
@@ -171,6 +208,114 @@ func (h *AsyncLambdaHandler) doHandle(sqsMsg events.SQSMessage, asyncControllerF
 	return asyncControllerFunc(sqsMsg)
 }
 
+// visibilityHeartbeat は、バッチ内の未処理のメッセージの可視性タイムアウトを延長するハートビートを表す構造体です。
+type visibilityHeartbeat struct {
+	mu sync.Mutex
+	// 可視性タイムアウトを延長する未処理のメッセージ（キー：メッセージID）
+	pending map[string]events.SQSMessage
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// complete は、処理済のメッセージを、可視性タイムアウトの延長の対象外とします。
+func (hb *visibilityHeartbeat) complete(messageId string) {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	delete(hb.pending, messageId)
+}
+
+// pendingMessages は、可視性タイムアウトの延長の対象の未処理のメッセージを返却します。
+func (hb *visibilityHeartbeat) pendingMessages() []events.SQSMessage {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	return slices.Collect(maps.Values(hb.pending))
+}
+
+// stop は、ハートビートを停止します。
+func (hb *visibilityHeartbeat) stop() {
+	if hb.cancel == nil {
+		return
+	}
+	hb.cancel()
+	<-hb.done
+}
+
+// startVisibilityHeartbeat は、設定した間隔で、バッチ内の未処理のメッセージの可視性タイムアウトを延長するgoroutineを開始します。
+// 処理中のメッセージだけでなく、処理待ちのメッセージも可視性タイムアウトを延長し、前のメッセージの処理中に再配信されないようにします。
+func (h *AsyncLambdaHandler) startVisibilityHeartbeat(ctx context.Context, queueName string, sqsMsgs []events.SQSMessage) *visibilityHeartbeat {
+	hb := &visibilityHeartbeat{pending: make(map[string]events.SQSMessage, len(sqsMsgs))}
+	for _, v := range sqsMsgs {
+		hb.pending[v.MessageId] = v
+	}
+	intervalSeconds := h.getQueueConfigInt(SQS_VISIBILITY_HEARTBEAT_INTERVAL_SECONDS_NAME, queueName, 0)
+	if intervalSeconds <= 0 || h.sqsAccessor == nil {
+		return hb
+	}
+	timeoutSeconds := h.getQueueConfigInt(SQS_VISIBILITY_HEARTBEAT_TIMEOUT_SECONDS_NAME, queueName, intervalSeconds*2)
+	h.logger.Debug("可視性タイムアウト延長のハートビート開始: 間隔[%d秒], 可視性タイムアウト[%d秒]", intervalSeconds, timeoutSeconds)
+
+	heartbeatCtx, cancel := context.WithCancel(ctx)
+	hb.cancel = cancel
+	hb.done = make(chan struct{})
+	go func() {
+		defer close(hb.done)
+		ticker := time.NewTicker(time.Duration(intervalSeconds) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-heartbeatCtx.Done():
+				return
+			case <-ticker.C:
+				for chunk := range slices.Chunk(hb.pendingMessages(), SQS_CHANGE_MESSAGE_VISIBILITY_BATCH_MAX_COUNT) {
+					if heartbeatCtx.Err() != nil {
+						return
+					}
+					h.changeMessageVisibilityBatch(heartbeatCtx, queueName, chunk, int32(timeoutSeconds))
+				}
+			}
+		}
+	}()
+	return hb
+}
+
+// changeMessageVisibilityBatch は、メッセージの可視性タイムアウトを一括で延長します。
+// 延長に失敗しても、Controllerの処理は継続するため警告ログ出力のみとします。
+func (h *AsyncLambdaHandler) changeMessageVisibilityBatch(ctx context.Context, queueName string, sqsMsgs []events.SQSMessage, timeoutSeconds int32) {
+	entries := make([]types.ChangeMessageVisibilityBatchRequestEntry, len(sqsMsgs))
+	for i, v := range sqsMsgs {
+		entries[i] = types.ChangeMessageVisibilityBatchRequestEntry{
+			// バッチ内で一意なIDとして、配列のインデックスを指定
+			Id:                aws.String(strconv.Itoa(i)),
+			ReceiptHandle:     aws.String(v.ReceiptHandle),
+			VisibilityTimeout: timeoutSeconds,
+		}
+	}
+	output, err := h.sqsAccessor.ChangeMessageVisibilityBatchSdkWithContext(ctx, queueName, &sqs.ChangeMessageVisibilityBatchInput{
+		Entries: entries,
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		for _, v := range sqsMsgs {
+			h.logger.WarnWithError(err, message.W_FW_8016, queueName, v.MessageId)
+		}
+		return
+	}
+	for _, failed := range output.Failed {
+		i, _ := strconv.Atoi(aws.ToString(failed.Id))
+		h.logger.WarnWithError(errors.Errorf("%s: %s", aws.ToString(failed.Code), aws.ToString(failed.Message)), message.W_FW_8016, queueName, sqsMsgs[i].MessageId)
+	}
+}
+
+// getQueueConfigInt は、キュー毎の設定値を取得します。キュー毎の設定がない場合は、全キュー共通の設定値を取得します。
+func (h *AsyncLambdaHandler) getQueueConfigInt(key string, queueName string, defaultValue int) int {
+	if value, found := h.config.GetIntWithContains(key + "_" + queueName); found {
+		return value
+	}
+	return h.config.GetInt(key, defaultValue)
+}
+
 // sortMessages は、メッセージをMessageGroupIdごとにSequenceNamberを昇順にします。
 func (h *AsyncLambdaHandler) sortMessages(sqsMsgs []events.SQSMessage) {
 	sort.Slice(sqsMsgs, func(i, j int) bool {
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"example.com/appbase/pkg/async"
+	"example.com/appbase/pkg/config"
+	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/message"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// changeVisibilityStub は、ChangeMessageVisibilityBatchの入力を通知し、エラーを返却するSQSAccessorです。
+type changeVisibilityStub struct {
+	async.SQSAccessor
+	called chan []string
+}
+
+func (s *changeVisibilityStub) ChangeMessageVisibilityBatchSdkWithContext(ctx context.Context, queueName string, input *sqs.ChangeMessageVisibilityBatchInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+	var receiptHandles []string
+	for _, v := range input.Entries {
+		receiptHandles = append(receiptHandles, *v.ReceiptHandle)
+	}
+	select {
+	case s.called <- receiptHandles:
+	default:
+	}
+	return nil, errors.New("change visibility error")
+}
+
+func TestStartVisibilityHeartbeat(t *testing.T) {
+	msg, err := message.NewMessageSource()
+	assert.NoError(t, err)
+	logger, err := logging.NewLogger(msg)
+	assert.NoError(t, err)
+	stub := &changeVisibilityStub{called: make(chan []string, 1)}
+	h := NewAsyncLambdaHandler(config.NewTestConfig(map[string]string{
+		SQS_VISIBILITY_HEARTBEAT_INTERVAL_SECONDS_NAME: "1",
+	}), logger, nil, stub)
+	sqsMsgs := []events.SQSMessage{
+		{MessageId: "m1", ReceiptHandle: "r1"},
+		{MessageId: "m2", ReceiptHandle: "r2"},
+	}
+
+	hb := h.startVisibilityHeartbeat(context.Background(), "test-queue", sqsMsgs)
+	defer hb.stop()
+	hb.complete("m1")
+
+	// ハートビートの延長失敗の警告ログ出力と、ログの付加情報の変更が並行しても競合しないこと（-raceで確認）
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case receiptHandles := <-stub.called:
+			// 処理済のメッセージは延長の対象外
+			assert.Equal(t, []string{"r2"}, receiptHandles)
+			return
+		case <-timeout:
+			t.Fatal("ハートビートが実行されませんでした")
+		default:
+			logger.ClearInfo()
+			logger.AddInfo("SQS MessageId", "m2")
+		}
+	}
+}
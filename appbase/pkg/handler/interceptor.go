@@ -9,9 +9,11 @@ import (
 	"example.com/appbase/pkg/config"
 	"example.com/appbase/pkg/constant"
 	"example.com/appbase/pkg/env"
+	myerrors "example.com/appbase/pkg/errors"
 	"example.com/appbase/pkg/logging"
 	"example.com/appbase/pkg/message"
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/cockroachdb/errors"
 	"github.com/gin-gonic/gin"
 )
 
@@ -69,7 +71,7 @@ func (i *defaultHandlerInterceptor) Handle(controllerFunc ControllerFunc) gin.Ha
 		result, err := controllerFunc(ctx)
 		if err != nil {
 			// 集約エラーハンドリングによるログ出力
-			logging.LogError(i.logger, err)
+			i.logControllerError(err)
 			// エラーをPublicなエラー（ginのエラーログ対象外）としてginのContextに格納
 			ctx.Error(err).SetType(gin.ErrorTypePublic)
 			return
@@ -80,6 +82,21 @@ func (i *defaultHandlerInterceptor) Handle(controllerFunc ControllerFunc) gin.Ha
 	}
 }
 
+// logControllerError は、同期処理のControllerのエラーのログを出力します。
+// 楽観ロックエラーは、業務エラー等に変換されていない場合も、APIのレスポンス（409 Conflict）に合わせて警告レベルで出力します。
+func (i *defaultHandlerInterceptor) logControllerError(err error) {
+	var (
+		optimisticLockError myerrors.OptimisticLockError
+		codableError        myerrors.CodableError
+		multiCodableError   myerrors.MultiCodableError
+	)
+	if errors.As(err, &optimisticLockError) && !errors.As(err, &codableError) && !errors.As(err, &multiCodableError) {
+		i.logger.WarnWithError(err, message.W_FW_8029, optimisticLockError.LockTarget(), optimisticLockError.ExpectedLockVersion())
+		return
+	}
+	logging.LogError(i.logger, err)
+}
+
 // HandleAsync implements HandlerInterceptor.
 func (i *defaultHandlerInterceptor) HandleAsync(asyncControllerFunc AsyncControllerFunc) AsyncControllerFunc {
 	return func(sqsMessage events.SQSMessage) error {
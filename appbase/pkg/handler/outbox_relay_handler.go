@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+
+	"example.com/appbase/pkg/apcontext"
+	"example.com/appbase/pkg/config"
+	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/outbox"
+	"example.com/appbase/pkg/transaction/model"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/cockroachdb/errors"
+)
+
+// DynamoDBStreamsTriggeredLambdaHandlerFunc は、DynamoDB StreamsトリガのLambdaのハンドラを表す関数です。
+type DynamoDBStreamsTriggeredLambdaHandlerFunc func(ctx context.Context, event events.DynamoDBEvent) (events.DynamoDBEventResponse, error)
+
+// OutboxRelayLambdaHandler は、アウトボックステーブルのDynamoDB Streamsトリガで、
+// アウトボックスのメッセージを送信先へ送信するLambdaのハンドラを管理する構造体です。
+// イベントソースマッピングのFunctionResponseTypesには、ReportBatchItemFailuresを指定してください。
+// イベントソースマッピングのリトライを使い切ったメッセージは、HandleUnsentの定期実行で再送します。
+type OutboxRelayLambdaHandler struct {
+	config      config.Config
+	logger      logging.Logger
+	outboxRelay outbox.OutboxRelay
+}
+
+// NewOutboxRelayLambdaHandler は、OutboxRelayLambdaHandlerを作成します。
+func NewOutboxRelayLambdaHandler(config config.Config,
+	logger logging.Logger,
+	outboxRelay outbox.OutboxRelay) *OutboxRelayLambdaHandler {
+	return &OutboxRelayLambdaHandler{
+		config:      config,
+		logger:      logger,
+		outboxRelay: outboxRelay,
+	}
+}
+
+// Handle は、DynamoDB StreamsトリガのLambdaのハンドラを実行します。
+func (h *OutboxRelayLambdaHandler) Handle() DynamoDBStreamsTriggeredLambdaHandlerFunc {
+	return func(ctx context.Context, event events.DynamoDBEvent) (response events.DynamoDBEventResponse, resultErr error) {
+		defer func() {
+			// パニックのリカバリ処理
+			if v := recover(); v != nil {
+				resultErr = errors.Errorf("recover from: %+v", v)
+				// パニックのスタックトレース情報をログ出力
+				h.logger.ErrorWithUnexpectedError(resultErr)
+			}
+			// ログのフラッシュ
+			h.logger.Sync()
+		}()
+		for i, v := range event.Records {
+			// ハンドラから受け取ったもとのContext（ctx）を毎回コンテキスト領域に格納しなおす
+			apcontext.Context = ctx
+
+			// リクエストID等をログの付加情報として追加
+			h.logger.ClearInfo()
+			lc := apcontext.GetLambdaContext(ctx)
+			h.logger.AddInfo("AWS RequestID", lc.AwsRequestID)
+			h.logger.AddInfo("DynamoDB Streams EventID", v.EventID)
+
+			if err := h.doHandle(ctx, v); err != nil {
+				h.logger.ErrorWithUnexpectedError(err)
+				// シャード内の順序を保つため、失敗したレコード以降は処理せず、全てBatchItemFailuresに登録
+				// https://docs.aws.amazon.com/ja_jp/lambda/latest/dg/with-ddb.html#services-ddb-batchfailurereporting
+				for _, r := range event.Records[i:] {
+					response.BatchItemFailures = append(response.BatchItemFailures,
+						events.DynamoDBBatchItemFailure{ItemIdentifier: r.Change.SequenceNumber})
+				}
+				return
+			}
+		}
+		return
+	}
+}
+
+// doHandle は、DynamoDB Streamsのレコード1件に対して、アウトボックスのメッセージを送信します。
+func (h *OutboxRelayLambdaHandler) doHandle(ctx context.Context, record events.DynamoDBEventRecord) error {
+	// 登録時のレコードのみを対象とし、送信済への更新やTTLによる削除のレコードは対象外とする
+	if record.EventName != string(events.DynamoDBOperationTypeInsert) {
+		h.logger.Debug("対象外のイベント: %s", record.EventName)
+		return nil
+	}
+	var outboxItem model.OutboxItem
+	if err := outbox.UnmarshalStreamImage(record.Change.NewImage, &outboxItem); err != nil {
+		return err
+	}
+	return h.outboxRelay.RelayWithContext(ctx, &outboxItem)
+}
+
+// HandleUnsent は、EventBridge Scheduler等による定期実行で、
+// DynamoDB Streamsのリトライを使い切る等で送信されずに残ったアウトボックスのメッセージを再送するLambdaのハンドラを実行します。
+func (h *OutboxRelayLambdaHandler) HandleUnsent() SimpleLambdaHandlerFunc {
+	return func(ctx context.Context, event any) (response any, resultErr error) {
+		defer func() {
+			// パニックのリカバリ処理
+			if v := recover(); v != nil {
+				resultErr = errors.Errorf("recover from: %+v", v)
+				// パニックのスタックトレース情報をログ出力
+				h.logger.ErrorWithUnexpectedError(resultErr)
+			}
+			// ログのフラッシュ
+			h.logger.Sync()
+		}()
+		// ハンドラから受け取ったもとのContext（ctx）をコンテキスト領域に格納
+		apcontext.Context = ctx
+
+		// リクエストID等をログの付加情報として追加
+		h.logger.ClearInfo()
+		lc := apcontext.GetLambdaContext(ctx)
+		h.logger.AddInfo("AWS RequestID", lc.AwsRequestID)
+
+		if err := h.outboxRelay.RelayUnsentWithContext(ctx); err != nil {
+			h.logger.ErrorWithUnexpectedError(err)
+			return nil, err
+		}
+		return nil, nil
+	}
+}
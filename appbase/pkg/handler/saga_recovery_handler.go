@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"context"
+
+	"example.com/appbase/pkg/apcontext"
+	"example.com/appbase/pkg/config"
+	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/saga"
+	"github.com/cockroachdb/errors"
+)
+
+// SagaRecoveryLambdaHandler は、EventBridge Scheduler等による定期実行で、
+// 中断したサーガを検索し、続きから再開するLambdaのハンドラを管理する構造体です。
+type SagaRecoveryLambdaHandler struct {
+	config           config.Config
+	logger           logging.Logger
+	sagaOrchestrator saga.SagaOrchestrator
+}
+
+// NewSagaRecoveryLambdaHandler は、SagaRecoveryLambdaHandlerを作成します。
+func NewSagaRecoveryLambdaHandler(config config.Config,
+	logger logging.Logger,
+	sagaOrchestrator saga.SagaOrchestrator) *SagaRecoveryLambdaHandler {
+	return &SagaRecoveryLambdaHandler{
+		config:           config,
+		logger:           logger,
+		sagaOrchestrator: sagaOrchestrator,
+	}
+}
+
+// Handle は、定期実行のLambdaのハンドラを実行します。
+// サーガの定義は、ハンドラの実行前にSagaOrchestrator.Registerで登録しておいてください。
+func (h *SagaRecoveryLambdaHandler) Handle() SimpleLambdaHandlerFunc {
+	return func(ctx context.Context, event any) (response any, resultErr error) {
+		defer func() {
+			// パニックのリカバリ処理
+			if v := recover(); v != nil {
+				resultErr = errors.Errorf("recover from: %+v", v)
+				// パニックのスタックトレース情報をログ出力
+				h.logger.ErrorWithUnexpectedError(resultErr)
+			}
+			// ログのフラッシュ
+			h.logger.Sync()
+		}()
+		// ハンドラから受け取ったもとのContext（ctx）をコンテキスト領域に格納
+		apcontext.Context = ctx
+
+		// リクエストID等をログの付加情報として追加
+		h.logger.ClearInfo()
+		lc := apcontext.GetLambdaContext(ctx)
+		h.logger.AddInfo("AWS RequestID", lc.AwsRequestID)
+
+		if err := h.sagaOrchestrator.RecoverWithContext(ctx); err != nil {
+			h.logger.ErrorWithUnexpectedError(err)
+			return nil, err
+		}
+		return nil, nil
+	}
+}
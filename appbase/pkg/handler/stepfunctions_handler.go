@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"example.com/appbase/pkg/apcontext"
+	"example.com/appbase/pkg/config"
+	"example.com/appbase/pkg/idempotency"
+	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/message"
+	"example.com/appbase/pkg/stepfunctions"
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// タスクのハートビート送信間隔（秒）のプロパティ名。0以下または未設定の場合はハートビートを送信しない
+	// ステートマシン定義のHeartbeatSecondsより短い値を設定すること
+	STEPFUNCTIONS_HEARTBEAT_INTERVAL_SECONDS_NAME = "STEPFUNCTIONS_HEARTBEAT_INTERVAL_SECONDS"
+)
+
+// StepFunctionsTaskLambdaHandler は、StepFunctionsのタスクから呼び出されるLambdaのハンドラを表す構造体です。
+// イベントにタスクトークンが含まれる場合（.waitForTaskToken）は、
+// Controllerの処理中にハートビートを送信し、処理結果をタスクトークンによるコールバックで通知します。
+// タスクトークンが含まれない場合は、Controllerの処理結果をLambdaの戻り値として返却します。
+// いずれの場合も、エラー発生時は、エラーの種類に応じたエラー名（ValidationError、BusinessError、SystemError等）で通知するため、
+// ステートマシン定義のRetry/CatchのErrorEqualsで判定できます。
+type StepFunctionsTaskLambdaHandler struct {
+	config                config.Config
+	logger                logging.Logger
+	messageSource         message.MessageSource
+	stepFunctionsAccessor stepfunctions.StepFunctionsAccessor
+}
+
+// NewStepFunctionsTaskLambdaHandler は、StepFunctionsTaskLambdaHandlerを作成します。
+func NewStepFunctionsTaskLambdaHandler(config config.Config,
+	logger logging.Logger,
+	messageSource message.MessageSource,
+	stepFunctionsAccessor stepfunctions.StepFunctionsAccessor) *StepFunctionsTaskLambdaHandler {
+	return &StepFunctionsTaskLambdaHandler{
+		config:                config,
+		logger:                logger,
+		messageSource:         messageSource,
+		stepFunctionsAccessor: stepFunctionsAccessor,
+	}
+}
+
+// Handle は、StepFunctionsのタスクから呼び出されるLambdaのハンドラを実行します。
+func (h *StepFunctionsTaskLambdaHandler) Handle(simpleControllerFunc SimpleControllerFunc) SimpleLambdaHandlerFunc {
+	return func(ctx context.Context, event any) (response any, resultErr error) {
+		var (
+			taskToken    string
+			hasTaskToken bool
+		)
+		defer func() {
+			// パニックのリカバリ処理
+			if v := recover(); v != nil {
+				resultErr = errors.Errorf("recover from: %+v", v)
+				// パニックのスタックトレース情報をログ出力
+				h.logger.ErrorWithUnexpectedError(resultErr)
+				if hasTaskToken {
+					// タスクトークンによるコールバックで、予期せぬエラーを通知し、タスクのタイムアウトまで待機させないようにする
+					if err := h.stepFunctionsAccessor.SendTaskFailureWithContext(ctx, taskToken, stepfunctions.ERROR_NAME_UNEXPECTED, resultErr.Error()); err != nil {
+						h.logger.ErrorWithUnexpectedError(err)
+					}
+				}
+			}
+			// ログのフラッシュ
+			h.logger.Sync()
+		}()
+		// ctxをコンテキスト領域に格納
+		apcontext.Context = ctx
+		// リクエストIDをログの付加情報として追加
+		h.logger.ClearInfo()
+		lc := apcontext.GetLambdaContext(ctx)
+		h.logger.AddInfo("AWS RequestID", lc.AwsRequestID)
+
+		stopHeartbeat := func() {}
+		taskToken, hasTaskToken = stepfunctions.GetTaskToken(event)
+		if hasTaskToken {
+			// Controllerの処理中、ハートビートを送信
+			// パニック発生時もハートビートのgoroutineを停止するよう、deferでも停止する
+			stopHeartbeat = h.startHeartbeat(ctx, taskToken)
+			defer stopHeartbeat()
+		}
+		response, resultErr = simpleControllerFunc(ctx, event)
+		stopHeartbeat()
+
+		if resultErr != nil {
+			if errors.Is(resultErr, idempotency.CompletedProcessIdempotencyError) || errors.Is(resultErr, idempotency.InprogressProcessIdempotencyError) {
+				// SimpleLambdaHandlerと同様に、二重実行エラーの場合は正常終了とし、二重実行を表す戻り値を設定する
+				resultErr = nil
+				response = map[string]any{
+					IDEMPOTENCCY_RESPONSE_NAME: true,
+				}
+			}
+		}
+
+		if hasTaskToken {
+			// タスクトークンによるコールバックで、処理結果を通知
+			if resultErr != nil {
+				resultErr = h.stepFunctionsAccessor.SendTaskFailureWithErrorAndContext(ctx, taskToken, resultErr)
+			} else {
+				resultErr = h.stepFunctionsAccessor.SendTaskSuccessWithContext(ctx, taskToken, response)
+			}
+			// コールバックに失敗した場合は、Lambdaのエラーとして返却
+			return nil, resultErr
+		}
+
+		if resultErr != nil {
+			// エラーの種類に応じたエラー名を、Lambdaのエラーの型名として返却
+			taskError := stepfunctions.NewTaskError(resultErr, h.messageSource)
+			return nil, messages.InvokeResponse_Error{
+				Type:    taskError.Name,
+				Message: taskError.Cause(),
+			}
+		}
+		return
+	}
+}
+
+// startHeartbeat は、設定した間隔でタスクのハートビートを送信するgoroutineを開始し、停止するための関数を返却します。
+// 停止するための関数は、複数回呼び出しても問題ありません。
+func (h *StepFunctionsTaskLambdaHandler) startHeartbeat(ctx context.Context, taskToken string) func() {
+	intervalSeconds := h.config.GetInt(STEPFUNCTIONS_HEARTBEAT_INTERVAL_SECONDS_NAME, 0)
+	if intervalSeconds <= 0 {
+		return func() {}
+	}
+	heartbeatCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(time.Duration(intervalSeconds) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-heartbeatCtx.Done():
+				return
+			case <-ticker.C:
+				if err := h.stepFunctionsAccessor.SendTaskHeartbeatWithContext(heartbeatCtx, taskToken); err != nil {
+					if heartbeatCtx.Err() != nil {
+						return
+					}
+					// ハートビートの送信失敗は、Controllerの処理を継続するため警告ログ出力のみとする
+					h.logger.WarnWithError(err, message.W_FW_8015)
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			<-done
+		})
+	}
+}
@@ -6,6 +6,7 @@ package logging
 import (
 	"fmt"
 	"os"
+	"sync"
 
 	"example.com/appbase/pkg/env"
 	"example.com/appbase/pkg/errors"
@@ -97,45 +98,59 @@ func NewLogger(messageSource message.MessageSource) (Logger, error) {
 }
 
 // zapLoggerは、Zapを使ったLogger実装です。
+// ハートビート等のgoroutineからのログ出力と、AddInfo、ClearInfoによる付加情報の変更が並行しても安全なよう、
+// 付加情報を持つロガーの参照はmuで保護します。
 type zapLogger struct {
 	originalLogger *zap.SugaredLogger
 	logger         *zap.SugaredLogger
 	messageSource  message.MessageSource
+	mu             sync.RWMutex
 }
 
 // AddInfo implements Logger.
 func (z *zapLogger) AddInfo(key string, value string) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
 	z.logger = z.logger.With(key, value)
 }
 
 // ClearInfo implements Logger.
 func (z *zapLogger) ClearInfo() {
+	z.mu.Lock()
+	defer z.mu.Unlock()
 	z.logger = z.originalLogger
 }
 
+// current は、現在の付加情報を持つ、実際にログ出力するためのロガーを返却します。
+func (z *zapLogger) current() *zap.SugaredLogger {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	return z.logger
+}
+
 // Debug implements Logger.
 func (z *zapLogger) Debug(template string, args ...any) {
-	z.logger.Debugf(template, args...)
+	z.current().Debugf(template, args...)
 }
 
 // Info implements Logger.
 func (z *zapLogger) Info(code string, args ...any) {
 	message := z.messageSource.GetMessage(code, args...)
 	if message != "" {
-		z.logger.Infof("[%s]%s", code, message)
+		z.current().Infof("[%s]%s", code, message)
 		return
 	}
-	z.logger.Infof("メッセージ未取得：%s %v", code, args)
+	z.current().Infof("メッセージ未取得：%s %v", code, args)
 }
 
 // Warn implements Logger.
 func (z *zapLogger) Warn(code string, args ...any) {
 	message := z.messageSource.GetMessage(code, args...)
 	if message != "" {
-		z.logger.Warnf("[%s]%s", code, message)
+		z.current().Warnf("[%s]%s", code, message)
 		return
 	}
-	z.logger.Warnf("メッセージ未取得：%s %v", code, args)
+	z.current().Warnf("メッセージ未取得：%s %v", code, args)
 }
 
 // WarnWithError implements Logger.
@@ -143,10 +158,10 @@ func (z *zapLogger) WarnWithError(err error, code string, args ...any) {
 	message := z.messageSource.GetMessage(code, args...)
 	// エラーのスタックトレース付きのWarnログ出力
 	if message != "" {
-		z.logger.Warnf("[%s]%s, %+v", code, message, err)
+		z.current().Warnf("[%s]%s, %+v", code, message, err)
 		return
 	}
-	z.logger.Warnf("メッセージ未取得：%s %v, %+v", code, args, err)
+	z.current().Warnf("メッセージ未取得：%s %v, %+v", code, args, err)
 }
 
 // WarnWithCodableError implements Logger.
@@ -169,17 +184,17 @@ func (z *zapLogger) WarnWithMultiCodableError(err errors.MultiCodableError) {
 			logStrs = append(logStrs, fmt.Sprintf("メッセージ未取得：%s %v, %+v\n", code, args, e))
 		}
 	}
-	z.logger.Warn(logStrs...)
+	z.current().Warn(logStrs...)
 }
 
 // Error implements Logger.
 func (z *zapLogger) Error(code string, args ...any) {
 	message := z.messageSource.GetMessage(code, args...)
 	if message != "" {
-		z.logger.Errorf("[%s]%s", code, message)
+		z.current().Errorf("[%s]%s", code, message)
 		return
 	}
-	z.logger.Error(code, args)
+	z.current().Error(code, args)
 }
 
 // ErrorWithError implements Logger.
@@ -187,10 +202,10 @@ func (z *zapLogger) ErrorWithError(err error, code string, args ...any) {
 	message := z.messageSource.GetMessage(code, args...)
 	// エラーのスタックトレース付きのErrorログ出力
 	if message != "" {
-		z.logger.Errorf("[%s]%s, %+v", code, message, err)
+		z.current().Errorf("[%s]%s, %+v", code, message, err)
 		return
 	}
-	z.logger.Errorf("メッセージ未取得：%s %v, %+v", code, args, err)
+	z.current().Errorf("メッセージ未取得：%s %v, %+v", code, args, err)
 }
 
 // ErrorWithCodableError implements Logger.
@@ -203,10 +218,10 @@ func (z *zapLogger) ErrorWithCodableError(err errors.CodableError) {
 // Error implements Logger.
 func (z *zapLogger) ErrorWithUnexpectedError(err error) {
 	message := z.messageSource.GetMessage(message.E_FW_9999)
-	z.logger.Errorf("%s, %+v", message, err)
+	z.current().Errorf("%s, %+v", message, err)
 }
 
 // Sync implements Logger.
 func (z *zapLogger) Sync() error {
-	return z.logger.Sync()
+	return z.current().Sync()
 }
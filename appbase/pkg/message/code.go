@@ -10,6 +10,15 @@ const (
 	I_FW_0006 = "i.fw.0006"
 	I_FW_0007 = "i.fw.0007"
 	I_FW_0008 = "i.fw.0008"
+	I_FW_0009 = "i.fw.0009"
+	I_FW_0010 = "i.fw.0010"
+	I_FW_0011 = "i.fw.0011"
+	I_FW_0012 = "i.fw.0012"
+	I_FW_0013 = "i.fw.0013"
+	I_FW_0014 = "i.fw.0014"
+	I_FW_0015 = "i.fw.0015"
+	I_FW_0016 = "i.fw.0016"
+	I_FW_0017 = "i.fw.0017"
 	W_FW_5001 = "w.fw.5001"
 	W_FW_8001 = "w.fw.8001"
 	W_FW_8002 = "w.fw.8002"
@@ -25,7 +34,33 @@ const (
 	W_FW_8012 = "w.fw.8012"
 	W_FW_8013 = "w.fw.8013"
 	W_FW_8014 = "w.fw.8014"
+	W_FW_8015 = "w.fw.8015"
+	W_FW_8016 = "w.fw.8016"
+	W_FW_8017 = "w.fw.8017"
+	W_FW_8018 = "w.fw.8018"
+	W_FW_8019 = "w.fw.8019"
+	W_FW_8020 = "w.fw.8020"
+	W_FW_8021 = "w.fw.8021"
+	W_FW_8022 = "w.fw.8022"
+	W_FW_8023 = "w.fw.8023"
+	W_FW_8024 = "w.fw.8024"
+	W_FW_8025 = "w.fw.8025"
+	W_FW_8026 = "w.fw.8026"
+	W_FW_8027 = "w.fw.8027"
+	W_FW_8028 = "w.fw.8028"
+	W_FW_8029 = "w.fw.8029"
+	W_FW_8030 = "w.fw.8030"
+	W_FW_8031 = "w.fw.8031"
+	W_FW_8032 = "w.fw.8032"
 	E_FW_9001 = "e.fw.9001"
 	E_FW_9002 = "e.fw.9002"
+	E_FW_9003 = "e.fw.9003"
+	E_FW_9004 = "e.fw.9004"
+	E_FW_9005 = "e.fw.9005"
+	E_FW_9006 = "e.fw.9006"
+	E_FW_9007 = "e.fw.9007"
+	E_FW_9008 = "e.fw.9008"
+	E_FW_9009 = "e.fw.9009"
+	E_FW_9010 = "e.fw.9010"
 	E_FW_9999 = "e.fw.9999"
 )
@@ -14,7 +14,6 @@ import (
 	lambdadetector "go.opentelemetry.io/contrib/detectors/aws/lambda"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-lambda-go/otellambda"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-lambda-go/otellambda/xrayconfig"
-	"go.opentelemetry.io/contrib/propagators/aws/xray"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/sdk/resource"
@@ -67,7 +66,7 @@ func StartLambda(lambdaHandler any) {
 	}(ctx)
 
 	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(xray.Propagator{})
+	otel.SetTextMapPropagator(newTextMapPropagator())
 
 	// Lambdaハンドラ関数を開始
 	lambda.Start(otellambda.InstrumentHandler(lambdaHandler, xrayconfig.WithRecommendedOptions(tp)...))
@@ -0,0 +1,170 @@
+package otel
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// X-Rayのトレースヘッダ名
+	XRAY_TRACE_HEADER_NAME = "X-Amzn-Trace-Id"
+)
+
+const (
+	// SQSのメッセージ属性の最大数
+	// https://docs.aws.amazon.com/ja_jp/AWSSimpleQueueService/latest/SQSDeveloperGuide/sqs-message-metadata.html
+	sqsMaxMessageAttributes = 10
+	// SQSのシステム属性で、X-Rayのトレースヘッダが格納される属性名
+	sqsAWSTraceHeaderName = "AWSTraceHeader"
+	tracerName            = "example.com/appbase/pkg/otel"
+)
+
+// SQSMessageAttributeCarrier は、SQS送信時のメッセージ属性をpropagation.TextMapCarrierとして扱うための型です。
+type SQSMessageAttributeCarrier map[string]types.MessageAttributeValue
+
+// Get implements propagation.TextMapCarrier.
+func (c SQSMessageAttributeCarrier) Get(key string) string {
+	v, ok := c[key]
+	if !ok || v.StringValue == nil {
+		return ""
+	}
+	return *v.StringValue
+}
+
+// Set implements propagation.TextMapCarrier.
+func (c SQSMessageAttributeCarrier) Set(key string, value string) {
+	if _, ok := c[key]; !ok && len(c) >= sqsMaxMessageAttributes {
+		// メッセージ属性の最大数を超える場合は、送信エラーとならないよう伝搬を諦める
+		return
+	}
+	c[key] = types.MessageAttributeValue{
+		DataType:    aws.String("String"),
+		StringValue: aws.String(value),
+	}
+}
+
+// Keys implements propagation.TextMapCarrier.
+func (c SQSMessageAttributeCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// SQSEventMessageAttributeCarrier は、SQSトリガで受信したメッセージのメッセージ属性をpropagation.TextMapCarrierとして扱うための型です。
+type SQSEventMessageAttributeCarrier map[string]events.SQSMessageAttribute
+
+// Get implements propagation.TextMapCarrier.
+func (c SQSEventMessageAttributeCarrier) Get(key string) string {
+	v, ok := c[key]
+	if !ok || v.StringValue == nil {
+		return ""
+	}
+	return *v.StringValue
+}
+
+// Set implements propagation.TextMapCarrier.
+func (c SQSEventMessageAttributeCarrier) Set(key string, value string) {
+	c[key] = events.SQSMessageAttribute{
+		DataType:    "String",
+		StringValue: aws.String(value),
+	}
+}
+
+// Keys implements propagation.TextMapCarrier.
+func (c SQSEventMessageAttributeCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// InjectSQSMessageAttributes は、ctxのトレースコンテキストを、SQS送信時のメッセージ属性に格納します。
+// 格納される属性は、StartLambdaで設定したPropagator（W3C Trace Context、X-Ray）に従います。
+func InjectSQSMessageAttributes(ctx context.Context, input map[string]types.MessageAttributeValue) {
+	if ctx == nil || input == nil {
+		return
+	}
+	otel.GetTextMapPropagator().Inject(ctx, SQSMessageAttributeCarrier(input))
+}
+
+// InjectTraceCarrier は、ctxのトレースコンテキストを、文字列のマップに格納して返却します。
+// アウトボックスのアイテム等、永続化して後から送信するメッセージに、登録時のトレースコンテキストを保存するために利用します。
+// トレースコンテキストがない場合は、nilを返却します。
+func InjectTraceCarrier(ctx context.Context) map[string]string {
+	if ctx == nil {
+		return nil
+	}
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// ExtractTraceCarrier は、InjectTraceCarrierで保存したトレースコンテキストを設定したContextを返却します。
+// 返却したContextを利用して送信すると、登録時のトレースコンテキストが送信先へ伝搬されます。
+func ExtractTraceCarrier(ctx context.Context, carrier map[string]string) context.Context {
+	if len(carrier) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(carrier))
+}
+
+// ExtractSQSMessage は、SQSトリガで受信したメッセージのメッセージ属性から、送信元のトレースコンテキストを取り出します。
+// メッセージ属性にトレースコンテキストがない場合は、SQSのシステム属性AWSTraceHeaderから取り出します。
+func ExtractSQSMessage(sqsMsg events.SQSMessage) trace.SpanContext {
+	propagator := otel.GetTextMapPropagator()
+	carrier := SQSEventMessageAttributeCarrier{}
+	for k, v := range sqsMsg.MessageAttributes {
+		carrier[k] = v
+	}
+	if _, ok := carrier[XRAY_TRACE_HEADER_NAME]; !ok {
+		if traceHeader, ok := sqsMsg.Attributes[sqsAWSTraceHeaderName]; ok && traceHeader != "" {
+			carrier.Set(XRAY_TRACE_HEADER_NAME, traceHeader)
+		}
+	}
+	return trace.SpanContextFromContext(propagator.Extract(context.Background(), carrier))
+}
+
+// StartSQSMessageSpan は、SQSトリガで受信したメッセージ1件の処理を表すSpanを開始します。
+// 送信元のトレースコンテキストがある場合は、SpanLinkで関連付けます。
+// 戻り値のContextは、メッセージの処理で利用し、処理終了時にSpanのEndを呼び出す必要があります。
+func StartSQSMessageSpan(ctx context.Context, queueName string, sqsMsg events.SQSMessage) (context.Context, trace.Span) {
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemAWSSqs,
+			semconv.MessagingDestinationName(queueName),
+			semconv.MessagingMessageID(sqsMsg.MessageId),
+		),
+	}
+	if remote := ExtractSQSMessage(sqsMsg); remote.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{
+			SpanContext: remote,
+			Attributes:  []attribute.KeyValue{semconv.MessagingMessageID(sqsMsg.MessageId)},
+		}))
+	}
+	return otel.Tracer(tracerName).Start(ctx, queueName+" process", opts...)
+}
+
+// newTextMapPropagator は、W3C Trace ContextとX-Rayのトレースヘッダの両方を伝搬するPropagatorを作成します。
+func newTextMapPropagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(
+		xray.Propagator{},
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	)
+}
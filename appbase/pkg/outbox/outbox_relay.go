@@ -0,0 +1,360 @@
+/*
+outbox パッケージは、トランザクショナルアウトボックスに登録されたメッセージを、SQS、SNS、EventBridgeへ送信するリレーの機能を提供するパッケージです。
+*/
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"example.com/appbase/pkg/apcontext"
+	"example.com/appbase/pkg/awssdk"
+	myConfig "example.com/appbase/pkg/config"
+	mydynamodb "example.com/appbase/pkg/dynamodb"
+	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/message"
+	"example.com/appbase/pkg/otel"
+	"example.com/appbase/pkg/transaction"
+	"example.com/appbase/pkg/transaction/model"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/cockroachdb/errors"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
+)
+
+const (
+	SNS_LOCAL_ENDPOINT_NAME         = "SNS_LOCAL_ENDPOINT"
+	EVENTBRIDGE_LOCAL_ENDPOINT_NAME = "EVENTBRIDGE_LOCAL_ENDPOINT"
+	// SNSのメッセージ属性の最大数
+	SNS_MAX_MESSAGE_ATTRIBUTES = 10
+	// 送信中のステータスの有効期限（秒）のプロパティ名。リレーのLambdaのタイムアウトより長い値を設定すること
+	OUTBOX_RELAY_SENDING_TIMEOUT_SECONDS_NAME = "OUTBOX_RELAY_SENDING_TIMEOUT_SECONDS"
+	// 送信されずに残ったアイテムを再送する、登録からの経過時間（秒）のプロパティ名。
+	// DynamoDB Streamsのイベントソースマッピングのリトライ（MaximumRetryAttempts、MaximumRecordAgeInSeconds）が終わるまでの時間より長い値を設定すること
+	OUTBOX_RELAY_SWEEP_THRESHOLD_SECONDS_NAME = "OUTBOX_RELAY_SWEEP_THRESHOLD_SECONDS"
+)
+
+// ErrOutboxSending は、アウトボックスのアイテムを他のリレーが送信中であることを表すエラーです。
+// DynamoDB Streamsのリトライにより、送信の完了後、または送信中のステータスの有効期限切れ後に再実行されます。
+var ErrOutboxSending = errors.New("アウトボックスのアイテムは他のリレーで送信中です")
+
+// OutboxRelay は、アウトボックスのアイテムを送信先へ送信し、送信済に更新するインタフェースです。
+// 送信前に、アイテムを条件付きで未送信から送信中に更新するため、複数のリレーが同じアイテムを同時に送信することはありません。
+// ただし、送信と送信済への更新はアトミックではないため、送信後、送信済への更新前に失敗した場合は再送され、
+// 少なくとも1回（at-least-once）の送信となります。
+// FIFOキュー、FIFOトピックの場合は、アウトボックスのIDを重複排除IDとするため、重複排除の期間内の再送は排除されますが、
+// 標準キュー、標準トピック、EventBridgeの受信側では、二重実行防止（冪等性）機能等で重複を考慮してください。
+type OutboxRelay interface {
+	// Relay は、アウトボックスのアイテムを送信先へ送信し、送信済に更新します。
+	Relay(outboxItem *model.OutboxItem) error
+	// RelayWithContext は、goroutine向けに渡されたContextを利用して、アウトボックスのアイテムを送信先へ送信し、送信済に更新します。
+	RelayWithContext(ctx context.Context, outboxItem *model.OutboxItem) error
+	// RelayUnsent は、登録から一定時間（OUTBOX_RELAY_SWEEP_THRESHOLD_SECONDS）経過しても送信済になっていないアイテムを検索し、再送します。
+	// DynamoDB Streamsのリトライを使い切ったアイテムは再送されないため、定期実行のLambda（OutboxRelayLambdaHandler.HandleUnsent）から呼び出します。
+	RelayUnsent() error
+	// RelayUnsentWithContext は、goroutine向けに渡されたContextを利用して、送信済になっていないアイテムを検索し、再送します。
+	RelayUnsentWithContext(ctx context.Context) error
+}
+
+// NewOutboxRelay は、OutboxRelayを作成します。
+// transactionManagerは、SQSへの送信とキューメッセージ管理テーブルへの登録を行うため、NewTransactionManagerで作成したものを指定してください。
+func NewOutboxRelay(logger logging.Logger, myCfg myConfig.Config,
+	transactionManager transaction.TransactionManager,
+	sqsAccessor transaction.TransactionalSQSAccessor,
+	outboxRegisterer transaction.OutboxRegisterer,
+	outboxItemRepository transaction.OutboxItemRepository) (OutboxRelay, error) {
+	// カスタムHTTPClientの作成
+	sdkHTTPClient := awssdk.NewHTTPClient(myCfg)
+	// ClientLogModeの取得
+	clientLogMode, found := awssdk.GetClientLogMode(myCfg)
+	var cfg aws.Config
+	var err error
+	if found {
+		cfg, err = config.LoadDefaultConfig(context.TODO(), config.WithHTTPClient(sdkHTTPClient), config.WithClientLogMode(clientLogMode))
+	} else {
+		cfg, err = config.LoadDefaultConfig(context.TODO(), config.WithHTTPClient(sdkHTTPClient))
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// X-Ray SDKからADOTの移行
+	// https://aws-otel.github.io/docs/getting-started/go-sdk/manual-instr#instrumenting-the-aws-sdk
+	otelaws.AppendMiddlewares(&cfg.APIOptions)
+	snsClient := sns.NewFromConfig(cfg, func(o *sns.Options) {
+		// ローカル実行のためSNSのローカル起動先が指定されている場合
+		snsEndpoint := myCfg.Get(SNS_LOCAL_ENDPOINT_NAME, "")
+		if snsEndpoint != "" {
+			o.BaseEndpoint = aws.String(snsEndpoint)
+		}
+	})
+	eventBridgeClient := eventbridge.NewFromConfig(cfg, func(o *eventbridge.Options) {
+		// ローカル実行のためEventBridgeのローカル起動先が指定されている場合
+		eventBridgeEndpoint := myCfg.Get(EVENTBRIDGE_LOCAL_ENDPOINT_NAME, "")
+		if eventBridgeEndpoint != "" {
+			o.BaseEndpoint = aws.String(eventBridgeEndpoint)
+		}
+	})
+	return &defaultOutboxRelay{
+		logger:               logger,
+		transactionManager:   transactionManager,
+		sqsAccessor:          sqsAccessor,
+		outboxRegisterer:     outboxRegisterer,
+		outboxItemRepository: outboxItemRepository,
+		snsClient:            snsClient,
+		eventBridgeClient:    eventBridgeClient,
+		sendingTimeout:       time.Duration(myCfg.GetInt(OUTBOX_RELAY_SENDING_TIMEOUT_SECONDS_NAME, 900)) * time.Second,
+		sweepThreshold:       time.Duration(myCfg.GetInt(OUTBOX_RELAY_SWEEP_THRESHOLD_SECONDS_NAME, 3600)) * time.Second,
+	}, nil
+}
+
+// defaultOutboxRelay は、OutboxRelayを実装する構造体です。
+type defaultOutboxRelay struct {
+	logger               logging.Logger
+	transactionManager   transaction.TransactionManager
+	sqsAccessor          transaction.TransactionalSQSAccessor
+	outboxRegisterer     transaction.OutboxRegisterer
+	outboxItemRepository transaction.OutboxItemRepository
+	snsClient            *sns.Client
+	eventBridgeClient    *eventbridge.Client
+	// 送信中のステータスの有効期限
+	sendingTimeout time.Duration
+	// 送信されずに残ったアイテムを再送する、登録からの経過時間
+	sweepThreshold time.Duration
+}
+
+// Relay implements OutboxRelay.
+func (r *defaultOutboxRelay) Relay(outboxItem *model.OutboxItem) error {
+	return r.RelayWithContext(apcontext.Context, outboxItem)
+}
+
+// RelayWithContext implements OutboxRelay.
+func (r *defaultOutboxRelay) RelayWithContext(ctx context.Context, outboxItem *model.OutboxItem) error {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	if outboxItem.Status != model.OUTBOX_STATUS_PENDING {
+		r.logger.Debug("送信済のアウトボックスのアイテム: %s", outboxItem.OutboxId)
+		return nil
+	}
+	// 送信前に送信中に更新し、他のリレーが同時に送信しないようにする
+	return r.relay(ctx, outboxItem)
+}
+
+// RelayUnsent implements OutboxRelay.
+func (r *defaultOutboxRelay) RelayUnsent() error {
+	return r.RelayUnsentWithContext(apcontext.Context)
+}
+
+// RelayUnsentWithContext implements OutboxRelay.
+func (r *defaultOutboxRelay) RelayUnsentWithContext(ctx context.Context) error {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	now := time.Now()
+	createdBefore := now.Add(-r.sweepThreshold).UnixMilli()
+	var resultErr error
+	for _, status := range []string{model.OUTBOX_STATUS_PENDING, model.OUTBOX_STATUS_SENDING} {
+		outboxItems, err := r.outboxItemRepository.FindSomeByStatusWithContext(ctx, status, createdBefore)
+		if err != nil {
+			return err
+		}
+		for i := range outboxItems {
+			v := &outboxItems[i]
+			if v.Status == model.OUTBOX_STATUS_SENDING && v.SendingExpireTime >= now.UnixMilli() {
+				// 他のリレーが送信中のアイテムは対象外とする
+				continue
+			}
+			// DynamoDB Streamsからの送信が行われなかったことを検知できるよう、エラーログを出力する
+			r.logger.Error(message.E_FW_9010, v.OutboxId, v.Status, time.UnixMilli(v.CreatedAt).Format(time.RFC3339))
+			if err := r.relay(ctx, v); err != nil {
+				if errors.Is(err, ErrOutboxSending) {
+					// 検索後に他のリレーが送信を開始した場合は、対象外とする
+					continue
+				}
+				// 他のアイテムの再送は継続し、最後にまとめてエラーを返却する
+				r.logger.WarnWithError(err, message.W_FW_8032, v.OutboxId)
+				resultErr = errors.Join(resultErr, err)
+			}
+		}
+	}
+	return resultErr
+}
+
+// relay は、アウトボックスのアイテムを送信中に更新してから送信先へ送信し、送信済に更新します。
+func (r *defaultOutboxRelay) relay(ctx context.Context, outboxItem *model.OutboxItem) error {
+	claimed, err := r.claim(ctx, outboxItem)
+	if err != nil || !claimed {
+		return err
+	}
+	// 登録時のトレースコンテキストを引き継いで送信する
+	sendCtx := otel.ExtractTraceCarrier(ctx, outboxItem.TraceCarrier)
+	switch outboxItem.DestinationType {
+	case model.OUTBOX_DESTINATION_TYPE_SQS:
+		err = r.relayToSQS(sendCtx, outboxItem)
+	case model.OUTBOX_DESTINATION_TYPE_SNS:
+		err = r.relayToSNS(sendCtx, outboxItem)
+	case model.OUTBOX_DESTINATION_TYPE_EVENTBRIDGE:
+		err = r.relayToEventBridge(sendCtx, outboxItem)
+	default:
+		err = errors.Errorf("未対応のアウトボックスの送信先の種類です: %s", outboxItem.DestinationType)
+	}
+	if err != nil {
+		if transaction.IsTransactionConditionalCheckFailed(err) {
+			// 送信中のステータスの有効期限が切れ、既に他のリレーが送信している場合は、正常終了とする
+			r.logger.Warn(message.W_FW_8018, outboxItem.OutboxId)
+			return nil
+		}
+		// リトライで再送できるよう、未送信に戻す
+		if releaseErr := r.outboxItemRepository.UpdatePendingWithContext(ctx, outboxItem); releaseErr != nil {
+			// 未送信に戻せない場合も、送信中のステータスの有効期限切れ後に再送できるため、警告ログ出力のみとする
+			r.logger.WarnWithError(releaseErr, message.W_FW_8030, outboxItem.OutboxId)
+		}
+		return err
+	}
+	r.logger.Info(message.I_FW_0012, outboxItem.DestinationType, outboxItem.Destination, outboxItem.OutboxId)
+	return nil
+}
+
+// claim は、アウトボックスのアイテムを送信中に更新し、送信する権利を取得します。
+// 既に送信済の場合は、falseを返却します。他のリレーが送信中の場合は、ErrOutboxSendingを返却します。
+func (r *defaultOutboxRelay) claim(ctx context.Context, outboxItem *model.OutboxItem) (bool, error) {
+	err := r.outboxItemRepository.UpdateSendingWithContext(ctx, outboxItem, time.Now().Add(r.sendingTimeout))
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, mydynamodb.ErrUpdateWithCondtion) {
+		return false, err
+	}
+	// 条件付き更新に失敗した場合は、現在のステータスを確認
+	current, err := r.outboxItemRepository.FindOneWithContext(ctx, outboxItem.OutboxId)
+	if err != nil {
+		if errors.Is(err, mydynamodb.ErrRecordNotFound) {
+			// TTLで削除済の場合は、対象外とする
+			r.logger.Debug("削除済のアウトボックスのアイテム: %s", outboxItem.OutboxId)
+			return false, nil
+		}
+		return false, err
+	}
+	if current.Status == model.OUTBOX_STATUS_DELIVERED {
+		r.logger.Warn(message.W_FW_8018, outboxItem.OutboxId)
+		return false, nil
+	}
+	return false, errors.Wrapf(ErrOutboxSending, "アウトボックスID[%s]", outboxItem.OutboxId)
+}
+
+// relayToSQS は、SQSへメッセージを送信し、送信済に更新します。
+func (r *defaultOutboxRelay) relayToSQS(ctx context.Context, outboxItem *model.OutboxItem) error {
+	input := &sqs.SendMessageInput{
+		MessageBody:  aws.String(outboxItem.Body),
+		DelaySeconds: outboxItem.DelaySeconds,
+	}
+	if outboxItem.MessageGroupId != "" {
+		input.MessageGroupId = aws.String(outboxItem.MessageGroupId)
+		// リレーの再実行で重複しないよう、重複排除IDの指定がない場合はアウトボックスのIDとする
+		input.MessageDeduplicationId = aws.String(outboxItem.OutboxId)
+		if outboxItem.MessageDeduplicationId != "" {
+			input.MessageDeduplicationId = aws.String(outboxItem.MessageDeduplicationId)
+		}
+	}
+	if len(outboxItem.Attributes) > 0 {
+		input.MessageAttributes = make(map[string]sqstypes.MessageAttributeValue, len(outboxItem.Attributes))
+		for k, v := range outboxItem.Attributes {
+			input.MessageAttributes[k] = sqstypes.MessageAttributeValue{
+				DataType:    aws.String("String"),
+				StringValue: aws.String(v),
+			}
+		}
+	}
+	// 終了時に、コンテキスト領域を呼び出し元のContextに戻す
+	outerCtx := apcontext.Context
+	defer func() {
+		apcontext.Context = outerCtx
+	}()
+	// TransactionalSQSAccessorを利用して送信し、キューメッセージ管理テーブルへの登録と送信済への更新を同一トランザクションで行う
+	// なお、メッセージ属性には、ctxに設定した登録時のトレースコンテキストが格納される
+	_, err := r.transactionManager.ExecuteTransactionWithContext(ctx, func(ctxWithTx context.Context) (any, error) {
+		// キューメッセージ管理テーブルへの登録は、コンテキスト領域のトランザクションを利用するため格納しておく
+		apcontext.Context = ctxWithTx
+		if err := r.sqsAccessor.AppendTransactMessageWithContext(ctxWithTx, outboxItem.Destination, input); err != nil {
+			return nil, err
+		}
+		return nil, r.outboxRegisterer.UpdateDeliveredWithContext(ctxWithTx, outboxItem)
+	})
+	return err
+}
+
+// relayToSNS は、SNSのトピックへメッセージを発行し、送信済に更新します。
+func (r *defaultOutboxRelay) relayToSNS(ctx context.Context, outboxItem *model.OutboxItem) error {
+	input := &sns.PublishInput{
+		TopicArn: aws.String(outboxItem.Destination),
+		Message:  aws.String(outboxItem.Body),
+	}
+	if outboxItem.MessageGroupId != "" {
+		input.MessageGroupId = aws.String(outboxItem.MessageGroupId)
+		input.MessageDeduplicationId = aws.String(outboxItem.OutboxId)
+		if outboxItem.MessageDeduplicationId != "" {
+			input.MessageDeduplicationId = aws.String(outboxItem.MessageDeduplicationId)
+		}
+	}
+	input.MessageAttributes = make(map[string]snstypes.MessageAttributeValue, len(outboxItem.Attributes))
+	for k, v := range outboxItem.Attributes {
+		input.MessageAttributes[k] = snstypes.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(v),
+		}
+	}
+	// トレースコンテキストをメッセージ属性に格納し、購読側へ伝搬する
+	for k, v := range otel.InjectTraceCarrier(ctx) {
+		if _, ok := input.MessageAttributes[k]; ok || len(input.MessageAttributes) >= SNS_MAX_MESSAGE_ATTRIBUTES {
+			continue
+		}
+		input.MessageAttributes[k] = snstypes.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(v),
+		}
+	}
+	if _, err := r.snsClient.Publish(ctx, input); err != nil {
+		return errors.WithStack(err)
+	}
+	return r.updateDelivered(ctx, outboxItem)
+}
+
+// relayToEventBridge は、EventBridgeのイベントバスへイベントを送信し、送信済に更新します。
+func (r *defaultOutboxRelay) relayToEventBridge(ctx context.Context, outboxItem *model.OutboxItem) error {
+	entry := ebtypes.PutEventsRequestEntry{
+		EventBusName: aws.String(outboxItem.Destination),
+		Source:       aws.String(outboxItem.Source),
+		DetailType:   aws.String(outboxItem.DetailType),
+		Detail:       aws.String(outboxItem.Body),
+	}
+	// X-Rayのトレースヘッダを指定し、ターゲットへ伝搬する
+	if traceHeader := otel.InjectTraceCarrier(ctx)[otel.XRAY_TRACE_HEADER_NAME]; traceHeader != "" {
+		entry.TraceHeader = aws.String(traceHeader)
+	}
+	output, err := r.eventBridgeClient.PutEvents(ctx, &eventbridge.PutEventsInput{
+		Entries: []ebtypes.PutEventsRequestEntry{entry},
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if output.FailedEntryCount > 0 {
+		entry := output.Entries[0]
+		return errors.Errorf("EventBridgeへのイベント送信に失敗しました: ErrorCode[%s], ErrorMessage[%s]",
+			aws.ToString(entry.ErrorCode), aws.ToString(entry.ErrorMessage))
+	}
+	return r.updateDelivered(ctx, outboxItem)
+}
+
+// updateDelivered は、アウトボックスのアイテムを送信済に更新します。
+func (r *defaultOutboxRelay) updateDelivered(ctx context.Context, outboxItem *model.OutboxItem) error {
+	_, err := r.transactionManager.ExecuteTransactionWithContext(ctx, func(ctxWithTx context.Context) (any, error) {
+		return nil, r.outboxRegisterer.UpdateDeliveredWithContext(ctxWithTx, outboxItem)
+	})
+	return err
+}
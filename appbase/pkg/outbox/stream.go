@@ -0,0 +1,70 @@
+package outbox
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cockroachdb/errors"
+)
+
+// UnmarshalStreamImage は、DynamoDB Streamsのレコードのイメージ（NewImage、OldImage）を、構造体outに変換します。
+func UnmarshalStreamImage(image map[string]events.DynamoDBAttributeValue, out any) error {
+	item := make(map[string]types.AttributeValue, len(image))
+	for k, v := range image {
+		av, err := toAttributeValue(v)
+		if err != nil {
+			return err
+		}
+		item[k] = av
+	}
+	if err := attributevalue.UnmarshalMap(item, out); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// toAttributeValue は、DynamoDB StreamsのDynamoDBAttributeValueを、AWS SDKのAttributeValueに変換します。
+func toAttributeValue(v events.DynamoDBAttributeValue) (types.AttributeValue, error) {
+	switch v.DataType() {
+	case events.DataTypeString:
+		return &types.AttributeValueMemberS{Value: v.String()}, nil
+	case events.DataTypeNumber:
+		return &types.AttributeValueMemberN{Value: v.Number()}, nil
+	case events.DataTypeBinary:
+		return &types.AttributeValueMemberB{Value: v.Binary()}, nil
+	case events.DataTypeBoolean:
+		return &types.AttributeValueMemberBOOL{Value: v.Boolean()}, nil
+	case events.DataTypeNull:
+		return &types.AttributeValueMemberNULL{Value: v.IsNull()}, nil
+	case events.DataTypeStringSet:
+		return &types.AttributeValueMemberSS{Value: v.StringSet()}, nil
+	case events.DataTypeNumberSet:
+		return &types.AttributeValueMemberNS{Value: v.NumberSet()}, nil
+	case events.DataTypeBinarySet:
+		return &types.AttributeValueMemberBS{Value: v.BinarySet()}, nil
+	case events.DataTypeList:
+		list := v.List()
+		values := make([]types.AttributeValue, len(list))
+		for i, e := range list {
+			av, err := toAttributeValue(e)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = av
+		}
+		return &types.AttributeValueMemberL{Value: values}, nil
+	case events.DataTypeMap:
+		m := v.Map()
+		values := make(map[string]types.AttributeValue, len(m))
+		for k, e := range m {
+			av, err := toAttributeValue(e)
+			if err != nil {
+				return nil, err
+			}
+			values[k] = av
+		}
+		return &types.AttributeValueMemberM{Value: values}, nil
+	default:
+		return nil, errors.Errorf("未対応のDynamoDB Streamsのデータ型です: %v", v.DataType())
+	}
+}
@@ -0,0 +1,35 @@
+package outbox
+
+import (
+	"testing"
+
+	"example.com/appbase/pkg/transaction/model"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnmarshalStreamImage(t *testing.T) {
+	image := map[string]events.DynamoDBAttributeValue{
+		"outbox_id":        events.NewStringAttribute("id-1"),
+		"destination_type": events.NewStringAttribute(model.OUTBOX_DESTINATION_TYPE_SQS),
+		"destination":      events.NewStringAttribute("SampleQueue"),
+		"body":             events.NewStringAttribute(`{"todo_id":"1"}`),
+		"delay_seconds":    events.NewNumberAttribute("5"),
+		"attributes": events.NewMapAttribute(map[string]events.DynamoDBAttributeValue{
+			"key": events.NewStringAttribute("value"),
+		}),
+		"status":      events.NewStringAttribute(model.OUTBOX_STATUS_PENDING),
+		"created_at":  events.NewNumberAttribute("1700000000000"),
+		"delete_time": events.NewNumberAttribute("1700000000"),
+	}
+	var item model.OutboxItem
+	err := UnmarshalStreamImage(image, &item)
+	assert.NoError(t, err)
+	assert.Equal(t, "id-1", item.OutboxId)
+	assert.Equal(t, model.OUTBOX_DESTINATION_TYPE_SQS, item.DestinationType)
+	assert.Equal(t, "SampleQueue", item.Destination)
+	assert.Equal(t, int32(5), item.DelaySeconds)
+	assert.Equal(t, map[string]string{"key": "value"}, item.Attributes)
+	assert.Equal(t, int64(1700000000000), item.CreatedAt)
+	assert.Equal(t, 1700000000, item.DeleteTime)
+}
@@ -0,0 +1,208 @@
+/*
+rdb パッケージは、RDBアクセスに関する機能を提供するパッケージです。
+*/
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"time"
+
+	"example.com/appbase/pkg/config"
+	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/message"
+
+	// database/sqlのOpentTelemetry対応のためのインポート
+	// https://github.com/XSAM/otelsql
+	"github.com/XSAM/otelsql"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// RDBユーザ名、パスワードを格納する設定のキー名のデフォルト値
+	// SecretsManagerのシークレット名を含むので、RDB_USERNAME_KEY、RDB_PASSWORD_KEYで変更できる
+	RDB_USERNAME_NAME = "rds_smconfig_username"
+	RDB_PASSWORD_NAME = "rds_smconfig_password"
+
+	RDB_ENDPOINT_NAME = "RDB_ENDPOINT"
+	RDB_PORT_NAME     = "RDB_PORT"
+	RDB_DBNAME_NAME   = "RDB_DB_NAME"
+	RDB_SSL_MODE_NAME = "RDB_SSL_MODE"
+
+	// コネクションプールの最大接続数のプロパティ名
+	RDB_MAX_OPEN_CONNS_NAME = "RDB_MAX_OPEN_CONNS"
+	// コネクションプールの最大アイドル接続数のプロパティ名
+	RDB_MAX_IDLE_CONNS_NAME = "RDB_MAX_IDLE_CONNS"
+	// 接続の最大生存時間（秒）のプロパティ名
+	RDB_CONN_MAX_LIFETIME_SECONDS_NAME = "RDB_CONN_MAX_LIFETIME_SECONDS"
+	// 接続の最大アイドル時間（秒）のプロパティ名
+	RDB_CONN_MAX_IDLE_TIME_SECONDS_NAME = "RDB_CONN_MAX_IDLE_TIME_SECONDS"
+	// 接続の死活監視を行う間隔（秒）のプロパティ名
+	RDB_HEALTH_CHECK_INTERVAL_SECONDS_NAME = "RDB_HEALTH_CHECK_INTERVAL_SECONDS"
+	// DBの統計情報のメトリクスを転送するかどうかのプロパティ名
+	RDB_DB_STATS_METRICS_ENABLED_NAME = "RDB_DB_STATS_METRICS_ENABLED"
+
+	// コネクションプールの最大接続数のデフォルト値
+	// 伝播属性REQUIRES_NEWで、同時に複数のトランザクションを開始する場合があるため、1より大きい値とする
+	RDB_DEFAULT_MAX_OPEN_CONNS = 5
+	// コネクションプールの最大アイドル接続数のデフォルト値
+	RDB_DEFAULT_MAX_IDLE_CONNS = 2
+	// 接続の最大生存時間（秒）のデフォルト値
+	RDB_DEFAULT_CONN_MAX_LIFETIME_SECONDS = 300
+	// 接続の最大アイドル時間（秒）のデフォルト値
+	RDB_DEFAULT_CONN_MAX_IDLE_TIME_SECONDS = 60
+	// 接続の死活監視を行う間隔（秒）のデフォルト値
+	RDB_DEFAULT_HEALTH_CHECK_INTERVAL_SECONDS = 60
+)
+
+// RDBConnectionPool は、RDBのコネクションプールを管理するインタフェースです。
+// コネクションプール（sql.DB）は初回利用時に作成し、以降のリクエストで再利用します。
+type RDBConnectionPool interface {
+	// GetDB は、コネクションプールを取得します。
+	// 前回の利用から死活監視の間隔以上経過している場合（Lambdaの実行環境の凍結からの再開時等）は、
+	// 接続を確認し、利用できない場合はコネクションプールを作成し直します。
+	GetDB(ctx context.Context) (*sql.DB, error)
+	// Close は、コネクションプールを閉じます。
+	Close() error
+	// GetDialect は、接続するRDBの種類に対応するDialectを取得します。
+	GetDialect() Dialect
+}
+
+// NewRDBConnectionPool は、RDBConnectionPoolを作成します。
+func NewRDBConnectionPool(logger logging.Logger, config config.Config, dialect Dialect) RDBConnectionPool {
+	return &defaultRDBConnectionPool{logger: logger, config: config, dialect: dialect}
+}
+
+// defaultRDBConnectionPool は、RDBConnectionPoolを実装する構造体です。
+type defaultRDBConnectionPool struct {
+	logger   logging.Logger
+	config   config.Config
+	dialect  Dialect
+	mu       sync.Mutex
+	db       *sql.DB
+	lastUsed time.Time
+	// DBの統計情報のメトリクスの登録（コネクションプールを作成し直す際に登録解除する）
+	metricsRegistration metricsRegistration
+}
+
+// metricsRegistration は、DBの統計情報のメトリクスの登録を表すインタフェースです。
+type metricsRegistration interface {
+	Unregister() error
+}
+
+// GetDB implements RDBConnectionPool.
+func (p *defaultRDBConnectionPool) GetDB(ctx context.Context) (*sql.DB, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.db == nil {
+		db, registration, err := p.open()
+		if err != nil {
+			return nil, err
+		}
+		p.db, p.metricsRegistration = db, registration
+	} else if p.needsHealthCheck() {
+		// 古い接続が切断されていないか確認
+		if err := p.db.PingContext(ctx); err != nil {
+			p.logger.WarnWithError(err, message.W_FW_8024)
+			// 新しいコネクションプールが利用できることを確認してから差し替え、古いコネクションプールを閉じる
+			db, registration, err := p.open()
+			if err != nil {
+				return nil, err
+			}
+			if err := db.PingContext(ctx); err != nil {
+				if closeErr := closeDB(db, registration); closeErr != nil {
+					p.logger.WarnWithError(closeErr, message.W_FW_8031)
+				}
+				return nil, errors.WithStack(err)
+			}
+			oldDB, oldRegistration := p.db, p.metricsRegistration
+			p.db, p.metricsRegistration = db, registration
+			if err := closeDB(oldDB, oldRegistration); err != nil {
+				p.logger.WarnWithError(err, message.W_FW_8031)
+			}
+		}
+	}
+	p.lastUsed = time.Now()
+	return p.db, nil
+}
+
+// Close implements RDBConnectionPool.
+func (p *defaultRDBConnectionPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	err := closeDB(p.db, p.metricsRegistration)
+	p.db, p.metricsRegistration = nil, nil
+	return errors.WithStack(err)
+}
+
+// GetDialect implements RDBConnectionPool.
+func (p *defaultRDBConnectionPool) GetDialect() Dialect {
+	return p.dialect
+}
+
+// closeDB は、DBの統計情報のメトリクスの登録を解除し、コネクションプールを閉じます。
+func closeDB(db *sql.DB, registration metricsRegistration) error {
+	var err error
+	if registration != nil {
+		err = registration.Unregister()
+	}
+	if db != nil {
+		err = errors.Join(err, db.Close())
+	}
+	return err
+}
+
+// needsHealthCheck は、前回の利用から死活監視の間隔以上経過しているかを判定します。
+func (p *defaultRDBConnectionPool) needsHealthCheck() bool {
+	interval := time.Duration(p.config.GetInt(RDB_HEALTH_CHECK_INTERVAL_SECONDS_NAME, RDB_DEFAULT_HEALTH_CHECK_INTERVAL_SECONDS)) * time.Second
+	return time.Since(p.lastUsed) >= interval
+}
+
+// open は、RDBに接続し、コネクションプールを作成します。
+// DBの統計情報のメトリクスを転送する場合は、メトリクスの登録も返却します。
+func (p *defaultRDBConnectionPool) open() (*sql.DB, metricsRegistration, error) {
+	// 設定された認証方式で接続するConnectorの作成
+	connector, err := newRDBConnector(p.logger, p.config, p.dialect)
+	if err != nil {
+		return nil, nil, err
+	}
+	// X-Rayを使わない場合のDB接続取得の実装例
+	//db := sql.OpenDB(connector)
+	// ADOTのSQLトレースに対応したDB接続の取得
+	db := otelsql.OpenDB(connector, otelsql.WithAttributes(p.dialect.DBSystem()),
+		// デフォルトだと、トランザクション開始・終了（sql.conn.begin/tx、sql.tx.commit、sql.tx.rollback）等もトレースされて見づらいので
+		// SQL実行(sql.conn.exec, sql.conn.query, sql.stmt.exec, sql.stmt.query)のみトレースするようにカスタマイズした例
+		// https://pkg.go.dev/github.com/XSAM/otelsql#SpanOptions
+		// https://pkg.go.dev/github.com/XSAM/otelsql#Method
+		otelsql.WithSpanOptions(
+			otelsql.SpanOptions{
+				SpanFilter: func(ctx context.Context, method otelsql.Method, query string, args []driver.NamedValue) bool {
+					// MethodConnExec, MethodConnQuery, MethodStmtExec, MethodStmtExec）のみトレース
+					return method == otelsql.MethodConnExec || method == otelsql.MethodConnQuery || method == otelsql.MethodStmtExec || method == otelsql.MethodStmtQuery
+				},
+			},
+		))
+
+	// コネクションプールの設定
+	// Lambdaの実行環境が凍結されている間に、RDS Proxy側でアイドル接続が切断されることがあるため、
+	// 最大アイドル時間はRDS Proxyのアイドルクライアントの接続タイムアウトより短くする
+	db.SetMaxOpenConns(p.config.GetInt(RDB_MAX_OPEN_CONNS_NAME, RDB_DEFAULT_MAX_OPEN_CONNS))
+	db.SetMaxIdleConns(p.config.GetInt(RDB_MAX_IDLE_CONNS_NAME, RDB_DEFAULT_MAX_IDLE_CONNS))
+	db.SetConnMaxLifetime(time.Duration(p.config.GetInt(RDB_CONN_MAX_LIFETIME_SECONDS_NAME, RDB_DEFAULT_CONN_MAX_LIFETIME_SECONDS)) * time.Second)
+	db.SetConnMaxIdleTime(time.Duration(p.config.GetInt(RDB_CONN_MAX_IDLE_TIME_SECONDS_NAME, RDB_DEFAULT_CONN_MAX_IDLE_TIME_SECONDS)) * time.Second)
+
+	// メトリックスも転送する場合
+	if p.config.GetBool(RDB_DB_STATS_METRICS_ENABLED_NAME, false) {
+		registration, err := otelsql.RegisterDBStatsMetrics(db, otelsql.WithAttributes(
+			p.dialect.DBSystem(),
+		))
+		if err != nil {
+			db.Close()
+			return nil, nil, errors.WithStack(err)
+		}
+		return db, registration, nil
+	}
+	return db, nil, nil
+}
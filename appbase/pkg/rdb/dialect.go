@@ -0,0 +1,119 @@
+/*
+rdb パッケージは、RDBアクセスに関する機能を提供するパッケージです。
+*/
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// RDBの種類（ドライバ）のプロパティ名
+	RDB_DRIVER_NAME = "RDB_DRIVER"
+
+	// RDB_DRIVER_POSTGRES は、PostgreSQL（Aurora PostgreSQL）を利用する場合の値です（デフォルト）。
+	RDB_DRIVER_POSTGRES = "postgres"
+	// RDB_DRIVER_MYSQL は、MySQL（Aurora MySQL）を利用する場合の値です。
+	RDB_DRIVER_MYSQL = "mysql"
+)
+
+// ErrorKind は、RDBのエラーの分類です。
+type ErrorKind int
+
+const (
+	// ERROR_KIND_OTHER は、以下のいずれにも該当しないエラーです。
+	ERROR_KIND_OTHER ErrorKind = iota
+	// ERROR_KIND_DUPLICATE_KEY は、一意制約違反（重複キー）のエラーです。
+	ERROR_KIND_DUPLICATE_KEY
+	// ERROR_KIND_DEADLOCK は、デッドロックの検出のエラーです。
+	ERROR_KIND_DEADLOCK
+	// ERROR_KIND_SERIALIZATION_FAILURE は、直列化失敗のエラーです。
+	ERROR_KIND_SERIALIZATION_FAILURE
+	// ERROR_KIND_CONNECTION は、RDS Proxyのフェイルオーバー等による接続の切断のエラーです。
+	ERROR_KIND_CONNECTION
+)
+
+// ConnectParams は、RDBへの接続情報を保持します。
+type ConnectParams struct {
+	// ホスト（RDS Proxyのエンドポイント）
+	Host string
+	// ポート
+	Port string
+	// ユーザ名
+	User string
+	// パスワード（IAMデータベース認証の場合は認証トークン）
+	Password string
+	// DB名
+	DBName string
+	// SSLMode（disable、require、verify-ca、verify-full）
+	SSLMode string
+	// IAMデータベース認証を利用するかどうか
+	IAMAuth bool
+}
+
+// Dialect は、RDBの種類ごとの差異を吸収するインタフェースです。
+// RDB_DRIVERの設定に応じて、PostgreSQLまたはMySQLの実装を利用します。
+type Dialect interface {
+	// DriverName は、RDBの種類（postgres、mysql）を返却します。
+	DriverName() string
+	// DefaultPort は、ポートのデフォルト値を返却します。
+	DefaultPort() string
+	// DBSystem は、OpenTelemetryのトレース、メトリクスに付与するDBの種類の属性を返却します。
+	DBSystem() attribute.KeyValue
+	// NewConnector は、接続情報から、RDBに接続するdriver.Connectorを作成します。
+	NewConnector(params *ConnectParams) (driver.Connector, error)
+	// BindVar は、n番目（1始まり）のバインド変数のプレースホルダを返却します。
+	BindVar(n int) string
+	// NumberedBindVar は、プレースホルダが番号付きで、同じバインド変数を複数回参照できるかを返却します。
+	NumberedBindVar() bool
+	// BackslashEscape は、文字列リテラル内でバックスラッシュによるエスケープが有効かを返却します。
+	BackslashEscape() bool
+	// DollarQuote は、ドル記号で囲んだ文字列定数（$$...$$）が有効かを返却します。
+	DollarQuote() bool
+	// QuoteLiteral は、文字列をエスケープした文字列リテラルを返却します。
+	QuoteLiteral(s string) string
+	// QuoteBytes は、バイト列のリテラルを返却します。
+	QuoteBytes(b []byte) string
+	// QuoteTime は、日時のリテラルを返却します。
+	QuoteTime(t time.Time) string
+	// ClassifyError は、エラーを分類します。
+	ClassifyError(err error) ErrorKind
+	// ConstraintName は、一意制約違反のエラーの場合に、制約名（キー名）を返却します。
+	ConstraintName(err error) string
+	// TryAcquireLock は、接続（セッション）単位の名前付きのロックの取得を試みます。
+	// 他の接続がロックを保持している場合は待機せず、falseを返却します。
+	TryAcquireLock(ctx context.Context, conn *sql.Conn, name string) (bool, error)
+	// ReleaseLock は、TryAcquireLockで取得したロックを解放します。
+	ReleaseLock(ctx context.Context, conn *sql.Conn, name string) error
+}
+
+// NewDialect は、RDB_DRIVERの設定に対応するDialectを作成します。
+func NewDialect(driverName string) (Dialect, error) {
+	switch driverName {
+	case "", RDB_DRIVER_POSTGRES:
+		return &postgresDialect{}, nil
+	case RDB_DRIVER_MYSQL:
+		return &mysqlDialect{}, nil
+	}
+	return nil, errors.Newf("%sの値が不正です: %s", RDB_DRIVER_NAME, driverName)
+}
+
+// isConnectionError は、RDBの種類によらない、接続の切断のエラーかどうかを判定します。
+// コミット中の接続の切断は、コミットの結果が不明なため、TransactionManagerでErrCommitOutcomeUnknownとして区別します。
+func isConnectionError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr *net.OpError
+	return errors.As(err, &netErr)
+}
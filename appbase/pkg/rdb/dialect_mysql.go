@@ -0,0 +1,206 @@
+/*
+rdb パッケージは、RDBアクセスに関する機能を提供するパッケージです。
+*/
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/go-sql-driver/mysql"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+const (
+	// MySQLの重複キーのエラー番号（ER_DUP_ENTRY）
+	mysqlDuplicateEntryNumber = 1062
+	// MySQLのデッドロック検出のエラー番号（ER_LOCK_DEADLOCK）
+	mysqlLockDeadlockNumber = 1213
+	// MySQLの直列化失敗のSQLSTATE
+	mysqlSerializationFailureState = "40001"
+	// MySQLのサーバのシャットダウン中のエラー番号（ER_SERVER_SHUTDOWN）
+	mysqlServerShutdownNumber = 1053
+)
+
+// mysqlDialect は、MySQL（Aurora MySQL）のDialectの実装です。
+type mysqlDialect struct{}
+
+// DriverName implements Dialect.
+func (d *mysqlDialect) DriverName() string {
+	return RDB_DRIVER_MYSQL
+}
+
+// DefaultPort implements Dialect.
+func (d *mysqlDialect) DefaultPort() string {
+	return "3306"
+}
+
+// DBSystem implements Dialect.
+func (d *mysqlDialect) DBSystem() attribute.KeyValue {
+	return semconv.DBSystemMySQL
+}
+
+// NewConnector implements Dialect.
+func (d *mysqlDialect) NewConnector(params *ConnectParams) (driver.Connector, error) {
+	cfg := mysql.NewConfig()
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(params.Host, params.Port)
+	cfg.User = params.User
+	cfg.Passwd = params.Password
+	cfg.DBName = params.DBName
+	cfg.TLSConfig = mysqlTLSConfig(params.SSLMode)
+	// DATE、DATETIME型をtime.Timeに変換する
+	cfg.ParseTime = true
+	// SQLインジェクションの影響を広げないよう、複数のSQL文をまとめて実行するMultiStatementsは有効にしない
+	// （マイグレーションファイルは、SplitStatementsで1文ずつに分割して実行する）
+	if params.IAMAuth {
+		// IAMデータベース認証では、認証トークンを平文のパスワードとして送信する（SSL/TLSが必須）
+		cfg.AllowCleartextPasswords = true
+	}
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return connector, nil
+}
+
+// mysqlTLSConfig は、PostgreSQLのSSLModeの値を、MySQLのドライバのTLSの設定値に変換します。
+func mysqlTLSConfig(sslMode string) string {
+	switch sslMode {
+	case "disable":
+		return "false"
+	case "require":
+		// PostgreSQLのrequireと同様、証明書の検証は行わない
+		return "skip-verify"
+	case "verify-ca", "verify-full":
+		return "true"
+	}
+	return "preferred"
+}
+
+// BindVar implements Dialect.
+func (d *mysqlDialect) BindVar(n int) string {
+	return "?"
+}
+
+// NumberedBindVar implements Dialect.
+func (d *mysqlDialect) NumberedBindVar() bool {
+	return false
+}
+
+// BackslashEscape implements Dialect.
+func (d *mysqlDialect) BackslashEscape() bool {
+	return true
+}
+
+// DollarQuote implements Dialect.
+func (d *mysqlDialect) DollarQuote() bool {
+	return false
+}
+
+// QuoteLiteral implements Dialect.
+// sql_modeにNO_BACKSLASH_ESCAPESが指定されていない前提で、バックスラッシュによるエスケープを行います。
+func (d *mysqlDialect) QuoteLiteral(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s) + 2)
+	sb.WriteByte('\'')
+	for _, r := range s {
+		switch r {
+		case 0:
+			sb.WriteString(`\0`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\x1a':
+			sb.WriteString(`\Z`)
+		case '\'':
+			sb.WriteString(`\'`)
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('\'')
+	return sb.String()
+}
+
+// QuoteBytes implements Dialect.
+func (d *mysqlDialect) QuoteBytes(b []byte) string {
+	return "X'" + hex.EncodeToString(b) + "'"
+}
+
+// QuoteTime implements Dialect.
+// ドライバのタイムゾーン（デフォルトはUTC）に合わせ、UTCに変換してDATETIME型の形式で出力します。
+func (d *mysqlDialect) QuoteTime(t time.Time) string {
+	return "'" + t.UTC().Format("2006-01-02 15:04:05.999999") + "'"
+}
+
+// ClassifyError implements Dialect.
+func (d *mysqlDialect) ClassifyError(err error) ErrorKind {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		if errors.Is(err, mysql.ErrInvalidConn) || isConnectionError(err) {
+			return ERROR_KIND_CONNECTION
+		}
+		return ERROR_KIND_OTHER
+	}
+	switch {
+	case mysqlErr.Number == mysqlDuplicateEntryNumber:
+		return ERROR_KIND_DUPLICATE_KEY
+	case mysqlErr.Number == mysqlLockDeadlockNumber:
+		return ERROR_KIND_DEADLOCK
+	case string(mysqlErr.SQLState[:]) == mysqlSerializationFailureState:
+		return ERROR_KIND_SERIALIZATION_FAILURE
+	case mysqlErr.Number == mysqlServerShutdownNumber:
+		return ERROR_KIND_CONNECTION
+	}
+	return ERROR_KIND_OTHER
+}
+
+// ConstraintName implements Dialect.
+// エラーメッセージ（Duplicate entry 'xxx' for key 'm_user.PRIMARY'）から、キー名を取得します。
+func (d *mysqlDialect) ConstraintName(err error) string {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return ""
+	}
+	const prefix = "for key '"
+	i := strings.LastIndex(mysqlErr.Message, prefix)
+	if i < 0 {
+		return ""
+	}
+	return strings.TrimSuffix(mysqlErr.Message[i+len(prefix):], "'")
+}
+
+// TryAcquireLock implements Dialect.
+// ユーザロック（GET_LOCK）を、タイムアウト0秒で利用します。名前は64文字以内である必要があります。
+func (d *mysqlDialect) TryAcquireLock(ctx context.Context, conn *sql.Conn, name string) (bool, error) {
+	var result sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK("+d.QuoteLiteral(name)+", 0)").Scan(&result); err != nil {
+		return false, errors.WithStack(err)
+	}
+	if !result.Valid {
+		// NULLはエラー（メモリ不足、スレッドのKill等）
+		return false, errors.Newf("ロックの取得に失敗しました: %s", name)
+	}
+	return result.Int64 == 1, nil
+}
+
+// ReleaseLock implements Dialect.
+func (d *mysqlDialect) ReleaseLock(ctx context.Context, conn *sql.Conn, name string) error {
+	if _, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK("+d.QuoteLiteral(name)+")"); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+/*
+rdb パッケージは、RDBアクセスに関する機能を提供するパッケージです。
+*/
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+const (
+	// PostgreSQLの一意制約違反のエラーコード
+	pqUniqueViolationCode = "23505"
+	// PostgreSQLのデッドロック検出のエラーコード
+	pqDeadlockDetectedCode = "40P01"
+	// PostgreSQLの直列化失敗のエラーコード
+	pqSerializationFailureCode = "40001"
+	// PostgreSQLの接続例外のエラーコードのクラス
+	pqConnectionExceptionClass = "08"
+	// PostgreSQLの管理者によるシャットダウン（フェイルオーバー時等）のエラーコード
+	pqAdminShutdownCode = "57P01"
+)
+
+// postgresDialect は、PostgreSQL（Aurora PostgreSQL）のDialectの実装です。
+type postgresDialect struct{}
+
+// DriverName implements Dialect.
+func (d *postgresDialect) DriverName() string {
+	return RDB_DRIVER_POSTGRES
+}
+
+// DefaultPort implements Dialect.
+func (d *postgresDialect) DefaultPort() string {
+	return "5432"
+}
+
+// DBSystem implements Dialect.
+func (d *postgresDialect) DBSystem() attribute.KeyValue {
+	return semconv.DBSystemPostgreSQL
+}
+
+// NewConnector implements Dialect.
+func (d *postgresDialect) NewConnector(params *ConnectParams) (driver.Connector, error) {
+	connectStr := fmt.Sprintf(
+		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		params.Host,
+		params.Port,
+		quoteConnectValue(params.User),
+		quoteConnectValue(params.Password),
+		params.DBName,
+		params.SSLMode)
+	connector, err := pq.NewConnector(connectStr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return connector, nil
+}
+
+// BindVar implements Dialect.
+func (d *postgresDialect) BindVar(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// NumberedBindVar implements Dialect.
+func (d *postgresDialect) NumberedBindVar() bool {
+	return true
+}
+
+// BackslashEscape implements Dialect.
+func (d *postgresDialect) BackslashEscape() bool {
+	return false
+}
+
+// DollarQuote implements Dialect.
+func (d *postgresDialect) DollarQuote() bool {
+	return true
+}
+
+// QuoteLiteral implements Dialect.
+func (d *postgresDialect) QuoteLiteral(s string) string {
+	return pq.QuoteLiteral(s)
+}
+
+// QuoteBytes implements Dialect.
+func (d *postgresDialect) QuoteBytes(b []byte) string {
+	return "'\\x" + hex.EncodeToString(b) + "'::bytea"
+}
+
+// QuoteTime implements Dialect.
+func (d *postgresDialect) QuoteTime(t time.Time) string {
+	return pq.QuoteLiteral(t.Format(time.RFC3339Nano))
+}
+
+// ClassifyError implements Dialect.
+func (d *postgresDialect) ClassifyError(err error) ErrorKind {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		if isConnectionError(err) {
+			return ERROR_KIND_CONNECTION
+		}
+		return ERROR_KIND_OTHER
+	}
+	switch pqErr.Code {
+	case pqUniqueViolationCode:
+		return ERROR_KIND_DUPLICATE_KEY
+	case pqDeadlockDetectedCode:
+		return ERROR_KIND_DEADLOCK
+	case pqSerializationFailureCode:
+		return ERROR_KIND_SERIALIZATION_FAILURE
+	case pqAdminShutdownCode:
+		return ERROR_KIND_CONNECTION
+	}
+	if pqErr.Code.Class() == pqConnectionExceptionClass {
+		return ERROR_KIND_CONNECTION
+	}
+	return ERROR_KIND_OTHER
+}
+
+// ConstraintName implements Dialect.
+func (d *postgresDialect) ConstraintName(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Constraint
+	}
+	return ""
+}
+
+// TryAcquireLock implements Dialect.
+// アドバイザリロック（pg_try_advisory_lock）を利用します。ロックのキーは、名前のハッシュ値です。
+func (d *postgresDialect) TryAcquireLock(ctx context.Context, conn *sql.Conn, name string) (bool, error) {
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, fmt.Sprintf("SELECT pg_try_advisory_lock(%d)", advisoryLockKey(name))).Scan(&acquired); err != nil {
+		return false, errors.WithStack(err)
+	}
+	return acquired, nil
+}
+
+// ReleaseLock implements Dialect.
+func (d *postgresDialect) ReleaseLock(ctx context.Context, conn *sql.Conn, name string) error {
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("SELECT pg_advisory_unlock(%d)", advisoryLockKey(name))); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// advisoryLockKey は、ロックの名前から、アドバイザリロックのキーを作成します。
+func advisoryLockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// quoteConnectValue は、接続文字列の値を、空白や記号を含む場合でも解釈されるようにクォートします。
+func quoteConnectValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
@@ -0,0 +1,304 @@
+/*
+migration パッケージは、RDBのスキーマのマイグレーション機能を提供するパッケージです。
+*/
+package migration
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"example.com/appbase/pkg/apcontext"
+	"example.com/appbase/pkg/config"
+	myerrors "example.com/appbase/pkg/errors"
+	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/message"
+	"example.com/appbase/pkg/rdb"
+	"example.com/appbase/pkg/retry"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// マイグレーションの適用履歴テーブル名のプロパティ名
+	RDB_MIGRATION_HISTORY_TABLE_NAME = "RDB_MIGRATION_HISTORY_TABLE_NAME"
+	// マイグレーションの適用履歴テーブル名のデフォルト値
+	RDB_DEFAULT_MIGRATION_HISTORY_TABLE_NAME = "schema_migration_history"
+	// ロックを取得できない場合の最大リトライ回数のプロパティ名
+	RDB_MIGRATION_LOCK_MAX_RETRY_TIMES_NAME = "RDB_MIGRATION_LOCK_MAX_RETRY_TIMES"
+	// ロックを取得できない場合のリトライ間隔（ミリ秒）のプロパティ名
+	RDB_MIGRATION_LOCK_RETRY_INTERVAL_MILLIS_NAME = "RDB_MIGRATION_LOCK_RETRY_INTERVAL_MILLIS"
+	// ロックを取得できない場合の最大リトライ回数のデフォルト値
+	RDB_DEFAULT_MIGRATION_LOCK_MAX_RETRY_TIMES = 30
+	// ロックを取得できない場合のリトライ間隔（ミリ秒）のデフォルト値
+	RDB_DEFAULT_MIGRATION_LOCK_RETRY_INTERVAL_MILLIS = 1000
+)
+
+// ErrMigrationLocked は、他のマイグレーションが実行中で、リトライしてもロックを取得できなかった場合のエラーです。
+var ErrMigrationLocked = errors.New("他のマイグレーションが実行中です")
+
+// migrationFileNamePattern は、マイグレーションファイル名のパターンです。
+// 「V{バージョン}__{説明}.sql」の形式で、バージョンの昇順に適用します（例：V1__create_m_user.sql）。
+var migrationFileNamePattern = regexp.MustCompile(`^V(\d+)__(.+)\.sql$`)
+
+// historyTableNamePattern は、適用履歴テーブル名として利用できる名前のパターンです。
+var historyTableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// Migration は、1つのマイグレーションファイルを表す構造体です。
+type Migration struct {
+	// バージョン
+	Version int64
+	// 説明
+	Description string
+	// SQL
+	SQL string
+	// SQLのチェックサム（SHA-256）
+	Checksum string
+}
+
+// Migrator は、go:embed等で埋め込んだバージョン付きのSQLファイルを、未適用のものからバージョン順に適用するインタフェースです。
+type Migrator interface {
+	// Migrate は、未適用のマイグレーションを適用し、適用した件数を返却します。
+	Migrate() (int, error)
+	// MigrateWithContext は、goroutine向けに、渡されたContextを利用して、未適用のマイグレーションを適用し、適用した件数を返却します。
+	//
+	// マイグレーションは、1ファイルごとにトランザクション内で実行し、適用履歴テーブルにバージョン、チェックサムを記録します。
+	// なお、MySQLではDDLは暗黙的にコミットされるため、失敗時にロールバックされない点に注意してください。
+	// 複数の実行が同時に動作しないよう、アドバイザリロック（MySQLの場合はGET_LOCK）で排他制御します。
+	// ロックは待機せずに取得を試み、取得できない場合は一定間隔でリトライします。
+	// リトライ回数の上限（RDB_MIGRATION_LOCK_MAX_RETRY_TIMES）に達した場合は、ErrMigrationLockedを原因とするSystemErrorを返却します。
+	// 適用済のマイグレーションファイルの内容が変更されている場合は、エラーとします。
+	MigrateWithContext(ctx context.Context) (int, error)
+}
+
+// NewMigrator は、Migratorを作成します。
+// fsysには、マイグレーションファイルを直下に配置したファイルシステム（embed.FSの場合はfs.Subで取得したもの）を指定します。
+func NewMigrator(logger logging.Logger, config config.Config, connectionPool rdb.RDBConnectionPool, fsys fs.FS) Migrator {
+	return &defaultMigrator{
+		logger:            logger,
+		connectionPool:    connectionPool,
+		fsys:              fsys,
+		historyTableName:  config.Get(RDB_MIGRATION_HISTORY_TABLE_NAME, RDB_DEFAULT_MIGRATION_HISTORY_TABLE_NAME),
+		lockMaxRetryTimes: config.GetInt(RDB_MIGRATION_LOCK_MAX_RETRY_TIMES_NAME, RDB_DEFAULT_MIGRATION_LOCK_MAX_RETRY_TIMES),
+		lockRetryInterval: time.Duration(config.GetInt(RDB_MIGRATION_LOCK_RETRY_INTERVAL_MILLIS_NAME, RDB_DEFAULT_MIGRATION_LOCK_RETRY_INTERVAL_MILLIS)) * time.Millisecond,
+	}
+}
+
+// defaultMigrator は、Migratorを実装する構造体です。
+type defaultMigrator struct {
+	logger            logging.Logger
+	connectionPool    rdb.RDBConnectionPool
+	fsys              fs.FS
+	historyTableName  string
+	lockMaxRetryTimes int
+	lockRetryInterval time.Duration
+}
+
+// Migrate implements Migrator.
+func (m *defaultMigrator) Migrate() (int, error) {
+	return m.MigrateWithContext(apcontext.Context)
+}
+
+// MigrateWithContext implements Migrator.
+func (m *defaultMigrator) MigrateWithContext(ctx context.Context) (int, error) {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	if !historyTableNamePattern.MatchString(m.historyTableName) {
+		return 0, errors.Newf("%sの値が不正です: %s", RDB_MIGRATION_HISTORY_TABLE_NAME, m.historyTableName)
+	}
+	migrations, err := LoadMigrations(m.fsys)
+	if err != nil {
+		return 0, err
+	}
+	db, err := m.connectionPool.GetDB(ctx)
+	if err != nil {
+		return 0, err
+	}
+	// ロック（PostgreSQLはアドバイザリロック、MySQLはGET_LOCK）はセッション単位のため、ロックの取得から解放まで同じ接続を利用する
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer conn.Close()
+
+	dialect := m.connectionPool.GetDialect()
+	if err := m.acquireLock(ctx, conn); err != nil {
+		return 0, err
+	}
+	defer func() {
+		// Contextがキャンセルされていてもロックを解放できるよう、キャンセルされないContextを利用
+		if err := dialect.ReleaseLock(context.WithoutCancel(ctx), conn, m.historyTableName); err != nil {
+			m.logger.Debug("マイグレーションのロック解放に失敗: %+v", err)
+		}
+	}()
+
+	if err := m.createHistoryTable(ctx, conn); err != nil {
+		return 0, err
+	}
+	applied, err := m.findApplied(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, migration := range migrations {
+		if checksum, ok := applied[migration.Version]; ok {
+			// 適用済のマイグレーションが変更されていないかを確認
+			if checksum != migration.Checksum {
+				return count, myerrors.NewSystemError(errors.Newf("チェックサムの不一致: 適用済[%s], ファイル[%s]", checksum, migration.Checksum),
+					message.E_FW_9007, migration.Version, migration.Description)
+			}
+			continue
+		}
+		if err := m.apply(ctx, conn, migration); err != nil {
+			return count, err
+		}
+		m.logger.Info(message.I_FW_0016, migration.Version, migration.Description)
+		count++
+	}
+	m.logger.Info(message.I_FW_0017, count)
+	return count, nil
+}
+
+// acquireLock は、ロックの取得を試み、他のマイグレーションが実行中で取得できない場合は、一定間隔でリトライします。
+func (m *defaultMigrator) acquireLock(ctx context.Context, conn *sql.Conn) error {
+	dialect := m.connectionPool.GetDialect()
+	retryer := retry.NewRetryer[bool](m.logger)
+	acquired, err := retryer.DoWithContext(ctx, func() (bool, error) {
+		m.logger.Debug("マイグレーションのロック取得: %s", m.historyTableName)
+		return dialect.TryAcquireLock(ctx, conn, m.historyTableName)
+	}, func(acquired bool, err error) bool {
+		// ロックを他の実行が保持している場合のみリトライ
+		return err == nil && !acquired
+	},
+		retry.MaxRetryTimes(uint(max(m.lockMaxRetryTimes, 0))),
+		retry.Interval(m.lockRetryInterval),
+		retry.MaxInterval(m.lockRetryInterval),
+		// リトライ回数で上限を判定するため、最大経過時間は無制限とする
+		retry.MaxElapsedTime(0),
+	)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		return myerrors.NewSystemError(errors.WithStack(ErrMigrationLocked), message.E_FW_9008, m.historyTableName)
+	}
+	return nil
+}
+
+// createHistoryTable は、適用履歴テーブルが存在しない場合に作成します。
+func (m *defaultMigrator) createHistoryTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	version BIGINT PRIMARY KEY,
+	description VARCHAR(200) NOT NULL,
+	checksum VARCHAR(64) NOT NULL,
+	applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`, m.historyTableName))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// findApplied は、適用済のマイグレーションのバージョンとチェックサムを取得します。
+func (m *defaultMigrator) findApplied(ctx context.Context, conn *sql.Conn) (map[int64]string, error) {
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT version, checksum FROM %s", m.historyTableName))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+	applied := map[int64]string{}
+	for rows.Next() {
+		var version int64
+		var checksum string
+		if err := rows.Scan(&version, &checksum); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		applied[version] = checksum
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return applied, nil
+}
+
+// apply は、トランザクション内でマイグレーションのSQLを実行し、適用履歴テーブルに記録します。
+func (m *defaultMigrator) apply(ctx context.Context, conn *sql.Conn, migration *Migration) (err error) {
+	m.logger.Debug("マイグレーション適用開始: バージョン[%d]", migration.Version)
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() {
+		if err != nil {
+			if err2 := tx.Rollback(); err2 != nil {
+				err = errors.Join(err, err2)
+			}
+		}
+	}()
+	dialect := m.connectionPool.GetDialect()
+	// 複数のSQL文をまとめて実行できないドライバの設定でも適用できるよう、1文ずつ実行する
+	for i, statement := range rdb.SplitStatements(migration.SQL, dialect) {
+		if _, err = tx.ExecContext(ctx, statement); err != nil {
+			return errors.Wrapf(err, "マイグレーションの実行に失敗しました: バージョン[%d], SQL文[%d文目]", migration.Version, i+1)
+		}
+	}
+	if _, err = tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (version, description, checksum) VALUES (%s, %s, %s)",
+		m.historyTableName, dialect.BindVar(1), dialect.BindVar(2), dialect.BindVar(3)),
+		migration.Version, migration.Description, migration.Checksum); err != nil {
+		return errors.WithStack(err)
+	}
+	if err = tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// LoadMigrations は、ファイルシステムの直下のマイグレーションファイルを読み込み、バージョンの昇順で返却します。
+// ファイル名が「V{バージョン}__{説明}.sql」の形式でないファイルは無視します。バージョンが重複している場合はエラーとします。
+func LoadMigrations(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var migrations []*Migration
+	versions := map[int64]string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		matches := migrationFileNamePattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "バージョンが不正です: %s", entry.Name())
+		}
+		if name, ok := versions[version]; ok {
+			return nil, errors.Newf("バージョンが重複しています: %s, %s", name, entry.Name())
+		}
+		versions[version] = entry.Name()
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		sum := sha256.Sum256(content)
+		migrations = append(migrations, &Migration{
+			Version:     version,
+			Description: matches[2],
+			SQL:         string(content),
+			Checksum:    hex.EncodeToString(sum[:]),
+		})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
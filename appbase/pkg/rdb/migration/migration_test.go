@@ -0,0 +1,309 @@
+package migration
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"example.com/appbase/pkg/config"
+	myerrors "example.com/appbase/pkg/errors"
+	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/message"
+	"example.com/appbase/pkg/rdb"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"V10__add_index.sql":     {Data: []byte("CREATE INDEX idx ON m_user(name);")},
+		"V2__create_m_user.sql":  {Data: []byte("CREATE TABLE m_user(id INT);")},
+		"V1__create_schema.sql":  {Data: []byte("CREATE SCHEMA app;")},
+		"README.md":              {Data: []byte("対象外")},
+		"v3__lower_case.sql":     {Data: []byte("対象外")},
+		"sub/V4__in_sub_dir.sql": {Data: []byte("対象外")},
+	}
+	migrations, err := LoadMigrations(fsys)
+	assert.NoError(t, err)
+	// ファイル名の辞書順ではなく、バージョンの数値の昇順
+	if assert.Len(t, migrations, 3) {
+		assert.Equal(t, int64(1), migrations[0].Version)
+		assert.Equal(t, "create_schema", migrations[0].Description)
+		assert.Equal(t, int64(2), migrations[1].Version)
+		assert.Equal(t, int64(10), migrations[2].Version)
+		assert.Equal(t, "CREATE INDEX idx ON m_user(name);", migrations[2].SQL)
+	}
+
+	// チェックサムはファイルの内容のSHA-256
+	sum := sha256.Sum256([]byte("CREATE SCHEMA app;"))
+	assert.Equal(t, hex.EncodeToString(sum[:]), migrations[0].Checksum)
+	// 内容が変わるとチェックサムも変わる
+	changed, err := LoadMigrations(fstest.MapFS{
+		"V1__create_schema.sql": {Data: []byte("CREATE SCHEMA app2;")},
+	})
+	assert.NoError(t, err)
+	assert.NotEqual(t, migrations[0].Checksum, changed[0].Checksum)
+}
+
+func TestLoadMigrations_DuplicateVersion(t *testing.T) {
+	_, err := LoadMigrations(fstest.MapFS{
+		"V1__a.sql":  {Data: []byte("SELECT 1;")},
+		"V01__b.sql": {Data: []byte("SELECT 2;")},
+	})
+	assert.Error(t, err)
+}
+
+// fakeDatabase は、適用履歴テーブルと実行したSQLを記録する、マイグレーションのテスト用のデータベースです。
+type fakeDatabase struct {
+	// 適用履歴テーブルのバージョンとチェックサム
+	applied map[int64]string
+	// コミットされたSQL
+	committed []string
+	// ロールバックの回数
+	rollbacks int
+	// 実行に失敗するSQLに含まれる文字列
+	failSQL string
+}
+
+// Connect implements driver.Connector.
+func (d *fakeDatabase) Connect(ctx context.Context) (driver.Conn, error) {
+	return &fakeConn{db: d}, nil
+}
+
+// Driver implements driver.Connector.
+func (d *fakeDatabase) Driver() driver.Driver {
+	return nil
+}
+
+// fakeConn は、fakeDatabaseへの接続です。
+type fakeConn struct {
+	db *fakeDatabase
+	tx *fakeTx
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.tx = &fakeTx{conn: c, applied: map[int64]string{}}
+	return c.tx, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if c.db.failSQL != "" && strings.Contains(query, c.db.failSQL) {
+		return nil, errors.New("exec error")
+	}
+	if c.tx == nil {
+		c.db.committed = append(c.db.committed, query)
+		return driver.RowsAffected(0), nil
+	}
+	if strings.HasPrefix(query, "INSERT INTO "+RDB_DEFAULT_MIGRATION_HISTORY_TABLE_NAME) {
+		c.tx.applied[args[0].Value.(int64)] = args[2].Value.(string)
+	}
+	c.tx.execs = append(c.tx.execs, query)
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows := &fakeRows{}
+	for version, checksum := range c.db.applied {
+		rows.values = append(rows.values, []driver.Value{version, checksum})
+	}
+	return rows, nil
+}
+
+// fakeTx は、コミット時に実行したSQLと適用履歴をfakeDatabaseに反映するトランザクションです。
+type fakeTx struct {
+	conn    *fakeConn
+	execs   []string
+	applied map[int64]string
+}
+
+func (t *fakeTx) Commit() error {
+	t.conn.db.committed = append(t.conn.db.committed, t.execs...)
+	for version, checksum := range t.applied {
+		t.conn.db.applied[version] = checksum
+	}
+	t.conn.tx = nil
+	return nil
+}
+
+func (t *fakeTx) Rollback() error {
+	t.conn.db.rollbacks++
+	t.conn.tx = nil
+	return nil
+}
+
+// fakeRows は、適用履歴テーブルの検索結果です。
+type fakeRows struct {
+	values [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"version", "checksum"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// dialectStub は、ロックの取得結果を指定できるDialectです。
+type dialectStub struct {
+	rdb.Dialect
+	// ロックを取得できるかどうか
+	lockable bool
+	// ロックの取得を試みた回数
+	lockCalls int
+	// ロックを解放した回数
+	releaseCalls int
+}
+
+func (d *dialectStub) TryAcquireLock(ctx context.Context, conn *sql.Conn, name string) (bool, error) {
+	d.lockCalls++
+	return d.lockable, nil
+}
+
+func (d *dialectStub) ReleaseLock(ctx context.Context, conn *sql.Conn, name string) error {
+	d.releaseCalls++
+	return nil
+}
+
+// connectionPoolStub は、fakeDatabaseに接続するRDBConnectionPoolです。
+type connectionPoolStub struct {
+	db      *sql.DB
+	dialect rdb.Dialect
+}
+
+func (p *connectionPoolStub) GetDB(ctx context.Context) (*sql.DB, error) {
+	return p.db, nil
+}
+
+func (p *connectionPoolStub) Close() error {
+	return p.db.Close()
+}
+
+func (p *connectionPoolStub) GetDialect() rdb.Dialect {
+	return p.dialect
+}
+
+// newTestMigrator は、fakeDatabaseに対してマイグレーションを実行するMigratorを作成します。
+func newTestMigrator(t *testing.T, db *fakeDatabase, dialect *dialectStub, fsys fstest.MapFS) Migrator {
+	msg, err := message.NewMessageSource()
+	assert.NoError(t, err)
+	logger, err := logging.NewLogger(msg)
+	assert.NoError(t, err)
+	dialect.Dialect, err = rdb.NewDialect(rdb.RDB_DRIVER_POSTGRES)
+	assert.NoError(t, err)
+	pool := &connectionPoolStub{db: sql.OpenDB(db), dialect: dialect}
+	t.Cleanup(func() { pool.Close() })
+	return NewMigrator(logger, config.NewTestConfig(map[string]string{
+		RDB_MIGRATION_LOCK_MAX_RETRY_TIMES_NAME:       "2",
+		RDB_MIGRATION_LOCK_RETRY_INTERVAL_MILLIS_NAME: "1",
+	}), pool, fsys)
+}
+
+// checksumOf は、SQLのチェックサムを返却します。
+func checksumOf(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestMigrateWithContext(t *testing.T) {
+	db := &fakeDatabase{applied: map[int64]string{1: checksumOf("CREATE SCHEMA app;")}}
+	dialect := &dialectStub{lockable: true}
+	migrator := newTestMigrator(t, db, dialect, fstest.MapFS{
+		"V1__create_schema.sql": {Data: []byte("CREATE SCHEMA app;")},
+		"V2__create_m_user.sql": {Data: []byte("CREATE TABLE m_user(id INT);")},
+		"V3__add_index.sql":     {Data: []byte("CREATE INDEX idx ON m_user(id);")},
+	})
+
+	count, err := migrator.MigrateWithContext(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Len(t, db.applied, 3)
+	// 適用済のバージョンは実行しない（SQL文は末尾のセミコロンを除いて1文ずつ実行される）
+	assert.NotContains(t, db.committed, "CREATE SCHEMA app")
+	assert.Contains(t, db.committed, "CREATE TABLE m_user(id INT)")
+	assert.Contains(t, db.committed, "CREATE INDEX idx ON m_user(id)")
+	assert.Equal(t, 1, dialect.releaseCalls)
+}
+
+func TestMigrateWithContext_ChecksumMismatch(t *testing.T) {
+	db := &fakeDatabase{applied: map[int64]string{1: checksumOf("CREATE SCHEMA old;")}}
+	migrator := newTestMigrator(t, db, &dialectStub{lockable: true}, fstest.MapFS{
+		"V1__create_schema.sql": {Data: []byte("CREATE SCHEMA app;")},
+		"V2__create_m_user.sql": {Data: []byte("CREATE TABLE m_user(id INT);")},
+	})
+
+	count, err := migrator.MigrateWithContext(context.Background())
+
+	var codableErr myerrors.CodableError
+	if assert.ErrorAs(t, err, &codableErr) {
+		assert.Equal(t, message.E_FW_9007, codableErr.ErrorCode())
+	}
+	assert.Equal(t, 0, count)
+	// 以降のバージョンも適用しない
+	assert.Len(t, db.applied, 1)
+}
+
+func TestMigrateWithContext_Locked(t *testing.T) {
+	db := &fakeDatabase{applied: map[int64]string{}}
+	dialect := &dialectStub{lockable: false}
+	migrator := newTestMigrator(t, db, dialect, fstest.MapFS{
+		"V1__create_schema.sql": {Data: []byte("CREATE SCHEMA app;")},
+	})
+
+	count, err := migrator.MigrateWithContext(context.Background())
+
+	var codableErr myerrors.CodableError
+	if assert.ErrorAs(t, err, &codableErr) {
+		assert.Equal(t, message.E_FW_9008, codableErr.ErrorCode())
+	}
+	assert.ErrorIs(t, err, ErrMigrationLocked)
+	assert.Equal(t, 0, count)
+	// 初回と、最大リトライ回数分のロックの取得を試みる
+	assert.Equal(t, 3, dialect.lockCalls)
+	assert.Empty(t, db.committed)
+}
+
+func TestMigrateWithContext_Rollback(t *testing.T) {
+	db := &fakeDatabase{applied: map[int64]string{}, failSQL: "DROP TABLE"}
+	dialect := &dialectStub{lockable: true}
+	migrator := newTestMigrator(t, db, dialect, fstest.MapFS{
+		"V1__create_schema.sql":  {Data: []byte("CREATE SCHEMA app;")},
+		"V2__replace_m_user.sql": {Data: []byte("CREATE TABLE m_user2(id INT);\nDROP TABLE m_user;")},
+		"V3__add_index.sql":      {Data: []byte("CREATE INDEX idx ON m_user2(id);")},
+	})
+
+	count, err := migrator.MigrateWithContext(context.Background())
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 1, db.rollbacks)
+	// 失敗したバージョンは、成功したSQL文もロールバックされ、適用履歴にも記録しない
+	assert.Equal(t, map[int64]string{1: checksumOf("CREATE SCHEMA app;")}, db.applied)
+	assert.NotContains(t, db.committed, "CREATE TABLE m_user2(id INT)")
+	assert.NotContains(t, db.committed, "CREATE INDEX idx ON m_user2(id)")
+	assert.Equal(t, 1, dialect.releaseCalls)
+}
@@ -4,12 +4,25 @@ rdb パッケージは、RDBアクセスに関する機能を提供するパッ
 package rdb
 
 import (
+	"context"
 	"database/sql"
+	"sync/atomic"
+
+	"example.com/appbase/pkg/apcontext"
+)
+
+const (
+	// RDBのトランザクションのContextのキー
+	RDB_TRANSACTION_CTX_KEY = apcontext.ContextKey("RDB_TRANSACTION")
 )
 
 type RDBAccessor interface {
 	// トランザクションを取得する
 	GetTransaction() *sql.Tx
+	// GetTransactionWithContext は、goroutine向けに、渡されたContextからトランザクションを取得します。
+	// トランザクションが開始されていない場合はnilを返却します。
+	GetTransactionWithContext(ctx context.Context) *sql.Tx
+	// Deprecated: トランザクションはContextで管理するため、TransactionManager以外から利用する必要はありません。
 	SetTransaction(tx *sql.Tx)
 }
 
@@ -23,10 +36,41 @@ type defaultRDBAccessor struct {
 
 // GetTransaction implements RDBAccessor.
 func (ra *defaultRDBAccessor) GetTransaction() *sql.Tx {
+	if tx := ra.GetTransactionWithContext(apcontext.Context); tx != nil {
+		return tx
+	}
 	return ra.tx
 }
 
+// GetTransactionWithContext implements RDBAccessor.
+func (ra *defaultRDBAccessor) GetTransactionWithContext(ctx context.Context) *sql.Tx {
+	if rdbTx := getRDBTransaction(ctx); rdbTx != nil {
+		return rdbTx.tx
+	}
+	return nil
+}
+
 // SetTransaction implements RDBAccessor.
 func (ra *defaultRDBAccessor) SetTransaction(tx *sql.Tx) {
 	ra.tx = tx
 }
+
+// rdbTransaction は、Contextに格納するRDBのトランザクションの情報を保持する構造体です。
+type rdbTransaction struct {
+	tx *sql.Tx
+	// トランザクションを開始したTransactionManager
+	manager *defaultTransactionManager
+	// セーブポイント名の採番用の連番
+	savepointSeq int
+	// ロールバックのみ可能な状態かどうか（参加した処理がエラーとなった場合に設定）
+	rollbackOnly atomic.Bool
+}
+
+// getRDBTransaction は、Contextからトランザクションの情報を取得します。
+func getRDBTransaction(ctx context.Context) *rdbTransaction {
+	if ctx == nil {
+		return nil
+	}
+	rdbTx, _ := ctx.Value(RDB_TRANSACTION_CTX_KEY).(*rdbTransaction)
+	return rdbTx
+}
@@ -0,0 +1,175 @@
+/*
+rdb パッケージは、RDBアクセスに関する機能を提供するパッケージです。
+*/
+package rdb
+
+import (
+	"context"
+	"database/sql/driver"
+	"net"
+	"sync"
+	"time"
+
+	myConfig "example.com/appbase/pkg/config"
+	"example.com/appbase/pkg/logging"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/rds/auth"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// RDBの認証方式のプロパティ名
+	RDB_AUTH_MODE_NAME = "RDB_AUTH_MODE"
+	// RDBユーザ名を格納する設定のキー名のプロパティ名（デフォルトはrds_smconfig_username）
+	RDB_USERNAME_KEY_NAME = "RDB_USERNAME_KEY"
+	// RDBユーザのパスワードを格納する設定のキー名のプロパティ名（デフォルトはrds_smconfig_password）
+	RDB_PASSWORD_KEY_NAME = "RDB_PASSWORD_KEY"
+
+	// RDB_AUTH_MODE_PASSWORD は、SecretsManagerで管理されたパスワードで認証する方式です（デフォルト）。
+	RDB_AUTH_MODE_PASSWORD = "PASSWORD"
+	// RDB_AUTH_MODE_IAM は、IAMデータベース認証の認証トークンで認証する方式です。
+	RDB_AUTH_MODE_IAM = "IAM"
+
+	// 認証トークンを再作成する間隔
+	// 認証トークンの有効期限は15分のため、有効期限より前に再作成する
+	RDB_IAM_AUTH_TOKEN_REFRESH_INTERVAL = 10 * time.Minute
+)
+
+// passwordProvider は、RDBへの接続時のパスワードを取得する関数です。
+type passwordProvider func(ctx context.Context) (string, error)
+
+// newRDBConnector は、設定された認証方式で、RDBに接続するdriver.Connectorを作成します。
+func newRDBConnector(logger logging.Logger, myCfg myConfig.Config, dialect Dialect) (driver.Connector, error) {
+	// RDBユーザ名
+	usernameKey := myCfg.Get(RDB_USERNAME_KEY_NAME, RDB_USERNAME_NAME)
+	rdbUser, found := myCfg.GetWithContains(usernameKey)
+	if !found {
+		return nil, errors.Newf("%sが設定されていません", usernameKey)
+	}
+	// RDS Proxyのエンドポイント
+	rdbEndpoint, found := myCfg.GetWithContains(RDB_ENDPOINT_NAME)
+	if !found {
+		return nil, errors.Newf("%sが設定されていません", RDB_ENDPOINT_NAME)
+	}
+	// RDS Proxyのポート（デフォルトは、PostgreSQLは5432番、MySQLは3306番）
+	rdbPort := myCfg.Get(RDB_PORT_NAME, dialect.DefaultPort())
+	// DB名
+	rdbName, found := myCfg.GetWithContains(RDB_DBNAME_NAME)
+	if !found {
+		return nil, errors.Newf("%sが設定されていません", RDB_DBNAME_NAME)
+	}
+	// SSLMode
+	rdbSslMode := myCfg.Get(RDB_SSL_MODE_NAME, "require")
+
+	// パスワードを除いた接続情報
+	params := ConnectParams{
+		Host:    rdbEndpoint,
+		Port:    rdbPort,
+		User:    rdbUser,
+		DBName:  rdbName,
+		SSLMode: rdbSslMode,
+	}
+	logger.Debug("接続情報: driver=%s host=%s port=%s user=%s dbname=%s sslmode=%s",
+		dialect.DriverName(), params.Host, params.Port, params.User, params.DBName, params.SSLMode)
+
+	var provider passwordProvider
+	switch authMode := myCfg.Get(RDB_AUTH_MODE_NAME, RDB_AUTH_MODE_PASSWORD); authMode {
+	case RDB_AUTH_MODE_PASSWORD:
+		// AppConfig/SecretsManagerを利用してDB接続情報を取得する実装例
+		// DBの認証情報は、SecretsManagerに管理されたものから取得されるが
+		// AppConfigを用いており、AppConfigAgentによりキャッシュされたものを取得するので
+		// APIのスロットリングの問題を防止できている
+		// 接続ごとに取得するため、シークレットのローテーション後の新しい接続には新しいパスワードが利用される
+		passwordKey := myCfg.Get(RDB_PASSWORD_KEY_NAME, RDB_PASSWORD_NAME)
+		if _, found := myCfg.GetWithContains(passwordKey); !found {
+			return nil, errors.Newf("%sが設定されていません", passwordKey)
+		}
+		provider = func(ctx context.Context) (string, error) {
+			rdbPassword, found := myCfg.GetWithContains(passwordKey)
+			if !found {
+				return "", errors.Newf("%sが設定されていません", passwordKey)
+			}
+			return rdbPassword, nil
+		}
+	case RDB_AUTH_MODE_IAM:
+		// IAMデータベース認証
+		// https://docs.aws.amazon.com/ja_jp/AmazonRDS/latest/UserGuide/UsingWithRDS.IAMDBAuth.Connecting.Go.html#UsingWithRDS.IAMDBAuth.Connecting.GoV2
+		cfg, err := config.LoadDefaultConfig(context.TODO())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		tokenProvider := &iamAuthTokenProvider{
+			endpoint:    net.JoinHostPort(rdbEndpoint, rdbPort),
+			region:      cfg.Region,
+			dbUser:      rdbUser,
+			credentials: cfg.Credentials,
+		}
+		provider = tokenProvider.get
+		params.IAMAuth = true
+	default:
+		return nil, errors.Newf("%sの値が不正です: %s", RDB_AUTH_MODE_NAME, authMode)
+	}
+	// ドライバの取得のため、パスワードなしでConnectorを作成（接続はしない）
+	connector, err := dialect.NewConnector(&params)
+	if err != nil {
+		return nil, err
+	}
+	return &rdbConnector{params: params, dialect: dialect, driver: connector.Driver(), passwordProvider: provider}, nil
+}
+
+// rdbConnector は、接続ごとにパスワード（認証トークン）を取得してRDBに接続するdriver.Connectorの実装です。
+// コネクションプールで新しい接続を作成する際に呼び出されるため、認証トークンの有効期限切れ後も接続できます。
+type rdbConnector struct {
+	params           ConnectParams
+	dialect          Dialect
+	driver           driver.Driver
+	passwordProvider passwordProvider
+}
+
+// Connect implements driver.Connector.
+func (c *rdbConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	password, err := c.passwordProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+	params := c.params
+	params.Password = password
+	connector, err := c.dialect.NewConnector(&params)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+// Driver implements driver.Connector.
+func (c *rdbConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// iamAuthTokenProvider は、IAMデータベース認証の認証トークンを作成、キャッシュする構造体です。
+type iamAuthTokenProvider struct {
+	mu          sync.Mutex
+	endpoint    string
+	region      string
+	dbUser      string
+	credentials aws.CredentialsProvider
+	token       string
+	createdAt   time.Time
+}
+
+// get は、認証トークンを取得します。キャッシュした認証トークンが再作成の間隔を過ぎている場合は、再作成します。
+func (p *iamAuthTokenProvider) get(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.token != "" && time.Since(p.createdAt) < RDB_IAM_AUTH_TOKEN_REFRESH_INTERVAL {
+		return p.token, nil
+	}
+	token, err := auth.BuildAuthToken(ctx, p.endpoint, p.region, p.dbUser, p.credentials)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	p.token = token
+	p.createdAt = time.Now()
+	return token, nil
+}
@@ -0,0 +1,333 @@
+/*
+rdb パッケージは、RDBアクセスに関する機能を提供するパッケージです。
+*/
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+
+	"example.com/appbase/pkg/apcontext"
+	"example.com/appbase/pkg/config"
+	myerrors "example.com/appbase/pkg/errors"
+	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/message"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// プリペアドステートメントを利用しないかどうかのプロパティ名
+	RDB_NO_PREPARED_STATEMENT_NAME = "RDB_NO_PREPARED_STATEMENT"
+)
+
+// RDBTemplate は、RDBAccessorを利用して、名前付きパラメータ（:name）のSQLの実行と、
+// 検索結果のdbタグを付与した構造体へのマッピングを行うインタフェースです。
+//
+// パラメータには、map[string]any、またはdbタグを付与した構造体を指定します。
+// RDS Proxy経由で接続する場合のピン留めを回避するため、デフォルトでは、プリペアドステートメントを利用せずに、
+// パラメータの値をエスケープしたリテラルとしてSQLに埋め込みます。
+// RDB_NO_PREPARED_STATEMENTにfalseを指定すると、バインド変数を利用します。
+//
+// トランザクションが開始されている場合はトランザクション内で、開始されていない場合は自動コミットで実行します。
+// エラーは、レコードが存在しない場合、一意制約違反の場合はBusinessError、それ以外の場合はSystemErrorとして返却するため、
+// 業務ロジックで再度ラップする必要はありません。メッセージIDは、WithNoRowsError、WithUniqueViolationErrorで変更できます。
+type RDBTemplate interface {
+	// QueryOne は、SQLを実行し、検索結果の1件目をresult（構造体または値のポインタ）に取得します。
+	QueryOne(query string, params any, result any, opts ...TemplateOption) error
+	// QueryOneWithContext は、goroutine向けに、渡されたContextを利用して、SQLを実行し、検索結果の1件目をresultに取得します。
+	QueryOneWithContext(ctx context.Context, query string, params any, result any, opts ...TemplateOption) error
+	// QueryList は、SQLを実行し、検索結果をresult（構造体または値のスライスのポインタ）に取得します。
+	// 検索結果が0件の場合は、エラーとせずに空のスライスを返却します。
+	QueryList(query string, params any, result any, opts ...TemplateOption) error
+	// QueryListWithContext は、goroutine向けに、渡されたContextを利用して、SQLを実行し、検索結果をresultに取得します。
+	QueryListWithContext(ctx context.Context, query string, params any, result any, opts ...TemplateOption) error
+	// Exec は、更新系のSQLを実行し、更新件数を返却します。
+	Exec(query string, params any, opts ...TemplateOption) (int64, error)
+	// ExecWithContext は、goroutine向けに、渡されたContextを利用して、更新系のSQLを実行し、更新件数を返却します。
+	ExecWithContext(ctx context.Context, query string, params any, opts ...TemplateOption) (int64, error)
+	// ExecBatch は、パラメータのスライスparamsListの要素ごとに、更新系のSQLを順に実行し、合計の更新件数を返却します。
+	// 途中で失敗した場合は、以降のSQLは実行しません。一括で反映するには、トランザクション内で実行してください。
+	ExecBatch(query string, paramsList any, opts ...TemplateOption) (int64, error)
+	// ExecBatchWithContext は、goroutine向けに、渡されたContextを利用して、更新系のSQLを順に実行し、合計の更新件数を返却します。
+	ExecBatchWithContext(ctx context.Context, query string, paramsList any, opts ...TemplateOption) (int64, error)
+}
+
+// TemplateOption は、RDBTemplateのFunctional Optionパターンによるオプションの関数です。
+type TemplateOption func(*TemplateOptions)
+
+// TemplateOptions は、RDBTemplateのSQL実行時のオプションを保持します。
+type TemplateOptions struct {
+	// レコードが存在しない場合のエラーのメッセージID
+	NoRowsErrorCode string
+	// レコードが存在しない場合のエラーのメッセージの置き換え文字列
+	NoRowsErrorArgs []any
+	// 一意制約違反の場合のエラーのメッセージID
+	UniqueViolationErrorCode string
+	// 一意制約違反の場合のエラーのメッセージの置き換え文字列
+	UniqueViolationErrorArgs []any
+}
+
+// WithNoRowsError は、QueryOneでレコードが存在しない場合に返却するBusinessErrorのメッセージIDを指定するオプションを生成します。
+func WithNoRowsError(errorCode string, args ...any) TemplateOption {
+	return func(o *TemplateOptions) {
+		o.NoRowsErrorCode = errorCode
+		o.NoRowsErrorArgs = args
+	}
+}
+
+// WithUniqueViolationError は、一意制約違反の場合に返却するBusinessErrorのメッセージIDを指定するオプションを生成します。
+func WithUniqueViolationError(errorCode string, args ...any) TemplateOption {
+	return func(o *TemplateOptions) {
+		o.UniqueViolationErrorCode = errorCode
+		o.UniqueViolationErrorArgs = args
+	}
+}
+
+// NewRDBTemplate は、RDBTemplateを作成します。
+func NewRDBTemplate(logger logging.Logger, config config.Config, rdbAccessor RDBAccessor, connectionPool RDBConnectionPool) RDBTemplate {
+	return &defaultRDBTemplate{logger: logger, config: config, rdbAccessor: rdbAccessor, connectionPool: connectionPool}
+}
+
+// sqlExecutor は、SQLを実行するインタフェースです。sql.Tx、sql.DBが実装します。
+type sqlExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// defaultRDBTemplate は、RDBTemplateを実装する構造体です。
+type defaultRDBTemplate struct {
+	logger         logging.Logger
+	config         config.Config
+	rdbAccessor    RDBAccessor
+	connectionPool RDBConnectionPool
+}
+
+// QueryOne implements RDBTemplate.
+func (t *defaultRDBTemplate) QueryOne(query string, params any, result any, opts ...TemplateOption) error {
+	return t.QueryOneWithContext(apcontext.Context, query, params, result, opts...)
+}
+
+// QueryOneWithContext implements RDBTemplate.
+func (t *defaultRDBTemplate) QueryOneWithContext(ctx context.Context, query string, params any, result any, opts ...TemplateOption) error {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	options := newTemplateOptions(opts...)
+	rv := reflect.ValueOf(result)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return myerrors.NewSystemError(errors.Newf("resultにはポインタを指定してください: %T", result), message.E_FW_9001)
+	}
+	rows, err := t.query(ctx, query, params)
+	if err != nil {
+		return t.convertError(err, options)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return t.convertError(err, options)
+		}
+		return t.convertError(sql.ErrNoRows, options)
+	}
+	if err := scanRow(rows, rv.Elem()); err != nil {
+		return t.convertError(err, options)
+	}
+	return nil
+}
+
+// QueryList implements RDBTemplate.
+func (t *defaultRDBTemplate) QueryList(query string, params any, result any, opts ...TemplateOption) error {
+	return t.QueryListWithContext(apcontext.Context, query, params, result, opts...)
+}
+
+// QueryListWithContext implements RDBTemplate.
+func (t *defaultRDBTemplate) QueryListWithContext(ctx context.Context, query string, params any, result any, opts ...TemplateOption) error {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	options := newTemplateOptions(opts...)
+	rv := reflect.ValueOf(result)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return myerrors.NewSystemError(errors.Newf("resultにはスライスのポインタを指定してください: %T", result), message.E_FW_9001)
+	}
+	rows, err := t.query(ctx, query, params)
+	if err != nil {
+		return t.convertError(err, options)
+	}
+	defer rows.Close()
+	slice := rv.Elem()
+	elemType := slice.Type().Elem()
+	// 要素がポインタの場合は、ポインタの指す型の値を作成する
+	isPtr := elemType.Kind() == reflect.Pointer
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+	list := reflect.MakeSlice(slice.Type(), 0, 0)
+	for rows.Next() {
+		elem := reflect.New(elemType)
+		if err := scanRow(rows, elem.Elem()); err != nil {
+			return t.convertError(err, options)
+		}
+		if isPtr {
+			list = reflect.Append(list, elem)
+		} else {
+			list = reflect.Append(list, elem.Elem())
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return t.convertError(err, options)
+	}
+	slice.Set(list)
+	return nil
+}
+
+// Exec implements RDBTemplate.
+func (t *defaultRDBTemplate) Exec(query string, params any, opts ...TemplateOption) (int64, error) {
+	return t.ExecWithContext(apcontext.Context, query, params, opts...)
+}
+
+// ExecWithContext implements RDBTemplate.
+func (t *defaultRDBTemplate) ExecWithContext(ctx context.Context, query string, params any, opts ...TemplateOption) (int64, error) {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	options := newTemplateOptions(opts...)
+	affected, err := t.exec(ctx, query, params)
+	if err != nil {
+		return 0, t.convertError(err, options)
+	}
+	return affected, nil
+}
+
+// ExecBatch implements RDBTemplate.
+func (t *defaultRDBTemplate) ExecBatch(query string, paramsList any, opts ...TemplateOption) (int64, error) {
+	return t.ExecBatchWithContext(apcontext.Context, query, paramsList, opts...)
+}
+
+// ExecBatchWithContext implements RDBTemplate.
+func (t *defaultRDBTemplate) ExecBatchWithContext(ctx context.Context, query string, paramsList any, opts ...TemplateOption) (int64, error) {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	options := newTemplateOptions(opts...)
+	list, isList := toList(paramsList)
+	if !isList {
+		return 0, myerrors.NewSystemError(errors.Newf("paramsListにはスライスを指定してください: %T", paramsList), message.E_FW_9001)
+	}
+	var total int64
+	for _, params := range list {
+		affected, err := t.exec(ctx, query, params)
+		if err != nil {
+			return total, t.convertError(err, options)
+		}
+		total += affected
+	}
+	return total, nil
+}
+
+// query は、検索系のSQLを実行します。
+func (t *defaultRDBTemplate) query(ctx context.Context, query string, params any) (*sql.Rows, error) {
+	executor, err := t.getExecutor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	boundQuery, args, err := t.bind(query, params)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := executor.QueryContext(ctx, boundQuery, args...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return rows, nil
+}
+
+// exec は、更新系のSQLを実行します。
+func (t *defaultRDBTemplate) exec(ctx context.Context, query string, params any) (int64, error) {
+	executor, err := t.getExecutor(ctx)
+	if err != nil {
+		return 0, err
+	}
+	boundQuery, args, err := t.bind(query, params)
+	if err != nil {
+		return 0, err
+	}
+	result, err := executor.ExecContext(ctx, boundQuery, args...)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return affected, nil
+}
+
+// bind は、名前付きパラメータを、設定に応じてリテラルまたはバインド変数に変換します。
+func (t *defaultRDBTemplate) bind(query string, params any) (string, []any, error) {
+	inline := t.config.GetBool(RDB_NO_PREPARED_STATEMENT_NAME, true)
+	boundQuery, args, err := bindNamedParams(query, params, inline, t.connectionPool.GetDialect())
+	if err != nil {
+		return "", nil, err
+	}
+	// パラメータの値は個人情報等を含む可能性があるため、値を埋め込む前の名前付きパラメータのSQLのみを出力する
+	t.logger.Debug("SQL: %s", query)
+	return boundQuery, args, nil
+}
+
+// getExecutor は、トランザクションが開始されている場合はトランザクションを、開始されていない場合はコネクションプールを取得します。
+func (t *defaultRDBTemplate) getExecutor(ctx context.Context) (sqlExecutor, error) {
+	if tx := t.rdbAccessor.GetTransactionWithContext(ctx); tx != nil {
+		return tx, nil
+	}
+	db, err := t.connectionPool.GetDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// convertError は、エラーをメッセージIDを持つエラーに変換します。
+func (t *defaultRDBTemplate) convertError(err error, options *TemplateOptions) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return myerrors.NewBusinessErrorWithCause(err, options.NoRowsErrorCode, options.NoRowsErrorArgs...)
+	}
+	dialect := t.connectionPool.GetDialect()
+	if dialect.ClassifyError(err) == ERROR_KIND_DUPLICATE_KEY {
+		args := options.UniqueViolationErrorArgs
+		if options.UniqueViolationErrorCode == message.W_FW_8026 && args == nil {
+			// デフォルトのメッセージの場合は制約名を出力
+			args = []any{dialect.ConstraintName(err)}
+		}
+		return myerrors.NewBusinessErrorWithCause(err, options.UniqueViolationErrorCode, args...)
+	}
+	return myerrors.NewSystemError(err, message.E_FW_9001)
+}
+
+// newTemplateOptions は、TemplateOptionを適用したTemplateOptionsを作成します。
+func newTemplateOptions(opts ...TemplateOption) *TemplateOptions {
+	options := &TemplateOptions{
+		NoRowsErrorCode:          message.W_FW_8025,
+		UniqueViolationErrorCode: message.W_FW_8026,
+	}
+	for _, optFn := range opts {
+		optFn(options)
+	}
+	return options
+}
+
+// scanRow は、現在の行を、構造体のdbタグに対応するフィールドまたは値にスキャンします。
+func scanRow(rows *sql.Rows, rv reflect.Value) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	dests, err := scanDestinations(rv, columns)
+	if err != nil {
+		return err
+	}
+	if err := rows.Scan(dests...); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
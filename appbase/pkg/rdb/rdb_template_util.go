@@ -0,0 +1,427 @@
+/*
+rdb パッケージは、RDBアクセスに関する機能を提供するパッケージです。
+*/
+package rdb
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/cockroachdb/errors"
+)
+
+// 構造体のフィールドとカラム名、パラメータ名の対応を指定するタグ名
+const DB_TAG_NAME = "db"
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// bindNamedParams は、名前付きパラメータ（:name）を含むSQLを、実行するSQLとバインド変数に変換します。
+// inlineがtrueの場合は、プリペアドステートメントを利用しないよう、パラメータの値をエスケープしたリテラルとしてSQLに埋め込みます。
+// falseの場合は、パラメータをDialectに応じたプレースホルダ（PostgreSQLは$1, $2...、MySQLは?）に置き換え、バインド変数として返却します。
+// パラメータの値がスライス（[]byteを除く）の場合は、IN句で利用できるよう、カンマ区切りに展開します。
+// 文字列リテラル（PostgreSQLのE'...'、ドル記号で囲んだ文字列定数を含む）、引用符付きの識別子、コメント（--、/* */）の中と、
+// 型キャスト（::）は置き換えの対象外です。
+func bindNamedParams(query string, params any, inline bool, dialect Dialect) (string, []any, error) {
+	values, err := toParamMap(params)
+	if err != nil {
+		return "", nil, err
+	}
+	var sb strings.Builder
+	var args []any
+	// 番号付きのプレースホルダの場合は、同じ名前のパラメータは同じプレースホルダを再利用
+	placeholders := map[string]string{}
+	runes := []rune(query)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if end, ok := skipLiteralOrComment(runes, i, dialect); ok {
+			// 文字列リテラル、引用符付きの識別子、コメントは終端までそのまま出力
+			sb.WriteString(string(runes[i:end]))
+			i = end - 1
+			continue
+		}
+		switch {
+		case r == ':' && i+1 < len(runes) && runes[i+1] == ':':
+			// 型キャスト
+			sb.WriteString("::")
+			i++
+		case r == ':' && i+1 < len(runes) && isParamNameStart(runes[i+1]):
+			j := i + 1
+			for j < len(runes) && isParamNamePart(runes[j]) {
+				j++
+			}
+			name := string(runes[i+1 : j])
+			value, ok := values[name]
+			if !ok {
+				return "", nil, errors.Newf("パラメータ[%s]が指定されていません", name)
+			}
+			if inline {
+				literal, err := toSQLLiteral(value, dialect)
+				if err != nil {
+					return "", nil, errors.Wrapf(err, "パラメータ[%s]", name)
+				}
+				sb.WriteString(literal)
+			} else {
+				placeholder, ok := placeholders[name]
+				if !ok {
+					var expanded []any
+					placeholder, expanded, err = toPlaceholders(value, len(args), dialect)
+					if err != nil {
+						return "", nil, errors.Wrapf(err, "パラメータ[%s]", name)
+					}
+					args = append(args, expanded...)
+					if dialect.NumberedBindVar() {
+						placeholders[name] = placeholder
+					}
+				}
+				sb.WriteString(placeholder)
+			}
+			i = j - 1
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String(), args, nil
+}
+
+// SplitStatements は、複数のSQL文を含むSQLを、セミコロンで区切られたSQL文ごとに分割します。
+// 文字列リテラル、引用符付きの識別子、コメントの中のセミコロンは区切りとみなしません。空白、コメントのみのSQL文は除きます。
+func SplitStatements(query string, dialect Dialect) []string {
+	var statements []string
+	runes := []rune(query)
+	start := 0
+	hasCode := false
+	flush := func(end int) {
+		if hasCode {
+			statements = append(statements, strings.TrimSpace(string(runes[start:end])))
+		}
+		start = end + 1
+		hasCode = false
+	}
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if end, ok := skipLiteralOrComment(runes, i, dialect); ok {
+			if r != '-' && r != '/' {
+				// コメント以外（文字列リテラル、識別子）
+				hasCode = true
+			}
+			i = end - 1
+			continue
+		}
+		switch {
+		case r == ';':
+			flush(i)
+		case !unicode.IsSpace(r):
+			hasCode = true
+		}
+	}
+	flush(len(runes))
+	return statements
+}
+
+// skipLiteralOrComment は、runes[i]から文字列リテラル、引用符付きの識別子、コメントが始まる場合に、その終端の次の位置を返却します。
+// いずれも始まらない場合は、falseを返却します。終端がない場合は、SQLの末尾までとします。
+func skipLiteralOrComment(runes []rune, i int, dialect Dialect) (int, bool) {
+	r := runes[i]
+	next := func(k int) rune {
+		if k < len(runes) {
+			return runes[k]
+		}
+		return 0
+	}
+	// 識別子の途中（E、$を含む識別子）ではないか
+	wordStart := i == 0 || !isParamNamePart(runes[i-1])
+	switch {
+	case r == '-' && next(i+1) == '-':
+		// 行コメント
+		j := i + 2
+		for j < len(runes) && runes[j] != '\n' {
+			j++
+		}
+		return j, true
+	case r == '/' && next(i+1) == '*':
+		// ブロックコメント
+		j := i + 2
+		for j < len(runes) && !(runes[j] == '*' && next(j+1) == '/') {
+			j++
+		}
+		return min(j+2, len(runes)), true
+	case r == '\'' || r == '"' || r == '`':
+		return skipQuoted(runes, i, r != '`' && dialect.BackslashEscape()), true
+	case (r == 'E' || r == 'e') && next(i+1) == '\'' && wordStart:
+		// PostgreSQLのエスケープ文字列定数は、バックスラッシュによるエスケープが有効
+		return skipQuoted(runes, i+1, true), true
+	case r == '$' && dialect.DollarQuote() && wordStart:
+		// ドル記号で囲んだ文字列定数（$$...$$、$tag$...$tag$）。$1等のプレースホルダは対象外
+		j := i + 1
+		if isParamNameStart(next(j)) {
+			for isParamNamePart(next(j)) {
+				j++
+			}
+		}
+		if next(j) != '$' {
+			return 0, false
+		}
+		tag := runes[i : j+1]
+		for k := j + 1; k+len(tag) <= len(runes); k++ {
+			if slices.Equal(runes[k:k+len(tag)], tag) {
+				return k + len(tag), true
+			}
+		}
+		return len(runes), true
+	}
+	return 0, false
+}
+
+// skipQuoted は、runes[i]の引用符で始まる文字列リテラル、引用符付きの識別子の終端の次の位置を返却します。
+// 引用符の二重化と、backslashがtrueの場合はバックスラッシュによるエスケープを考慮します。
+func skipQuoted(runes []rune, i int, backslash bool) int {
+	quote := runes[i]
+	j := i + 1
+	for j < len(runes) {
+		if runes[j] == '\\' && backslash {
+			j += 2
+			continue
+		}
+		if runes[j] == quote {
+			if j+1 < len(runes) && runes[j+1] == quote {
+				j += 2
+				continue
+			}
+			break
+		}
+		j++
+	}
+	return min(j+1, len(runes))
+}
+
+// isParamNameStart は、パラメータ名の先頭に利用できる文字かを判定します。
+func isParamNameStart(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// isParamNamePart は、パラメータ名の2文字目以降に利用できる文字かを判定します。
+func isParamNamePart(r rune) bool {
+	return isParamNameStart(r) || (r >= '0' && r <= '9')
+}
+
+// toPlaceholders は、パラメータの値に対応するプレースホルダとバインド変数を作成します。
+// offsetは、既に作成済のバインド変数の数です。
+func toPlaceholders(value any, offset int, dialect Dialect) (string, []any, error) {
+	elems, isList := toList(value)
+	if !isList {
+		return dialect.BindVar(offset + 1), []any{value}, nil
+	}
+	if len(elems) == 0 {
+		return "", nil, errors.New("空のスライスは指定できません")
+	}
+	placeholders := make([]string, len(elems))
+	for i := range elems {
+		placeholders[i] = dialect.BindVar(offset + i + 1)
+	}
+	return strings.Join(placeholders, ", "), elems, nil
+}
+
+// toList は、値がスライス（[]byteを除く）または配列の場合に、要素のリストを返却します。
+func toList(value any) ([]any, bool) {
+	if value == nil {
+		return nil, false
+	}
+	if _, ok := value.(driver.Valuer); ok {
+		return nil, false
+	}
+	rv := reflect.ValueOf(value)
+	if (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+	elems := make([]any, rv.Len())
+	for i := range elems {
+		elems[i] = rv.Index(i).Interface()
+	}
+	return elems, true
+}
+
+// toSQLLiteral は、パラメータの値を、SQLに埋め込むリテラルに変換します。
+// 文字列は、SQLインジェクション対策でDialectに応じてエスケープします。
+func toSQLLiteral(value any, dialect Dialect) (string, error) {
+	if value == nil {
+		return "NULL", nil
+	}
+	if valuer, ok := value.(driver.Valuer); ok {
+		rv := reflect.ValueOf(value)
+		if rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return "NULL", nil
+		}
+		v, err := valuer.Value()
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		return toSQLLiteral(v, dialect)
+	}
+	if elems, isList := toList(value); isList {
+		if len(elems) == 0 {
+			return "", errors.New("空のスライスは指定できません")
+		}
+		literals := make([]string, len(elems))
+		for i, elem := range elems {
+			literal, err := toSQLLiteral(elem, dialect)
+			if err != nil {
+				return "", err
+			}
+			literals[i] = literal
+		}
+		return strings.Join(literals, ", "), nil
+	}
+	switch v := value.(type) {
+	case []byte:
+		return dialect.QuoteBytes(v), nil
+	case time.Time:
+		return dialect.QuoteTime(v), nil
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return "NULL", nil
+		}
+		return toSQLLiteral(rv.Elem().Interface(), dialect)
+	case reflect.String:
+		return dialect.QuoteLiteral(rv.String()), nil
+	case reflect.Bool:
+		if rv.Bool() {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			// NaN、Infinityは数値リテラルで表せないため、文字列リテラルとして指定
+			return dialect.QuoteLiteral(strconv.FormatFloat(f, 'g', -1, 64)), nil
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64), nil
+	}
+	return "", errors.Newf("リテラルに変換できない型です: %T", value)
+}
+
+// toParamMap は、パラメータをパラメータ名と値のマップに変換します。
+// パラメータには、map[string]any、またはdbタグを付与した構造体（ポインタ）を指定できます。
+func toParamMap(params any) (map[string]any, error) {
+	if params == nil {
+		return map[string]any{}, nil
+	}
+	if m, ok := params.(map[string]any); ok {
+		return m, nil
+	}
+	rv := reflect.ValueOf(params)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return map[string]any{}, nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, errors.Newf("パラメータのマップのキーは文字列である必要があります: %T", params)
+		}
+		m := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = iter.Value().Interface()
+		}
+		return m, nil
+	case reflect.Struct:
+		m := map[string]any{}
+		for name, index := range fieldIndexes(rv.Type()) {
+			m[name] = rv.FieldByIndex(index).Interface()
+		}
+		return m, nil
+	}
+	return nil, errors.Newf("パラメータにはマップまたは構造体を指定してください: %T", params)
+}
+
+// fieldIndexes は、構造体のdbタグ（タグがない場合はフィールド名の小文字）と、フィールドのインデックスの対応を返却します。
+// dbタグに"-"を指定したフィールド、非公開のフィールドは対象外です。埋め込みの構造体（ポインタを除く）のフィールドも対象とします。
+func fieldIndexes(t reflect.Type) map[string][]int {
+	indexes := map[string][]int{}
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || throughPointer(t, field.Index) {
+			continue
+		}
+		tag := field.Tag.Get(DB_TAG_NAME)
+		if tag == "-" {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && tag == "" {
+			// 埋め込みの構造体自体は対象外（フィールドはVisibleFieldsで列挙される）
+			continue
+		}
+		name := tag
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		if _, ok := indexes[name]; !ok {
+			indexes[name] = field.Index
+		}
+	}
+	return indexes
+}
+
+// throughPointer は、フィールドが埋め込みのポインタの構造体を経由しているかを判定します。
+func throughPointer(t reflect.Type, index []int) bool {
+	for i := 1; i < len(index); i++ {
+		if t.FieldByIndex(index[:i]).Type.Kind() == reflect.Pointer {
+			return true
+		}
+	}
+	return false
+}
+
+// isScalarType は、構造体のフィールドへのマッピングではなく、カラムの値を直接スキャンする型かを判定します。
+func isScalarType(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return true
+	}
+	return t == timeType || reflect.PointerTo(t).Implements(scannerType) || t.Implements(valuerType)
+}
+
+// scanDestinations は、カラム名に対応する、構造体のフィールドのスキャン先を作成します。
+// 対応するフィールドがないカラムは、読み捨てます。
+func scanDestinations(rv reflect.Value, columns []string) ([]any, error) {
+	if isScalarType(rv.Type()) {
+		if len(columns) != 1 {
+			return nil, errors.Newf("構造体以外に取得する場合は、カラムを1つにしてください: カラム数[%d]", len(columns))
+		}
+		return []any{rv.Addr().Interface()}, nil
+	}
+	// カラム名は大文字・小文字を区別せずに対応付ける
+	indexes := map[string][]int{}
+	for name, index := range fieldIndexes(rv.Type()) {
+		indexes[strings.ToLower(name)] = index
+	}
+	dests := make([]any, len(columns))
+	for i, column := range columns {
+		index, ok := indexes[strings.ToLower(column)]
+		if !ok {
+			var discard any
+			dests[i] = &discard
+			continue
+		}
+		dests[i] = rv.FieldByIndex(index).Addr().Interface()
+	}
+	return dests, nil
+}
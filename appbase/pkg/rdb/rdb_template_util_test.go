@@ -0,0 +1,185 @@
+package rdb
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testUser struct {
+	ID      string `db:"user_id"`
+	Name    string `db:"user_name"`
+	Age     int
+	Ignored string `db:"-"`
+}
+
+func TestBindNamedParams(t *testing.T) {
+	params := map[string]any{"id": "a'b", "ids": []int{1, 2}, "flag": true, "none": nil}
+	tests := []struct {
+		name      string
+		query     string
+		inline    bool
+		wantQuery string
+		wantArgs  []any
+	}{
+		{
+			name:      "リテラル埋め込み",
+			query:     "SELECT * FROM t WHERE id = :id AND flag = :flag AND v IS :none",
+			inline:    true,
+			wantQuery: "SELECT * FROM t WHERE id = 'a''b' AND flag = TRUE AND v IS NULL",
+		},
+		{
+			name:      "バインド変数",
+			query:     "SELECT * FROM t WHERE id = :id OR parent_id = :id",
+			wantQuery: "SELECT * FROM t WHERE id = $1 OR parent_id = $1",
+			wantArgs:  []any{"a'b"},
+		},
+		{
+			name:      "IN句の展開（リテラル）",
+			query:     "SELECT * FROM t WHERE n IN (:ids)",
+			inline:    true,
+			wantQuery: "SELECT * FROM t WHERE n IN (1, 2)",
+		},
+		{
+			name:      "IN句の展開（バインド変数）",
+			query:     "SELECT * FROM t WHERE id = :id AND n IN (:ids)",
+			wantQuery: "SELECT * FROM t WHERE id = $1 AND n IN ($2, $3)",
+			wantArgs:  []any{"a'b", 1, 2},
+		},
+		{
+			name:      "文字列リテラルと型キャストは対象外",
+			query:     "SELECT ':id', \":id\", n::text FROM t WHERE id = :id",
+			inline:    true,
+			wantQuery: "SELECT ':id', \":id\", n::text FROM t WHERE id = 'a''b'",
+		},
+		{
+			name:      "コメントは対象外",
+			query:     "SELECT n -- :id\nFROM t /* :id */ WHERE id = :id",
+			inline:    true,
+			wantQuery: "SELECT n -- :id\nFROM t /* :id */ WHERE id = 'a''b'",
+		},
+		{
+			name:      "エスケープ文字列定数は対象外",
+			query:     "SELECT E'it\\'s :id' FROM t WHERE id = :id",
+			wantQuery: "SELECT E'it\\'s :id' FROM t WHERE id = $1",
+			wantArgs:  []any{"a'b"},
+		},
+		{
+			name:      "ドル記号で囲んだ文字列定数は対象外",
+			query:     "SELECT $$ :id $$, $fn$ ':id $fn$ FROM t WHERE id = :id",
+			wantQuery: "SELECT $$ :id $$, $fn$ ':id $fn$ FROM t WHERE id = $1",
+			wantArgs:  []any{"a'b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := bindNamedParams(tt.query, params, tt.inline, &postgresDialect{})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantQuery, query)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
+
+func TestBindNamedParamsWithStruct(t *testing.T) {
+	user := &testUser{ID: "1", Name: "taro", Age: 20}
+	query, _, err := bindNamedParams("INSERT INTO m_user VALUES(:user_id, :user_name, :age)", user, true, &postgresDialect{})
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO m_user VALUES('1', 'taro', 20)", query)
+
+	// 存在しないパラメータ
+	_, _, err = bindNamedParams("SELECT :ignored", user, true, &postgresDialect{})
+	assert.Error(t, err)
+}
+
+func TestBindNamedParamsForMySQL(t *testing.T) {
+	params := map[string]any{"id": "a'b\\c", "ids": []int{1, 2}, "data": []byte{0x01, 0xff}}
+	tests := []struct {
+		name      string
+		query     string
+		inline    bool
+		wantQuery string
+		wantArgs  []any
+	}{
+		{
+			name:      "リテラル埋め込み",
+			query:     "SELECT * FROM t WHERE id = :id AND data = :data",
+			inline:    true,
+			wantQuery: "SELECT * FROM t WHERE id = 'a\\'b\\\\c' AND data = X'01ff'",
+		},
+		{
+			name:      "バインド変数は名前が同じでも繰り返す",
+			query:     "SELECT * FROM t WHERE id = :id OR parent_id = :id AND n IN (:ids)",
+			wantQuery: "SELECT * FROM t WHERE id = ? OR parent_id = ? AND n IN (?, ?)",
+			wantArgs:  []any{"a'b\\c", "a'b\\c", 1, 2},
+		},
+		{
+			name:      "バックスラッシュでエスケープした文字列リテラルとバッククォートの識別子は対象外",
+			query:     "SELECT 'it\\'s :id', `:id` FROM t WHERE n IN (:ids)",
+			inline:    true,
+			wantQuery: "SELECT 'it\\'s :id', `:id` FROM t WHERE n IN (1, 2)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := bindNamedParams(tt.query, params, tt.inline, &mysqlDialect{})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantQuery, query)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	query := `-- ユーザマスタ
+CREATE TABLE m_user (id INT, name VARCHAR(10) DEFAULT 'a;b');
+/* 初期データ; */
+INSERT INTO m_user VALUES (1, 'x');;
+CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql;
+-- 末尾のコメント;
+`
+	assert.Equal(t, []string{
+		"-- ユーザマスタ\nCREATE TABLE m_user (id INT, name VARCHAR(10) DEFAULT 'a;b')",
+		"/* 初期データ; */\nINSERT INTO m_user VALUES (1, 'x')",
+		"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql",
+	}, SplitStatements(query, &postgresDialect{}))
+}
+
+func TestToSQLLiteral(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{name: "整数", value: 10, want: "10"},
+		{name: "小数", value: 1.5, want: "1.5"},
+		{name: "float32", value: float32(0.25), want: "0.25"},
+		{name: "指数表記", value: 1e21, want: "1e+21"},
+		{name: "NaN", value: math.NaN(), want: "'NaN'"},
+		{name: "Infinity", value: math.Inf(-1), want: "'-Inf'"},
+		{name: "nil", value: nil, want: "NULL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toSQLLiteral(tt.value, &postgresDialect{})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestScanDestinations(t *testing.T) {
+	var user testUser
+	dests, err := scanDestinations(reflect.ValueOf(&user).Elem(), []string{"USER_ID", "age", "unknown"})
+	assert.NoError(t, err)
+	assert.Len(t, dests, 3)
+	assert.Same(t, &user.ID, dests[0])
+	assert.Same(t, &user.Age, dests[1])
+
+	// 構造体以外はカラムが1つの場合のみ
+	var count int
+	_, err = scanDestinations(reflect.ValueOf(&count).Elem(), []string{"a", "b"})
+	assert.Error(t, err)
+}
@@ -6,173 +6,268 @@ package rdb
 import (
 	"context"
 	"database/sql"
-	"database/sql/driver"
 	"fmt"
 
 	"example.com/appbase/pkg/apcontext"
-	"example.com/appbase/pkg/config"
 	"example.com/appbase/pkg/domain"
 	"example.com/appbase/pkg/logging"
-	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
-
-	// database/sqlのOpentTelemetry対応のためのインポート
-	// https://github.com/XSAM/otelsql
-	"github.com/XSAM/otelsql"
+	"example.com/appbase/pkg/message"
+	"example.com/appbase/pkg/retry"
+	"example.com/appbase/pkg/transaction"
 	"github.com/cockroachdb/errors"
-
-	_ "github.com/lib/pq"
 )
 
-const (
-	// TODO: SecretsManagerのシークレット名を含むので、定数ではなく設定変更できるようにする。
-	RDB_USERNAME_NAME = "rds_smconfig_username"
-	RDB_PASSWORD_NAME = "rds_smconfig_password"
-
-	RDB_ENDPOINT_NAME = "RDB_ENDPOINT"
-	RDB_PORT_NAME     = "RDB_PORT"
-	RDB_DBNAME_NAME   = "RDB_DB_NAME"
-	RDB_SSL_MODE_NAME = "RDB_SSL_MODE"
-)
+// ErrCommitOutcomeUnknown は、コミット中に接続が切断され、コミットされたかどうかが不明な場合のエラーです。
+// コミット済の可能性があるため、WithRetrySafeを指定した場合も、トランザクションは再実行しません。
+var ErrCommitOutcomeUnknown = errors.New("コミットの結果が不明です")
 
 // TransactionManager はトランザクションを管理するインタフェースです
 type TransactionManager interface {
-	// ExecuteTransaction は、Serviceの関数serviceFuncの実行前後でDynamoDBトランザクション実行します。
-	ExecuteTransaction(serviceFunc domain.ServiceFunc) (any, error)
+	// ExecuteTransaction は、Serviceの関数serviceFuncの実行前後でRDBトランザクション実行します。
+	// 既に開始されたトランザクションの中から呼び出した場合の動作は、DynamoDBのTransactionManagerと同様に、
+	// transaction.WithPropagationで伝播属性を指定できます（デフォルトはREQUIRED）。
+	// 伝播属性にNESTEDを指定すると、既に開始されたトランザクション内にセーブポイントを作成して実行します。
+	// 既に開始されたトランザクションに参加できるのは、同じTransactionManagerで開始したトランザクションのみです。
+	ExecuteTransaction(serviceFunc domain.ServiceFunc, opts ...transaction.Option) (any, error)
+	// ExecuteTransactionWithContext は、goroutine向けに、渡されたContextを利用して、
+	// Serviceの関数serviceFuncの実行前後でRDBトランザクション実行します。
+	// トランザクションは、serviceFuncに渡されるContextに格納されます。RDBAccessor.GetTransactionWithContextで取得してください。
+	// 分離レベル、読み取り専用、タイムアウト時間は、WithIsolationLevel、WithReadOnly、WithTimeoutで指定できます。
+	// WithRetrySafeを指定した場合は、直列化失敗、デッドロック、接続の切断のエラー時に、トランザクションごと再実行します。
+	// ただし、コミット中の接続の切断は、コミットされたかどうかが不明なため再実行せず、ErrCommitOutcomeUnknownでマークしたエラーを返却します。
+	ExecuteTransactionWithContext(ctx context.Context, serviceFunc domain.ServiceFuncWithContext, opts ...transaction.Option) (any, error)
 }
 
 // NewTransactionManager は、TransactionManagerを作成します
-func NewTransactionManager(logger logging.Logger, config config.Config, rdbAccessor RDBAccessor) TransactionManager {
-	return &defaultTransactionManager{logger: logger, config: config, rdbAccessor: rdbAccessor}
+func NewTransactionManager(logger logging.Logger, rdbAccessor RDBAccessor, connectionPool RDBConnectionPool) TransactionManager {
+	return &defaultTransactionManager{logger: logger, rdbAccessor: rdbAccessor, connectionPool: connectionPool}
 }
 
 // defaultTransactionManager は、TransactionManagerを実装する構造体です。
 type defaultTransactionManager struct {
-	logger      logging.Logger
-	config      config.Config
-	tx          *sql.Tx
-	rdbAccessor RDBAccessor
+	logger         logging.Logger
+	rdbAccessor    RDBAccessor
+	connectionPool RDBConnectionPool
 }
 
 // ExecuteTransaction implements TransactionManager.
-func (tm *defaultTransactionManager) ExecuteTransaction(serviceFunc domain.ServiceFunc) (any, error) {
-	db, err := tm.rdbConnect()
+func (tm *defaultTransactionManager) ExecuteTransaction(serviceFunc domain.ServiceFunc, opts ...transaction.Option) (any, error) {
+	// 終了時に、コンテキスト領域を呼び出し元のContextに戻す
+	// （トランザクション終了後に、呼び出し元で終了済のトランザクションを利用しないようにするため）
+	outerCtx := apcontext.Context
+	defer func() {
+		apcontext.Context = outerCtx
+	}()
+	return tm.ExecuteTransactionWithContext(apcontext.Context, func(ctx context.Context) (any, error) {
+		// トランザクション付きのContextを設定
+		apcontext.Context = ctx
+		return serviceFunc()
+	}, opts...)
+}
+
+// ExecuteTransactionWithContext implements TransactionManager.
+func (tm *defaultTransactionManager) ExecuteTransactionWithContext(ctx context.Context,
+	serviceFunc domain.ServiceFuncWithContext, opts ...transaction.Option) (result any, err error) {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	options := transaction.NewOptions(opts...)
+	// 既に開始されたトランザクション
+	outerTx := getRDBTransaction(ctx)
+	switch options.Propagation {
+	case transaction.PROPAGATION_MANDATORY:
+		if outerTx == nil {
+			return nil, errors.WithStack(transaction.ErrTransactionRequired)
+		}
+		// 既に開始されたトランザクションに参加
+		return tm.joinTransaction(ctx, outerTx, serviceFunc)
+	case transaction.PROPAGATION_NEVER:
+		if outerTx != nil {
+			return nil, errors.WithStack(transaction.ErrTransactionExists)
+		}
+		// トランザクションを開始せずに実行
+		return serviceFunc(ctx)
+	case transaction.PROPAGATION_NESTED:
+		if outerTx != nil {
+			if outerTx.manager != tm {
+				return nil, errors.WithStack(transaction.ErrTransactionManagerMismatch)
+			}
+			// 既に開始されたトランザクション内で、セーブポイントを作成して実行
+			return tm.executeNestedTransaction(ctx, outerTx, serviceFunc)
+		}
+	case transaction.PROPAGATION_REQUIRES_NEW:
+		// 既に開始されたトランザクションの有無に関わらず、新しいトランザクションを開始
+	default:
+		if outerTx != nil {
+			// 既に開始されたトランザクションに参加
+			return tm.joinTransaction(ctx, outerTx, serviceFunc)
+		}
+	}
+
+	if !options.RetrySafe {
+		return tm.executeNewTransaction(ctx, serviceFunc, options)
+	}
+	// 再実行しても安全な関数の場合は、リトライ可能なエラーの場合にトランザクションごと再実行
+	retryer := retry.NewRetryer[any](tm.logger)
+	attempts := 0
+	var lastErr error
+	result, err = retryer.DoWithContext(ctx,
+		func() (any, error) {
+			attempts++
+			if attempts > 1 {
+				tm.logger.WarnWithError(lastErr, message.W_FW_8027, attempts-1)
+			}
+			return tm.executeNewTransaction(ctx, serviceFunc, options)
+		},
+		func(_ any, err error) bool {
+			lastErr = err
+			return tm.isRetryableError(err)
+		},
+		options.RetryOptions...,
+	)
+	if err != nil && attempts > 1 && tm.isRetryableError(err) {
+		tm.logger.WarnWithError(err, message.W_FW_8028, attempts-1)
+	}
+	return result, err
+}
+
+// executeNewTransaction は、新たにトランザクションを開始してserviceFuncを実行します。
+func (tm *defaultTransactionManager) executeNewTransaction(ctx context.Context,
+	serviceFunc domain.ServiceFuncWithContext, options *transaction.Options) (result any, err error) {
+	// タイムアウト時間の指定がある場合は、タイムアウト付きのContextでトランザクションを実行
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+	// コネクションプールの取得
+	db, err := tm.connectionPool.GetDB(ctx)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	// 終了時にRDBコネクションの切断
-	defer db.Close()
 	// RDBトランザクション開始
-	tm.tx, err = tm.startTransaction(db)
+	tx, err := tm.startTransaction(ctx, db, options)
 	if err != nil {
 		return nil, err
 	}
-	// RDBアクセッサにTransactionをセット
-	tm.rdbAccessor.SetTransaction(tm.tx)
+	// トランザクション付きのContextを作成
+	rdbTx := &rdbTransaction{tx: tx, manager: tm}
+	ctxWithTx := context.WithValue(ctx, RDB_TRANSACTION_CTX_KEY, rdbTx)
+
+	defer func() {
+		if r := recover(); r != nil {
+			// panic発生時トランザクションをロールバックし、上位にpanicをリスロー
+			tm.endTransaction(tx, errors.Errorf("recover from: %+v", r))
+			panic(r)
+		}
+	}()
 	// サービスの実行
-	result, err := serviceFunc()
+	result, err = serviceFunc(ctxWithTx)
+	if err == nil && rdbTx.rollbackOnly.Load() {
+		// 参加した処理のエラーを呼び出し元で無視した場合も、トランザクションをロールバック
+		err = errors.WithStack(transaction.ErrTransactionRollbackOnly)
+	}
 	// RDBトランザクション終了
-	err = tm.endTransaction(err)
+	err = tm.endTransaction(tx, err)
 	if err != nil {
 		return nil, err
 	}
 	return result, nil
-
 }
 
-// rdbConnectは、RDBに接続します。
-func (tm *defaultTransactionManager) rdbConnect() (*sql.DB, error) {
-	// TODO: IAM認証でのDB接続の実装
-	// https://qiita.com/k-sasaki-hisys-biz/items/12f680f9a97998322cc0#5-lambda%E3%81%AE%E4%BD%9C%E6%88%90
-	// https://docs.aws.amazon.com/ja_jp/AmazonRDS/latest/UserGuide/UsingWithRDS.IAMDBAuth.Connecting.Go.html#UsingWithRDS.IAMDBAuth.Connecting.GoV2
-
-	// AppConfig/SecretsManagerを利用してDB接続情報を取得する実装例
-	// DBの認証情報は、SecretsManagerに管理されたものから取得されるが
-	// AppConfigを用いており、AppConfigAgentによりキャッシュされたものを取得するので
-	// APIのスロットリングの問題を防止できている
-
-	// RDBユーザ名
-	rdbUser, found := tm.config.GetWithContains(RDB_USERNAME_NAME)
-	if !found {
-		return nil, errors.Newf("%sが設定されていません", RDB_USERNAME_NAME)
-	}
-	// RDBユーザのパスワード
-	rdbPassword, found := tm.config.GetWithContains(RDB_PASSWORD_NAME)
-	if !found {
-		return nil, errors.Newf("%sが設定されていません", RDB_PASSWORD_NAME)
-	}
-	// RDS Proxyのエンドポイント
-	rdbEndpoint, found := tm.config.GetWithContains(RDB_ENDPOINT_NAME)
-	if !found {
-		return nil, errors.Newf("%sが設定されていません", RDB_ENDPOINT_NAME)
-	}
-	// RDS Proxyのポート（デフォルトは5432番）
-	rdbPort := tm.config.Get(RDB_PORT_NAME, "5432")
-	// DB名
-	rdbName, found := tm.config.GetWithContains(RDB_DBNAME_NAME)
-	if !found {
-		return nil, errors.Newf("%sが設定されていません", RDB_DBNAME_NAME)
-	}
-	// SSLMode
-	rdbSslMode := tm.config.Get(RDB_SSL_MODE_NAME, "require")
-
-	connectStr := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		rdbEndpoint,
-		rdbPort,
-		rdbUser,
-		rdbPassword,
-		rdbName,
-		rdbSslMode)
-	tm.logger.Debug("接続文字列: %s", connectStr)
-	// X-Rayを使わない場合のDB接続取得の実装例
-	//db, err := sql.Open("postgres", connectStr)
-	// ADOTのSQLトレースに対応したDB接続の取得
-	db, err := otelsql.Open("postgres", connectStr, otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
-		// デフォルトだと、トランザクション開始・終了（sql.conn.begin/tx、sql.tx.commit、sql.tx.rollback）等もトレースされて見づらいので
-		// SQL実行(sql.conn.exec, sql.conn.query, sql.stmt.exec, sql.stmt.query)のみトレースするようにカスタマイズした例
-		// https://pkg.go.dev/github.com/XSAM/otelsql#SpanOptions
-		// https://pkg.go.dev/github.com/XSAM/otelsql#Method
-		otelsql.WithSpanOptions(
-			otelsql.SpanOptions{
-				SpanFilter: func(ctx context.Context, method otelsql.Method, query string, args []driver.NamedValue) bool {
-					// MethodConnExec, MethodConnQuery, MethodStmtExec, MethodStmtExec）のみトレース
-					return method == otelsql.MethodConnExec || method == otelsql.MethodConnQuery || method == otelsql.MethodStmtExec || method == otelsql.MethodStmtQuery
-				},
-			},
-		))
+// joinTransaction は、既に開始されたトランザクションに参加してserviceFuncを実行します。
+// コミット、ロールバックは、トランザクションを開始した呼び出し元で行います。
+// serviceFuncがエラーの場合は、呼び出し元でエラーを無視してもコミットしないよう、トランザクションをロールバックのみ可能な状態にします。
+func (tm *defaultTransactionManager) joinTransaction(ctx context.Context, outerTx *rdbTransaction, serviceFunc domain.ServiceFuncWithContext) (any, error) {
+	if outerTx.manager != tm {
+		// 別のTransactionManager（別の接続先）のトランザクションには参加しない
+		return nil, errors.WithStack(transaction.ErrTransactionManagerMismatch)
+	}
+	tm.logger.Debug("既に開始されたトランザクションに参加")
+	result, err := serviceFunc(ctx)
 	if err != nil {
+		outerTx.rollbackOnly.Store(true)
+	}
+	return result, err
+}
+
+// isRetryableError は、トランザクションごと再実行することで成功する可能性があるエラー（直列化失敗、デッドロック、接続の切断）かどうかを判定します。
+// コミットの結果が不明なエラーは、二重に反映されないよう再実行の対象外とします。
+func (tm *defaultTransactionManager) isRetryableError(err error) bool {
+	if err == nil || errors.Is(err, ErrCommitOutcomeUnknown) {
+		return false
+	}
+	switch tm.connectionPool.GetDialect().ClassifyError(err) {
+	case ERROR_KIND_SERIALIZATION_FAILURE, ERROR_KIND_DEADLOCK, ERROR_KIND_CONNECTION:
+		return true
+	}
+	return false
+}
+
+// executeNestedTransaction は、既に開始されたトランザクション内にセーブポイントを作成してserviceFuncを実行します。
+// serviceFuncがエラーの場合はセーブポイントまでロールバックし、成功した場合はセーブポイントを解放します。
+// 外側のトランザクションのコミット、ロールバックは、トランザクションを開始した呼び出し元で行います。
+func (tm *defaultTransactionManager) executeNestedTransaction(ctx context.Context, outerTx *rdbTransaction,
+	serviceFunc domain.ServiceFuncWithContext) (result any, err error) {
+	outerTx.savepointSeq++
+	savepoint := fmt.Sprintf("sp_%d", outerTx.savepointSeq)
+	tm.logger.Debug("セーブポイント作成: %s", savepoint)
+	if _, err := outerTx.tx.ExecContext(ctx, "SAVEPOINT "+savepoint); err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	// メトリックスも転送する場合は以下も追記
-	/*
-		_, err = otelsql.RegisterDBStatsMetrics(db, otelsql.WithAttributes(
-			semconv.DBSystemPostgreSQL,
-		))
-		if err != nil {
-			return nil, errors.WithStack(err)
+	defer func() {
+		if r := recover(); r != nil {
+			// panic発生時セーブポイントまでロールバックし、上位にpanicをリスロー
+			tm.endNestedTransaction(ctx, outerTx.tx, savepoint, errors.Errorf("recover from: %+v", r))
+			panic(r)
 		}
-	*/
+	}()
+	// サービスの実行
+	result, err = serviceFunc(ctx)
+	err = tm.endNestedTransaction(ctx, outerTx.tx, savepoint, err)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
 
-	return db, nil
+// endNestedTransaction は、エラーの場合はセーブポイントまでロールバックし、成功した場合はセーブポイントを解放します。
+func (tm *defaultTransactionManager) endNestedTransaction(ctx context.Context, tx *sql.Tx, savepoint string, err error) error {
+	if err != nil {
+		tm.logger.Debug("セーブポイントまでロールバック: %s", savepoint)
+		if _, err2 := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepoint); err2 != nil {
+			tm.logger.Debug("セーブポイントまでのロールバックに失敗")
+			//元のエラー、ロールバックに失敗したエラーまとめて返却する
+			return errors.Join(err, err2)
+		}
+		// ロールバックに成功したら元のエラーオブジェクトを返却
+		return err
+	}
+	tm.logger.Debug("セーブポイント解放: %s", savepoint)
+	if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepoint); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
 }
 
 // startTransaction はトランザクションを開始します。
-func (tm *defaultTransactionManager) startTransaction(db *sql.DB) (*sql.Tx, error) {
+func (tm *defaultTransactionManager) startTransaction(ctx context.Context, db *sql.DB, options *transaction.Options) (*sql.Tx, error) {
 	tm.logger.Debug("トランザクション開始")
-	tx, err := db.BeginTx(apcontext.Context, nil)
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: options.IsolationLevel, ReadOnly: options.ReadOnly})
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 	return tx, nil
 }
 
-// endTransaction は、トランザクションを
-func (tm *defaultTransactionManager) endTransaction(err error) error {
+// endTransaction は、エラーの場合はトランザクションをロールバックし、成功した場合はコミットします。
+// コミット中に接続が切断された場合は、ErrCommitOutcomeUnknownでマークしたエラーを返却します。
+func (tm *defaultTransactionManager) endTransaction(tx *sql.Tx, err error) error {
 	if err != nil {
 		// トランザクションロールバック
 		tm.logger.Debug("トランザクションロールバック")
-		err2 := tm.tx.Rollback()
+		err2 := tx.Rollback()
 		if err2 != nil {
 			tm.logger.Debug("トランザクションロールバックに失敗")
 			//元のエラー、ロールバックに失敗したエラーまとめて返却する
@@ -183,5 +278,13 @@ func (tm *defaultTransactionManager) endTransaction(err error) error {
 	}
 	// トランザクションコミット
 	tm.logger.Debug("トランザクションコミット")
-	return tm.tx.Commit()
+	if err := tx.Commit(); err != nil {
+		if tm.connectionPool.GetDialect().ClassifyError(err) == ERROR_KIND_CONNECTION {
+			// コミット要求がRDBに届いて反映済の可能性がある
+			tm.logger.ErrorWithError(err, message.E_FW_9009)
+			return errors.Mark(errors.WithStack(err), ErrCommitOutcomeUnknown)
+		}
+		return errors.WithStack(err)
+	}
+	return nil
 }
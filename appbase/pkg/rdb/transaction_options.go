@@ -0,0 +1,44 @@
+/*
+rdb パッケージは、RDBアクセスに関する機能を提供するパッケージです。
+*/
+package rdb
+
+import (
+	"database/sql"
+	"time"
+
+	"example.com/appbase/pkg/transaction"
+)
+
+// WithIsolationLevel は、RDBのトランザクションの分離レベルを指定するオプションを生成します。
+// 指定しない場合は、RDBのデフォルトの分離レベル（PostgreSQLの場合はREAD COMMITTED）となります。
+func WithIsolationLevel(level sql.IsolationLevel) transaction.Option {
+	return func(o *transaction.Options) {
+		o.IsolationLevel = level
+	}
+}
+
+// WithReadOnly は、RDBのトランザクションを読み取り専用で開始するオプションを生成します。
+func WithReadOnly() transaction.Option {
+	return func(o *transaction.Options) {
+		o.ReadOnly = true
+	}
+}
+
+// WithTimeout は、RDBのトランザクションのタイムアウト時間を指定するオプションを生成します。
+// タイムアウト時間を超えた場合、実行中のSQLはキャンセルされ、トランザクションはロールバックされます。
+func WithTimeout(timeout time.Duration) transaction.Option {
+	return func(o *transaction.Options) {
+		o.Timeout = timeout
+	}
+}
+
+// WithRetrySafe は、サービスの関数が再実行しても安全（冪等）であることを示し、
+// 直列化失敗、デッドロック、接続の切断のエラーの場合に、トランザクションごとサービスの関数を再実行するオプションを生成します。
+// コミット中に接続が切断された場合は、コミット済の可能性があるため再実行せず、ErrCommitOutcomeUnknownでマークしたエラーを返却します。
+// リトライ回数、間隔は、transaction.WithRetryOptionsで指定できます。
+func WithRetrySafe() transaction.Option {
+	return func(o *transaction.Options) {
+		o.RetrySafe = true
+	}
+}
@@ -0,0 +1,29 @@
+/*
+model パッケージは、サーガ管理テーブルに関連するエンティティを提供します。
+*/
+package model
+
+// SagaItem は、サーガ管理テーブルのアイテムを表す構造体です。
+type SagaItem struct {
+	// SagaId は、サーガの実行ごとのIDです。
+	SagaId string `dynamodbav:"saga_id"`
+	// SagaName は、サーガの定義名です。
+	SagaName string `dynamodbav:"saga_name"`
+	// Status は、サーガの実行状況のステータスです。
+	Status string `dynamodbav:"status"`
+	// CurrentStep は、実行中の場合は次に実行するステップ、補償中の場合は次に補償するステップの順番（0始まり）です。
+	CurrentStep int `dynamodbav:"current_step"`
+	// Data は、ステップ間で受け渡すデータです。
+	Data map[string]string `dynamodbav:"data"`
+	// ErrorMessage は、補償処理の原因となったステップのエラーメッセージです。
+	ErrorMessage string `dynamodbav:"error_message,omitempty"`
+	// UpdateTime は、アイテムの更新時間（UNIX時間（秒））です。
+	UpdateTime int64 `dynamodbav:"update_time"`
+	// Owner は、ステップの処理、補償処理を実行中の実行者のIDです。
+	Owner string `dynamodbav:"owner,omitempty"`
+	// LeaseExpireTime は、実行者の実行権（リース）の有効期限（UNIX時間（秒））です。
+	// リースの取得時に期限切れを条件とするため、登録時も0で保存します。
+	LeaseExpireTime int64 `dynamodbav:"lease_expire_time"`
+	// DeleteTime は、TTLによるアイテムの削除時間（UNIX時間（秒））です。
+	DeleteTime int64 `dynamodbav:"delete_time,omitempty"`
+}
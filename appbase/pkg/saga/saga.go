@@ -0,0 +1,440 @@
+/*
+saga パッケージは、複数のサービスにまたがる処理を、補償処理付きのステップの連なり（サーガ）として実行する機能を提供します。
+*/
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"example.com/appbase/pkg/apcontext"
+	"example.com/appbase/pkg/async"
+	"example.com/appbase/pkg/config"
+	"example.com/appbase/pkg/date"
+	mydynamodb "example.com/appbase/pkg/dynamodb"
+	"example.com/appbase/pkg/id"
+	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/message"
+	"example.com/appbase/pkg/saga/model"
+	mytables "example.com/appbase/pkg/saga/tables"
+	"example.com/appbase/pkg/transaction"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// 非同期実行で利用するSQSのキュー名のプロパティ名
+	SAGA_QUEUE_NAME = "SAGA_QUEUE_NAME"
+	// 中断したとみなすまでの、最終更新からの経過時間（秒）のプロパティ名。ステップごとに取得する実行権（リース）の有効期間にも利用する
+	SAGA_RECOVERY_THRESHOLD_SECONDS = "SAGA_RECOVERY_THRESHOLD_SECONDS"
+	// 中断したとみなすまでの、最終更新からの経過時間（秒）のデフォルト値（5分）
+	DEFAULT_SAGA_RECOVERY_THRESHOLD_SECONDS = 5 * 60
+	// 完了、補償済のサーガ管理テーブルのアイテムの有効期間（TTL）（秒）のプロパティ名。未設定の場合は削除しない
+	SAGA_TTL_SECONDS = "SAGA_TTL_SECONDS"
+)
+
+var (
+	// ErrSagaDefinitionNotFound は、指定したサーガ名の定義が登録されていない場合のエラーです。
+	ErrSagaDefinitionNotFound = errors.New("サーガの定義が登録されていません")
+	// ErrSagaConflict は、同じサーガが他で実行中のため、実行権（リース）の取得や実行状況の更新に失敗した場合のエラーです。
+	ErrSagaConflict = errors.New("同じサーガが他で実行中です")
+)
+
+// SagaData は、サーガのステップ間で受け渡すデータです。
+// ステップの処理で設定した値は、ステップの完了ごとにサーガ管理テーブルに保存され、後続のステップや再開時に引き継がれます。
+type SagaData map[string]string
+
+// StepFunc は、サーガのステップの処理、補償処理を表す関数です。
+// 中断したサーガの再開時に、同じステップが再実行されることがあるため、冪等な処理としてください。
+type StepFunc func(ctx context.Context, data SagaData) error
+
+// Step は、サーガのステップを表す構造体です。
+type Step struct {
+	// Name は、ステップ名です。
+	Name string
+	// Action は、ステップの処理です。
+	Action StepFunc
+	// Compensation は、後続のステップが失敗した場合に、ステップの処理を取り消す補償処理です。補償処理がない場合はnilとします。
+	Compensation StepFunc
+}
+
+// SagaDefinition は、サーガの定義を表す構造体です。
+type SagaDefinition struct {
+	// Name は、サーガ名です。
+	Name string
+	// Steps は、実行順のステップです。
+	Steps []Step
+}
+
+// NewSagaDefinition は、SagaDefinitionを作成します。
+func NewSagaDefinition(name string, steps ...Step) *SagaDefinition {
+	return &SagaDefinition{Name: name, Steps: steps}
+}
+
+// SagaMessage は、サーガの非同期実行のため、SQSのキューに送信するメッセージです。
+type SagaMessage struct {
+	SagaId string `json:"saga_id"`
+}
+
+// SagaOrchestrator は、サーガを実行するインタフェースです。
+// ステップの処理を順に実行し、失敗した場合は、それまでに完了したステップの補償処理を逆順に実行します。
+// 実行状況はステップごとにサーガ管理テーブルに保存されるため、中断したサーガは、Resume、Recoverで続きから再開できます。
+// ステップの処理、補償処理の実行前には、サーガ管理テーブルのアイテムの実行者と更新時間を条件付きで更新して実行権（リース）を取得するため、
+// 同じサーガのステップが、リースの有効期間（SAGA_RECOVERY_THRESHOLD_SECONDS）内に複数の実行者で同時に実行されることはありません。
+// ただし、リースはステップの実行前にのみ取得し、実行中には延長しないため、1つのステップの処理、補償処理がリースの有効期間を超えた場合は、
+// Resume、Recoverにより同じステップが並行して実行される可能性があります。
+// ステップの処理、補償処理は、SAGA_RECOVERY_THRESHOLD_SECONDSより十分短い時間で完了するようにし、冪等に実装してください。
+type SagaOrchestrator interface {
+	// Register は、サーガの定義を登録します。
+	Register(definition *SagaDefinition)
+	// Execute は、サーガを同期実行し、サーガIDを返却します。
+	// ステップの処理が失敗し補償処理が完了した場合は、ステップの処理のエラーを返却します。
+	// なお、サーガの実行状況を他のトランザクションと独立して保存するため、トランザクションの外で実行してください。
+	Execute(sagaName string, data SagaData) (string, error)
+	// ExecuteWithContext は、goroutine向けに渡されたContextを利用して、サーガを同期実行し、サーガIDを返却します。
+	ExecuteWithContext(ctx context.Context, sagaName string, data SagaData) (string, error)
+	// ExecuteAsync は、サーガ管理テーブルへの登録と、SQSのキューへのメッセージ送信を同一トランザクションで行い、サーガを非同期実行します。
+	// メッセージを受信する非同期処理のControllerでは、HandleAsyncMessageを呼び出してください。
+	ExecuteAsync(sagaName string, data SagaData) (string, error)
+	// ExecuteAsyncWithContext は、goroutine向けに渡されたContextを利用して、サーガを非同期実行します。
+	ExecuteAsyncWithContext(ctx context.Context, sagaName string, data SagaData) (string, error)
+	// HandleAsyncMessage は、ExecuteAsyncで送信したメッセージを受信し、サーガを実行します。
+	// AsyncLambdaHandlerのAsyncControllerFuncとして利用できます。
+	HandleAsyncMessage(sqsMessage events.SQSMessage) error
+	// Resume は、中断したサーガを続きから再開します。完了、補償済のサーガの場合は何もしません。
+	Resume(sagaId string) error
+	// ResumeWithContext は、goroutine向けに渡されたContextを利用して、中断したサーガを続きから再開します。
+	ResumeWithContext(ctx context.Context, sagaId string) error
+	// Recover は、最終更新から一定時間（SAGA_RECOVERY_THRESHOLD_SECONDS）経過した実行中、補償中のサーガを検索し、再開します。
+	// 定期実行のLambda（SagaRecoveryLambdaHandler）から呼び出します。
+	Recover() error
+	// RecoverWithContext は、goroutine向けに渡されたContextを利用して、中断したサーガを検索し、再開します。
+	RecoverWithContext(ctx context.Context) error
+}
+
+// NewSagaOrchestrator は、SagaOrchestratorを作成します。
+// transactionManagerは、非同期実行のメッセージ送信を行うため、NewTransactionManagerで作成したものを指定してください。
+func NewSagaOrchestrator(logger logging.Logger, config config.Config,
+	idGenerator id.IDGenerator, dateManager date.DateManager,
+	transactionManager transaction.TransactionManager,
+	sqsTemplate async.SQSTemplate,
+	repository SagaRepository) SagaOrchestrator {
+	return &defaultSagaOrchestrator{
+		logger:             logger,
+		config:             config,
+		idGenerator:        idGenerator,
+		dateManager:        dateManager,
+		transactionManager: transactionManager,
+		sqsTemplate:        sqsTemplate,
+		repository:         repository,
+		definitions:        make(map[string]*SagaDefinition),
+	}
+}
+
+// defaultSagaOrchestrator は、SagaOrchestratorを実装する構造体です。
+type defaultSagaOrchestrator struct {
+	logger             logging.Logger
+	config             config.Config
+	idGenerator        id.IDGenerator
+	dateManager        date.DateManager
+	transactionManager transaction.TransactionManager
+	sqsTemplate        async.SQSTemplate
+	repository         SagaRepository
+	mu                 sync.RWMutex
+	definitions        map[string]*SagaDefinition
+}
+
+// Register implements SagaOrchestrator.
+func (o *defaultSagaOrchestrator) Register(definition *SagaDefinition) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.definitions[definition.Name] = definition
+}
+
+// Execute implements SagaOrchestrator.
+func (o *defaultSagaOrchestrator) Execute(sagaName string, data SagaData) (string, error) {
+	return o.ExecuteWithContext(apcontext.Context, sagaName, data)
+}
+
+// ExecuteWithContext implements SagaOrchestrator.
+func (o *defaultSagaOrchestrator) ExecuteWithContext(ctx context.Context, sagaName string, data SagaData) (string, error) {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	definition, err := o.getDefinition(sagaName)
+	if err != nil {
+		return "", err
+	}
+	sagaItem, err := o.newSagaItem(sagaName, data)
+	if err != nil {
+		return "", err
+	}
+	if err := o.repository.CreateOneWithContext(ctx, sagaItem); err != nil {
+		return "", err
+	}
+	owner, err := o.idGenerator.GenerateUUID()
+	if err != nil {
+		return "", err
+	}
+	return sagaItem.SagaId, o.run(ctx, definition, sagaItem, owner)
+}
+
+// ExecuteAsync implements SagaOrchestrator.
+func (o *defaultSagaOrchestrator) ExecuteAsync(sagaName string, data SagaData) (string, error) {
+	return o.ExecuteAsyncWithContext(apcontext.Context, sagaName, data)
+}
+
+// ExecuteAsyncWithContext implements SagaOrchestrator.
+func (o *defaultSagaOrchestrator) ExecuteAsyncWithContext(ctx context.Context, sagaName string, data SagaData) (string, error) {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	if _, err := o.getDefinition(sagaName); err != nil {
+		return "", err
+	}
+	sagaItem, err := o.newSagaItem(sagaName, data)
+	if err != nil {
+		return "", err
+	}
+	queueName := o.config.Get(SAGA_QUEUE_NAME, "saga")
+	// 終了時に、コンテキスト領域を呼び出し元のContextに戻す
+	outerCtx := apcontext.Context
+	defer func() {
+		apcontext.Context = outerCtx
+	}()
+	// サーガ管理テーブルへの登録とメッセージの送信を同一トランザクションで行う
+	_, err = o.transactionManager.ExecuteTransactionWithContext(ctx, func(ctxWithTx context.Context) (any, error) {
+		// キューメッセージ管理テーブルへの登録は、コンテキスト領域のトランザクションを利用するため格納しておく
+		apcontext.Context = ctxWithTx
+		if err := o.repository.CreateOneWithTxInContext(ctxWithTx, sagaItem); err != nil {
+			return nil, err
+		}
+		return nil, o.sqsTemplate.SendToStandardQueueWithContext(ctxWithTx, queueName, &SagaMessage{SagaId: sagaItem.SagaId})
+	})
+	if err != nil {
+		return "", err
+	}
+	return sagaItem.SagaId, nil
+}
+
+// HandleAsyncMessage implements SagaOrchestrator.
+func (o *defaultSagaOrchestrator) HandleAsyncMessage(sqsMessage events.SQSMessage) error {
+	var sagaMessage SagaMessage
+	if err := json.Unmarshal([]byte(sqsMessage.Body), &sagaMessage); err != nil {
+		return errors.WithStack(err)
+	}
+	return o.ResumeWithContext(apcontext.Context, sagaMessage.SagaId)
+}
+
+// Resume implements SagaOrchestrator.
+func (o *defaultSagaOrchestrator) Resume(sagaId string) error {
+	return o.ResumeWithContext(apcontext.Context, sagaId)
+}
+
+// ResumeWithContext implements SagaOrchestrator.
+func (o *defaultSagaOrchestrator) ResumeWithContext(ctx context.Context, sagaId string) error {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	sagaItem, err := o.repository.FindOneWithContext(ctx, sagaId)
+	if err != nil {
+		return err
+	}
+	if sagaItem.Status == mytables.STATUS_COMPLETED || sagaItem.Status == mytables.STATUS_COMPENSATED {
+		o.logger.Debug("終了済のサーガ: %s[%s]", sagaItem.SagaId, sagaItem.Status)
+		return nil
+	}
+	definition, err := o.getDefinition(sagaItem.SagaName)
+	if err != nil {
+		return err
+	}
+	owner, err := o.idGenerator.GenerateUUID()
+	if err != nil {
+		return err
+	}
+	err = o.run(ctx, definition, sagaItem, owner)
+	if err != nil && sagaItem.Status == mytables.STATUS_COMPENSATED {
+		// 補償処理が完了した場合は、ステップの処理のエラーはログ出力済のため、正常終了とする
+		return nil
+	}
+	return err
+}
+
+// Recover implements SagaOrchestrator.
+func (o *defaultSagaOrchestrator) Recover() error {
+	return o.RecoverWithContext(apcontext.Context)
+}
+
+// RecoverWithContext implements SagaOrchestrator.
+func (o *defaultSagaOrchestrator) RecoverWithContext(ctx context.Context) error {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	threshold := o.config.GetInt(SAGA_RECOVERY_THRESHOLD_SECONDS, DEFAULT_SAGA_RECOVERY_THRESHOLD_SECONDS)
+	updatedBefore := o.dateManager.GetSystemDate().Add(-time.Duration(threshold) * time.Second).Unix()
+	var resultErr error
+	for _, status := range []string{mytables.STATUS_RUNNING, mytables.STATUS_COMPENSATING} {
+		sagaItems, err := o.repository.FindSomeByStatusWithContext(ctx, status, updatedBefore)
+		if err != nil {
+			return err
+		}
+		for _, v := range sagaItems {
+			o.logger.Info(message.I_FW_0015, v.SagaName, v.SagaId, v.Status, v.CurrentStep)
+			if err := o.ResumeWithContext(ctx, v.SagaId); err != nil {
+				// 他のサーガの再開は継続し、最後にまとめてエラーを返却する
+				o.logger.WarnWithError(err, message.W_FW_8023, v.SagaName, v.SagaId)
+				resultErr = errors.Join(resultErr, err)
+			}
+		}
+	}
+	return resultErr
+}
+
+// getDefinition は、サーガ名の定義を取得します。
+func (o *defaultSagaOrchestrator) getDefinition(sagaName string) (*SagaDefinition, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	definition, ok := o.definitions[sagaName]
+	if !ok {
+		return nil, errors.Wrapf(ErrSagaDefinitionNotFound, "サーガ名[%s]", sagaName)
+	}
+	return definition, nil
+}
+
+// newSagaItem は、実行開始時のサーガ管理テーブルのアイテムを作成します。
+func (o *defaultSagaOrchestrator) newSagaItem(sagaName string, data SagaData) (*model.SagaItem, error) {
+	sagaId, err := o.idGenerator.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		data = SagaData{}
+	}
+	return &model.SagaItem{
+		SagaId:      sagaId,
+		SagaName:    sagaName,
+		Status:      mytables.STATUS_RUNNING,
+		CurrentStep: 0,
+		Data:        data,
+		UpdateTime:  o.dateManager.GetSystemDate().Unix(),
+	}, nil
+}
+
+// run は、サーガ管理テーブルのアイテムの実行状況に応じて、ステップの処理または補償処理を続きから実行します。
+// ステップの処理が失敗し、補償処理が完了した場合は、ステップの処理のエラーを返却します。
+// ownerは、実行権（リース）を取得する実行者のIDです。
+func (o *defaultSagaOrchestrator) run(ctx context.Context, definition *SagaDefinition, sagaItem *model.SagaItem, owner string) error {
+	var actionErr error
+	if sagaItem.Status == mytables.STATUS_RUNNING {
+		for sagaItem.CurrentStep < len(definition.Steps) {
+			step := definition.Steps[sagaItem.CurrentStep]
+			if err := o.acquireLease(ctx, sagaItem, owner); err != nil {
+				return err
+			}
+			o.logger.Debug("サーガ[%s]のステップ実行(%d/%d): %s", sagaItem.SagaId, sagaItem.CurrentStep+1, len(definition.Steps), step.Name)
+			if actionErr = step.Action(ctx, sagaItem.Data); actionErr != nil {
+				o.logger.WarnWithError(actionErr, message.W_FW_8022, sagaItem.SagaName, sagaItem.SagaId, step.Name)
+				// 失敗したステップより前の、完了したステップから補償処理を行う
+				if err := o.updateState(ctx, sagaItem, mytables.STATUS_COMPENSATING, sagaItem.CurrentStep-1, actionErr.Error(), false); err != nil {
+					return err
+				}
+				break
+			}
+			if err := o.updateState(ctx, sagaItem, mytables.STATUS_RUNNING, sagaItem.CurrentStep+1, "", false); err != nil {
+				return err
+			}
+		}
+		if actionErr == nil {
+			// 全てのステップが完了
+			if err := o.updateState(ctx, sagaItem, mytables.STATUS_COMPLETED, sagaItem.CurrentStep, "", true); err != nil {
+				return err
+			}
+			o.logger.Info(message.I_FW_0013, sagaItem.SagaName, sagaItem.SagaId)
+			return nil
+		}
+	}
+	if actionErr == nil {
+		// 補償中に中断したサーガの再開の場合
+		actionErr = errors.New(sagaItem.ErrorMessage)
+	}
+	// 補償処理を逆順に実行
+	for sagaItem.CurrentStep >= 0 {
+		step := definition.Steps[sagaItem.CurrentStep]
+		if step.Compensation != nil {
+			if err := o.acquireLease(ctx, sagaItem, owner); err != nil {
+				return err
+			}
+			o.logger.Debug("サーガ[%s]の補償処理実行: %s", sagaItem.SagaId, step.Name)
+			if err := step.Compensation(ctx, sagaItem.Data); err != nil {
+				// 補償中のまま終了し、Resume、Recoverによる再実行で続きから再開する
+				o.logger.ErrorWithError(err, message.E_FW_9006, sagaItem.SagaName, sagaItem.SagaId, step.Name)
+				return errors.Wrapf(err, "サーガ[%s]の補償処理[%s]に失敗", sagaItem.SagaId, step.Name)
+			}
+		}
+		if err := o.updateState(ctx, sagaItem, mytables.STATUS_COMPENSATING, sagaItem.CurrentStep-1, "", false); err != nil {
+			return err
+		}
+	}
+	// 全ての補償処理が完了
+	if err := o.updateState(ctx, sagaItem, mytables.STATUS_COMPENSATED, sagaItem.CurrentStep, "", true); err != nil {
+		return err
+	}
+	o.logger.Info(message.I_FW_0014, sagaItem.SagaName, sagaItem.SagaId)
+	return actionErr
+}
+
+// acquireLease は、ステップの処理、補償処理の実行前に、サーガ管理テーブルのアイテムの実行者と更新時間を更新し、実行権（リース）を取得します。
+// 他の実行者がリースの有効期間内で実行中の場合は、ErrSagaConflictを返却します。
+func (o *defaultSagaOrchestrator) acquireLease(ctx context.Context, sagaItem *model.SagaItem, owner string) error {
+	threshold := o.config.GetInt(SAGA_RECOVERY_THRESHOLD_SECONDS, DEFAULT_SAGA_RECOVERY_THRESHOLD_SECONDS)
+	now := o.dateManager.GetSystemDate()
+	next := *sagaItem
+	next.Owner = owner
+	next.LeaseExpireTime = now.Add(time.Duration(threshold) * time.Second).Unix()
+	next.UpdateTime = now.Unix()
+	if err := o.repository.UpdateLeaseWithContext(ctx, &next, now.Unix()); err != nil {
+		if errors.Is(err, mydynamodb.ErrUpdateWithCondtion) {
+			return errors.Wrapf(ErrSagaConflict, "サーガID[%s]", sagaItem.SagaId)
+		}
+		return err
+	}
+	*sagaItem = next
+	return nil
+}
+
+// updateState は、サーガ管理テーブルのアイテムの実行状況を更新します。
+// 実行権（リース）を取得済の場合は、リースの有効期限切れで他の実行者に実行権が移っていないことも条件とします。
+// 終了時の更新は、非同期実行の場合にキューメッセージ管理テーブルのステータスを同時に完了とするため、トランザクションで行います。
+func (o *defaultSagaOrchestrator) updateState(ctx context.Context, sagaItem *model.SagaItem,
+	status string, currentStep int, errorMessage string, finished bool) error {
+	prevStatus, prevStep := sagaItem.Status, sagaItem.CurrentStep
+	next := *sagaItem
+	next.Status = status
+	next.CurrentStep = currentStep
+	if errorMessage != "" {
+		next.ErrorMessage = errorMessage
+	}
+	now := o.dateManager.GetSystemDate()
+	next.UpdateTime = now.Unix()
+	var err error
+	if finished {
+		if ttl := o.config.GetInt(SAGA_TTL_SECONDS, 0); ttl > 0 {
+			next.DeleteTime = now.Add(time.Duration(ttl) * time.Second).Unix()
+		}
+		_, err = o.transactionManager.ExecuteTransactionWithContext(ctx, func(ctxWithTx context.Context) (any, error) {
+			return nil, o.repository.UpdateStateWithTxInContext(ctxWithTx, &next, prevStatus, prevStep)
+		}, transaction.WithPropagation(transaction.PROPAGATION_REQUIRES_NEW))
+	} else {
+		err = o.repository.UpdateStateWithContext(ctx, &next, prevStatus, prevStep)
+	}
+	if err != nil {
+		if errors.Is(err, mydynamodb.ErrUpdateWithCondtion) || transaction.IsTransactionConditionalCheckFailed(err) {
+			return errors.Wrapf(ErrSagaConflict, "サーガID[%s]", sagaItem.SagaId)
+		}
+		return err
+	}
+	*sagaItem = next
+	return nil
+}
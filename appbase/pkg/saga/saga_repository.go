@@ -0,0 +1,249 @@
+/*
+saga パッケージは、複数のサービスにまたがる処理を、補償処理付きのステップの連なり（サーガ）として実行する機能を提供します。
+*/
+package saga
+
+import (
+	"context"
+
+	"example.com/appbase/pkg/config"
+	mydynamodb "example.com/appbase/pkg/dynamodb"
+	"example.com/appbase/pkg/dynamodb/input"
+	"example.com/appbase/pkg/dynamodb/tables"
+	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/saga/model"
+	mytables "example.com/appbase/pkg/saga/tables"
+	"example.com/appbase/pkg/transaction"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// サーガ管理テーブル名のプロパティ名
+	SAGA_TABLE_NAME = "SAGA_TABLE_NAME"
+	// 中断したサーガの検索で、1回のクエリで取得する件数
+	SAGA_FIND_BY_STATUS_PAGE_SIZE = 100
+)
+
+// SagaRepository は、サーガ管理テーブルのリポジトリインタフェースです。
+type SagaRepository interface {
+	// CreateOneWithContext は、サーガ管理テーブルにアイテムを登録します。
+	CreateOneWithContext(ctx context.Context, sagaItem *model.SagaItem) error
+	// CreateOneWithTxInContext は、トランザクションでサーガ管理テーブルにアイテムを登録します。
+	CreateOneWithTxInContext(ctx context.Context, sagaItem *model.SagaItem) error
+	// FindOneWithContext は、サーガ管理テーブルからアイテムを取得します。
+	FindOneWithContext(ctx context.Context, sagaId string) (*model.SagaItem, error)
+	// FindSomeByStatusWithContext は、指定したステータスで、更新時間が指定した時間より前のアイテムを全て取得します。
+	// GSIのクエリは、LastEvaluatedKeyがなくなるまでページングして取得します。
+	FindSomeByStatusWithContext(ctx context.Context, status string, updatedBefore int64) ([]model.SagaItem, error)
+	// UpdateStateWithContext は、サーガ管理テーブルのアイテムの実行状況を更新します。
+	// 他の実行と競合しないよう、更新前のステータス、ステップが一致する場合のみ更新します。
+	UpdateStateWithContext(ctx context.Context, sagaItem *model.SagaItem, prevStatus string, prevStep int) error
+	// UpdateStateWithTxInContext は、トランザクションでサーガ管理テーブルのアイテムの実行状況を更新します。
+	// 他の実行と競合しないよう、更新前のステータス、ステップが一致する場合のみ更新します。
+	UpdateStateWithTxInContext(ctx context.Context, sagaItem *model.SagaItem, prevStatus string, prevStep int) error
+	// UpdateLeaseWithContext は、サーガ管理テーブルのアイテムの実行者（Owner）、リースの有効期限、更新時間を更新し、実行権を取得します。
+	// 実行状況が変わっておらず、他の実行者がいないか、同じ実行者か、他の実行者のリースの有効期限（now）が切れている場合のみ更新します。
+	UpdateLeaseWithContext(ctx context.Context, sagaItem *model.SagaItem, now int64) error
+}
+
+// NewSagaRepository は、SagaRepositoryを作成します。
+func NewSagaRepository(config config.Config,
+	logger logging.Logger,
+	dynamodbTemplate transaction.TransactionalDynamoDBTemplate) SagaRepository {
+	// テーブル名取得
+	tableName := tables.DynamoDBTableName(config.Get(SAGA_TABLE_NAME, "saga"))
+	// テーブル定義の設定
+	mytables.SagaTable{}.InitPK(tableName)
+	// プライマリキーの設定
+	primaryKey := tables.GetPrimaryKey(tableName)
+	return &defaultSagaRepository{
+		logger:           logger,
+		dynamodbTemplate: dynamodbTemplate,
+		tableName:        tableName,
+		primaryKey:       primaryKey,
+	}
+}
+
+// defaultSagaRepository は、SagaRepositoryを実装する構造体です。
+type defaultSagaRepository struct {
+	logger           logging.Logger
+	dynamodbTemplate transaction.TransactionalDynamoDBTemplate
+	tableName        tables.DynamoDBTableName
+	primaryKey       *tables.PKKeyPair
+}
+
+// CreateOneWithContext implements SagaRepository.
+func (r *defaultSagaRepository) CreateOneWithContext(ctx context.Context, sagaItem *model.SagaItem) error {
+	err := r.dynamodbTemplate.CreateOneWithContext(ctx, r.tableName, sagaItem)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// CreateOneWithTxInContext implements SagaRepository.
+func (r *defaultSagaRepository) CreateOneWithTxInContext(ctx context.Context, sagaItem *model.SagaItem) error {
+	err := r.dynamodbTemplate.CreateOneWithTransactionInContext(ctx, r.tableName, sagaItem)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// FindOneWithContext implements SagaRepository.
+func (r *defaultSagaRepository) FindOneWithContext(ctx context.Context, sagaId string) (*model.SagaItem, error) {
+	input := input.PKOnlyQueryInput{
+		PrimaryKey: input.PrimaryKey{
+			PartitionKey: input.Attribute{
+				Name:  r.primaryKey.PartitionKey,
+				Value: sagaId,
+			},
+		},
+		// 実行状況の判定に利用するため、強い整合性のある読み込みとする
+		ConsitentRead: true,
+	}
+	var sagaItem model.SagaItem
+	err := r.dynamodbTemplate.FindOneByTableKeyWithContext(ctx, r.tableName, input, &sagaItem)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &sagaItem, nil
+}
+
+// FindSomeByStatusWithContext implements SagaRepository.
+func (r *defaultSagaRepository) FindSomeByStatusWithContext(ctx context.Context, status string, updatedBefore int64) ([]model.SagaItem, error) {
+	input := input.GsiQueryInput{
+		GSIName: mytables.STATUS_INDEX_NAME,
+		IndexKey: input.PrimaryKey{
+			PartitionKey: input.Attribute{
+				Name:  mytables.STATUS,
+				Value: status,
+			},
+			SortKey: &input.Attribute{
+				Name:  mytables.UPDATE_TIME,
+				Value: updatedBefore,
+			},
+			SortKeyOp: input.SORTKEY_LESS_THAN,
+		},
+	}
+	// 1ページ分のクエリの件数を制限し、FindSomeByGSIKeyでLastEvaluatedKeyがなくなるまでページングして全件取得する
+	input.LimitPerQuery = aws.Int32(SAGA_FIND_BY_STATUS_PAGE_SIZE)
+	var sagaItems []model.SagaItem
+	err := r.dynamodbTemplate.FindSomeByGSIKeyWithContext(ctx, r.tableName, input, &sagaItems)
+	if err != nil {
+		if errors.Is(err, mydynamodb.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	return sagaItems, nil
+}
+
+// UpdateStateWithContext implements SagaRepository.
+func (r *defaultSagaRepository) UpdateStateWithContext(ctx context.Context, sagaItem *model.SagaItem, prevStatus string, prevStep int) error {
+	err := r.dynamodbTemplate.UpdateOneWithContext(ctx, r.tableName, r.createUpdateStateInput(sagaItem, prevStatus, prevStep))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// UpdateStateWithTxInContext implements SagaRepository.
+func (r *defaultSagaRepository) UpdateStateWithTxInContext(ctx context.Context, sagaItem *model.SagaItem, prevStatus string, prevStep int) error {
+	err := r.dynamodbTemplate.UpdateOneWithTransactionInContext(ctx, r.tableName, r.createUpdateStateInput(sagaItem, prevStatus, prevStep))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// UpdateLeaseWithContext implements SagaRepository.
+func (r *defaultSagaRepository) UpdateLeaseWithContext(ctx context.Context, sagaItem *model.SagaItem, now int64) error {
+	input := input.UpdateInput{
+		PrimaryKey: r.newPrimaryKey(sagaItem.SagaId),
+		UpdateAttributes: []*input.Attribute{
+			{Name: mytables.OWNER, Value: sagaItem.Owner},
+			{Name: mytables.LEASE_EXPIRE_TIME, Value: sagaItem.LeaseExpireTime},
+			{Name: mytables.UPDATE_TIME, Value: sagaItem.UpdateTime},
+		},
+		// Where句は先頭から順に連結されるため、「((リース期限切れ OR 同じ実行者) AND ステータス) AND ステップ」の条件となる
+		// なお、登録時はリースの有効期限を0とするため、実行者がいない場合はリース期限切れとして扱われる
+		WhereClauses: []*input.WhereClause{
+			{
+				Attribute: input.Attribute{Name: mytables.LEASE_EXPIRE_TIME, Value: now},
+				WhereOp:   input.WHERE_LESS_THAN,
+			},
+			{
+				Attribute: input.Attribute{Name: mytables.OWNER, Value: sagaItem.Owner},
+				WhereOp:   input.WHERE_EQUAL,
+				AppendOp:  input.APPEND_OR,
+			},
+			{
+				Attribute: input.Attribute{Name: mytables.STATUS, Value: sagaItem.Status},
+				WhereOp:   input.WHERE_EQUAL,
+				AppendOp:  input.APPEND_AND,
+			},
+			{
+				Attribute: input.Attribute{Name: mytables.CURRENT_STEP, Value: sagaItem.CurrentStep},
+				WhereOp:   input.WHERE_EQUAL,
+				AppendOp:  input.APPEND_AND,
+			},
+		},
+	}
+	err := r.dynamodbTemplate.UpdateOneWithContext(ctx, r.tableName, input)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// createUpdateStateInput は、実行状況を更新するための入力を作成します。
+func (r *defaultSagaRepository) createUpdateStateInput(sagaItem *model.SagaItem, prevStatus string, prevStep int) input.UpdateInput {
+	updateAttributes := []*input.Attribute{
+		{Name: mytables.STATUS, Value: sagaItem.Status},
+		{Name: mytables.CURRENT_STEP, Value: sagaItem.CurrentStep},
+		{Name: mytables.DATA, Value: sagaItem.Data},
+		{Name: mytables.UPDATE_TIME, Value: sagaItem.UpdateTime},
+	}
+	if sagaItem.ErrorMessage != "" {
+		updateAttributes = append(updateAttributes, &input.Attribute{Name: mytables.ERROR_MESSAGE, Value: sagaItem.ErrorMessage})
+	}
+	if sagaItem.DeleteTime > 0 {
+		updateAttributes = append(updateAttributes, &input.Attribute{Name: mytables.DELETE_TIME, Value: sagaItem.DeleteTime})
+	}
+	whereClauses := []*input.WhereClause{
+		{
+			Attribute: input.Attribute{Name: mytables.STATUS, Value: prevStatus},
+			WhereOp:   input.WHERE_EQUAL,
+		},
+		{
+			Attribute: input.Attribute{Name: mytables.CURRENT_STEP, Value: prevStep},
+			WhereOp:   input.WHERE_EQUAL,
+			AppendOp:  input.APPEND_AND,
+		},
+	}
+	if sagaItem.Owner != "" {
+		// リースの有効期限切れで、他の実行者に実行権が移った場合は更新しない
+		whereClauses = append(whereClauses, &input.WhereClause{
+			Attribute: input.Attribute{Name: mytables.OWNER, Value: sagaItem.Owner},
+			WhereOp:   input.WHERE_EQUAL,
+			AppendOp:  input.APPEND_AND,
+		})
+	}
+	return input.UpdateInput{
+		PrimaryKey:       r.newPrimaryKey(sagaItem.SagaId),
+		UpdateAttributes: updateAttributes,
+		WhereClauses:     whereClauses,
+	}
+}
+
+// newPrimaryKey は、サーガ管理テーブルのプライマリキーを作成します。
+func (r *defaultSagaRepository) newPrimaryKey(sagaId string) input.PrimaryKey {
+	return input.PrimaryKey{
+		PartitionKey: input.Attribute{
+			Name:  r.primaryKey.PartitionKey,
+			Value: sagaId,
+		},
+	}
+}
@@ -0,0 +1,54 @@
+/*
+tables パッケージは、サーガ管理テーブルに関連するテーブル情報を提供します。
+*/
+package tables
+
+import (
+	"example.com/appbase/pkg/dynamodb/gsi"
+	"example.com/appbase/pkg/dynamodb/tables"
+)
+
+// サーガ管理テーブルの属性名
+const (
+	SAGA_ID           = "saga_id"
+	SAGA_NAME         = "saga_name"
+	STATUS            = "status"
+	CURRENT_STEP      = "current_step"
+	DATA              = "data"
+	ERROR_MESSAGE     = "error_message"
+	UPDATE_TIME       = "update_time"
+	DELETE_TIME       = "delete_time"
+	OWNER             = "owner"
+	LEASE_EXPIRE_TIME = "lease_expire_time"
+)
+
+// サーガ管理テーブルのステータス
+const (
+	// STATUS_RUNNING は、ステップの処理を順に実行中であることを表します。
+	STATUS_RUNNING = "RUNNING"
+	// STATUS_COMPENSATING は、ステップの処理の失敗により、補償処理を逆順に実行中であることを表します。
+	STATUS_COMPENSATING = "COMPENSATING"
+	// STATUS_COMPLETED は、全てのステップの処理が完了したことを表します。
+	STATUS_COMPLETED = "COMPLETED"
+	// STATUS_COMPENSATED は、全ての補償処理が完了したことを表します。
+	STATUS_COMPENSATED = "COMPENSATED"
+)
+
+// STATUS_INDEX_NAME は、中断したサーガを検索するための、ステータスと更新時間をキーとするGSI名です。
+const STATUS_INDEX_NAME = gsi.DynamoDBGSIName("status-update_time-index")
+
+// SagaTable は、サーガ管理テーブルのテーブル情報を提供します。
+type SagaTable struct {
+}
+
+// InitPK は、サーガ管理テーブルのプライマリキー、GSIを初期化します。
+func (SagaTable) InitPK(tableName tables.DynamoDBTableName) {
+	pkKeyPair := &tables.PKKeyPair{
+		PartitionKey: SAGA_ID,
+	}
+	tables.SetPrimaryKey(tableName, pkKeyPair)
+	gsi.AddGSIKeyPair(tableName, STATUS_INDEX_NAME, &gsi.GSIKeyPair{
+		PartitionKey: STATUS,
+		SortKey:      UPDATE_TIME,
+	})
+}
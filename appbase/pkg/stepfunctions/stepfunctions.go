@@ -0,0 +1,206 @@
+/*
+stepfunctions パッケージは、StepFunctionsのタスク連携に関する機能を提供するパッケージです。
+*/
+package stepfunctions
+
+import (
+	"context"
+	"encoding/json"
+
+	"example.com/appbase/pkg/apcontext"
+	"example.com/appbase/pkg/awssdk"
+	myConfig "example.com/appbase/pkg/config"
+	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/message"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sfn"
+	"github.com/cockroachdb/errors"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
+)
+
+const (
+	SFN_LOCAL_ENDPOINT_NAME = "SFN_LOCAL_ENDPOINT"
+	// TASK_TOKEN_NAME は、ステートマシン定義のPayloadでタスクトークンを渡す際の項目名です。
+	// 例）"Payload": {"TaskToken.$": "$$.Task.Token", ...}
+	TASK_TOKEN_NAME = "TaskToken"
+)
+
+// TaskTokenHolder は、StepFunctionsのタスクトークンを保持するイベントを表すインタフェースです。
+type TaskTokenHolder interface {
+	// GetTaskToken は、タスクトークンを返却します。
+	GetTaskToken() string
+}
+
+// TaskTokenEvent は、.waitForTaskTokenで連携されるイベントに埋め込んで利用する、タスクトークンを保持する構造体です。
+type TaskTokenEvent struct {
+	TaskToken string `json:"TaskToken"`
+}
+
+// GetTaskToken implements TaskTokenHolder.
+func (e TaskTokenEvent) GetTaskToken() string {
+	return e.TaskToken
+}
+
+// GetTaskToken は、イベントからタスクトークンを取得します。OKカンマイディオムにより、取得できなかった場合にfalseを返します。
+// イベントがTaskTokenHolderを実装している場合、またはmap[string]anyでTaskTokenの項目を持つ場合に取得できます。
+func GetTaskToken(event any) (string, bool) {
+	switch v := event.(type) {
+	case TaskTokenHolder:
+		token := v.GetTaskToken()
+		return token, token != ""
+	case map[string]any:
+		token, ok := v[TASK_TOKEN_NAME].(string)
+		return token, ok && token != ""
+	default:
+		return "", false
+	}
+}
+
+// StepFunctionsAccessor は、AWS SDKを使ったStepFunctionsのタスクトークンによるコールバックの実装をラップしカプセル化するインタフェースです。
+// .waitForTaskTokenで一時停止したワークフローを、LambdaやSQSトリガの非同期処理から再開させる場合に利用します。
+type StepFunctionsAccessor interface {
+	// SendTaskSuccess は、タスクトークンに対応するタスクの成功を通知します。outputは、json文字列に変換して送信します。
+	SendTaskSuccess(taskToken string, output any, optFns ...func(*sfn.Options)) error
+	// SendTaskSuccessWithContext は、goroutine向けに渡されたContextを利用して、タスクトークンに対応するタスクの成功を通知します。
+	SendTaskSuccessWithContext(ctx context.Context, taskToken string, output any, optFns ...func(*sfn.Options)) error
+	// SendTaskFailure は、タスクトークンに対応するタスクの失敗を、エラー名errorNameと原因causeを指定して通知します。
+	SendTaskFailure(taskToken string, errorName string, cause string, optFns ...func(*sfn.Options)) error
+	// SendTaskFailureWithContext は、goroutine向けに渡されたContextを利用して、タスクトークンに対応するタスクの失敗を通知します。
+	SendTaskFailureWithContext(ctx context.Context, taskToken string, errorName string, cause string, optFns ...func(*sfn.Options)) error
+	// SendTaskFailureWithError は、タスクトークンに対応するタスクの失敗を、エラーの種類に応じたエラー名で通知します。
+	SendTaskFailureWithError(taskToken string, err error, optFns ...func(*sfn.Options)) error
+	// SendTaskFailureWithErrorAndContext は、goroutine向けに渡されたContextを利用して、
+	// タスクトークンに対応するタスクの失敗を、エラーの種類に応じたエラー名で通知します。
+	SendTaskFailureWithErrorAndContext(ctx context.Context, taskToken string, err error, optFns ...func(*sfn.Options)) error
+	// SendTaskHeartbeat は、タスクトークンに対応するタスクのハートビートを送信します。
+	SendTaskHeartbeat(taskToken string, optFns ...func(*sfn.Options)) error
+	// SendTaskHeartbeatWithContext は、goroutine向けに渡されたContextを利用して、タスクトークンに対応するタスクのハートビートを送信します。
+	SendTaskHeartbeatWithContext(ctx context.Context, taskToken string, optFns ...func(*sfn.Options)) error
+}
+
+// NewStepFunctionsAccessor は、StepFunctionsAccessorを作成します。
+func NewStepFunctionsAccessor(logger logging.Logger, myCfg myConfig.Config, messageSource message.MessageSource) (StepFunctionsAccessor, error) {
+	// カスタムHTTPClientの作成
+	sdkHTTPClient := awssdk.NewHTTPClient(myCfg)
+	// ClientLogModeの取得
+	clientLogMode, found := awssdk.GetClientLogMode(myCfg)
+	var cfg aws.Config
+	var err error
+	if found {
+		cfg, err = config.LoadDefaultConfig(context.TODO(), config.WithHTTPClient(sdkHTTPClient), config.WithClientLogMode(clientLogMode))
+	} else {
+		cfg, err = config.LoadDefaultConfig(context.TODO(), config.WithHTTPClient(sdkHTTPClient))
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// X-Ray SDKからADOTの移行
+	// https://aws-otel.github.io/docs/getting-started/go-sdk/manual-instr#instrumenting-the-aws-sdk
+	otelaws.AppendMiddlewares(&cfg.APIOptions)
+	sfnClient := sfn.NewFromConfig(cfg, func(o *sfn.Options) {
+		// ローカル実行のためStepFunctions Local起動先が指定されている場合
+		sfnEndpoint := myCfg.Get(SFN_LOCAL_ENDPOINT_NAME, "")
+		if sfnEndpoint != "" {
+			o.BaseEndpoint = aws.String(sfnEndpoint)
+		}
+	})
+	return &defaultStepFunctionsAccessor{
+		logger:        logger,
+		config:        myCfg,
+		messageSource: messageSource,
+		sfnClient:     sfnClient,
+	}, nil
+}
+
+// defaultStepFunctionsAccessor は、StepFunctionsAccessorを実装する構造体です。
+type defaultStepFunctionsAccessor struct {
+	logger        logging.Logger
+	config        myConfig.Config
+	messageSource message.MessageSource
+	sfnClient     *sfn.Client
+}
+
+// SendTaskSuccess implements StepFunctionsAccessor.
+func (sa *defaultStepFunctionsAccessor) SendTaskSuccess(taskToken string, output any, optFns ...func(*sfn.Options)) error {
+	return sa.SendTaskSuccessWithContext(apcontext.Context, taskToken, output, optFns...)
+}
+
+// SendTaskSuccessWithContext implements StepFunctionsAccessor.
+func (sa *defaultStepFunctionsAccessor) SendTaskSuccessWithContext(ctx context.Context, taskToken string, output any, optFns ...func(*sfn.Options)) error {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	// outputが未指定の場合も、StepFunctionsの仕様上json文字列が必要なため空のオブジェクトとする
+	if output == nil {
+		output = map[string]any{}
+	}
+	bOutput, err := json.Marshal(output)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = sa.sfnClient.SendTaskSuccess(ctx, &sfn.SendTaskSuccessInput{
+		TaskToken: aws.String(taskToken),
+		Output:    aws.String(string(bOutput)),
+	}, optFns...)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	sa.logger.Info(message.I_FW_0009)
+	sa.logger.Debug("Output=%s", string(bOutput))
+	return nil
+}
+
+// SendTaskFailure implements StepFunctionsAccessor.
+func (sa *defaultStepFunctionsAccessor) SendTaskFailure(taskToken string, errorName string, cause string, optFns ...func(*sfn.Options)) error {
+	return sa.SendTaskFailureWithContext(apcontext.Context, taskToken, errorName, cause, optFns...)
+}
+
+// SendTaskFailureWithContext implements StepFunctionsAccessor.
+func (sa *defaultStepFunctionsAccessor) SendTaskFailureWithContext(ctx context.Context, taskToken string, errorName string, cause string, optFns ...func(*sfn.Options)) error {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	_, err := sa.sfnClient.SendTaskFailure(ctx, &sfn.SendTaskFailureInput{
+		TaskToken: aws.String(taskToken),
+		Error:     aws.String(errorName),
+		Cause:     aws.String(cause),
+	}, optFns...)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	sa.logger.Info(message.I_FW_0010, errorName)
+	sa.logger.Debug("Cause=%s", cause)
+	return nil
+}
+
+// SendTaskFailureWithError implements StepFunctionsAccessor.
+func (sa *defaultStepFunctionsAccessor) SendTaskFailureWithError(taskToken string, err error, optFns ...func(*sfn.Options)) error {
+	return sa.SendTaskFailureWithErrorAndContext(apcontext.Context, taskToken, err, optFns...)
+}
+
+// SendTaskFailureWithErrorAndContext implements StepFunctionsAccessor.
+func (sa *defaultStepFunctionsAccessor) SendTaskFailureWithErrorAndContext(ctx context.Context, taskToken string, err error, optFns ...func(*sfn.Options)) error {
+	taskError := NewTaskError(err, sa.messageSource)
+	return sa.SendTaskFailureWithContext(ctx, taskToken, taskError.Name, taskError.Cause(), optFns...)
+}
+
+// SendTaskHeartbeat implements StepFunctionsAccessor.
+func (sa *defaultStepFunctionsAccessor) SendTaskHeartbeat(taskToken string, optFns ...func(*sfn.Options)) error {
+	return sa.SendTaskHeartbeatWithContext(apcontext.Context, taskToken, optFns...)
+}
+
+// SendTaskHeartbeatWithContext implements StepFunctionsAccessor.
+func (sa *defaultStepFunctionsAccessor) SendTaskHeartbeatWithContext(ctx context.Context, taskToken string, optFns ...func(*sfn.Options)) error {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	_, err := sa.sfnClient.SendTaskHeartbeat(ctx, &sfn.SendTaskHeartbeatInput{
+		TaskToken: aws.String(taskToken),
+	}, optFns...)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	sa.logger.Debug("タスクのハートビート送信")
+	return nil
+}
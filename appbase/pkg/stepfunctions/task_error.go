@@ -0,0 +1,97 @@
+package stepfunctions
+
+import (
+	"encoding/json"
+
+	myerrors "example.com/appbase/pkg/errors"
+	"example.com/appbase/pkg/message"
+)
+
+// StepFunctionsのステートマシン定義のRetry/CatchのErrorEqualsで指定するエラー名
+const (
+	ERROR_NAME_VALIDATION = "ValidationError"
+	ERROR_NAME_BUSINESS   = "BusinessError"
+	ERROR_NAME_SYSTEM     = "SystemError"
+	ERROR_NAME_OTHER      = "OtherError"
+	ERROR_NAME_UNEXPECTED = "UnexpectedError"
+)
+
+// TaskError は、StepFunctionsのRetry/Catchで判定できるよう、エラーの種類に応じたエラー名とエラー内容を保持する構造体です。
+type TaskError struct {
+	// Name は、StepFunctionsに通知するエラー名です。
+	Name string
+	// Code は、エラーコード（メッセージID）です。
+	Code string
+	// Message は、エラーコードに対応するメッセージです。
+	Message string
+	cause   error
+}
+
+// NewTaskError は、エラーerrの種類に応じたエラー名を設定し、TaskErrorを作成します。
+// エラーコードに対応するメッセージは、messageSourceから取得します。
+func NewTaskError(err error, messageSource message.MessageSource) *TaskError {
+	var (
+		validationError *myerrors.ValidationError
+		businessError   *myerrors.BusinessError
+		businessErrors  *myerrors.BusinessErrors
+		systemError     *myerrors.SystemError
+		otherError      *myerrors.OtherError
+	)
+	var name string
+	var codableError myerrors.CodableError
+	if myerrors.As(err, &validationError) {
+		name = ERROR_NAME_VALIDATION
+		codableError = validationError
+	} else if myerrors.As(err, &businessErrors) {
+		name = ERROR_NAME_BUSINESS
+		// 複数の業務エラーが保持されている場合は、先頭の業務エラーの内容とする
+		if bizErrs := businessErrors.BusinessErrors(); len(bizErrs) > 0 {
+			codableError = bizErrs[0]
+		}
+	} else if myerrors.As(err, &businessError) {
+		name = ERROR_NAME_BUSINESS
+		codableError = businessError
+	} else if myerrors.As(err, &otherError) {
+		name = ERROR_NAME_OTHER
+		codableError = otherError
+	} else if myerrors.As(err, &systemError) {
+		name = ERROR_NAME_SYSTEM
+		codableError = systemError
+	} else {
+		name = ERROR_NAME_UNEXPECTED
+	}
+	taskError := &TaskError{Name: name, cause: err}
+	if codableError != nil {
+		taskError.Code = codableError.ErrorCode()
+		if messageSource != nil {
+			taskError.Message = messageSource.GetMessage(codableError.ErrorCode(), codableError.Args()...)
+		}
+	} else if messageSource != nil {
+		taskError.Code = message.E_FW_9999
+		taskError.Message = messageSource.GetMessage(message.E_FW_9999)
+	}
+	return taskError
+}
+
+// Cause は、StepFunctionsに通知するエラーの原因を、json文字列で返却します。
+// ステートマシン定義では、States.StringToJson関数でjsonとして扱うことができます。
+func (e *TaskError) Cause() string {
+	bCause, err := json.Marshal(map[string]string{
+		"code":    e.Code,
+		"message": e.Message,
+	})
+	if err != nil {
+		return e.Message
+	}
+	return string(bCause)
+}
+
+// Error は、エラーを返却します。errorインタフェースを実装します。
+func (e *TaskError) Error() string {
+	return e.Name + ": " + e.Cause()
+}
+
+// Unwrap は、原因となるエラーにUnwrapします。
+func (e *TaskError) Unwrap() error {
+	return e.cause
+}
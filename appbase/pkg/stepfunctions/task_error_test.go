@@ -0,0 +1,45 @@
+package stepfunctions
+
+import (
+	"testing"
+
+	myerrors "example.com/appbase/pkg/errors"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTaskError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantName string
+		wantCode string
+	}{
+		{name: "業務エラー", err: myerrors.NewBusinessError("w.ex.8001"), wantName: ERROR_NAME_BUSINESS, wantCode: "w.ex.8001"},
+		{name: "複数の業務エラー", err: myerrors.NewBusinessErrors(myerrors.NewBusinessError("w.ex.8002"), myerrors.NewBusinessError("w.ex.8003")), wantName: ERROR_NAME_BUSINESS, wantCode: "w.ex.8002"},
+		{name: "システムエラー", err: myerrors.NewSystemError(errors.New("sys"), "e.ex.9001"), wantName: ERROR_NAME_SYSTEM, wantCode: "e.ex.9001"},
+		{name: "その他のエラー", err: myerrors.NewOtherError(errors.New("other"), "e.ex.9002"), wantName: ERROR_NAME_OTHER, wantCode: "e.ex.9002"},
+		{name: "予期せぬエラー", err: errors.New("unexpected"), wantName: ERROR_NAME_UNEXPECTED, wantCode: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taskError := NewTaskError(errors.WithStack(tt.err), nil)
+			assert.Equal(t, tt.wantName, taskError.Name)
+			assert.Equal(t, tt.wantCode, taskError.Code)
+			assert.ErrorIs(t, taskError, tt.err)
+		})
+	}
+}
+
+func TestGetTaskToken(t *testing.T) {
+	token, ok := GetTaskToken(map[string]any{TASK_TOKEN_NAME: "token"})
+	assert.True(t, ok)
+	assert.Equal(t, "token", token)
+
+	token, ok = GetTaskToken(TaskTokenEvent{TaskToken: "token2"})
+	assert.True(t, ok)
+	assert.Equal(t, "token2", token)
+
+	_, ok = GetTaskToken(map[string]any{"hoge": "fuga"})
+	assert.False(t, ok)
+}
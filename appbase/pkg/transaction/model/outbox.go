@@ -0,0 +1,51 @@
+package model
+
+const (
+	// OUTBOX_DESTINATION_TYPE_SQS は、送信先がSQSのキューであることを表します。
+	OUTBOX_DESTINATION_TYPE_SQS = "sqs"
+	// OUTBOX_DESTINATION_TYPE_SNS は、送信先がSNSのトピックであることを表します。
+	OUTBOX_DESTINATION_TYPE_SNS = "sns"
+	// OUTBOX_DESTINATION_TYPE_EVENTBRIDGE は、送信先がEventBridgeのイベントバスであることを表します。
+	OUTBOX_DESTINATION_TYPE_EVENTBRIDGE = "eventbridge"
+	// OUTBOX_STATUS_PENDING は、アウトボックスのアイテムが未送信であることを表します。
+	OUTBOX_STATUS_PENDING = "pending"
+	// OUTBOX_STATUS_SENDING は、アウトボックスのアイテムをリレーが送信中であることを表します。
+	OUTBOX_STATUS_SENDING = "sending"
+	// OUTBOX_STATUS_DELIVERED は、アウトボックスのアイテムが送信済であることを表します。
+	OUTBOX_STATUS_DELIVERED = "delivered"
+)
+
+// OutboxItem は、アウトボックステーブルのアイテムを表す構造体です。
+type OutboxItem struct {
+	// OutboxId は、アウトボックスのアイテムのIDです。
+	OutboxId string `dynamodbav:"outbox_id"`
+	// DestinationType は、送信先の種類（sqs、sns、eventbridge）です。
+	DestinationType string `dynamodbav:"destination_type"`
+	// Destination は、送信先のキュー名、トピックのARN、またはイベントバス名です。
+	Destination string `dynamodbav:"destination"`
+	// Body は、メッセージ本文です。EventBridgeの場合は、イベントのDetailです。
+	Body string `dynamodbav:"body"`
+	// MessageGroupId は、FIFOキュー、FIFOトピックの場合のメッセージグループIDです。
+	MessageGroupId string `dynamodbav:"message_group_id,omitempty"`
+	// MessageDeduplicationId は、FIFOキュー、FIFOトピックの場合のメッセージ重複排除IDです。
+	MessageDeduplicationId string `dynamodbav:"message_deduplication_id,omitempty"`
+	// DelaySeconds は、SQSの場合の配信遅延秒数です。
+	DelaySeconds int32 `dynamodbav:"delay_seconds,omitempty"`
+	// Attributes は、文字列型のメッセージ属性です。
+	Attributes map[string]string `dynamodbav:"attributes,omitempty"`
+	// Source は、EventBridgeの場合のイベントのSourceです。
+	Source string `dynamodbav:"source,omitempty"`
+	// DetailType は、EventBridgeの場合のイベントのDetailTypeです。
+	DetailType string `dynamodbav:"detail_type,omitempty"`
+	// TraceCarrier は、登録時のトレースコンテキストです。リレーでの送信時に送信先へ伝搬します。
+	TraceCarrier map[string]string `dynamodbav:"trace_carrier,omitempty"`
+	// Status は、送信状況のステータスです。
+	Status string `dynamodbav:"status"`
+	// SendingExpireTime は、送信中のステータスの有効期限（UNIX時間（ミリ秒））です。
+	// 期限を過ぎた送信中のアイテムは、リレーの異常終了等で送信が完了しなかったものとして、他のリレーが再送できます。
+	SendingExpireTime int64 `dynamodbav:"sending_expire_time,omitempty"`
+	// CreatedAt は、アイテムの登録日時（UNIX時間（ミリ秒））です。
+	CreatedAt int64 `dynamodbav:"created_at"`
+	// DeleteTime は、TTLによるアイテムの削除時間（UNIX時間（秒））です。
+	DeleteTime int `dynamodbav:"delete_time"`
+}
@@ -0,0 +1,108 @@
+package transaction
+
+import (
+	"context"
+	"time"
+
+	"example.com/appbase/pkg/apcontext"
+	"example.com/appbase/pkg/config"
+	"example.com/appbase/pkg/id"
+	"example.com/appbase/pkg/otel"
+	"example.com/appbase/pkg/transaction/model"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	OUTBOX_TABLE_TTL_HOUR = "OUTBOX_TABLE_TTL_HOUR"
+)
+
+// OutboxRegisterer は、アウトボックスのアイテムをトランザクションに登録するためのインターフェースです。
+type OutboxRegisterer interface {
+	// RegisterOutbox は、アウトボックスのアイテムを未送信のステータスでトランザクションに登録します。
+	RegisterOutbox(outboxItem *model.OutboxItem) error
+	// RegisterOutboxWithContext は、goroutine向けに渡されたContextを利用して、
+	// アウトボックスのアイテムを未送信のステータスでトランザクションに登録します。
+	RegisterOutboxWithContext(ctx context.Context, outboxItem *model.OutboxItem) error
+	// UpdateDelivered は、アウトボックスのアイテムのステータスを送信済に更新するトランザクションを追加します。
+	UpdateDelivered(outboxItem *model.OutboxItem) error
+	// UpdateDeliveredWithContext は、goroutine向けに渡されたContextを利用して、
+	// アウトボックスのアイテムのステータスを送信済に更新するトランザクションを追加します。
+	UpdateDeliveredWithContext(ctx context.Context, outboxItem *model.OutboxItem) error
+}
+
+// NewOutboxRegisterer は、OutboxRegistererを作成します。
+func NewOutboxRegisterer(config config.Config, id id.IDGenerator, outboxItemRepository OutboxItemRepository) OutboxRegisterer {
+	// TTL（時間）の取得
+	ttl := config.GetInt(OUTBOX_TABLE_TTL_HOUR, 24*4)
+	return &defaultOutboxRegisterer{
+		id:                   id,
+		outboxItemRepository: outboxItemRepository,
+		ttl:                  ttl,
+	}
+}
+
+// defaultOutboxRegisterer は、OutboxRegistererの実装です。
+type defaultOutboxRegisterer struct {
+	id                   id.IDGenerator
+	outboxItemRepository OutboxItemRepository
+	ttl                  int
+}
+
+// RegisterOutbox implements OutboxRegisterer.
+func (r *defaultOutboxRegisterer) RegisterOutbox(outboxItem *model.OutboxItem) error {
+	return r.RegisterOutboxWithContext(apcontext.Context, outboxItem)
+}
+
+// RegisterOutboxWithContext implements OutboxRegisterer.
+func (r *defaultOutboxRegisterer) RegisterOutboxWithContext(ctx context.Context, outboxItem *model.OutboxItem) error {
+	if outboxItem.OutboxId == "" {
+		outboxId, err := r.id.GenerateUUID()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		outboxItem.OutboxId = outboxId
+	}
+	if outboxItem.TraceCarrier == nil {
+		// リレーでの送信時に伝搬するため、登録時のトレースコンテキストを保存
+		outboxItem.TraceCarrier = otel.InjectTraceCarrier(ctx)
+	}
+	now := time.Now()
+	outboxItem.Status = model.OUTBOX_STATUS_PENDING
+	outboxItem.CreatedAt = now.UnixMilli()
+	outboxItem.DeleteTime = int(now.Add(time.Duration(r.ttl) * time.Hour).Unix())
+	return r.outboxItemRepository.CreateOneWithTxInContext(ctx, outboxItem)
+}
+
+// UpdateDelivered implements OutboxRegisterer.
+func (r *defaultOutboxRegisterer) UpdateDelivered(outboxItem *model.OutboxItem) error {
+	return r.UpdateDeliveredWithContext(apcontext.Context, outboxItem)
+}
+
+// UpdateDeliveredWithContext implements OutboxRegisterer.
+func (r *defaultOutboxRegisterer) UpdateDeliveredWithContext(ctx context.Context, outboxItem *model.OutboxItem) error {
+	return r.outboxItemRepository.UpdateDeliveredWithTxInContext(ctx, outboxItem)
+}
+
+// newOutboxItemFromMessage は、SQSのメッセージから、アウトボックスのアイテムを作成します。
+func newOutboxItemFromMessage(msg *Message) *model.OutboxItem {
+	outboxItem := &model.OutboxItem{
+		DestinationType:        model.OUTBOX_DESTINATION_TYPE_SQS,
+		Destination:            msg.QueueName,
+		Body:                   aws.ToString(msg.Input.MessageBody),
+		MessageGroupId:         aws.ToString(msg.Input.MessageGroupId),
+		MessageDeduplicationId: aws.ToString(msg.Input.MessageDeduplicationId),
+		DelaySeconds:           msg.Input.DelaySeconds,
+	}
+	// 文字列型のメッセージ属性のみ引き継ぐ
+	for k, v := range msg.Input.MessageAttributes {
+		if v.StringValue == nil {
+			continue
+		}
+		if outboxItem.Attributes == nil {
+			outboxItem.Attributes = make(map[string]string)
+		}
+		outboxItem.Attributes[k] = *v.StringValue
+	}
+	return outboxItem
+}
@@ -0,0 +1,305 @@
+package transaction
+
+import (
+	"context"
+	"time"
+
+	"example.com/appbase/pkg/apcontext"
+	"example.com/appbase/pkg/config"
+	"example.com/appbase/pkg/constant"
+	mydynamodb "example.com/appbase/pkg/dynamodb"
+	"example.com/appbase/pkg/dynamodb/input"
+	"example.com/appbase/pkg/dynamodb/tables"
+	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/transaction/model"
+	mytables "example.com/appbase/pkg/transaction/tables"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// アウトボックステーブル名のプロパティ名
+	OUTBOX_TABLE_NAME = "OUTBOX_TABLE_NAME"
+	// アウトボックステーブルの送信中のステータスの有効期限の属性名
+	OUTBOX_SENDING_EXPIRE_TIME = "sending_expire_time"
+	// ステータスによる検索時の、1回のクエリでの取得件数の上限値
+	OUTBOX_FIND_BY_STATUS_PAGE_SIZE = 100
+)
+
+// OutboxItemRepository は、アウトボックステーブルのリポジトリインタフェースです。
+type OutboxItemRepository interface {
+	// CreateOneWithTx は、トランザクションでアウトボックステーブルにアイテムを登録します。
+	CreateOneWithTx(outboxItem *model.OutboxItem) error
+	// CreateOneWithTxInContext は、goroutine向けに渡されたContextを利用して、トランザクションでアウトボックステーブルにアイテムを登録します。
+	CreateOneWithTxInContext(ctx context.Context, outboxItem *model.OutboxItem) error
+	// FindOne は、強い整合性読み込みで、アウトボックステーブルのアイテムを取得します。アイテムがない場合は、ErrRecordNotFoundを返却します。
+	FindOne(outboxId string) (*model.OutboxItem, error)
+	// FindOneWithContext は、goroutine向けに渡されたContextを利用して、強い整合性読み込みで、アウトボックステーブルのアイテムを取得します。
+	FindOneWithContext(ctx context.Context, outboxId string) (*model.OutboxItem, error)
+	// FindSomeByStatus は、指定したステータスで、登録日時が指定した時間（UNIX時間（ミリ秒））より前のアイテムを全て取得します。
+	FindSomeByStatus(status string, createdBefore int64) ([]model.OutboxItem, error)
+	// FindSomeByStatusWithContext は、goroutine向けに渡されたContextを利用して、
+	// 指定したステータスで、登録日時が指定した時間（UNIX時間（ミリ秒））より前のアイテムを全て取得します。
+	// GSIのクエリは、LastEvaluatedKeyがなくなるまでページングして取得します。
+	FindSomeByStatusWithContext(ctx context.Context, status string, createdBefore int64) ([]model.OutboxItem, error)
+	// UpdateSending は、未送信、または有効期限を過ぎた送信中のアイテムのステータスを、送信中に更新します。
+	// 送信前に更新することで、複数のリレーが同じアイテムを同時に送信しないようにします。
+	// 更新できない場合は、ErrUpdateWithCondtionを返却します。
+	UpdateSending(outboxItem *model.OutboxItem, expireTime time.Time) error
+	// UpdateSendingWithContext は、goroutine向けに渡されたContextを利用して、
+	// 未送信、または有効期限を過ぎた送信中のアイテムのステータスを、送信中に更新します。
+	UpdateSendingWithContext(ctx context.Context, outboxItem *model.OutboxItem, expireTime time.Time) error
+	// UpdatePending は、送信中のアイテムのステータスを、未送信に戻します。
+	// 送信に失敗した場合に、リトライで再送できるようにするために利用します。
+	UpdatePending(outboxItem *model.OutboxItem) error
+	// UpdatePendingWithContext は、goroutine向けに渡されたContextを利用して、送信中のアイテムのステータスを、未送信に戻します。
+	UpdatePendingWithContext(ctx context.Context, outboxItem *model.OutboxItem) error
+	// UpdateDeliveredWithTx は、トランザクションでアウトボックステーブルの送信中のアイテムのステータスを送信済に更新します。
+	UpdateDeliveredWithTx(outboxItem *model.OutboxItem) error
+	// UpdateDeliveredWithTxInContext は、goroutine向けに渡されたContextを利用して、
+	// トランザクションでアウトボックステーブルの送信中のアイテムのステータスを送信済に更新します。
+	UpdateDeliveredWithTxInContext(ctx context.Context, outboxItem *model.OutboxItem) error
+}
+
+// NewOutboxItemRepository は、OutboxItemRepositoryを作成します。
+func NewOutboxItemRepository(config config.Config,
+	logger logging.Logger,
+	dynamodbTemplate TransactionalDynamoDBTemplate) OutboxItemRepository {
+	// テーブル名取得
+	tableName := tables.DynamoDBTableName(config.Get(OUTBOX_TABLE_NAME, "outbox"))
+	// テーブル定義の設定
+	mytables.OutboxTable{}.InitPK(tableName)
+	// プライマリキーの設定
+	primaryKey := tables.GetPrimaryKey(tableName)
+	return &defaultOutboxItemRepository{
+		logger:           logger,
+		dynamodbTemplate: dynamodbTemplate,
+		tableName:        tableName,
+		primaryKey:       primaryKey,
+	}
+}
+
+// defaultOutboxItemRepository は、OutboxItemRepositoryを実装する構造体です。
+type defaultOutboxItemRepository struct {
+	logger           logging.Logger
+	dynamodbTemplate TransactionalDynamoDBTemplate
+	tableName        tables.DynamoDBTableName
+	primaryKey       *tables.PKKeyPair
+}
+
+// CreateOneWithTx implements OutboxItemRepository.
+func (r *defaultOutboxItemRepository) CreateOneWithTx(outboxItem *model.OutboxItem) error {
+	return r.CreateOneWithTxInContext(apcontext.Context, outboxItem)
+}
+
+// CreateOneWithTxInContext implements OutboxItemRepository.
+func (r *defaultOutboxItemRepository) CreateOneWithTxInContext(ctx context.Context, outboxItem *model.OutboxItem) error {
+	err := r.dynamodbTemplate.CreateOneWithTransactionInContext(ctx, r.tableName, outboxItem)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// FindOne implements OutboxItemRepository.
+func (r *defaultOutboxItemRepository) FindOne(outboxId string) (*model.OutboxItem, error) {
+	return r.FindOneWithContext(apcontext.Context, outboxId)
+}
+
+// FindOneWithContext implements OutboxItemRepository.
+func (r *defaultOutboxItemRepository) FindOneWithContext(ctx context.Context, outboxId string) (*model.OutboxItem, error) {
+	var outboxItem model.OutboxItem
+	err := r.dynamodbTemplate.FindOneByTableKeyWithContext(ctx, r.tableName, input.PKOnlyQueryInput{
+		PrimaryKey: r.newPrimaryKey(outboxId),
+		// 他のリレーによる直前の更新を確認するため、強い整合性読み込みとする
+		ConsitentRead: true,
+	}, &outboxItem)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &outboxItem, nil
+}
+
+// FindSomeByStatus implements OutboxItemRepository.
+func (r *defaultOutboxItemRepository) FindSomeByStatus(status string, createdBefore int64) ([]model.OutboxItem, error) {
+	return r.FindSomeByStatusWithContext(apcontext.Context, status, createdBefore)
+}
+
+// FindSomeByStatusWithContext implements OutboxItemRepository.
+func (r *defaultOutboxItemRepository) FindSomeByStatusWithContext(ctx context.Context, status string, createdBefore int64) ([]model.OutboxItem, error) {
+	input := input.GsiQueryInput{
+		GSIName: mytables.OUTBOX_STATUS_INDEX_NAME,
+		IndexKey: input.PrimaryKey{
+			PartitionKey: input.Attribute{
+				Name:  mytables.OUTBOX_STATUS,
+				Value: status,
+			},
+			SortKey: &input.Attribute{
+				Name:  mytables.OUTBOX_CREATED_AT,
+				Value: createdBefore,
+			},
+			SortKeyOp: input.SORTKEY_LESS_THAN,
+		},
+		// 1ページ分のクエリの件数を制限し、FindSomeByGSIKeyでLastEvaluatedKeyがなくなるまでページングして全件取得する
+		LimitPerQuery: aws.Int32(OUTBOX_FIND_BY_STATUS_PAGE_SIZE),
+	}
+	var outboxItems []model.OutboxItem
+	err := r.dynamodbTemplate.FindSomeByGSIKeyWithContext(ctx, r.tableName, input, &outboxItems)
+	if err != nil {
+		if errors.Is(err, mydynamodb.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	return outboxItems, nil
+}
+
+// UpdateSending implements OutboxItemRepository.
+func (r *defaultOutboxItemRepository) UpdateSending(outboxItem *model.OutboxItem, expireTime time.Time) error {
+	return r.UpdateSendingWithContext(apcontext.Context, outboxItem, expireTime)
+}
+
+// UpdateSendingWithContext implements OutboxItemRepository.
+func (r *defaultOutboxItemRepository) UpdateSendingWithContext(ctx context.Context, outboxItem *model.OutboxItem, expireTime time.Time) error {
+	input := input.UpdateInput{
+		PrimaryKey: r.newPrimaryKey(outboxItem.OutboxId),
+		UpdateAttributes: []*input.Attribute{
+			{
+				Name:  constant.QUEUE_MESSAGE_STATUS,
+				Value: model.OUTBOX_STATUS_SENDING,
+			},
+			{
+				Name:  OUTBOX_SENDING_EXPIRE_TIME,
+				Value: expireTime.UnixMilli(),
+			},
+		},
+		// 有効期限を過ぎた送信中のアイテム、または未送信のアイテムのみ更新
+		// Where句は先頭から順に連結されるため、「(送信中 AND 有効期限切れ) OR 未送信」の条件となる
+		WhereClauses: []*input.WhereClause{
+			{
+				Attribute: input.Attribute{
+					Name:  constant.QUEUE_MESSAGE_STATUS,
+					Value: model.OUTBOX_STATUS_SENDING,
+				},
+				WhereOp: input.WHERE_EQUAL,
+			},
+			{
+				Attribute: input.Attribute{
+					Name:  OUTBOX_SENDING_EXPIRE_TIME,
+					Value: time.Now().UnixMilli(),
+				},
+				WhereOp:  input.WHERE_LESS_THAN,
+				AppendOp: input.APPEND_AND,
+			},
+			{
+				Attribute: input.Attribute{
+					Name:  constant.QUEUE_MESSAGE_STATUS,
+					Value: model.OUTBOX_STATUS_PENDING,
+				},
+				WhereOp:  input.WHERE_EQUAL,
+				AppendOp: input.APPEND_OR,
+			},
+		},
+	}
+	if err := r.dynamodbTemplate.UpdateOneWithContext(ctx, r.tableName, input); err != nil {
+		return errors.WithStack(err)
+	}
+	outboxItem.Status = model.OUTBOX_STATUS_SENDING
+	outboxItem.SendingExpireTime = expireTime.UnixMilli()
+	return nil
+}
+
+// UpdatePending implements OutboxItemRepository.
+func (r *defaultOutboxItemRepository) UpdatePending(outboxItem *model.OutboxItem) error {
+	return r.UpdatePendingWithContext(apcontext.Context, outboxItem)
+}
+
+// UpdatePendingWithContext implements OutboxItemRepository.
+func (r *defaultOutboxItemRepository) UpdatePendingWithContext(ctx context.Context, outboxItem *model.OutboxItem) error {
+	input := input.UpdateInput{
+		PrimaryKey: r.newPrimaryKey(outboxItem.OutboxId),
+		UpdateAttributes: []*input.Attribute{
+			{
+				Name:  constant.QUEUE_MESSAGE_STATUS,
+				Value: model.OUTBOX_STATUS_PENDING,
+			},
+		},
+		// 自身が送信中に更新したアイテムのみ更新
+		WhereClauses: []*input.WhereClause{
+			{
+				Attribute: input.Attribute{
+					Name:  constant.QUEUE_MESSAGE_STATUS,
+					Value: model.OUTBOX_STATUS_SENDING,
+				},
+				WhereOp: input.WHERE_EQUAL,
+			},
+			{
+				Attribute: input.Attribute{
+					Name:  OUTBOX_SENDING_EXPIRE_TIME,
+					Value: outboxItem.SendingExpireTime,
+				},
+				WhereOp:  input.WHERE_EQUAL,
+				AppendOp: input.APPEND_AND,
+			},
+		},
+	}
+	if err := r.dynamodbTemplate.UpdateOneWithContext(ctx, r.tableName, input); err != nil {
+		return errors.WithStack(err)
+	}
+	outboxItem.Status = model.OUTBOX_STATUS_PENDING
+	return nil
+}
+
+// UpdateDeliveredWithTx implements OutboxItemRepository.
+func (r *defaultOutboxItemRepository) UpdateDeliveredWithTx(outboxItem *model.OutboxItem) error {
+	return r.UpdateDeliveredWithTxInContext(apcontext.Context, outboxItem)
+}
+
+// UpdateDeliveredWithTxInContext implements OutboxItemRepository.
+func (r *defaultOutboxItemRepository) UpdateDeliveredWithTxInContext(ctx context.Context, outboxItem *model.OutboxItem) error {
+	r.logger.Debug("partitionKey: %s", r.primaryKey.PartitionKey)
+	input := input.UpdateInput{
+		PrimaryKey: r.newPrimaryKey(outboxItem.OutboxId),
+		UpdateAttributes: []*input.Attribute{
+			// Status列を更新
+			{
+				Name:  constant.QUEUE_MESSAGE_STATUS,
+				Value: model.OUTBOX_STATUS_DELIVERED,
+			},
+		},
+		// 自身が送信中に更新したアイテムのみ更新（有効期限切れで他のリレーが再送中の場合は更新しない）
+		WhereClauses: []*input.WhereClause{
+			{
+				Attribute: input.Attribute{
+					Name:  constant.QUEUE_MESSAGE_STATUS,
+					Value: model.OUTBOX_STATUS_SENDING,
+				},
+				WhereOp: input.WHERE_EQUAL,
+			},
+			{
+				Attribute: input.Attribute{
+					Name:  OUTBOX_SENDING_EXPIRE_TIME,
+					Value: outboxItem.SendingExpireTime,
+				},
+				WhereOp:  input.WHERE_EQUAL,
+				AppendOp: input.APPEND_AND,
+			},
+		},
+	}
+	err := r.dynamodbTemplate.UpdateOneWithTransactionInContext(ctx, r.tableName, input)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	outboxItem.Status = model.OUTBOX_STATUS_DELIVERED
+	return nil
+}
+
+// newPrimaryKey は、アウトボックスのIDから、プライマリキーを作成します。
+func (r *defaultOutboxItemRepository) newPrimaryKey(outboxId string) input.PrimaryKey {
+	return input.PrimaryKey{
+		PartitionKey: input.Attribute{
+			Name:  r.primaryKey.PartitionKey,
+			Value: outboxId,
+		},
+	}
+}
@@ -0,0 +1,32 @@
+package tables
+
+import (
+	"example.com/appbase/pkg/dynamodb/gsi"
+	"example.com/appbase/pkg/dynamodb/tables"
+)
+
+// アウトボックステーブルの属性名
+const (
+	OUTBOX_ID         = "outbox_id"
+	OUTBOX_STATUS     = "status"
+	OUTBOX_CREATED_AT = "created_at"
+)
+
+// OUTBOX_STATUS_INDEX_NAME は、送信されずに残ったアイテムを検索するための、ステータスと登録日時をキーとするGSI名です。
+const OUTBOX_STATUS_INDEX_NAME = gsi.DynamoDBGSIName("status-created_at-index")
+
+// OutboxTable は、アウトボックステーブルのテーブル情報を提供します。
+type OutboxTable struct {
+}
+
+// InitPK は、アウトボックステーブルのプライマリキー、GSIを初期化します。
+func (OutboxTable) InitPK(tableName tables.DynamoDBTableName) {
+	pkKeyPair := &tables.PKKeyPair{
+		PartitionKey: OUTBOX_ID,
+	}
+	tables.SetPrimaryKey(tableName, pkKeyPair)
+	gsi.AddGSIKeyPair(tableName, OUTBOX_STATUS_INDEX_NAME, &gsi.GSIKeyPair{
+		PartitionKey: OUTBOX_STATUS,
+		SortKey:      OUTBOX_CREATED_AT,
+	})
+}
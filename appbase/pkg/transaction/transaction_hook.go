@@ -0,0 +1,88 @@
+/*
+transaction パッケージは、トランザクション管理に関する機能を提供するパッケージです。
+*/
+package transaction
+
+import (
+	"context"
+
+	"example.com/appbase/pkg/apcontext"
+	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/message"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// トランザクションの完了時の処理の種類
+	HOOK_PHASE_COMMIT   = "コミット"
+	HOOK_PHASE_ROLLBACK = "ロールバック"
+)
+
+// TransactionHookFunc は、トランザクションのコミット後、ロールバック後に実行する関数です。
+// 引数のContextは、トランザクションを開始した呼び出し元のContextです（終了したトランザクションは含みません）。
+type TransactionHookFunc func(ctx context.Context) error
+
+// OnCommit は、コンテキスト領域のトランザクションのコミット後に実行する関数を登録します。
+// キャッシュのクリア、トランザクション管理外の通知、一時ファイルの削除等、コミットが成功した場合のみ行いたい処理に利用します。
+// 関数のエラーはログ出力のみ行い、コミットの結果には影響しません。
+func OnCommit(hook TransactionHookFunc) error {
+	return OnCommitWithContext(apcontext.Context, hook)
+}
+
+// OnCommitWithContext は、goroutine向けに渡されたContextのトランザクションのコミット後に実行する関数を登録します。
+func OnCommitWithContext(ctx context.Context, hook TransactionHookFunc) error {
+	transaction, err := getTransaction(ctx)
+	if err != nil {
+		return err
+	}
+	transaction.OnCommit(hook)
+	return nil
+}
+
+// OnRollback は、コンテキスト領域のトランザクションのロールバック後に実行する関数を登録します。
+// コミットに失敗した場合も、ロールバック後の関数が実行されます。
+// 関数のエラーはログ出力のみ行い、ロールバックの原因のエラーには影響しません。
+func OnRollback(hook TransactionHookFunc) error {
+	return OnRollbackWithContext(apcontext.Context, hook)
+}
+
+// OnRollbackWithContext は、goroutine向けに渡されたContextのトランザクションのロールバック後に実行する関数を登録します。
+func OnRollbackWithContext(ctx context.Context, hook TransactionHookFunc) error {
+	transaction, err := getTransaction(ctx)
+	if err != nil {
+		return err
+	}
+	transaction.OnRollback(hook)
+	return nil
+}
+
+// getTransaction は、Contextからトランザクションを取得します。
+func getTransaction(ctx context.Context) (Transaction, error) {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	transaction, ok := ctx.Value(TRANSACTION_CTX_KEY).(Transaction)
+	if !ok {
+		return nil, errors.New("トランザクションが開始されていません")
+	}
+	return transaction, nil
+}
+
+// runHooks は、登録順に関数を実行します。関数のエラー、panicはログ出力のみ行い、後続の関数の実行を継続します。
+func runHooks(ctx context.Context, logger logging.Logger, phase string, hooks []TransactionHookFunc) {
+	for i, hook := range hooks {
+		if err := runHook(ctx, hook); err != nil {
+			logger.WarnWithError(err, message.W_FW_8021, phase, i+1)
+		}
+	}
+}
+
+// runHook は、関数を実行し、panicの場合はエラーに変換します。
+func runHook(ctx context.Context, hook TransactionHookFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("recover from: %+v", r)
+		}
+	}()
+	return hook(ctx)
+}
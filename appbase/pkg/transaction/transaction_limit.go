@@ -0,0 +1,159 @@
+/*
+transaction パッケージは、トランザクション管理に関する機能を提供するパッケージです。
+*/
+package transaction
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"example.com/appbase/pkg/dynamodb/tables"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoDBのトランザクションの上限
+// https://docs.aws.amazon.com/ja_jp/amazondynamodb/latest/developerguide/ServiceQuotas.html#limits-dynamodb-transactions
+const (
+	// TransactWriteItemsで1回に書き込み可能な項目数の上限
+	TRANSACT_WRITE_ITEMS_MAX_COUNT = 100
+	// TransactWriteItemsで1回に書き込み可能な項目の合計サイズ（バイト）の上限
+	TRANSACT_WRITE_ITEMS_MAX_SIZE = 4 * 1024 * 1024
+)
+
+// transactKey は、トランザクション内での項目の重複判定のため、テーブル名とプライマリキーから文字列を作成します。
+func transactKey(tableName tables.DynamoDBTableName, key map[string]types.AttributeValue) string {
+	return string(tableName) + formatKey(key)
+}
+
+// formatKey は、プライマリキーをログやエラーメッセージ向けの文字列に変換します。
+func formatKey(key map[string]types.AttributeValue) string {
+	names := make([]string, 0, len(key))
+	for k := range key {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	sb.WriteString("{")
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(name)
+		sb.WriteString(":")
+		sb.WriteString(formatAttributeValue(key[name]))
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+// formatAttributeValue は、プライマリキーの属性の値を文字列に変換します。
+func formatAttributeValue(av types.AttributeValue) string {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return v.Value
+	case *types.AttributeValueMemberN:
+		return v.Value
+	case *types.AttributeValueMemberB:
+		return fmt.Sprintf("%x", v.Value)
+	default:
+		return fmt.Sprintf("%v", av)
+	}
+}
+
+// estimateTransactWriteItemSize は、TransactWriteItemの項目のサイズ（バイト）を見積もります。
+// Putの場合は項目全体、Updateの場合はプライマリキーと更新値、Delete、ConditionCheckの場合はプライマリキーのサイズとします。
+func estimateTransactWriteItemSize(item types.TransactWriteItem) int {
+	switch {
+	case item.Put != nil:
+		return estimateItemSize(item.Put.Item)
+	case item.Update != nil:
+		return estimateItemSize(item.Update.Key) + estimateItemSize(item.Update.ExpressionAttributeValues)
+	case item.Delete != nil:
+		return estimateItemSize(item.Delete.Key)
+	case item.ConditionCheck != nil:
+		return estimateItemSize(item.ConditionCheck.Key)
+	default:
+		return 0
+	}
+}
+
+// estimateItemSize は、DynamoDBの項目のサイズ（バイト）を見積もります。
+// https://docs.aws.amazon.com/ja_jp/amazondynamodb/latest/developerguide/CapacityUnitCalculations.html
+func estimateItemSize(item map[string]types.AttributeValue) int {
+	size := 0
+	for name, v := range item {
+		size += len(name) + estimateAttributeValueSize(v)
+	}
+	return size
+}
+
+// estimateAttributeValueSize は、DynamoDBの属性の値のサイズ（バイト）を見積もります。
+func estimateAttributeValueSize(av types.AttributeValue) int {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return len(v.Value)
+	case *types.AttributeValueMemberN:
+		// 数値は、有効桁数2桁ごとに1バイト＋1バイト
+		return (len(v.Value)+1)/2 + 1
+	case *types.AttributeValueMemberB:
+		return len(v.Value)
+	case *types.AttributeValueMemberBOOL, *types.AttributeValueMemberNULL:
+		return 1
+	case *types.AttributeValueMemberSS:
+		size := 0
+		for _, s := range v.Value {
+			size += len(s)
+		}
+		return size
+	case *types.AttributeValueMemberNS:
+		size := 0
+		for _, n := range v.Value {
+			size += (len(n)+1)/2 + 1
+		}
+		return size
+	case *types.AttributeValueMemberBS:
+		size := 0
+		for _, b := range v.Value {
+			size += len(b)
+		}
+		return size
+	case *types.AttributeValueMemberL:
+		// リストは、3バイト＋要素ごとに1バイト
+		size := 3
+		for _, e := range v.Value {
+			size += estimateAttributeValueSize(e) + 1
+		}
+		return size
+	case *types.AttributeValueMemberM:
+		// マップは、3バイト＋要素ごとに1バイト
+		size := 3
+		for name, e := range v.Value {
+			size += len(name) + estimateAttributeValueSize(e) + 1
+		}
+		return size
+	default:
+		return 0
+	}
+}
+
+// chunkTransactWriteItems は、非アトミックな分割実行のため、TransactWriteItemを項目数、サイズの上限以内に分割します。
+func chunkTransactWriteItems(items []types.TransactWriteItem) [][]types.TransactWriteItem {
+	var chunks [][]types.TransactWriteItem
+	var chunk []types.TransactWriteItem
+	chunkSize := 0
+	for _, item := range items {
+		size := estimateTransactWriteItemSize(item)
+		if len(chunk) > 0 && (len(chunk) >= TRANSACT_WRITE_ITEMS_MAX_COUNT || chunkSize+size > TRANSACT_WRITE_ITEMS_MAX_SIZE) {
+			chunks = append(chunks, chunk)
+			chunk = nil
+			chunkSize = 0
+		}
+		chunk = append(chunk, item)
+		chunkSize += size
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
@@ -0,0 +1,66 @@
+package transaction
+
+import (
+	"fmt"
+	"testing"
+
+	myerrors "example.com/appbase/pkg/errors"
+	"example.com/appbase/pkg/message"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestUpdateItem(id string) *types.TransactWriteItem {
+	return &types.TransactWriteItem{Update: &types.Update{
+		TableName: aws.String("test_temp"),
+		Key:       map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: id}},
+	}}
+}
+
+func TestAppendTransactWriteItem(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  *Options
+		count    int
+		dup      bool
+		wantCode string
+	}{
+		{name: "上限以内", options: &Options{}, count: TRANSACT_WRITE_ITEMS_MAX_COUNT},
+		{name: "項目数の上限超過", options: &Options{}, count: TRANSACT_WRITE_ITEMS_MAX_COUNT + 1, wantCode: message.E_FW_9003},
+		{name: "分割実行の場合は上限超過しない", options: &Options{NonAtomicChunked: true}, count: TRANSACT_WRITE_ITEMS_MAX_COUNT + 1},
+		{name: "キーの重複", options: &Options{}, count: 2, dup: true, wantCode: message.E_FW_9005},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &defaultTransaction{options: tt.options}
+			var err error
+			for i := 0; i < tt.count && err == nil; i++ {
+				id := fmt.Sprintf("id%d", i)
+				if tt.dup {
+					id = "id"
+				}
+				err = tx.AppendTransactWriteItem(newTestUpdateItem(id))
+			}
+			if tt.wantCode == "" {
+				assert.NoError(t, err)
+				assert.Len(t, tx.transactWriteItems, tt.count)
+				return
+			}
+			var sysErr *myerrors.SystemError
+			assert.ErrorAs(t, err, &sysErr)
+			assert.Equal(t, tt.wantCode, sysErr.ErrorCode())
+		})
+	}
+}
+
+func TestChunkTransactWriteItems(t *testing.T) {
+	var items []types.TransactWriteItem
+	for i := 0; i < 250; i++ {
+		items = append(items, *newTestUpdateItem(fmt.Sprintf("id%d", i)))
+	}
+	chunks := chunkTransactWriteItems(items)
+	assert.Len(t, chunks, 3)
+	assert.Len(t, chunks[0], TRANSACT_WRITE_ITEMS_MAX_COUNT)
+	assert.Len(t, chunks[2], 50)
+}
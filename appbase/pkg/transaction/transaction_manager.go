@@ -5,12 +5,15 @@ package transaction
 
 import (
 	"context"
+	"sync/atomic"
 
 	"example.com/appbase/pkg/apcontext"
 	"example.com/appbase/pkg/constant"
 	"example.com/appbase/pkg/domain"
+	myerrors "example.com/appbase/pkg/errors"
 	"example.com/appbase/pkg/logging"
 	"example.com/appbase/pkg/message"
+	"example.com/appbase/pkg/retry"
 	"example.com/appbase/pkg/transaction/model"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
@@ -19,11 +22,16 @@ import (
 
 const (
 	TRANSACTION_CTX_KEY = apcontext.ContextKey("TRANSACTION")
+	// トランザクションに追加する操作のラベルのContextのキー
+	ITEM_LABEL_CTX_KEY = apcontext.ContextKey("TRANSACTION_ITEM_LABEL")
 )
 
 // TransactionManager はトランザクションを管理するインタフェースです
 type TransactionManager interface {
 	// ExecuteTransaction は、Serviceの関数serviceFuncの実行前後でDynamoDBトランザクション実行します。
+	// 既に開始されたトランザクションの中から呼び出した場合の動作は、WithPropagationで伝播属性を指定できます（デフォルトはREQUIRED）。
+	// 既に開始されたトランザクションに参加できるのは、同じTransactionManagerで開始したトランザクションのみです。
+	// 参加した処理がエラーとなった場合は、呼び出し元でエラーを無視しても、トランザクションはロールバックされます（ErrTransactionRollbackOnly）。
 	ExecuteTransaction(serviceFunc domain.ServiceFunc, opts ...Option) (any, error)
 
 	// ExecuteTransactionWithContext は、goroutine向けに、渡されたContextを利用して、
@@ -59,16 +67,38 @@ func NewTransactionManagerForDBOnly(logger logging.Logger,
 	}
 }
 
+// NewTransactionManagerWithOutbox は、トランザクショナルアウトボックスに対応するTransactionManagerを作成します。
+// SQSのメッセージは直接送信せず、アウトボックステーブルのアイテムとして業務データと同一のDynamoDBトランザクションで登録します。
+// 登録したアイテムは、DynamoDB Streamsをトリガとするリレー（OutboxRelayLambdaHandler）により送信されます。
+func NewTransactionManagerWithOutbox(logger logging.Logger,
+	dynamodbAccessor TransactionalDynamoDBAccessor,
+	messageRegsiterer MessageRegisterer,
+	outboxRegisterer OutboxRegisterer,
+) TransactionManager {
+	return &defaultTransactionManager{logger: logger,
+		dynamodbAccessor:  dynamodbAccessor,
+		messageRegsiterer: messageRegsiterer,
+		outboxRegisterer:  outboxRegisterer,
+	}
+}
+
 // defaultTransactionManager は、TransactionManagerを実装する構造体です。
 type defaultTransactionManager struct {
 	logger            logging.Logger
 	dynamodbAccessor  TransactionalDynamoDBAccessor
 	sqsAccessor       TransactionalSQSAccessor
 	messageRegsiterer MessageRegisterer
+	outboxRegisterer  OutboxRegisterer
 }
 
 // ExecuteTransaction implements TransactionManager.
 func (tm *defaultTransactionManager) ExecuteTransaction(serviceFunc domain.ServiceFunc, opts ...Option) (any, error) {
+	// 終了時に、コンテキスト領域を呼び出し元のContextに戻す
+	// （トランザクション終了後に、呼び出し元で終了済のトランザクションに参加しないようにするため）
+	outerCtx := apcontext.Context
+	defer func() {
+		apcontext.Context = outerCtx
+	}()
 	return tm.ExecuteTransactionWithContext(apcontext.Context, func(ctx context.Context) (any, error) {
 		// トランザクション付きのContextを設定
 		apcontext.Context = ctx
@@ -82,8 +112,36 @@ func (tm *defaultTransactionManager) ExecuteTransactionWithContext(ctx context.C
 	if ctx == nil {
 		ctx = apcontext.Context
 	}
+	options := NewOptions(opts...)
+	// 既に開始されたトランザクションの有無
+	outerTx, hasOuter := ctx.Value(TRANSACTION_CTX_KEY).(*defaultTransaction)
+	switch options.Propagation {
+	case PROPAGATION_MANDATORY:
+		if !hasOuter {
+			return nil, errors.WithStack(ErrTransactionRequired)
+		}
+		// 既に開始されたトランザクションに参加
+		return tm.joinTransaction(ctx, outerTx, serviceFunc)
+	case PROPAGATION_NEVER:
+		if hasOuter {
+			return nil, errors.WithStack(ErrTransactionExists)
+		}
+		// トランザクションを開始せずに実行
+		return serviceFunc(ctx)
+	case PROPAGATION_NESTED:
+		// DynamoDBのトランザクションはセーブポイントに対応していないため、エラーとする
+		return nil, errors.WithStack(ErrNestedTransactionNotSupported)
+	case PROPAGATION_REQUIRES_NEW:
+		// 既に開始されたトランザクションの有無に関わらず、新しいトランザクションを開始
+	default:
+		if hasOuter {
+			// 既に開始されたトランザクションに参加
+			return tm.joinTransaction(ctx, outerTx, serviceFunc)
+		}
+	}
 	// 新しいトランザクションを作成
-	transaction := newTransaction(tm.logger, tm.messageRegsiterer, opts...)
+	// ClientRequestTokenの生成のため、トランザクションIDを採番
+	transaction := newTransaction(tm, newTransactionID(), options)
 	// トランザクション付きのContextを作成
 	ctxWithTx := context.WithValue(ctx, TRANSACTION_CTX_KEY, transaction)
 
@@ -91,17 +149,35 @@ func (tm *defaultTransactionManager) ExecuteTransactionWithContext(ctx context.C
 	transaction.Start(tm.dynamodbAccessor, tm.sqsAccessor)
 
 	defer func() {
-		if r := recover(); r != nil {
+		r := recover()
+		if r != nil {
 			// panic発生時トランザクションをロールバック
 			transaction.Rollback()
-			// 上位にpanicをリスロー
-			panic(r)
 		} else if err != nil {
 			// Serviceの実行エラー時トランザクションをロールバック
 			transaction.Rollback()
+		} else if transaction.IsRollbackOnly() {
+			// 参加した処理のエラーを呼び出し元で無視した場合も、トランザクションをロールバック
+			transaction.Rollback()
+			err = errors.WithStack(ErrTransactionRollbackOnly)
 		} else {
 			// Serviceの実行成功時トランザクションをコミット
 			_, err = transaction.Commit(ctx)
+			if err != nil && len(options.ConditionFailedMappings) > 0 {
+				// 条件チェックの失敗を業務エラーに変換
+				err = ToBusinessError(err, options.ConditionFailedMappings...)
+			}
+		}
+		// コンテキスト領域にトランザクション付きのContextが格納されている場合は、
+		// コミット後、ロールバック後の関数で終了したトランザクションに参加しないよう、呼び出し元のContextに戻す
+		if apcontext.Context == ctxWithTx {
+			apcontext.Context = ctx
+		}
+		// コミット後、ロールバック後の関数を実行
+		transaction.AfterCompletion(ctx, r == nil && err == nil)
+		if r != nil {
+			// 上位にpanicをリスロー
+			panic(r)
 		}
 	}()
 
@@ -111,12 +187,32 @@ func (tm *defaultTransactionManager) ExecuteTransactionWithContext(ctx context.C
 	return
 }
 
+// joinTransaction は、既に開始されたトランザクションに参加してserviceFuncを実行します。
+// コミット、ロールバックは、トランザクションを開始した呼び出し元で行います。
+// serviceFuncがエラーの場合は、呼び出し元でエラーを無視してもコミットしないよう、トランザクションをロールバックのみ可能な状態にします。
+func (tm *defaultTransactionManager) joinTransaction(ctx context.Context, outerTx *defaultTransaction, serviceFunc domain.ServiceFuncWithContext) (any, error) {
+	if outerTx.manager != tm {
+		// 別のTransactionManagerのトランザクションには、SQSのメッセージやアウトボックスの扱いが異なるため参加しない
+		return nil, errors.WithStack(ErrTransactionManagerMismatch)
+	}
+	tm.logger.Debug("既に開始されたトランザクションに参加")
+	result, err := serviceFunc(ctx)
+	if err != nil {
+		outerTx.SetRollbackOnly()
+	}
+	return result, err
+}
+
 // Transactionは トランザクションを表すインタフェースです
 type Transaction interface {
 	// Start は、トランザクションを開始します。
 	Start(dynamodbAccessor TransactionalDynamoDBAccessor, sqsAccessor TransactionalSQSAccessor)
 	// AppendTransactWriteItemは、DBへトランザクション書き込みしたい場合に対象のTransactWriteItemを追加します。
-	AppendTransactWriteItem(item *types.TransactWriteItem)
+	// トランザクションの上限（100項目、4MB）を超える場合、同一のプライマリキーの項目が既に追加されている場合は、SystemErrorを返却します。
+	AppendTransactWriteItem(item *types.TransactWriteItem) error
+	// AppendTransactWriteItemWithLabel は、業務ロジックで指定したラベルを付与して、対象のTransactWriteItemを追加します。
+	// ラベルは、コミット時にトランザクションがキャンセルされた場合に、TransactionCanceledErrorで原因の操作を識別するために利用します。
+	AppendTransactWriteItemWithLabel(item *types.TransactWriteItem, label string) error
 	// AppendTransactMessageは、SQSへトランザクション管理してメッセージ送信したい場合に対象のMessageを追加します。
 	AppendTransactMessage(message *Message)
 	// CheckTransactWriteItems は、TransactWriteItemが存在するかを確認します。
@@ -125,32 +221,57 @@ type Transaction interface {
 	Commit(ctx context.Context) (*dynamodb.TransactWriteItemsOutput, error)
 	// Rollback は、トランザクションをロールバックします。
 	Rollback()
+	// OnCommit は、トランザクションのコミット後に実行する関数を登録します。
+	OnCommit(hook TransactionHookFunc)
+	// OnRollback は、トランザクションのロールバック後（コミットの失敗を含む）に実行する関数を登録します。
+	OnRollback(hook TransactionHookFunc)
+	// SetRollbackOnly は、トランザクションをロールバックのみ可能な状態にします。
+	// 参加した処理がエラーとなった場合に、TransactionManagerが設定します。
+	SetRollbackOnly()
+	// IsRollbackOnly は、トランザクションがロールバックのみ可能な状態かどうかを返却します。
+	IsRollbackOnly() bool
+	// AfterCompletion は、トランザクションの完了後に、コミット後またはロールバック後の関数を登録順に実行します。
+	// 関数のエラーはログ出力のみ行います。TransactionManagerが実行するため業務ロジックで利用する必要はありません。
+	AfterCompletion(ctx context.Context, committed bool)
 }
 
 // newTransactionは 新しいTransactionを作成します。
-func newTransaction(logger logging.Logger, messageRegsiterer MessageRegisterer, opts ...Option) Transaction {
-	options := &Options{}
-	for _, optFn := range opts {
-		optFn(options)
-	}
-	return &defaultTransaction{logger: logger, messageRegsiterer: messageRegsiterer, options: options}
+func newTransaction(manager *defaultTransactionManager, transactionID string, options *Options) *defaultTransaction {
+	return &defaultTransaction{manager: manager, logger: manager.logger, messageRegsiterer: manager.messageRegsiterer,
+		outboxRegisterer: manager.outboxRegisterer, transactionID: transactionID, options: options}
 }
 
 // defaultTransactionは、transactionを実装する構造体です。
+// なお、読み込みトランザクション（TransactGetItems）は、書き込みトランザクションと異なりコミット時まで遅延せず、
+// TransactionalDynamoDBTemplate.FindManyByKeysWithTransactionで即時に実行します。
 type defaultTransaction struct {
+	// トランザクションを開始したTransactionManager
+	manager           *defaultTransactionManager
 	logger            logging.Logger
 	messageRegsiterer MessageRegisterer
+	outboxRegisterer  OutboxRegisterer
 	dynamodbAccessor  TransactionalDynamoDBAccessor
 	sqsAccessor       TransactionalSQSAccessor
+	// トランザクションID（ClientRequestTokenの生成に利用）
+	transactionID string
 	// DynamoDBの書き込みトランザクション
 	transactWriteItems []types.TransactWriteItem
+	// 書き込みトランザクションの項目ごとのラベル
+	transactWriteItemLabels []string
+	// 書き込みトランザクションの項目の合計サイズの見積もり
+	transactWriteItemsSize int
+	// 書き込みトランザクションの項目のキーの集合（重複判定用）
+	transactKeys map[string]struct{}
 	// SQSのメッセージ
 	messages []*Message
+	// コミット後に実行する関数
+	commitHooks []TransactionHookFunc
+	// ロールバック後に実行する関数
+	rollbackHooks []TransactionHookFunc
+	// ロールバックのみ可能な状態かどうか
+	rollbackOnly atomic.Bool
 	// Option
 	options *Options
-
-	// TODO: 読み込みトランザクションTransactGetItems
-	// transactGetItems []types.TransactGetItem
 }
 
 // Start implements Transaction.
@@ -161,8 +282,39 @@ func (t *defaultTransaction) Start(dynamodbAccessor TransactionalDynamoDBAccesso
 }
 
 // AppendTransactWriteItem implements Transaction.
-func (t *defaultTransaction) AppendTransactWriteItem(item *types.TransactWriteItem) {
+func (t *defaultTransaction) AppendTransactWriteItem(item *types.TransactWriteItem) error {
+	return t.AppendTransactWriteItemWithLabel(item, "")
+}
+
+// AppendTransactWriteItemWithLabel implements Transaction.
+func (t *defaultTransaction) AppendTransactWriteItemWithLabel(item *types.TransactWriteItem, label string) error {
+	_, tableName, key := describeTransactWriteItem(*item)
+	// 同一トランザクション内での同一項目に対する複数の操作はエラーとなるため、追加時にチェック
+	if t.transactKeys == nil {
+		t.transactKeys = make(map[string]struct{})
+	}
+	// なお、プライマリキーが特定できない項目（テーブル定義が未登録のPut）はチェック対象外
+	k := transactKey(tableName, key)
+	if _, ok := t.transactKeys[k]; ok && len(key) > 0 {
+		return myerrors.NewSystemError(nil, message.E_FW_9005, tableName, formatKey(key))
+	}
+	size := estimateTransactWriteItemSize(*item)
+	// 非アトミックな分割実行の場合は、コミット時に分割するため、上限のチェックは行わない
+	if !t.options.NonAtomicChunked {
+		if len(t.transactWriteItems) >= TRANSACT_WRITE_ITEMS_MAX_COUNT {
+			return myerrors.NewSystemError(nil, message.E_FW_9003, TRANSACT_WRITE_ITEMS_MAX_COUNT, tableName, formatKey(key))
+		}
+		if t.transactWriteItemsSize+size > TRANSACT_WRITE_ITEMS_MAX_SIZE {
+			return myerrors.NewSystemError(nil, message.E_FW_9004, TRANSACT_WRITE_ITEMS_MAX_SIZE, tableName, formatKey(key))
+		}
+	}
+	if len(key) > 0 {
+		t.transactKeys[k] = struct{}{}
+	}
+	t.transactWriteItemsSize += size
 	t.transactWriteItems = append(t.transactWriteItems, *item)
+	t.transactWriteItemLabels = append(t.transactWriteItemLabels, label)
+	return nil
 }
 
 // AppendTransactMessage implements transaction.
@@ -178,7 +330,13 @@ func (t *defaultTransaction) CheckTransactWriteItems() bool {
 // Commit implements Transaction.
 func (t *defaultTransaction) Commit(ctx context.Context) (*dynamodb.TransactWriteItemsOutput, error) {
 	var err error
-	if t.sqsAccessor != nil {
+	if t.outboxRegisterer != nil {
+		// アウトボックスの場合は、SQSのメッセージを送信せず、アウトボックスのアイテムとしてDBトランザクションに追加
+		err = t.registerOutboxMessages(ctx)
+		if err != nil {
+			return nil, err
+		}
+	} else if t.sqsAccessor != nil {
 		// SQSのメッセージの送信とメッセージのDBトランザクション管理
 		err = t.sqsAccessor.TransactSendMessagesWithContext(ctx, t.messages, t.options.SqsOptions...)
 		if err != nil {
@@ -198,36 +356,61 @@ func (t *defaultTransaction) Commit(ctx context.Context) (*dynamodb.TransactWrit
 		return nil, err
 	}
 
+	if t.options.NonAtomicChunked {
+		// 非アトミックな分割実行
+		return t.commitChunks(ctx)
+	}
 	// DynamoDBトランザクション実行
-	output, err := t.dynamodbAccessor.TransactWriteItemsSDKWithContext(ctx, t.transactWriteItems, t.options.DynamoDBOptions...)
+	return t.transactWriteItemsSDK(ctx, t.transactWriteItems, t.transactWriteItemLabels, 0)
+}
+
+// commitChunks は、書き込みトランザクションを上限以内に分割し、順に実行します。
+func (t *defaultTransaction) commitChunks(ctx context.Context) (*dynamodb.TransactWriteItemsOutput, error) {
+	chunks := chunkTransactWriteItems(t.transactWriteItems)
+	var output *dynamodb.TransactWriteItemsOutput
+	// 分割したトランザクションの先頭の項目の位置
+	offset := 0
+	for i, chunk := range chunks {
+		t.logger.Debug("分割トランザクション実行(%d/%d): %d件", i+1, len(chunks), len(chunk))
+		labels := t.transactWriteItemLabels[offset : offset+len(chunk)]
+		offset += len(chunk)
+		var err error
+		output, err = t.transactWriteItemsSDK(ctx, chunk, labels, i)
+		if err != nil {
+			// 途中で失敗した場合は、実行済の分割トランザクション数をログ出力
+			t.logger.Warn(message.W_FW_8019, i, len(chunks))
+			return nil, err
+		}
+	}
+	return output, nil
+}
+
+// transactWriteItemsSDK は、DynamoDBの書き込みトランザクションを実行します。
+// 決定的なClientRequestTokenを指定し、トランザクションの競合、スロットリング、通信エラーの場合は、同じトークンでリトライします。
+// 同じトークンでのリトライのため、前回の実行が実際には成功していた場合でも、二重に書き込まれることはありません。
+func (t *defaultTransaction) transactWriteItemsSDK(ctx context.Context, items []types.TransactWriteItem, labels []string, chunkIndex int) (*dynamodb.TransactWriteItemsOutput, error) {
+	clientRequestToken := newClientRequestToken(t.transactionID, chunkIndex)
+	retryer := retry.NewRetryer[*dynamodb.TransactWriteItemsOutput](t.logger)
+	attempts := 0
+	output, err := retryer.DoWithContext(ctx,
+		func() (*dynamodb.TransactWriteItemsOutput, error) {
+			attempts++
+			if attempts > 1 {
+				t.logger.Warn(message.W_FW_8020, attempts-1, clientRequestToken)
+			}
+			return t.dynamodbAccessor.TransactWriteItemsWithTokenSDKWithContext(ctx, items, clientRequestToken, t.options.DynamoDBOptions...)
+		},
+		func(_ *dynamodb.TransactWriteItemsOutput, err error) bool {
+			return isRetryableTransactWriteError(err)
+		},
+		t.options.RetryOptions...,
+	)
 	if err != nil {
 		t.logger.Debug("トランザクションコミットエラー")
-		// https://docs.aws.amazon.com/ja_jp/amazondynamodb/latest/developerguide/transaction-apis.html
-		var txCanceledException *types.TransactionCanceledException
-		var txConflictException *types.TransactionConflictException
-		if errors.As(err, &txCanceledException) {
-			// トランザクションコミット失敗の理由をログ出力
-			for _, v := range txCanceledException.CancellationReasons {
-				codePtr := v.Code
-				messagePtr := v.Message
-				var code string
-				if codePtr == nil {
-					code = ""
-				} else {
-					code = *codePtr
-				}
-				var msg string
-				if messagePtr == nil {
-					msg = ""
-				} else {
-					msg = *messagePtr
-				}
-				t.logger.Info(message.I_FW_0003, code, msg, v.Item)
-			}
-		} else if errors.As(err, &txConflictException) {
-			t.logger.Info(message.I_FW_0004, *txConflictException.ErrorCodeOverride, *txConflictException.Message)
-		}
-		return nil, errors.WithStack(err)
+		// トランザクションコミット失敗の理由をログ出力
+		logTransactionCanceledReasons(t.logger, err)
+		// キャンセルの原因を、トランザクションに追加した操作、ラベルと対応付ける
+		return nil, errors.WithStack(newTransactionCanceledError(err, items, labels))
 	}
 	t.logger.Debug("トランザクションコミット")
 	return output, nil
@@ -242,6 +425,76 @@ func (t *defaultTransaction) Rollback() {
 	}
 }
 
+// OnCommit implements Transaction.
+func (t *defaultTransaction) OnCommit(hook TransactionHookFunc) {
+	t.commitHooks = append(t.commitHooks, hook)
+}
+
+// OnRollback implements Transaction.
+func (t *defaultTransaction) OnRollback(hook TransactionHookFunc) {
+	t.rollbackHooks = append(t.rollbackHooks, hook)
+}
+
+// SetRollbackOnly implements Transaction.
+func (t *defaultTransaction) SetRollbackOnly() {
+	t.rollbackOnly.Store(true)
+}
+
+// IsRollbackOnly implements Transaction.
+func (t *defaultTransaction) IsRollbackOnly() bool {
+	return t.rollbackOnly.Load()
+}
+
+// AfterCompletion implements Transaction.
+func (t *defaultTransaction) AfterCompletion(ctx context.Context, committed bool) {
+	if committed {
+		runHooks(ctx, t.logger, HOOK_PHASE_COMMIT, t.commitHooks)
+	} else {
+		runHooks(ctx, t.logger, HOOK_PHASE_ROLLBACK, t.rollbackHooks)
+	}
+}
+
+// registerOutboxMessages は、SQSのメッセージをアウトボックスのアイテムとして登録するトランザクションを追加します。
+func (t *defaultTransaction) registerOutboxMessages(ctx context.Context) error {
+	// 当該トランザクションに追加するため、トランザクション付きのContextを作成
+	ctxWithTx := context.WithValue(ctx, TRANSACTION_CTX_KEY, t)
+	for _, v := range t.messages {
+		if err := t.outboxRegisterer.RegisterOutboxWithContext(ctxWithTx, newOutboxItemFromMessage(v)); err != nil {
+			return err
+		}
+	}
+	t.logger.Debug("アウトボックスに登録: %d件", len(t.messages))
+	return nil
+}
+
+// logTransactionCanceledReasons は、DynamoDBのトランザクションが失敗した理由をログ出力します。
+// https://docs.aws.amazon.com/ja_jp/amazondynamodb/latest/developerguide/transaction-apis.html
+func logTransactionCanceledReasons(logger logging.Logger, err error) {
+	var txCanceledException *types.TransactionCanceledException
+	var txConflictException *types.TransactionConflictException
+	if errors.As(err, &txCanceledException) {
+		for _, v := range txCanceledException.CancellationReasons {
+			codePtr := v.Code
+			messagePtr := v.Message
+			var code string
+			if codePtr == nil {
+				code = ""
+			} else {
+				code = *codePtr
+			}
+			var msg string
+			if messagePtr == nil {
+				msg = ""
+			} else {
+				msg = *messagePtr
+			}
+			logger.Info(message.I_FW_0003, code, msg, v.Item)
+		}
+	} else if errors.As(err, &txConflictException) {
+		logger.Info(message.I_FW_0004, txConflictException.ErrorCode(), txConflictException.ErrorMessage())
+	}
+}
+
 // transactUpdateQueueMessageItem は、メッセージ管理テーブルのアイテムのステータス更新するトランザクションを追加します。
 func (t *defaultTransaction) transactUpdateQueueMessageItem(ctx context.Context) error {
 	// Contextから非同期処理情報を取得
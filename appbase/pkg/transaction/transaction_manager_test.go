@@ -0,0 +1,65 @@
+package transaction
+
+import (
+	"context"
+	"testing"
+
+	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/message"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTransactionManager(t *testing.T) TransactionManager {
+	msg, err := message.NewMessageSource()
+	assert.NoError(t, err)
+	logger, err := logging.NewLogger(msg)
+	assert.NoError(t, err)
+	return NewTransactionManagerForDBOnly(logger, nil, nil)
+}
+
+func TestExecuteTransactionWithContext_Propagation(t *testing.T) {
+	tm := newTestTransactionManager(t)
+	otherTm := newTestTransactionManager(t)
+	errInner := errors.New("inner")
+
+	t.Run("参加した処理のエラーを無視した場合はロールバック", func(t *testing.T) {
+		_, err := tm.ExecuteTransactionWithContext(context.Background(), func(ctx context.Context) (any, error) {
+			_, innerErr := tm.ExecuteTransactionWithContext(ctx, func(ctx context.Context) (any, error) {
+				return nil, errInner
+			})
+			assert.ErrorIs(t, innerErr, errInner)
+			// 呼び出し元でエラーを無視
+			return nil, nil
+		})
+		assert.ErrorIs(t, err, ErrTransactionRollbackOnly)
+	})
+
+	t.Run("参加した処理が成功した場合はコミット", func(t *testing.T) {
+		result, err := tm.ExecuteTransactionWithContext(context.Background(), func(ctx context.Context) (any, error) {
+			return tm.ExecuteTransactionWithContext(ctx, func(ctx context.Context) (any, error) {
+				return "ok", nil
+			}, WithPropagation(PROPAGATION_MANDATORY))
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", result)
+	})
+
+	t.Run("別のTransactionManagerのトランザクションには参加しない", func(t *testing.T) {
+		_, err := tm.ExecuteTransactionWithContext(context.Background(), func(ctx context.Context) (any, error) {
+			return otherTm.ExecuteTransactionWithContext(ctx, func(ctx context.Context) (any, error) {
+				return nil, nil
+			})
+		})
+		assert.ErrorIs(t, err, ErrTransactionManagerMismatch)
+	})
+
+	t.Run("REQUIRES_NEWは別のTransactionManagerのトランザクション内でも開始", func(t *testing.T) {
+		_, err := tm.ExecuteTransactionWithContext(context.Background(), func(ctx context.Context) (any, error) {
+			return otherTm.ExecuteTransactionWithContext(ctx, func(ctx context.Context) (any, error) {
+				return nil, nil
+			}, WithPropagation(PROPAGATION_REQUIRES_NEW))
+		})
+		assert.NoError(t, err)
+	})
+}
@@ -4,8 +4,13 @@ transaction パッケージは、トランザクション管理に関する機
 package transaction
 
 import (
+	"database/sql"
+	"time"
+
+	"example.com/appbase/pkg/retry"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/cockroachdb/errors"
 )
 
 // Option は、トランザクション実行のFunctaional Optionパターンによるオプションの関数です。
@@ -15,6 +20,62 @@ type Option func(*Options)
 type Options struct {
 	DynamoDBOptions []func(*dynamodb.Options)
 	SqsOptions      []func(*sqs.Options)
+	// 非アトミックな分割実行の有無
+	NonAtomicChunked bool
+	// トランザクションの伝播属性
+	Propagation Propagation
+	// コミット時のTransactWriteItemsのリトライ処理（RDBの場合はトランザクションの再実行）のオプション
+	RetryOptions []retry.Option
+	// コミット時の条件チェックの失敗を業務エラーに変換するための定義
+	ConditionFailedMappings []ConditionFailedMapping
+	// RDBのトランザクションの分離レベル（RDBのみ）
+	IsolationLevel sql.IsolationLevel
+	// RDBの読み取り専用トランザクションの有無（RDBのみ）
+	ReadOnly bool
+	// RDBのトランザクションのタイムアウト時間（RDBのみ）
+	Timeout time.Duration
+	// 直列化失敗、デッドロック、接続の切断時に、トランザクションごと再実行してよいかどうか（RDBのみ）
+	RetrySafe bool
+}
+
+// Propagation は、トランザクションの伝播属性です。
+// ExecuteTransactionを、既に開始されたトランザクションの中から呼び出した場合の動作を指定します。
+type Propagation string
+
+const (
+	// PROPAGATION_REQUIRED は、既に開始されたトランザクションがあれば参加し、なければ新たにトランザクションを開始します（デフォルト）。
+	PROPAGATION_REQUIRED = Propagation("REQUIRED")
+	// PROPAGATION_REQUIRES_NEW は、既に開始されたトランザクションの有無に関わらず、新たにトランザクションを開始します。
+	PROPAGATION_REQUIRES_NEW = Propagation("REQUIRES_NEW")
+	// PROPAGATION_MANDATORY は、既に開始されたトランザクションに参加します。トランザクションがない場合はエラーとします。
+	PROPAGATION_MANDATORY = Propagation("MANDATORY")
+	// PROPAGATION_NEVER は、トランザクションを開始せずに実行します。既に開始されたトランザクションがある場合はエラーとします。
+	PROPAGATION_NEVER = Propagation("NEVER")
+	// PROPAGATION_NESTED は、既に開始されたトランザクションがあればセーブポイントを作成して入れ子のトランザクションとして実行し、
+	// なければ新たにトランザクションを開始します。エラー時は、セーブポイントまでロールバックします（RDBのみ）。
+	PROPAGATION_NESTED = Propagation("NESTED")
+)
+
+var (
+	// ErrTransactionRequired は、伝播属性がMANDATORYで、トランザクションが開始されていない場合のエラーです。
+	ErrTransactionRequired = errors.New("伝播属性がMANDATORYですが、トランザクションが開始されていません")
+	// ErrTransactionExists は、伝播属性がNEVERで、既にトランザクションが開始されている場合のエラーです。
+	ErrTransactionExists = errors.New("伝播属性がNEVERですが、既にトランザクションが開始されています")
+	// ErrNestedTransactionNotSupported は、入れ子のトランザクションに未対応のTransactionManagerで、伝播属性にNESTEDを指定した場合のエラーです。
+	ErrNestedTransactionNotSupported = errors.New("伝播属性NESTEDには対応していません")
+	// ErrTransactionManagerMismatch は、既に開始されたトランザクションが、別のTransactionManagerで開始されたため参加できない場合のエラーです。
+	ErrTransactionManagerMismatch = errors.New("既に開始されたトランザクションは、別のTransactionManagerで開始されたため参加できません")
+	// ErrTransactionRollbackOnly は、参加したトランザクション内の処理がエラーとなったため、呼び出し元の処理が成功してもロールバックした場合のエラーです。
+	ErrTransactionRollbackOnly = errors.New("参加したトランザクション内の処理がエラーとなったため、トランザクションをロールバックしました")
+)
+
+// NewOptions は、Optionを適用したOptionsを作成します。
+func NewOptions(opts ...Option) *Options {
+	options := &Options{Propagation: PROPAGATION_REQUIRED}
+	for _, optFn := range opts {
+		optFn(options)
+	}
+	return options
 }
 
 // WithDynamoDBOptions は、DynamoDBのオプションを追加するオプションを生成します。
@@ -30,3 +91,39 @@ func WithSQSOptions(options []func(*sqs.Options)) Option {
 		o.SqsOptions = append(o.SqsOptions, options...)
 	}
 }
+
+// WithNonAtomicChunked は、DynamoDBのトランザクションの上限（100項目、4MB）を超える書き込みを、
+// 上限以内に分割した複数のトランザクションで順に実行するオプションを生成します。
+// 分割したトランザクション間はアトミックではなく、途中で失敗した場合は、それ以前に実行したトランザクションの書き込みは残ります。
+// 再実行しても問題がない、一括取込等の処理でのみ利用してください。
+func WithNonAtomicChunked() Option {
+	return func(o *Options) {
+		o.NonAtomicChunked = true
+	}
+}
+
+// WithPropagation は、トランザクションの伝播属性を指定するオプションを生成します。
+// 既に開始されたトランザクションに参加する場合、参加する側で指定したその他のオプションは無視されます。
+func WithPropagation(propagation Propagation) Option {
+	return func(o *Options) {
+		o.Propagation = propagation
+	}
+}
+
+// WithRetryOptions は、コミット時のTransactWriteItemsのリトライ処理のオプションを指定するオプションを生成します。
+// ClientRequestTokenによる冪等性の有効期間は10分のため、MaxElapsedTimeは10分未満を指定してください。
+// リトライしない場合は、retry.MaxRetryTimes(0)を指定してください。
+func WithRetryOptions(retryOptions []retry.Option) Option {
+	return func(o *Options) {
+		o.RetryOptions = retryOptions
+	}
+}
+
+// WithConditionFailedMappings は、コミット時に、ラベルを付与した操作の条件チェックが失敗した場合に、
+// 対応するメッセージIDの業務エラー（BusinessError）を返却するオプションを生成します。
+// 操作へのラベルの付与は、WithItemLabelで行います。
+func WithConditionFailedMappings(mappings ...ConditionFailedMapping) Option {
+	return func(o *Options) {
+		o.ConditionFailedMappings = append(o.ConditionFailedMappings, mappings...)
+	}
+}
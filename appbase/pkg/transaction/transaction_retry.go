@@ -0,0 +1,64 @@
+/*
+transaction パッケージは、トランザクション管理に関する機能を提供するパッケージです。
+*/
+package transaction
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/google/uuid"
+)
+
+const (
+	reasonCodeThrottlingError = "ThrottlingError"
+)
+
+// clientRequestTokenNamespace は、ClientRequestTokenを生成するためのUUIDの名前空間です。
+var clientRequestTokenNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("example.com/appbase/pkg/transaction"))
+
+// newTransactionID は、ClientRequestTokenの生成に利用するトランザクションIDを、トランザクションの開始ごとにランダムに採番します。
+// LambdaのリクエストID等から決定的に採番すると、別のTransactionManagerや、Lambdaの再実行で書き込み内容が異なるトランザクションに
+// 同じトークンを指定してしまい、IdempotentParameterMismatchExceptionとなるため、トランザクションの開始ごとに一意な値とします。
+func newTransactionID() string {
+	return uuid.NewString()
+}
+
+// newClientRequestToken は、トランザクションIDと分割実行時の分割番号から、決定的なClientRequestToken（36文字以内）を作成します。
+// 同じトランザクションのコミットのリトライであれば、同じトークンとなるため、
+// 冪等性の有効期間（10分）内のリトライで二重に書き込まれることはありません。
+func newClientRequestToken(transactionID string, chunkIndex int) string {
+	return uuid.NewSHA1(clientRequestTokenNamespace, []byte(fmt.Sprintf("%s/%d", transactionID, chunkIndex))).String()
+}
+
+// isRetryableTransactWriteError は、TransactWriteItemsのエラーが、ClientRequestTokenを指定したまま再実行可能なエラーかどうかを判定します。
+// トランザクションの競合、スロットリング、同一トークンのトランザクションの実行中、通信エラーの場合にリトライ対象とします。
+// https://docs.aws.amazon.com/ja_jp/amazondynamodb/latest/developerguide/transaction-apis.html#transaction-apis-txwriteitems
+func isRetryableTransactWriteError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var txCanceledException *types.TransactionCanceledException
+	if errors.As(err, &txCanceledException) {
+		// キャンセルの原因が、競合またはスロットリングのみの場合
+		return containsTargetCancellationReasons(txCanceledException, reasonCodeTransactionConflict, reasonCodeThrottlingError)
+	}
+	var txConflictException *types.TransactionConflictException
+	var txInProgressException *types.TransactionInProgressException
+	var throughputExceededException *types.ProvisionedThroughputExceededException
+	var requestLimitExceeded *types.RequestLimitExceeded
+	if errors.As(err, &txConflictException) || errors.As(err, &txInProgressException) ||
+		errors.As(err, &throughputExceededException) || errors.As(err, &requestLimitExceeded) {
+		return true
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode() == "ThrottlingException"
+	}
+	// 通信エラー
+	var requestSendError *smithyhttp.RequestSendError
+	return errors.As(err, &requestSendError)
+}
@@ -0,0 +1,54 @@
+package transaction
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTransactionID(t *testing.T) {
+	// トランザクションごとに異なるトークンとなるよう、毎回異なる値を採番
+	assert.NotEqual(t, newTransactionID(), newTransactionID())
+}
+
+func TestNewClientRequestToken(t *testing.T) {
+	token := newClientRequestToken("req1/1", 0)
+	assert.Len(t, token, 36)
+	assert.Equal(t, token, newClientRequestToken("req1/1", 0))
+	assert.NotEqual(t, token, newClientRequestToken("req1/1", 1))
+	assert.NotEqual(t, token, newClientRequestToken("req1/2", 0))
+}
+
+func TestIsRetryableTransactWriteError(t *testing.T) {
+	canceled := func(codes ...string) error {
+		var reasons []types.CancellationReason
+		for _, c := range codes {
+			reasons = append(reasons, types.CancellationReason{Code: aws.String(c)})
+		}
+		return errors.WithStack(&types.TransactionCanceledException{CancellationReasons: reasons})
+	}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "エラーなし", err: nil, want: false},
+		{name: "競合", err: canceled(reasonCodeNone, reasonCodeTransactionConflict), want: true},
+		{name: "スロットリング", err: canceled(reasonCodeThrottlingError), want: true},
+		{name: "条件チェック失敗", err: canceled(reasonCodeConditionalCheckFailed, reasonCodeTransactionConflict), want: false},
+		{name: "実行中", err: errors.WithStack(&types.TransactionInProgressException{}), want: true},
+		{name: "スループット超過", err: errors.WithStack(&types.ProvisionedThroughputExceededException{}), want: true},
+		{name: "トークンの不一致", err: errors.WithStack(&types.IdempotentParameterMismatchException{}), want: false},
+		{name: "通信エラー", err: errors.WithStack(&smithyhttp.RequestSendError{Err: errors.New("connection reset")}), want: true},
+		{name: "その他", err: errors.New("other"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isRetryableTransactWriteError(tt.err))
+		})
+	}
+}
@@ -5,8 +5,14 @@ package transaction
 
 import (
 	"errors"
+	"reflect"
 	"slices"
 
+	mydynamodb "example.com/appbase/pkg/dynamodb"
+	"example.com/appbase/pkg/dynamodb/input"
+	"example.com/appbase/pkg/dynamodb/tables"
+	myerrors "example.com/appbase/pkg/errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
@@ -16,6 +22,161 @@ const (
 	reasonCodeTransactionConflict    = "TransactionConflict"
 )
 
+const (
+	// トランザクションの操作の種類
+	TRANSACT_OPERATION_PUT             = "Put"
+	TRANSACT_OPERATION_UPDATE          = "Update"
+	TRANSACT_OPERATION_DELETE          = "Delete"
+	TRANSACT_OPERATION_CONDITION_CHECK = "ConditionCheck"
+)
+
+// CanceledOperation は、トランザクションのキャンセルの原因を、原因となった操作と対応付けた構造体です。
+type CanceledOperation struct {
+	// トランザクションに追加した順序（0始まり）
+	Index int
+	// 操作の種類（Put、Update、Delete、ConditionCheck）
+	Operation string
+	// テーブル名
+	TableName tables.DynamoDBTableName
+	// プライマリキー
+	Key map[string]types.AttributeValue
+	// キャンセルの原因のコード（ConditionalCheckFailed等）
+	Code string
+	// キャンセルの原因のメッセージ
+	Message string
+	// 操作の追加時に、WithItemLabelで業務ロジックが指定したラベル
+	Label string
+}
+
+// TransactionCanceledError は、TransactionCanceledExceptionに、トランザクションに追加した操作の情報を付加したエラーです。
+// トランザクションのコミットに失敗した場合に、errors.Asで取得できます。
+type TransactionCanceledError struct {
+	cause error
+	// Operations は、キャンセルの原因となった操作の一覧です。
+	Operations []CanceledOperation
+}
+
+// Error implements error.
+func (e *TransactionCanceledError) Error() string {
+	return e.cause.Error()
+}
+
+// Unwrap は、元のエラーを返却します。
+func (e *TransactionCanceledError) Unwrap() error {
+	return e.cause
+}
+
+// newTransactionCanceledError は、エラーがTransactionCanceledExceptionの場合に、
+// キャンセルの原因をトランザクションに追加した操作、ラベルと対応付けたエラーを作成します。それ以外のエラーの場合はそのまま返却します。
+// キャンセルの原因が楽観ロックのバージョンの不一致の場合は、さらにOptimisticLockErrorでラップします。
+func newTransactionCanceledError(err error, items []types.TransactWriteItem, labels []string) error {
+	var txCanceledException *types.TransactionCanceledException
+	if !errors.As(err, &txCanceledException) {
+		return err
+	}
+	var operations []CanceledOperation
+	// CancellationReasonsは、TransactItemsと同じ順序で返却される
+	for i, reason := range txCanceledException.CancellationReasons {
+		code := aws.ToString(reason.Code)
+		if code == "" || code == reasonCodeNone || i >= len(items) {
+			continue
+		}
+		operation, tableName, key := describeTransactWriteItem(items[i])
+		var label string
+		if i < len(labels) {
+			label = labels[i]
+		}
+		operations = append(operations, CanceledOperation{
+			Index:     i,
+			Operation: operation,
+			TableName: tableName,
+			Key:       key,
+			Code:      code,
+			Message:   aws.ToString(reason.Message),
+			Label:     label,
+		})
+	}
+	txCanceledError := &TransactionCanceledError{cause: err, Operations: operations}
+	for _, op := range operations {
+		if op.Code != reasonCodeConditionalCheckFailed {
+			continue
+		}
+		names, values := expressionAttributesOf(items[op.Index])
+		ver := mydynamodb.VersionAttributeFromExpression(names, values)
+		if ver.IsConflict(txCanceledException.CancellationReasons[op.Index].Item) {
+			return mydynamodb.NewOptimisticLockError(txCanceledError, op.TableName, op.Key, ver.Expected)
+		}
+	}
+	return txCanceledError
+}
+
+// expressionAttributesOf は、TransactWriteItemの式の属性名、属性値を返却します。
+func expressionAttributesOf(item types.TransactWriteItem) (map[string]string, map[string]types.AttributeValue) {
+	switch {
+	case item.Put != nil:
+		return item.Put.ExpressionAttributeNames, item.Put.ExpressionAttributeValues
+	case item.Update != nil:
+		return item.Update.ExpressionAttributeNames, item.Update.ExpressionAttributeValues
+	case item.Delete != nil:
+		return item.Delete.ExpressionAttributeNames, item.Delete.ExpressionAttributeValues
+	case item.ConditionCheck != nil:
+		return item.ConditionCheck.ExpressionAttributeNames, item.ConditionCheck.ExpressionAttributeValues
+	default:
+		return nil, nil
+	}
+}
+
+// describeTransactWriteItem は、TransactWriteItemの操作の種類、テーブル名、プライマリキーを返却します。
+func describeTransactWriteItem(item types.TransactWriteItem) (string, tables.DynamoDBTableName, map[string]types.AttributeValue) {
+	switch {
+	case item.Put != nil:
+		tableName := tables.DynamoDBTableName(aws.ToString(item.Put.TableName))
+		// Putの場合は、項目からプライマリキーを取り出す
+		key := make(map[string]types.AttributeValue)
+		if pk := tables.GetPrimaryKey(tableName); pk != nil {
+			key[pk.PartitionKey] = item.Put.Item[pk.PartitionKey]
+			if pk.SortKey != nil {
+				key[*pk.SortKey] = item.Put.Item[*pk.SortKey]
+			}
+		}
+		return TRANSACT_OPERATION_PUT, tableName, key
+	case item.Update != nil:
+		return TRANSACT_OPERATION_UPDATE, tables.DynamoDBTableName(aws.ToString(item.Update.TableName)), item.Update.Key
+	case item.Delete != nil:
+		return TRANSACT_OPERATION_DELETE, tables.DynamoDBTableName(aws.ToString(item.Delete.TableName)), item.Delete.Key
+	case item.ConditionCheck != nil:
+		return TRANSACT_OPERATION_CONDITION_CHECK, tables.DynamoDBTableName(aws.ToString(item.ConditionCheck.TableName)), item.ConditionCheck.Key
+	default:
+		return "", "", nil
+	}
+}
+
+// GetCanceledOperations は、トランザクションのキャンセル時のエラーから、キャンセルの原因となった操作の一覧を取得します。
+// トランザクションのキャンセル以外のエラーの場合は、nilを返却します。
+func GetCanceledOperations(err error) []CanceledOperation {
+	var txCanceledError *TransactionCanceledError
+	if errors.As(err, &txCanceledError) {
+		return txCanceledError.Operations
+	}
+	return nil
+}
+
+// IsConditionalCheckFailedOn は、トランザクションのキャンセルの原因が、
+// 指定したテーブル、プライマリキーに対する操作（Put、Update、Delete、ConditionCheck）の条件チェックの失敗かどうかを判定します。
+// 業務ロジックで、どの条件を満たさなかったかに応じた業務エラーを返却する場合に利用します。
+func IsConditionalCheckFailedOn(err error, tableName tables.DynamoDBTableName, primaryKey input.PrimaryKey) bool {
+	key, keyErr := mydynamodb.CreatePkAttributeValue(primaryKey)
+	if keyErr != nil {
+		return false
+	}
+	for _, v := range GetCanceledOperations(err) {
+		if v.Code == reasonCodeConditionalCheckFailed && v.TableName == tableName && reflect.DeepEqual(v.Key, key) {
+			return true
+		}
+	}
+	return false
+}
+
 // IsTransactionConditionalCheckFailed は、エラーの原因がトランザクション実行中にConditionCheckに失敗
 // （TransactionCanceledExceptionが発生しConditionalCheckFailedのみが含まれている）かどうかを判定します。
 // トランザクションのキャンセルの原因については以下のドキュメントを参照してください。
@@ -73,3 +234,36 @@ func containsTargetCancellationReasons(txCanceledException *types.TransactionCan
 	}
 	return contains
 }
+
+// ConditionFailedMapping は、トランザクションの条件チェックの失敗を、業務エラーに変換するための定義です。
+type ConditionFailedMapping struct {
+	// Label は、条件チェックに失敗した操作のラベルです。
+	Label string
+	// ErrorCode は、業務エラーのメッセージIDです。
+	ErrorCode string
+	// Args は、業務エラーのメッセージの置換文字列です。
+	Args []any
+}
+
+// OnConditionFailed は、指定したラベルの操作の条件チェックが失敗した場合に、
+// 指定したメッセージIDの業務エラーに変換するConditionFailedMappingを作成します。
+func OnConditionFailed(label string, errorCode string, args ...any) ConditionFailedMapping {
+	return ConditionFailedMapping{Label: label, ErrorCode: errorCode, Args: args}
+}
+
+// ToBusinessError は、トランザクションのキャンセルの原因が、ConditionFailedMappingのラベルの操作の条件チェックの失敗の場合に、
+// 対応する業務エラー（BusinessError）に変換します。複数の操作が失敗した場合は、トランザクションに追加した順で最初の操作の定義を利用します。
+// 対応する定義がない場合は、元のエラーをそのまま返却します。
+func ToBusinessError(err error, mappings ...ConditionFailedMapping) error {
+	for _, op := range GetCanceledOperations(err) {
+		if op.Code != reasonCodeConditionalCheckFailed || op.Label == "" {
+			continue
+		}
+		for _, m := range mappings {
+			if m.Label == op.Label {
+				return myerrors.NewBusinessErrorWithCause(err, m.ErrorCode, m.Args...)
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,130 @@
+package transaction
+
+import (
+	"testing"
+
+	mydynamodb "example.com/appbase/pkg/dynamodb"
+	"example.com/appbase/pkg/dynamodb/input"
+	"example.com/appbase/pkg/dynamodb/tables"
+	myerrors "example.com/appbase/pkg/errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTransactionCanceledError(t *testing.T) {
+	tables.SetPrimaryKey("test_todo", &tables.PKKeyPair{PartitionKey: "todo_id"})
+	items := []types.TransactWriteItem{
+		{Put: &types.Put{
+			TableName: aws.String("test_todo"),
+			Item: map[string]types.AttributeValue{
+				"todo_id": &types.AttributeValueMemberS{Value: "todo1"},
+				"title":   &types.AttributeValueMemberS{Value: "title1"},
+			},
+		}},
+		{ConditionCheck: &types.ConditionCheck{
+			TableName: aws.String("test_user"),
+			Key:       map[string]types.AttributeValue{"user_id": &types.AttributeValueMemberS{Value: "user1"}},
+		}},
+	}
+	cause := errors.WithStack(&types.TransactionCanceledException{
+		CancellationReasons: []types.CancellationReason{
+			{Code: aws.String(reasonCodeNone)},
+			{Code: aws.String(reasonCodeConditionalCheckFailed), Message: aws.String("The conditional request failed")},
+		},
+	})
+
+	err := newTransactionCanceledError(cause, items, []string{"todo", "user"})
+
+	operations := GetCanceledOperations(err)
+	assert.Len(t, operations, 1)
+	assert.Equal(t, 1, operations[0].Index)
+	assert.Equal(t, TRANSACT_OPERATION_CONDITION_CHECK, operations[0].Operation)
+	assert.Equal(t, tables.DynamoDBTableName("test_user"), operations[0].TableName)
+	assert.Equal(t, "user", operations[0].Label)
+	assert.True(t, IsTransactionConditionalCheckFailed(err))
+	assert.True(t, IsConditionalCheckFailedOn(err, "test_user", input.PrimaryKey{
+		PartitionKey: input.Attribute{Name: "user_id", Value: "user1"},
+	}))
+	assert.False(t, IsConditionalCheckFailedOn(err, "test_user", input.PrimaryKey{
+		PartitionKey: input.Attribute{Name: "user_id", Value: "user2"},
+	}))
+	assert.False(t, IsConditionalCheckFailedOn(err, "test_todo", input.PrimaryKey{
+		PartitionKey: input.Attribute{Name: "todo_id", Value: "todo1"},
+	}))
+}
+
+func TestNewTransactionCanceledError_OtherError(t *testing.T) {
+	cause := errors.New("other")
+	err := newTransactionCanceledError(cause, nil, nil)
+	assert.Equal(t, cause, err)
+	assert.Nil(t, GetCanceledOperations(err))
+}
+
+func TestNewTransactionCanceledError_OptimisticLock(t *testing.T) {
+	type versionedTodo struct {
+		TodoID  string `dynamodbav:"todo_id"`
+		Version int    `dynamodbav:"version" dynamodb:"version"`
+	}
+	todo := &versionedTodo{TodoID: "todo1", Version: 3}
+	ver, err := mydynamodb.GetVersionAttribute(todo)
+	assert.NoError(t, err)
+	condition, names, values := ver.AppendCondition(nil, nil, nil)
+	assert.Equal(t, "#optlock_version = :optlock_expected_version", *condition)
+	key := map[string]types.AttributeValue{"todo_id": &types.AttributeValueMemberS{Value: "todo1"}}
+	items := []types.TransactWriteItem{
+		{Delete: &types.Delete{
+			TableName:                 aws.String("test_todo"),
+			Key:                       key,
+			ConditionExpression:       condition,
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+		}},
+	}
+	newCause := func(version string) error {
+		return errors.WithStack(&types.TransactionCanceledException{
+			CancellationReasons: []types.CancellationReason{
+				{Code: aws.String(reasonCodeConditionalCheckFailed), Item: map[string]types.AttributeValue{
+					"todo_id": &types.AttributeValueMemberS{Value: "todo1"},
+					"version": &types.AttributeValueMemberN{Value: version},
+				}},
+			},
+		})
+	}
+
+	// バージョンが一致しない場合は、楽観ロックエラー
+	err = newTransactionCanceledError(newCause("4"), items, nil)
+	var optimisticLockError *mydynamodb.OptimisticLockError
+	assert.ErrorAs(t, err, &optimisticLockError)
+	assert.Equal(t, tables.DynamoDBTableName("test_todo"), optimisticLockError.TableName)
+	assert.Equal(t, int64(3), optimisticLockError.ExpectedVersion)
+	assert.Len(t, GetCanceledOperations(err), 1)
+	// バージョンが一致する場合は、楽観ロックエラーではない
+	err = newTransactionCanceledError(newCause("3"), items, nil)
+	assert.False(t, errors.As(err, &optimisticLockError))
+	assert.Len(t, GetCanceledOperations(err), 1)
+	// コミット後のバージョンの更新
+	ver.Increment()
+	assert.Equal(t, 4, todo.Version)
+}
+
+func TestToBusinessError(t *testing.T) {
+	cause := errors.WithStack(&types.TransactionCanceledException{
+		CancellationReasons: []types.CancellationReason{
+			{Code: aws.String(reasonCodeNone)},
+			{Code: aws.String(reasonCodeConditionalCheckFailed)},
+		},
+	})
+	items := []types.TransactWriteItem{
+		{Update: &types.Update{TableName: aws.String("test_todo")}},
+		{ConditionCheck: &types.ConditionCheck{TableName: aws.String("test_user")}},
+	}
+	err := newTransactionCanceledError(cause, items, []string{"todo", "user"})
+
+	var bizErr *myerrors.BusinessError
+	assert.ErrorAs(t, ToBusinessError(err, OnConditionFailed("user", "w.ex.8001", "user1")), &bizErr)
+	assert.Equal(t, "w.ex.8001", bizErr.ErrorCode())
+	// 対応する定義がない場合はそのまま返却
+	assert.Equal(t, err, ToBusinessError(err, OnConditionFailed("todo", "w.ex.8002")))
+}
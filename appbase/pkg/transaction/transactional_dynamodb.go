@@ -11,6 +11,7 @@ import (
 	myDynamoDB "example.com/appbase/pkg/dynamodb"
 	"example.com/appbase/pkg/id"
 	"example.com/appbase/pkg/logging"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/cockroachdb/errors"
@@ -30,6 +31,17 @@ type TransactionalDynamoDBAccessor interface {
 	TransactWriteItemsSDK(items []types.TransactWriteItem, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
 	// TransactWriteItemsSDKWithContext は、AWS SDKによるTransactWriteItemsをラップします。goroutine向けに、渡されたContextを利用して実行します。
 	TransactWriteItemsSDKWithContext(ctx context.Context, items []types.TransactWriteItem, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+	// TransactWriteItemsWithTokenSDK は、AWS SDKによるTransactWriteItemsを、冪等性のためのClientRequestTokenを指定して実行します。
+	// なお、TransactWriteItemsの実行は、TransactionManagerが実行するため業務ロジックで利用する必要はありません。
+	TransactWriteItemsWithTokenSDK(items []types.TransactWriteItem, clientRequestToken string, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+	// TransactWriteItemsWithTokenSDKWithContext は、AWS SDKによるTransactWriteItemsを、冪等性のためのClientRequestTokenを指定して実行します。
+	// goroutine向けに、渡されたContextを利用して実行します。
+	TransactWriteItemsWithTokenSDKWithContext(ctx context.Context, items []types.TransactWriteItem, clientRequestToken string, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+	// TransactGetItemsSDK は、AWS SDKによるTransactGetItemsをラップします。
+	// 書き込みトランザクションと異なり、TransactionManagerのトランザクションとは関係なく、即時に実行されます。
+	TransactGetItemsSDK(items []types.TransactGetItem, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactGetItemsOutput, error)
+	// TransactGetItemsSDKWithContext は、AWS SDKによるTransactGetItemsをラップします。goroutine向けに、渡されたContextを利用して実行します。
+	TransactGetItemsSDKWithContext(ctx context.Context, items []types.TransactGetItem, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactGetItemsOutput, error)
 }
 
 // NewTransactionalDynamoDBAccessor は、TransactionalDynamoDBAccessorを作成します。
@@ -97,6 +109,26 @@ func (da *defaultTransactionalDynamoDBAccessor) QueryPagesSdkWithContext(ctx con
 	return da.dynamodbAccessor.QueryPagesSdkWithContext(ctx, input, fn, optFns...)
 }
 
+// ScanSdk implements TransactionalDynamoDBAccessor.
+func (da *defaultTransactionalDynamoDBAccessor) ScanSdk(input *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	return da.dynamodbAccessor.ScanSdk(input, optFns...)
+}
+
+// ScanSdkWithContext implements TransactionalDynamoDBAccessor.
+func (da *defaultTransactionalDynamoDBAccessor) ScanSdkWithContext(ctx context.Context, input *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	return da.dynamodbAccessor.ScanSdkWithContext(ctx, input, optFns...)
+}
+
+// ScanPagesSdk implements TransactionalDynamoDBAccessor.
+func (da *defaultTransactionalDynamoDBAccessor) ScanPagesSdk(input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput) bool, optFns ...func(*dynamodb.Options)) error {
+	return da.dynamodbAccessor.ScanPagesSdk(input, fn, optFns...)
+}
+
+// ScanPagesSdkWithContext implements TransactionalDynamoDBAccessor.
+func (da *defaultTransactionalDynamoDBAccessor) ScanPagesSdkWithContext(ctx context.Context, input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput) bool, optFns ...func(*dynamodb.Options)) error {
+	return da.dynamodbAccessor.ScanPagesSdkWithContext(ctx, input, fn, optFns...)
+}
+
 // UpdateItemSdk implements TransactionalDynamoDBAccessor.
 func (da *defaultTransactionalDynamoDBAccessor) UpdateItemSdk(input *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
 	return da.dynamodbAccessor.UpdateItemSdk(input, optFns...)
@@ -157,8 +189,20 @@ func (da *defaultTransactionalDynamoDBAccessor) AppendTransactWriteItemWithConte
 	if !ok {
 		return errors.New("トランザクションが開始されていません")
 	}
-	transaction.AppendTransactWriteItem(item)
-	return nil
+	// 業務ロジックでラベルが指定されている場合は、ラベルを付与して追加
+	label, _ := ctx.Value(ITEM_LABEL_CTX_KEY).(string)
+	return transaction.AppendTransactWriteItemWithLabel(item, label)
+}
+
+// WithItemLabel は、トランザクションに追加する操作に、ラベルを付与するContextを作成します。
+// 作成したContextをTransactionalDynamoDBTemplateのInContextの関数に渡すと、追加した操作にラベルが付与されます。
+// コミット時にトランザクションがキャンセルされた場合、TransactionCanceledErrorの各操作のラベルとして取得でき、
+// WithConditionFailedMappings、ToBusinessErrorで、ラベルに対応する業務エラーに変換できます。
+func WithItemLabel(ctx context.Context, label string) context.Context {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	return context.WithValue(ctx, ITEM_LABEL_CTX_KEY, label)
 }
 
 // TransactWriteItemsSDK implements TransactionalDynamoDBAccessor.
@@ -168,11 +212,24 @@ func (da *defaultTransactionalDynamoDBAccessor) TransactWriteItemsSDK(items []ty
 
 // TransactWriteItemsSDKWithContext implements TransactionalDynamoDBAccessor.
 func (da *defaultTransactionalDynamoDBAccessor) TransactWriteItemsSDKWithContext(ctx context.Context, items []types.TransactWriteItem, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
-	da.logger.Debug("TransactWriteItemsSDK: %d件", len(items))
+	return da.TransactWriteItemsWithTokenSDKWithContext(ctx, items, "", optFns...)
+}
+
+// TransactWriteItemsWithTokenSDK implements TransactionalDynamoDBAccessor.
+func (da *defaultTransactionalDynamoDBAccessor) TransactWriteItemsWithTokenSDK(items []types.TransactWriteItem, clientRequestToken string, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	return da.TransactWriteItemsWithTokenSDKWithContext(apcontext.Context, items, clientRequestToken, optFns...)
+}
+
+// TransactWriteItemsWithTokenSDKWithContext implements TransactionalDynamoDBAccessor.
+func (da *defaultTransactionalDynamoDBAccessor) TransactWriteItemsWithTokenSDKWithContext(ctx context.Context, items []types.TransactWriteItem, clientRequestToken string, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	da.logger.Debug("TransactWriteItemsSDK: %d件, ClientRequestToken[%s]", len(items), clientRequestToken)
 	if ctx == nil {
 		ctx = apcontext.Context
 	}
 	input := &dynamodb.TransactWriteItemsInput{TransactItems: items}
+	if clientRequestToken != "" {
+		input.ClientRequestToken = aws.String(clientRequestToken)
+	}
 	// ReturnConsumedCapacityを設定
 	if myDynamoDB.ReturnConsumedCapacity(da.config) {
 		input.ReturnConsumedCapacity = types.ReturnConsumedCapacityTotal
@@ -191,3 +248,34 @@ func (da *defaultTransactionalDynamoDBAccessor) TransactWriteItemsSDKWithContext
 	}
 	return output, nil
 }
+
+// TransactGetItemsSDK implements TransactionalDynamoDBAccessor.
+func (da *defaultTransactionalDynamoDBAccessor) TransactGetItemsSDK(items []types.TransactGetItem, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactGetItemsOutput, error) {
+	return da.TransactGetItemsSDKWithContext(apcontext.Context, items, optFns...)
+}
+
+// TransactGetItemsSDKWithContext implements TransactionalDynamoDBAccessor.
+func (da *defaultTransactionalDynamoDBAccessor) TransactGetItemsSDKWithContext(ctx context.Context, items []types.TransactGetItem, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactGetItemsOutput, error) {
+	da.logger.Debug("TransactGetItemsSDK: %d件", len(items))
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	input := &dynamodb.TransactGetItemsInput{TransactItems: items}
+	// ReturnConsumedCapacityを設定
+	if myDynamoDB.ReturnConsumedCapacity(da.config) {
+		input.ReturnConsumedCapacity = types.ReturnConsumedCapacityTotal
+	}
+	output, err := da.GetDynamoDBClient().TransactGetItems(ctx, input, optFns...)
+	if err != nil {
+		// トランザクション失敗の理由をログ出力
+		logTransactionCanceledReasons(da.logger, err)
+		return nil, errors.WithStack(err)
+	}
+	if len(output.ConsumedCapacity) > 0 {
+		da.logger.Debug("消費キャパシティユニット: %d件", len(output.ConsumedCapacity))
+		for i, v := range output.ConsumedCapacity {
+			da.logger.Debug("TransactGetItems(%d番目)[%s]消費キャパシティユニット:%f", i+1, *v.TableName, *v.CapacityUnits)
+		}
+	}
+	return output, nil
+}
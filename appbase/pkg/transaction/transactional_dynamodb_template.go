@@ -13,26 +13,59 @@ import (
 	"example.com/appbase/pkg/logging"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/cockroachdb/errors"
 )
 
+const (
+	// TransactGetItemsで1回に取得可能な項目数の上限
+	// https://docs.aws.amazon.com/ja_jp/amazondynamodb/latest/developerguide/ServiceQuotas.html#limits-dynamodb-transactions
+	TRANSACT_GET_ITEMS_MAX_COUNT = 100
+)
+
 // TransactionalDynamoDBTemplate は、トランザクション管理対応のDynamoDBアクセスを定型化した高次のインタフェースです。
 type TransactionalDynamoDBTemplate interface {
 	mydynamodb.DynamoDBTemplate
 	// CreateOneWithTransaction は、トランザクションでDynamoDBに項目を登録します。
+	// エンティティに楽観ロックのバージョン属性（dynamodb:"version"タグ）がある場合は、バージョンを1加算して登録し、コミット後にエンティティのバージョンを更新します。
 	CreateOneWithTransaction(tableName tables.DynamoDBTableName, inputEntity any) error
 	// CreateOneWithTransactionInContext は、goroutine向けに渡されたContextを利用して、トランザクションでDynamoDBに項目を登録します。
 	CreateOneWithTransactionInContext(ctx context.Context, tableName tables.DynamoDBTableName, inputEntity any) error
+	// PutOneWithTransaction は、トランザクションでDynamoDBに項目を登録します。
+	// CreateOneWithTransactionと異なり、パーティションキーの重複判定を行わず、既存の項目がある場合は置き換えます（upsert）。
+	// エンティティに楽観ロックのバージョン属性がある場合は、既存の項目のバージョンが一致する場合のみ置き換えます。
+	// 楽観ロックのバージョンが一致しない場合は、トランザクションのコミット時にOptimisticLockErrorを返却します。エンティティのバージョンは、コミット後に更新します。
+	PutOneWithTransaction(tableName tables.DynamoDBTableName, inputEntity any) error
+	// PutOneWithTransactionInContext は、goroutine向けに渡されたContextを利用して、トランザクションでDynamoDBに項目を登録します。
+	// CreateOneWithTransactionInContextと異なり、パーティションキーの重複判定を行わず、既存の項目がある場合は置き換えます（upsert）。
+	PutOneWithTransactionInContext(ctx context.Context, tableName tables.DynamoDBTableName, inputEntity any) error
 	// UpdateOneWithTransaction は、トランザクションでDynamoDBに項目を更新します。
+	// input.VersionedEntityを指定した場合は、楽観ロックを行います。
+	// 楽観ロックのバージョンが一致しない場合は、トランザクションのコミット時にOptimisticLockErrorを返却します。エンティティのバージョンは、コミット後に更新します。
 	UpdateOneWithTransaction(tableName tables.DynamoDBTableName, input input.UpdateInput) error
 	// UpdateOneWithTransactionInContext は、goroutine向けに渡されたContextを利用して、トランザクションでDynamoDBに項目を更新します。
 	UpdateOneWithTransactionInContext(ctx context.Context, tableName tables.DynamoDBTableName, input input.UpdateInput) error
 	// DeleteOneWithTransaction は、トランザクションでDynamoDBに項目を削除します。
+	// input.VersionedEntityを指定した場合は、楽観ロックを行います。
+	// 楽観ロックのバージョンが一致しない場合は、トランザクションのコミット時にOptimisticLockErrorを返却します。
 	DeleteOneWithTransaction(tableName tables.DynamoDBTableName, input input.DeleteInput) error
 	// DeleteOneWithTransactionInContext は、goroutine向けに渡されたContextを利用して、トランザクションでDynamoDBに項目を削除します。
 	DeleteOneWithTransactionInContext(ctx context.Context, tableName tables.DynamoDBTableName, input input.DeleteInput) error
+	// ConditionCheckWithTransaction は、トランザクションで、DynamoDBの項目が条件を満たすかをチェックします。
+	// 条件を満たさない場合、トランザクション全体がキャンセルされます。
+	// キャンセル時は、GetCanceledOperations、IsConditionalCheckFailedOnで、どの操作が原因かを判定できます。
+	ConditionCheckWithTransaction(tableName tables.DynamoDBTableName, input input.ConditionCheckInput) error
+	// ConditionCheckWithTransactionInContext は、goroutine向けに渡されたContextを利用して、トランザクションで、DynamoDBの項目が条件を満たすかをチェックします。
+	ConditionCheckWithTransactionInContext(ctx context.Context, tableName tables.DynamoDBTableName, input input.ConditionCheckInput) error
+	// FindManyByKeysWithTransaction は、読み込みトランザクション（TransactGetItems）で、複数のテーブルからプライマリキーの完全一致で最大100件の項目をアトミックに取得します。
+	// 取得結果は、inputsの各要素のOutEntityに格納し、項目の有無をFoundに設定します。
+	// 書き込みトランザクションと異なり、TransactionManagerのトランザクションとは関係なく、即時に実行されます。
+	FindManyByKeysWithTransaction(inputs []*input.TransactGetInput, optFns ...func(*dynamodb.Options)) error
+	// FindManyByKeysWithTransactionInContext は、goroutine向けに渡されたContextを利用して、
+	// 読み込みトランザクション（TransactGetItems）で、複数のテーブルからプライマリキーの完全一致で最大100件の項目をアトミックに取得します。
+	FindManyByKeysWithTransactionInContext(ctx context.Context, inputs []*input.TransactGetInput, optFns ...func(*dynamodb.Options)) error
 }
 
 func NewTransactionalDynamoDBTemplate(logger logging.Logger,
@@ -91,6 +124,56 @@ func (t *defaultTransactionalDynamoDBTemplate) FindSomeByGSIKeyWithContext(ctx c
 	return t.dynamodbTemplate.FindSomeByGSIKeyWithContext(ctx, tableName, input, outEntities, optFns...)
 }
 
+// FindAllByScan implements TransactinalDynamoDBTemplate.
+func (t *defaultTransactionalDynamoDBTemplate) FindAllByScan(tableName tables.DynamoDBTableName, input input.ScanInput, outEntities any, optFns ...func(*dynamodb.Options)) error {
+	return t.dynamodbTemplate.FindAllByScan(tableName, input, outEntities, optFns...)
+}
+
+// FindAllByScanWithContext implements TransactionalDynamoDBTemplate.
+func (t *defaultTransactionalDynamoDBTemplate) FindAllByScanWithContext(ctx context.Context, tableName tables.DynamoDBTableName, input input.ScanInput, outEntities any, optFns ...func(*dynamodb.Options)) error {
+	return t.dynamodbTemplate.FindAllByScanWithContext(ctx, tableName, input, outEntities, optFns...)
+}
+
+// ScanPages implements TransactinalDynamoDBTemplate.
+func (t *defaultTransactionalDynamoDBTemplate) ScanPages(tableName tables.DynamoDBTableName, input input.ScanInput, fn mydynamodb.ScanPageFunc, optFns ...func(*dynamodb.Options)) error {
+	return t.dynamodbTemplate.ScanPages(tableName, input, fn, optFns...)
+}
+
+// ScanPagesWithContext implements TransactionalDynamoDBTemplate.
+func (t *defaultTransactionalDynamoDBTemplate) ScanPagesWithContext(ctx context.Context, tableName tables.DynamoDBTableName, input input.ScanInput, fn mydynamodb.ScanPageFunc, optFns ...func(*dynamodb.Options)) error {
+	return t.dynamodbTemplate.ScanPagesWithContext(ctx, tableName, input, fn, optFns...)
+}
+
+// FindManyByKeys implements TransactinalDynamoDBTemplate.
+func (t *defaultTransactionalDynamoDBTemplate) FindManyByKeys(tableName tables.DynamoDBTableName, input input.BatchGetInput, outEntities any, optFns ...func(*dynamodb.Options)) error {
+	return t.dynamodbTemplate.FindManyByKeys(tableName, input, outEntities, optFns...)
+}
+
+// FindManyByKeysWithContext implements TransactionalDynamoDBTemplate.
+func (t *defaultTransactionalDynamoDBTemplate) FindManyByKeysWithContext(ctx context.Context, tableName tables.DynamoDBTableName, input input.BatchGetInput, outEntities any, optFns ...func(*dynamodb.Options)) error {
+	return t.dynamodbTemplate.FindManyByKeysWithContext(ctx, tableName, input, outEntities, optFns...)
+}
+
+// CreateMany implements TransactinalDynamoDBTemplate.
+func (t *defaultTransactionalDynamoDBTemplate) CreateMany(tableName tables.DynamoDBTableName, inputEntities any, optFns ...func(*dynamodb.Options)) error {
+	return t.dynamodbTemplate.CreateMany(tableName, inputEntities, optFns...)
+}
+
+// CreateManyWithContext implements TransactionalDynamoDBTemplate.
+func (t *defaultTransactionalDynamoDBTemplate) CreateManyWithContext(ctx context.Context, tableName tables.DynamoDBTableName, inputEntities any, optFns ...func(*dynamodb.Options)) error {
+	return t.dynamodbTemplate.CreateManyWithContext(ctx, tableName, inputEntities, optFns...)
+}
+
+// DeleteMany implements TransactinalDynamoDBTemplate.
+func (t *defaultTransactionalDynamoDBTemplate) DeleteMany(tableName tables.DynamoDBTableName, primaryKeys []input.PrimaryKey, optFns ...func(*dynamodb.Options)) error {
+	return t.dynamodbTemplate.DeleteMany(tableName, primaryKeys, optFns...)
+}
+
+// DeleteManyWithContext implements TransactionalDynamoDBTemplate.
+func (t *defaultTransactionalDynamoDBTemplate) DeleteManyWithContext(ctx context.Context, tableName tables.DynamoDBTableName, primaryKeys []input.PrimaryKey, optFns ...func(*dynamodb.Options)) error {
+	return t.dynamodbTemplate.DeleteManyWithContext(ctx, tableName, primaryKeys, optFns...)
+}
+
 // UpdateOne implements TransactinalDynamoDBTemplate.
 func (t *defaultTransactionalDynamoDBTemplate) UpdateOne(tableName tables.DynamoDBTableName, input input.UpdateInput, optFns ...func(*dynamodb.Options)) error {
 	return t.dynamodbTemplate.UpdateOne(tableName, input, optFns...)
@@ -119,21 +202,26 @@ func (t *defaultTransactionalDynamoDBTemplate) CreateOneWithTransaction(tableNam
 // CreateOneWithTransactionInContext implements TransactionalDynamoDBTemplate.
 func (t *defaultTransactionalDynamoDBTemplate) CreateOneWithTransactionInContext(ctx context.Context, tableName tables.DynamoDBTableName, inputEntity any) error {
 	// TransactWriteItemの作成
-	item, err := t.newPutTransactionWriteItem(tableName, inputEntity)
+	item, ver, err := t.newPutTransactionWriteItem(tableName, inputEntity)
 	if err != nil {
 		return err
 	}
 	// TransactWriteItemの追加
-	return t.transactionalDynamoDBAccessor.AppendTransactWriteItemWithContext(ctx, item)
-
+	return t.appendTransactWriteItem(ctx, item, ver)
 }
 
 // newPutTransactionWriteItem は、PutのためのTransactWriteItemを作成します。
-func (t *defaultTransactionalDynamoDBTemplate) newPutTransactionWriteItem(tableName tables.DynamoDBTableName, inputEntity any) (*types.TransactWriteItem, error) {
+func (t *defaultTransactionalDynamoDBTemplate) newPutTransactionWriteItem(tableName tables.DynamoDBTableName, inputEntity any) (*types.TransactWriteItem, *mydynamodb.VersionAttribute, error) {
 	attributes, err := attributevalue.MarshalMap(inputEntity)
 	if err != nil {
-		return nil, errors.Wrap(err, "CreateOneWithTransactionで構造体をAttributeValueのMap変換時にエラー")
+		return nil, nil, errors.Wrap(err, "CreateOneWithTransactionで構造体をAttributeValueのMap変換時にエラー")
 	}
+	// 楽観ロックのバージョン属性の初期値
+	ver, err := mydynamodb.GetVersionAttribute(inputEntity)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "CreateOneWithTransactionでバージョン属性の取得時エラー")
+	}
+	ver.SetNextVersion(attributes)
 	// パーティションキーの重複判定条件
 	partitonkeyName := tables.GetPrimaryKey(tableName).PartitionKey
 	conditionExpression := aws.String("attribute_not_exists(#partition_key)")
@@ -149,7 +237,55 @@ func (t *defaultTransactionalDynamoDBTemplate) newPutTransactionWriteItem(tableN
 			ExpressionAttributeNames: expressionAttributeNames,
 		},
 	}
-	return &item, nil
+	return &item, ver, nil
+}
+
+// PutOneWithTransaction implements TransactionalDynamoDBTemplate.
+func (t *defaultTransactionalDynamoDBTemplate) PutOneWithTransaction(tableName tables.DynamoDBTableName, inputEntity any) error {
+	return t.PutOneWithTransactionInContext(apcontext.Context, tableName, inputEntity)
+}
+
+// PutOneWithTransactionInContext implements TransactionalDynamoDBTemplate.
+func (t *defaultTransactionalDynamoDBTemplate) PutOneWithTransactionInContext(ctx context.Context, tableName tables.DynamoDBTableName, inputEntity any) error {
+	attributes, err := attributevalue.MarshalMap(inputEntity)
+	if err != nil {
+		return errors.Wrap(err, "PutOneWithTransactionで構造体をAttributeValueのMap変換時にエラー")
+	}
+	// 楽観ロックのバージョンの条件（バージョン属性がない場合は条件なし）
+	ver, err := mydynamodb.GetVersionAttribute(inputEntity)
+	if err != nil {
+		return errors.Wrap(err, "PutOneWithTransactionでバージョン属性の取得時エラー")
+	}
+	ver.SetNextVersion(attributes)
+	condition, names, values := ver.AppendCondition(nil, nil, nil)
+	// TransactWriteItem（パーティションキーの重複判定条件なし）
+	item := types.TransactWriteItem{
+		Put: &types.Put{
+			TableName:                           aws.String(string(tableName)),
+			Item:                                attributes,
+			ConditionExpression:                 condition,
+			ExpressionAttributeNames:            names,
+			ExpressionAttributeValues:           values,
+			ReturnValuesOnConditionCheckFailure: ver.ReturnValuesOnConditionCheckFailure(),
+		},
+	}
+	// TransactWriteItemの追加
+	return t.appendTransactWriteItem(ctx, &item, ver)
+}
+
+// appendTransactWriteItem は、TransactWriteItemを追加し、楽観ロックのバージョン属性がある場合は、
+// トランザクションのコミット後にエンティティのバージョンを更新します。
+func (t *defaultTransactionalDynamoDBTemplate) appendTransactWriteItem(ctx context.Context, item *types.TransactWriteItem, ver *mydynamodb.VersionAttribute) error {
+	if err := t.transactionalDynamoDBAccessor.AppendTransactWriteItemWithContext(ctx, item); err != nil {
+		return err
+	}
+	if ver == nil {
+		return nil
+	}
+	return OnCommitWithContext(ctx, func(context.Context) error {
+		ver.Increment()
+		return nil
+	})
 }
 
 // UpdateOneWithTransaction implements TransactinalDynamoDBTemplate.
@@ -159,38 +295,45 @@ func (t *defaultTransactionalDynamoDBTemplate) UpdateOneWithTransaction(tableNam
 
 // UpdateOneWithTransactionInContext implements TransactionalDynamoDBTemplate.
 func (t *defaultTransactionalDynamoDBTemplate) UpdateOneWithTransactionInContext(ctx context.Context, tableName tables.DynamoDBTableName, input input.UpdateInput) error {
-	item, err := t.newUpdateTransactionWriteItem(tableName, input)
+	item, ver, err := t.newUpdateTransactionWriteItem(tableName, input)
 	if err != nil {
 		return err
 	}
 	// TransactWriteItemの追加
-	return t.transactionalDynamoDBAccessor.AppendTransactWriteItemWithContext(ctx, item)
+	return t.appendTransactWriteItem(ctx, item, ver)
 }
 
 // newUpdateTransactionWriteItem は、UpdateのためのTransactWriteItemを作成します。
-func (t *defaultTransactionalDynamoDBTemplate) newUpdateTransactionWriteItem(tableName tables.DynamoDBTableName, input input.UpdateInput) (*types.TransactWriteItem, error) {
+func (t *defaultTransactionalDynamoDBTemplate) newUpdateTransactionWriteItem(tableName tables.DynamoDBTableName, input input.UpdateInput) (*types.TransactWriteItem, *mydynamodb.VersionAttribute, error) {
 	// プライマリキーの条件
 	keyMap, err := mydynamodb.CreatePkAttributeValue(input.PrimaryKey)
 	if err != nil {
-		return nil, errors.Wrap(err, "UpdateOneWithTransactionで更新対象条件の生成時エラー")
+		return nil, nil, errors.Wrap(err, "UpdateOneWithTransactionで更新対象条件の生成時エラー")
 	}
 	// 更新表現
 	expr, err := mydynamodb.CreateUpdateExpression(input)
 	if err != nil {
-		return nil, errors.Wrap(err, "UpdateOneWithTransactionで更新条件の生成時エラー")
+		return nil, nil, errors.Wrap(err, "UpdateOneWithTransactionで更新条件の生成時エラー")
+	}
+	// 楽観ロックのバージョンの条件
+	ver, err := mydynamodb.GetVersionAttribute(input.VersionedEntity)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "UpdateOneWithTransactionでバージョン属性の取得時エラー")
 	}
+	condition, names, values := ver.AppendCondition(expr.Condition(), expr.Names(), expr.Values())
 	// TransactWriteItem
 	item := types.TransactWriteItem{
 		Update: &types.Update{
-			TableName:                 aws.String(string(tableName)),
-			Key:                       keyMap,
-			ExpressionAttributeNames:  expr.Names(),
-			ExpressionAttributeValues: expr.Values(),
-			UpdateExpression:          expr.Update(),
-			ConditionExpression:       expr.Condition(),
+			TableName:                           aws.String(string(tableName)),
+			Key:                                 keyMap,
+			ExpressionAttributeNames:            names,
+			ExpressionAttributeValues:           values,
+			UpdateExpression:                    expr.Update(),
+			ConditionExpression:                 condition,
+			ReturnValuesOnConditionCheckFailure: ver.ReturnValuesOnConditionCheckFailure(),
 		},
 	}
-	return &item, nil
+	return &item, ver, nil
 }
 
 // DeleteOneWithTransaction implements TransactinalDynamoDBTemplate.
@@ -220,9 +363,64 @@ func (t *defaultTransactionalDynamoDBTemplate) newDeleteTransactionWriteItem(tab
 	if err != nil {
 		return nil, errors.Wrap(err, "DelteOneWithTransactionで削除条件の生成時エラー")
 	}
+	var (
+		condition *string
+		names     map[string]string
+		values    map[string]types.AttributeValue
+	)
+	if expr != nil {
+		condition, names, values = expr.Condition(), expr.Names(), expr.Values()
+	}
+	// 楽観ロックのバージョンの条件
+	ver, err := mydynamodb.GetVersionAttribute(input.VersionedEntity)
+	if err != nil {
+		return nil, errors.Wrap(err, "DeleteOneWithTransactionでバージョン属性の取得時エラー")
+	}
+	condition, names, values = ver.AppendCondition(condition, names, values)
 	// TransactWriteItemの作成
 	item := types.TransactWriteItem{
 		Delete: &types.Delete{
+			TableName:                           aws.String(string(tableName)),
+			Key:                                 keyMap,
+			ExpressionAttributeNames:            names,
+			ExpressionAttributeValues:           values,
+			ConditionExpression:                 condition,
+			ReturnValuesOnConditionCheckFailure: ver.ReturnValuesOnConditionCheckFailure(),
+		},
+	}
+	return &item, nil
+}
+
+// ConditionCheckWithTransaction implements TransactionalDynamoDBTemplate.
+func (t *defaultTransactionalDynamoDBTemplate) ConditionCheckWithTransaction(tableName tables.DynamoDBTableName, input input.ConditionCheckInput) error {
+	return t.ConditionCheckWithTransactionInContext(apcontext.Context, tableName, input)
+}
+
+// ConditionCheckWithTransactionInContext implements TransactionalDynamoDBTemplate.
+func (t *defaultTransactionalDynamoDBTemplate) ConditionCheckWithTransactionInContext(ctx context.Context, tableName tables.DynamoDBTableName, input input.ConditionCheckInput) error {
+	item, err := t.newConditionCheckTransactionWriteItem(tableName, input)
+	if err != nil {
+		return err
+	}
+	// TransactWriteItemの追加
+	return t.transactionalDynamoDBAccessor.AppendTransactWriteItemWithContext(ctx, item)
+}
+
+// newConditionCheckTransactionWriteItem は、ConditionCheckのためのTransactWriteItemを作成します。
+func (t *defaultTransactionalDynamoDBTemplate) newConditionCheckTransactionWriteItem(tableName tables.DynamoDBTableName, input input.ConditionCheckInput) (*types.TransactWriteItem, error) {
+	// プライマリキーの条件
+	keyMap, err := mydynamodb.CreatePkAttributeValue(input.PrimaryKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "ConditionCheckWithTransactionでチェック対象条件の生成時エラー")
+	}
+	// チェック条件の表現
+	expr, err := mydynamodb.CreateConditionCheckExpression(input)
+	if err != nil {
+		return nil, errors.Wrap(err, "ConditionCheckWithTransactionでチェック条件の生成時エラー")
+	}
+	// TransactWriteItemの作成
+	item := types.TransactWriteItem{
+		ConditionCheck: &types.ConditionCheck{
 			TableName:                 aws.String(string(tableName)),
 			Key:                       keyMap,
 			ExpressionAttributeNames:  expr.Names(),
@@ -232,3 +430,67 @@ func (t *defaultTransactionalDynamoDBTemplate) newDeleteTransactionWriteItem(tab
 	}
 	return &item, nil
 }
+
+// FindManyByKeysWithTransaction implements TransactionalDynamoDBTemplate.
+func (t *defaultTransactionalDynamoDBTemplate) FindManyByKeysWithTransaction(inputs []*input.TransactGetInput, optFns ...func(*dynamodb.Options)) error {
+	return t.FindManyByKeysWithTransactionInContext(apcontext.Context, inputs, optFns...)
+}
+
+// FindManyByKeysWithTransactionInContext implements TransactionalDynamoDBTemplate.
+func (t *defaultTransactionalDynamoDBTemplate) FindManyByKeysWithTransactionInContext(ctx context.Context, inputs []*input.TransactGetInput, optFns ...func(*dynamodb.Options)) error {
+	if len(inputs) == 0 {
+		return nil
+	}
+	if len(inputs) > TRANSACT_GET_ITEMS_MAX_COUNT {
+		return errors.Errorf("FindManyByKeysWithTransactionで取得件数が上限(%d件)を超えています: %d件", TRANSACT_GET_ITEMS_MAX_COUNT, len(inputs))
+	}
+	// TransactGetItemの作成
+	items := make([]types.TransactGetItem, 0, len(inputs))
+	for _, v := range inputs {
+		item, err := t.newTransactGetItem(v)
+		if err != nil {
+			return err
+		}
+		items = append(items, *item)
+	}
+	// TransactGetItemsの実行
+	output, err := t.transactionalDynamoDBAccessor.TransactGetItemsSDKWithContext(ctx, items, optFns...)
+	if err != nil {
+		return errors.Wrap(err, "FindManyByKeysWithTransactionで検索実行時エラー")
+	}
+	// 取得結果は、リクエストの順序と同じ順序で返却される
+	for i, v := range output.Responses {
+		inputs[i].Found = len(v.Item) > 0
+		if !inputs[i].Found {
+			continue
+		}
+		if err := attributevalue.UnmarshalMap(v.Item, inputs[i].OutEntity); err != nil {
+			return errors.Wrapf(err, "FindManyByKeysWithTransactionで検索結果(%d番目)を構造体にアンマーシャル時エラー", i+1)
+		}
+	}
+	return nil
+}
+
+// newTransactGetItem は、GetのためのTransactGetItemを作成します。
+func (t *defaultTransactionalDynamoDBTemplate) newTransactGetItem(input *input.TransactGetInput) (*types.TransactGetItem, error) {
+	// プライマリキーの条件
+	keyMap, err := mydynamodb.CreatePkAttributeValue(input.PrimaryKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "FindManyByKeysWithTransactionで検索条件生成時エラー")
+	}
+	get := &types.Get{
+		TableName: aws.String(string(input.TableName)),
+		Key:       keyMap,
+	}
+	// 取得項目
+	proj := mydynamodb.CreateProjection(input.SelectAttributes)
+	if proj != nil {
+		expr, err := expression.NewBuilder().WithProjection(*proj).Build()
+		if err != nil {
+			return nil, errors.Wrap(err, "FindManyByKeysWithTransactionで取得項目の生成時エラー")
+		}
+		get.ProjectionExpression = expr.Projection()
+		get.ExpressionAttributeNames = expr.Names()
+	}
+	return &types.TransactGetItem{Get: get}, nil
+}
@@ -0,0 +1,101 @@
+package transaction
+
+import (
+	"context"
+	"encoding/json"
+
+	"example.com/appbase/pkg/apcontext"
+	"example.com/appbase/pkg/logging"
+	"example.com/appbase/pkg/transaction/model"
+	"github.com/cockroachdb/errors"
+)
+
+// OutboxTemplate は、SNSのトピックへの発行、EventBridgeへのイベント送信を、
+// トランザクショナルアウトボックスにより業務データと同一のDynamoDBトランザクションで登録するための高次のインタフェースです。
+// 登録したアイテムは、DynamoDB Streamsをトリガとするリレー（OutboxRelayLambdaHandler）により送信されます。
+// なお、SQSのキューへの送信は、NewTransactionManagerWithOutboxで作成したTransactionManagerとSQSTemplateを利用してください。
+type OutboxTemplate interface {
+	// PublishToTopic は、SNSのトピックへ発行するメッセージを、アウトボックスに登録します。
+	PublishToTopic(topicArn string, msg any) error
+	// PublishToTopicWithContext は、goroutine向けに渡されたContextを利用して、SNSのトピックへ発行するメッセージを、アウトボックスに登録します。
+	PublishToTopicWithContext(ctx context.Context, topicArn string, msg any) error
+	// PublishToFIFOTopic は、SNSのFIFOトピックへ発行するメッセージを、アウトボックスに登録します。
+	PublishToFIFOTopic(topicArn string, msg any, msgGroupId string) error
+	// PublishToFIFOTopicWithContext は、goroutine向けに渡されたContextを利用して、SNSのFIFOトピックへ発行するメッセージを、アウトボックスに登録します。
+	PublishToFIFOTopicWithContext(ctx context.Context, topicArn string, msg any, msgGroupId string) error
+	// PutEvent は、EventBridgeのイベントバスへ送信するイベントを、アウトボックスに登録します。
+	PutEvent(eventBusName string, source string, detailType string, detail any) error
+	// PutEventWithContext は、goroutine向けに渡されたContextを利用して、EventBridgeのイベントバスへ送信するイベントを、アウトボックスに登録します。
+	PutEventWithContext(ctx context.Context, eventBusName string, source string, detailType string, detail any) error
+}
+
+// NewOutboxTemplate は、OutboxTemplateを作成します。
+func NewOutboxTemplate(logger logging.Logger, outboxRegisterer OutboxRegisterer) OutboxTemplate {
+	return &defaultOutboxTemplate{
+		logger:           logger,
+		outboxRegisterer: outboxRegisterer,
+	}
+}
+
+// defaultOutboxTemplate は、OutboxTemplateの実装です。
+type defaultOutboxTemplate struct {
+	logger           logging.Logger
+	outboxRegisterer OutboxRegisterer
+}
+
+// PublishToTopic implements OutboxTemplate.
+func (t *defaultOutboxTemplate) PublishToTopic(topicArn string, msg any) error {
+	return t.PublishToTopicWithContext(apcontext.Context, topicArn, msg)
+}
+
+// PublishToTopicWithContext implements OutboxTemplate.
+func (t *defaultOutboxTemplate) PublishToTopicWithContext(ctx context.Context, topicArn string, msg any) error {
+	return t.PublishToFIFOTopicWithContext(ctx, topicArn, msg, "")
+}
+
+// PublishToFIFOTopic implements OutboxTemplate.
+func (t *defaultOutboxTemplate) PublishToFIFOTopic(topicArn string, msg any, msgGroupId string) error {
+	return t.PublishToFIFOTopicWithContext(apcontext.Context, topicArn, msg, msgGroupId)
+}
+
+// PublishToFIFOTopicWithContext implements OutboxTemplate.
+func (t *defaultOutboxTemplate) PublishToFIFOTopicWithContext(ctx context.Context, topicArn string, msg any, msgGroupId string) error {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	// 構造体をjson文字列としてメッセージ送信
+	byteMessage, err := json.Marshal(msg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return t.outboxRegisterer.RegisterOutboxWithContext(ctx, &model.OutboxItem{
+		DestinationType: model.OUTBOX_DESTINATION_TYPE_SNS,
+		Destination:     topicArn,
+		Body:            string(byteMessage),
+		MessageGroupId:  msgGroupId,
+	})
+}
+
+// PutEvent implements OutboxTemplate.
+func (t *defaultOutboxTemplate) PutEvent(eventBusName string, source string, detailType string, detail any) error {
+	return t.PutEventWithContext(apcontext.Context, eventBusName, source, detailType, detail)
+}
+
+// PutEventWithContext implements OutboxTemplate.
+func (t *defaultOutboxTemplate) PutEventWithContext(ctx context.Context, eventBusName string, source string, detailType string, detail any) error {
+	if ctx == nil {
+		ctx = apcontext.Context
+	}
+	// 構造体をjson文字列としてイベントのDetailに設定
+	byteDetail, err := json.Marshal(detail)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return t.outboxRegisterer.RegisterOutboxWithContext(ctx, &model.OutboxItem{
+		DestinationType: model.OUTBOX_DESTINATION_TYPE_EVENTBRIDGE,
+		Destination:     eventBusName,
+		Body:            string(byteDetail),
+		Source:          source,
+		DetailType:      detailType,
+	})
+}
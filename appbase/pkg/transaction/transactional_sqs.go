@@ -87,6 +87,56 @@ func (sa *defaultTransactionalSQSAccessor) SendMessageSdkWithContext(ctx context
 	return sa.sqsAccessor.SendMessageSdkWithContext(ctx, queueName, input, optFns...)
 }
 
+// ChangeMessageVisibilitySdk implements TransactionalSQSAccessor.
+func (sa *defaultTransactionalSQSAccessor) ChangeMessageVisibilitySdk(queueName string, input *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	return sa.sqsAccessor.ChangeMessageVisibilitySdk(queueName, input, optFns...)
+}
+
+// ChangeMessageVisibilitySdkWithContext implements TransactionalSQSAccessor.
+func (sa *defaultTransactionalSQSAccessor) ChangeMessageVisibilitySdkWithContext(ctx context.Context, queueName string, input *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	return sa.sqsAccessor.ChangeMessageVisibilitySdkWithContext(ctx, queueName, input, optFns...)
+}
+
+// ChangeMessageVisibilityBatchSdk implements TransactionalSQSAccessor.
+func (sa *defaultTransactionalSQSAccessor) ChangeMessageVisibilityBatchSdk(queueName string, input *sqs.ChangeMessageVisibilityBatchInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+	return sa.sqsAccessor.ChangeMessageVisibilityBatchSdk(queueName, input, optFns...)
+}
+
+// ChangeMessageVisibilityBatchSdkWithContext implements TransactionalSQSAccessor.
+func (sa *defaultTransactionalSQSAccessor) ChangeMessageVisibilityBatchSdkWithContext(ctx context.Context, queueName string, input *sqs.ChangeMessageVisibilityBatchInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+	return sa.sqsAccessor.ChangeMessageVisibilityBatchSdkWithContext(ctx, queueName, input, optFns...)
+}
+
+// ReceiveMessageSdk implements TransactionalSQSAccessor.
+func (sa *defaultTransactionalSQSAccessor) ReceiveMessageSdk(queueName string, input *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	return sa.sqsAccessor.ReceiveMessageSdk(queueName, input, optFns...)
+}
+
+// ReceiveMessageSdkWithContext implements TransactionalSQSAccessor.
+func (sa *defaultTransactionalSQSAccessor) ReceiveMessageSdkWithContext(ctx context.Context, queueName string, input *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	return sa.sqsAccessor.ReceiveMessageSdkWithContext(ctx, queueName, input, optFns...)
+}
+
+// DeleteMessageSdk implements TransactionalSQSAccessor.
+func (sa *defaultTransactionalSQSAccessor) DeleteMessageSdk(queueName string, input *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	return sa.sqsAccessor.DeleteMessageSdk(queueName, input, optFns...)
+}
+
+// DeleteMessageSdkWithContext implements TransactionalSQSAccessor.
+func (sa *defaultTransactionalSQSAccessor) DeleteMessageSdkWithContext(ctx context.Context, queueName string, input *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	return sa.sqsAccessor.DeleteMessageSdkWithContext(ctx, queueName, input, optFns...)
+}
+
+// PurgeQueueSdk implements TransactionalSQSAccessor.
+func (sa *defaultTransactionalSQSAccessor) PurgeQueueSdk(queueName string, optFns ...func(*sqs.Options)) (*sqs.PurgeQueueOutput, error) {
+	return sa.sqsAccessor.PurgeQueueSdk(queueName, optFns...)
+}
+
+// PurgeQueueSdkWithContext implements TransactionalSQSAccessor.
+func (sa *defaultTransactionalSQSAccessor) PurgeQueueSdkWithContext(ctx context.Context, queueName string, optFns ...func(*sqs.Options)) (*sqs.PurgeQueueOutput, error) {
+	return sa.sqsAccessor.PurgeQueueSdkWithContext(ctx, queueName, optFns...)
+}
+
 // AppendTransactMessage implements TransactionalSQSAccessor.
 func (sa *defaultTransactionalSQSAccessor) AppendTransactMessage(queueName string, input *sqs.SendMessageInput) error {
 	sa.logger.Debug("AppendTransactMessage")